@@ -2,30 +2,154 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"expvar"
+	"flag"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/cluster"
 	"github.com/platform-edn/vinyl/internal/discovery"
 	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/platform-edn/vinyl/internal/gossip"
 	"github.com/platform-edn/vinyl/internal/proto"
+	"github.com/platform-edn/vinyl/internal/source/docker"
+	"github.com/platform-edn/vinyl/internal/source/file"
+	"github.com/platform-edn/vinyl/internal/source/hosts"
+	"github.com/platform-edn/vinyl/internal/source/kubernetes"
+	"github.com/platform-edn/vinyl/internal/source/lease"
 	"github.com/platform-edn/vinyl/internal/store"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
 )
 
 type RecordStorer interface {
-	CreateRecord(string, string, uint32) (*vinyl.Record, error)
+	CreateRecord(string, string, uint32, ...vinyl.RecordOption) (*vinyl.Record, error)
+	UpdateRecord(string, string, uint32, ...vinyl.RecordOption) (*vinyl.Record, error)
 	RemoveRecord(string) (*vinyl.Record, error)
-	ListRecords() ([]vinyl.Record, error)
+	RemoveOwnedRecord(string, string) (*vinyl.Record, error)
+	ListRecords(vinyl.RecordFilter, vinyl.Page) ([]vinyl.Record, string, error)
 	GetRecord(string) (*vinyl.Record, error)
+	GetRecordsByAddress(string) ([]vinyl.Record, error)
+	Watch(context.Context) <-chan vinyl.RecordEvent
+}
+
+// ZoneFile is a zone file to import at startup along with the origin its relative names are under
+type ZoneFile struct {
+	Origin string
+	Path   string
+}
+
+// ZoneFlag collects repeated origin=path flags, the origin can be left out when the file sets $ORIGIN
+type ZoneFlag []ZoneFile
+
+func (zones *ZoneFlag) String() string {
+	pairs := []string{}
+	for _, zone := range *zones {
+		pairs = append(pairs, zone.Origin+"="+zone.Path)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (zones *ZoneFlag) Set(value string) error {
+	zone := ZoneFile{
+		Path: value,
+	}
+
+	if origin, path, found := strings.Cut(value, "="); found {
+		zone.Origin = origin
+		zone.Path = path
+	}
+
+	*zones = append(*zones, zone)
+
+	return nil
+}
+
+// PeerFlag collects repeated id=raft-address,api-address flags describing the raft group
+type PeerFlag []cluster.Peer
+
+func (peers *PeerFlag) String() string {
+	values := []string{}
+	for _, peer := range *peers {
+		values = append(values, peer.ID+"="+peer.RaftAddress+","+peer.APIAddress)
+	}
+
+	return strings.Join(values, " ")
+}
+
+func (peers *PeerFlag) Set(value string) error {
+	peer, err := cluster.ParsePeer(value)
+	if err != nil {
+		return err
+	}
+
+	*peers = append(*peers, peer)
+
+	return nil
+}
+
+// ListFlag collects the values of a repeated flag
+type ListFlag []string
+
+func (values *ListFlag) String() string {
+	return strings.Join(*values, ",")
+}
+
+func (values *ListFlag) Set(value string) error {
+	*values = append(*values, value)
+
+	return nil
 }
 
 func main() {
+	grpcAddress := flag.String("grpc-address", "localhost:8080", "address the grpc server listens on")
+	raftID := flag.String("raft-id", "", "id of this node in the raft group, the store is not replicated when empty")
+	raftPeers := PeerFlag{}
+	flag.Var(&raftPeers, "raft-peer", "member of the raft group, including this node, as id=raft-address,api-address, can be repeated")
+	raftBind := flag.String("raft-bind", "", "address the raft transport listens on when it differs from this node's peer address")
+	raftDir := flag.String("raft-dir", "", "directory the raft log and snapshots are kept in, only held in memory when empty")
+	gossipID := flag.String("gossip-id", "", "id of this node when gossiping with other nodes, the store is not shared when empty")
+	gossipAddress := flag.String("gossip-address", "", "grpc address other nodes reach this node at, the grpc address when empty")
+	gossipSeeds := ListFlag{}
+	flag.Var(&gossipSeeds, "gossip-seed", "grpc address of a node to join the gossip through, can be repeated")
+	dockerSocket := flag.String("docker-socket", "", "docker api socket to register running containers from, disabled when empty")
+	kubernetesEnabled := flag.Bool("kubernetes", false, "register kubernetes services from the cluster")
+	kubeconfig := flag.String("kubeconfig", "", "kubeconfig for the kubernetes watcher, in cluster config is used when empty")
+	kubernetesDomain := flag.String("kubernetes-domain", kubernetes.DefaultDomain, "cluster domain kubernetes records are created under")
+	kubernetesPods := flag.Bool("kubernetes-pods", false, "register running pods as well as services")
+	zoneFiles := ZoneFlag{}
+	flag.Var(&zoneFiles, "zone-file", "rfc 1035 zone file to import at startup as origin=path, can be repeated")
+	recordsDir := flag.String("records-dir", "", "directory of yaml and json record files to keep the store in sync with, disabled when empty")
+	hostsFile := flag.String("hosts-file", "", "hosts format file to keep the store in sync with, disabled when empty")
+	hostsDomain := flag.String("hosts-domain", "", "domain appended to single label names in the hosts file")
+	hostsExport := flag.String("hosts-export", "", "hosts file to keep updated with every record in the store, disabled when empty")
+	leaseFile := flag.String("lease-file", "", "dhcp lease file to register hostnames from, disabled when empty")
+	leaseFormat := flag.String("lease-format", lease.FormatDnsmasq, "format of the lease file, dnsmasq or isc")
+	leaseDomain := flag.String("lease-domain", lease.DefaultDomain, "domain lease hostnames are registered under")
+	canonicalPTR := flag.Bool("canonical-ptr", false, "answer reverse lookups with only the first domain pointing at an address")
+	dnsConfig := flag.String("dns-config", "", "yaml file of the zones served as primary or transferred as secondary, and their TSIG keys")
+	dotCert := flag.String("dot-cert", "", "certificate file dns over tls is served with on --dot-port, disabled when empty")
+	dotKey := flag.String("dot-key", "", "key file of the dns over tls certificate")
+	dotPort := flag.Int("dot-port", dns.DefaultDoTPort, "port dns over tls is served on")
+	dotIdleTimeout := flag.Duration("dot-idle-timeout", dns.DefaultIdleTimeout, "how long a dns over tls connection is kept open without queries")
+	dotMaxQueries := flag.Int("dot-max-queries", -1, "queries a dns over tls connection can pipeline before it is closed, no limit when -1")
+	dohAddress := flag.String("doh-address", "", "address dns over https is served on at /dns-query, disabled when empty")
+	dohCert := flag.String("doh-cert", "", "certificate file dns over https is served with, plain http when empty for serving behind a proxy")
+	dohKey := flag.String("doh-key", "", "key file of the dns over https certificate")
+	dohJSON := flag.Bool("doh-json", false, "answer json queries at /resolve alongside dns over https for debugging")
+	dohTrustedProxies := ListFlag{}
+	flag.Var(&dohTrustedProxies, "doh-trusted-proxy", "cidr of a proxy whose forwarding headers give the dns over https client address, can be repeated")
+	flag.Parse()
+
 	// setup os signal trigger for shutdown
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
@@ -36,22 +160,329 @@ func main() {
 	defer cancel()
 	errGroup, ctx := errgroup.WithContext(ctx)
 
+	var config *dns.Config
+	if *dnsConfig != "" {
+		var err error
+		config, err = dns.LoadConfig(*dnsConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// tsig keys can be rotated through the api while the dns servers run
+	keys := []dns.Key{}
+	if config != nil {
+		keys = config.Keys
+	}
+	keyring, err := dns.NewKeyring(keys...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// every node would roll its own keys, so the nodes would answer with signatures the others' DNSKEY sets don't have
+	if config != nil && (*raftID != "" || *gossipID != "") {
+		for _, zone := range config.Zones {
+			if zone.DNSSEC.Enabled {
+				log.Fatal("dnssec can't be used with --raft-id or --gossip-id")
+			}
+		}
+	}
+
+	// secondary zones are transferred into the memory store, where the names inside them are read only
+	memory := store.NewMemory()
+	var secondary *dns.Secondary
+	if config != nil && len(config.Secondaries) > 0 {
+		if *raftID != "" || *gossipID != "" {
+			log.Fatal("secondary zones can't be used with --raft-id or --gossip-id")
+		}
+
+		secondary = dns.NewSecondary(memory, config.Secondaries, keyring)
+	}
+
+	// every view answers from its own records, kept in memory on this node
+	viewStores := map[string]*store.Memory{}
+	recordsOptions := []discovery.RecordsServerOption{}
+	if config != nil && len(config.Views) > 0 {
+		if *raftID != "" || *gossipID != "" {
+			log.Fatal("views can't be used with --raft-id or --gossip-id")
+		}
+
+		for _, view := range config.Views {
+			viewStores[view.Name] = store.NewMemory()
+			recordsOptions = append(recordsOptions, discovery.WithViewStore(view.Name, viewStores[view.Name]))
+		}
+	}
+
 	// business logic
-	store := store.NewMemory()
+	var store RecordStorer = memory
+
+	var node *cluster.Node
+	if *raftID != "" {
+		node = cluster.NewNode(*raftID, raftPeers, cluster.WithBindAddress(*raftBind), cluster.WithDataDir(*raftDir))
+		err := node.Open()
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer node.Close()
+
+		log.Printf("joined raft group as %s", *raftID)
+		store = node
+	}
+
+	var gossipNode *gossip.Node
+	if *gossipID != "" {
+		if node != nil {
+			log.Fatal("--raft-id and --gossip-id can't be used together")
+		}
+
+		address := *gossipAddress
+		if address == "" {
+			address = *grpcAddress
+		}
+
+		gossipNode = gossip.NewNode(*gossipID, address, gossip.WithSeeds(gossipSeeds...))
+		store = gossipNode
+	}
 
 	// generate grpc services
-	recordService := discovery.NewRecordsServer(store)
+	// the api can't write to the names inside secondary zones
+	var apiStore RecordStorer = store
+	if secondary != nil {
+		apiStore = secondary
+	}
+	recordService := discovery.NewRecordsServer(apiStore, recordsOptions...)
 
 	// generate servers
 	grpcServer := grpc.NewServer()
 	proto.RegisterRecordsServer(grpcServer, recordService)
+	proto.RegisterKeysServer(grpcServer, discovery.NewKeysServer(keyring))
+	if node != nil {
+		proto.RegisterClusterServer(grpcServer, cluster.NewClusterServer(node))
+	}
+	if gossipNode != nil {
+		proto.RegisterGossipServer(grpcServer, gossip.NewGossipServer(gossipNode))
+	}
+
+	// a replicated store can only take writes once the servers are up and a leader is elected
+	if node == nil {
+		for _, zone := range zoneFiles {
+			err := ImportZone(ctx, recordService, zone)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	handlerOptions := []dns.HandlerOption{dns.WithKeyring(keyring), dns.WithCanonicalPTR(*canonicalPTR)}
+	if config != nil {
+		handlerOptions = append(handlerOptions, dns.WithACL(&config.ACL))
+		for i := range config.Views {
+			handlerOptions = append(handlerOptions, dns.WithView(&config.Views[i], viewStores[config.Views[i].Name]))
+		}
+	}
+	serverOptions := []dns.ServerOption{dns.WithTsigKeyring(keyring)}
+	var (
+		journal  *dns.Journal
+		notifier *dns.Notifier
+		signer   *dns.Signer
+	)
+	if config != nil {
+		notifier = dns.NewNotifier(config.Zones, keyring)
+		journal = dns.NewJournal(store, config.Zones, dns.WithNotifier(notifier))
+		handlerOptions = append(handlerOptions, dns.WithJournal(journal), dns.WithUpdater(store))
+
+		signer, err = dns.NewSigner(journal, config.Zones)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handlerOptions = append(handlerOptions, dns.WithSigner(signer))
+	}
+	if secondary != nil {
+		handlerOptions = append(handlerOptions, dns.WithSecondary(secondary))
+	}
+
+	handler := dns.NewRecordHandler(store, handlerOptions...)
+	serveDNSFunc, dnsServer := ServeDNS(handler, 53, "udp", serverOptions...)
+	serveDNSTCPFunc, dnsTCPServer := ServeDNS(handler, 53, "tcp", serverOptions...)
+
+	var (
+		serveDoTFunc func() error
+		dotServer    *dns.DNSServer
+	)
+	if *dotCert != "" {
+		tlsConfig, err := dns.NewTLSConfig(*dotCert, *dotKey, "dot")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		dotOptions := append([]dns.ServerOption{
+			dns.WithTLS(tlsConfig),
+			dns.WithIdleTimeout(*dotIdleTimeout),
+			dns.WithMaxQueries(*dotMaxQueries),
+		}, serverOptions...)
+		serveDoTFunc, dotServer = ServeDNS(handler, *dotPort, "tcp-tls", dotOptions...)
+	}
+
+	var (
+		serveDoHFunc func() error
+		dohServer    *http.Server
+	)
+	if *dohAddress != "" {
+		var tlsConfig *tls.Config
+		if *dohCert != "" {
+			tlsConfig, err = dns.NewTLSConfig(*dohCert, *dohKey, "h2", "http/1.1")
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		proxies := []*net.IPNet{}
+		for _, cidr := range dohTrustedProxies {
+			_, proxy, err := net.ParseCIDR(cidr)
+			if err != nil {
+				log.Fatalf("--doh-trusted-proxy %s is not a valid cidr", cidr)
+			}
 
-	serveDNSFunc, dnsServer := ServeDNS(store, 53, "udp")
-	serveGRPCFunc := ServeGRPC(grpcServer, 8080)
+			proxies = append(proxies, proxy)
+		}
+
+		serveDoHFunc, dohServer = ServeDoH(dns.NewDoHHandler(handler, dns.WithJSON(*dohJSON), dns.WithTrustedProxies(proxies)), *dohAddress, tlsConfig)
+	}
+	serveGRPCFunc := ServeGRPC(grpcServer, *grpcAddress)
+
+	gateway, err := discovery.NewGateway(ctx, recordService)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// counters like the requests the acl refused are published next to the gateway
+	mux := http.NewServeMux()
+	mux.Handle("/", gateway)
+	mux.Handle("/debug/vars", expvar.Handler())
+	serveHTTPFunc, httpServer := ServeHTTP(mux, 8081)
 
 	// start servers
 	errGroup.Go(serveDNSFunc)
+	errGroup.Go(serveDNSTCPFunc)
+	if dotServer != nil {
+		errGroup.Go(serveDoTFunc)
+	}
+	if dohServer != nil {
+		errGroup.Go(serveDoHFunc)
+	}
 	errGroup.Go(serveGRPCFunc)
+	errGroup.Go(serveHTTPFunc)
+
+	errGroup.Go(func() error {
+		log.Println("starting record lease expiry...")
+		return recordService.ExpireLeases(ctx)
+	})
+
+	if node != nil && len(zoneFiles) > 0 {
+		errGroup.Go(func() error {
+			err := node.WaitLeader(ctx)
+			if err != nil {
+				return err
+			}
+
+			for _, zone := range zoneFiles {
+				err := ImportZone(ctx, recordService, zone)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	}
+
+	if journal != nil {
+		errGroup.Go(func() error {
+			log.Println("starting zone journal...")
+			return journal.Run(ctx)
+		})
+	}
+
+	if notifier != nil {
+		errGroup.Go(func() error {
+			log.Println("starting zone notifier...")
+			return notifier.Run(ctx)
+		})
+	}
+
+	if signer != nil && len(signer.Zones) > 0 {
+		errGroup.Go(func() error {
+			log.Println("starting dnssec key rollover...")
+			return signer.Run(ctx)
+		})
+	}
+
+	if secondary != nil {
+		errGroup.Go(func() error {
+			log.Println("starting secondary zone transfers...")
+			return secondary.Run(ctx)
+		})
+	}
+
+	if gossipNode != nil {
+		errGroup.Go(func() error {
+			log.Printf("starting gossip as %s...", *gossipID)
+			return gossipNode.Run(ctx)
+		})
+	}
+
+	// start discovery sources
+	if *dockerSocket != "" {
+		watcher := docker.NewWatcher(docker.NewClient(*dockerSocket), store)
+		errGroup.Go(func() error {
+			log.Println("starting docker watcher...")
+			return watcher.Run(ctx)
+		})
+	}
+
+	if *kubernetesEnabled {
+		client, err := kubernetes.NewClient(*kubeconfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		watcher := kubernetes.NewWatcher(client, store, kubernetes.WithDomain(*kubernetesDomain), kubernetes.WithPods(*kubernetesPods))
+		errGroup.Go(func() error {
+			log.Println("starting kubernetes watcher...")
+			return watcher.Run(ctx)
+		})
+	}
+
+	if *recordsDir != "" {
+		watcher := file.NewWatcher(*recordsDir, store)
+		errGroup.Go(func() error {
+			log.Println("starting file watcher...")
+			return watcher.Run(ctx)
+		})
+	}
+
+	if *hostsFile != "" {
+		watcher := hosts.NewWatcher(*hostsFile, store, hosts.WithDomain(*hostsDomain))
+		errGroup.Go(func() error {
+			log.Println("starting hosts watcher...")
+			return watcher.Run(ctx)
+		})
+	}
+
+	if *hostsExport != "" {
+		exporter := hosts.NewExporter(*hostsExport, store)
+		errGroup.Go(func() error {
+			log.Println("starting hosts exporter...")
+			return exporter.Run(ctx)
+		})
+	}
+
+	if *leaseFile != "" {
+		watcher := lease.NewWatcher(*leaseFile, *leaseFormat, store, lease.WithDomain(*leaseDomain))
+		errGroup.Go(func() error {
+			log.Println("starting lease watcher...")
+			return watcher.Run(ctx)
+		})
+	}
 
 	// wait for shutdown signals
 	select {
@@ -67,7 +498,27 @@ func main() {
 	log.Println("attempting to shutdown servers...")
 
 	// shutdown servers
-	err := dnsServer.Shutdown()
+	err = dnsServer.Shutdown()
+	if err != nil {
+		log.Println(err)
+	}
+	err = dnsTCPServer.Shutdown()
+	if err != nil {
+		log.Println(err)
+	}
+	if dotServer != nil {
+		err = dotServer.Shutdown()
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	if dohServer != nil {
+		err = dohServer.Shutdown(context.Background())
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	err = httpServer.Shutdown(context.Background())
 	if err != nil {
 		log.Println(err)
 	}
@@ -83,12 +534,34 @@ func main() {
 	log.Println("Good bye!")
 }
 
-func ServeDNS(store RecordStorer, port int, protocol string) (func() error, *dns.DNSServer) {
-	handler := dns.NewRecordHandler(store)
-	server := dns.NewServer(handler, port, protocol)
+// ImportZone loads a zone file into the store, logging every entry that couldn't be stored by its line
+func ImportZone(ctx context.Context, server *discovery.RecordsServer, zone ZoneFile) error {
+	data, err := os.ReadFile(zone.Path)
+	if err != nil {
+		return fmt.Errorf("ImportZone: %w", err)
+	}
+
+	resp, err := server.ImportZone(ctx, &proto.ImportZoneRequest{
+		Origin: zone.Origin,
+		Zone:   string(data),
+	})
+	if err != nil {
+		return fmt.Errorf("ImportZone: %s: %w", zone.Path, err)
+	}
+
+	for _, skipped := range resp.Skipped {
+		log.Printf("%s:%v: skipped %s %s: %s", zone.Path, skipped.Line, skipped.Name, skipped.Type, skipped.Reason)
+	}
+	log.Printf("imported %v records from %s", resp.Imported, zone.Path)
+
+	return nil
+}
+
+func ServeDNS(handler *dns.RecordHandler, port int, protocol string, options ...dns.ServerOption) (func() error, *dns.DNSServer) {
+	server := dns.NewServer(handler, port, protocol, options...)
 
 	serverFunc := func() error {
-		log.Println("starting dns server...")
+		log.Printf("starting dns server over %s...", protocol)
 		err := dns.Start(server)
 		if err != nil {
 			return err
@@ -100,9 +573,9 @@ func ServeDNS(store RecordStorer, port int, protocol string) (func() error, *dns
 	return serverFunc, server
 }
 
-func ServeGRPC(server *grpc.Server, port int) func() error {
+func ServeGRPC(server *grpc.Server, address string) func() error {
 	serverFunc := func() error {
-		lis, err := net.Listen("tcp", fmt.Sprintf("localhost:%v", port))
+		lis, err := net.Listen("tcp", address)
 		if err != nil {
 			return err
 		}
@@ -118,3 +591,49 @@ func ServeGRPC(server *grpc.Server, port int) func() error {
 
 	return serverFunc
 }
+
+func ServeHTTP(handler http.Handler, port int) (func() error, *http.Server) {
+	server := &http.Server{
+		Addr:    fmt.Sprintf("localhost:%v", port),
+		Handler: handler,
+	}
+
+	serverFunc := func() error {
+		log.Println("starting http gateway...")
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			return err
+		}
+
+		return nil
+	}
+
+	return serverFunc, server
+}
+
+// ServeDoH serves dns over https on address, over plain http when tlsConfig is nil
+func ServeDoH(handler http.Handler, address string, tlsConfig *tls.Config) (func() error, *http.Server) {
+	server := &http.Server{
+		Addr:      address,
+		Handler:   handler,
+		TLSConfig: tlsConfig,
+	}
+
+	serverFunc := func() error {
+		log.Println("starting dns over https server...")
+
+		var err error
+		if tlsConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			return err
+		}
+
+		return nil
+	}
+
+	return serverFunc, server
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/platform-edn/vinyl/internal/client"
+	"github.com/platform-edn/vinyl/internal/ctl"
+	"github.com/platform-edn/vinyl/internal/proto"
+)
+
+const usage = `usage: vinylctl [flags] <command> [args]
+
+commands:
+  create [--ttl seconds] [-l key=value] <domain> <address>
+  get <domain>
+  list [--suffix domain] [--type A|AAAA] [--cidr network] [--owner source] [-l key=value]
+  remove <domain>
+  watch
+  import [-f file] [--zone origin]
+  export [-f file] [--zone origin]
+  context list | current | use <name> | set [tls flags] <name> <server>
+
+flags:
+`
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := run(ctx, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("vinylctl", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+
+	configPath := fs.String("config", ctl.DefaultConfigPath(), "path to the context file")
+	contextName := fs.String("context", "", "context to use instead of the current context")
+	format := fs.String("o", ctl.TableFormat, "output format: table, json or yaml")
+	overrides := &ctl.Context{}
+	fs.StringVar(&overrides.Server, "server", "", "server address, overrides the context")
+	ctl.TLSFlags(fs, overrides)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing command")
+	}
+
+	err = ctl.ValidateFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	config, err := ctl.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	command, commandArgs := fs.Arg(0), fs.Args()[1:]
+	if command == "context" {
+		return ctl.ContextCommand(config, *configPath, os.Stdout, commandArgs)
+	}
+
+	endpoint, err := config.Get(*contextName)
+	if err != nil {
+		return err
+	}
+
+	// explicitly set flags win over the context file
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "server":
+			endpoint.Server = overrides.Server
+		case "tls":
+			endpoint.TLS = overrides.TLS
+		case "ca-file":
+			endpoint.CAFile = overrides.CAFile
+		case "cert-file":
+			endpoint.CertFile = overrides.CertFile
+		case "key-file":
+			endpoint.KeyFile = overrides.KeyFile
+		case "server-name":
+			endpoint.ServerName = overrides.ServerName
+		case "insecure-skip-verify":
+			endpoint.Insecure = overrides.Insecure
+		}
+	})
+
+	conn, err := ctl.Dial(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	recordsClient := client.NewRecordsClient(proto.NewRecordsClient(conn))
+	commander := ctl.NewCommander(recordsClient, os.Stdout, *format)
+
+	return commander.Run(ctx, command, commandArgs)
+}
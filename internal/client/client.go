@@ -3,17 +3,24 @@ package client
 import (
 	"context"
 	"fmt"
+	"io"
+	"time"
 
 	vinyl "github.com/platform-edn/vinyl/internal"
 	"github.com/platform-edn/vinyl/internal/proto"
+	"github.com/platform-edn/vinyl/internal/zone"
 	"google.golang.org/grpc"
 )
 
 type Clienter interface {
 	CreateRecord(ctx context.Context, in *proto.CreateRecordRequest, opts ...grpc.CallOption) (*proto.CreateRecordResponse, error)
 	RemoveRecord(ctx context.Context, in *proto.RemoveRecordRequest, opts ...grpc.CallOption) (*proto.RemoveRecordResponse, error)
+	RenewRecord(ctx context.Context, in *proto.RenewRecordRequest, opts ...grpc.CallOption) (*proto.RenewRecordResponse, error)
 	GetRecord(ctx context.Context, in *proto.GetRecordRequest, opts ...grpc.CallOption) (*proto.GetRecordResponse, error)
 	ListRecords(ctx context.Context, in *proto.ListRecordsRequest, opts ...grpc.CallOption) (*proto.ListRecordsResponse, error)
+	WatchRecords(ctx context.Context, in *proto.WatchRecordsRequest, opts ...grpc.CallOption) (proto.Records_WatchRecordsClient, error)
+	ImportZone(ctx context.Context, in *proto.ImportZoneRequest, opts ...grpc.CallOption) (*proto.ImportZoneResponse, error)
+	ExportZone(ctx context.Context, in *proto.ExportZoneRequest, opts ...grpc.CallOption) (*proto.ExportZoneResponse, error)
 }
 
 type RecordsClient struct {
@@ -28,24 +35,65 @@ func NewRecordsClient(protoClient proto.RecordsClient, options ...grpc.CallOptio
 	}
 }
 
-func (client *RecordsClient) Create(ctx context.Context, domain string, address string, ttl uint32) (*vinyl.Record, error) {
-	// used to validate record before sending server side
-	_, err := vinyl.NewRecord(domain, address, ttl)
+func (client *RecordsClient) Create(ctx context.Context, domain string, address string, ttl uint32, options ...vinyl.RecordOption) (*vinyl.Record, error) {
+	record, err := client.create(ctx, domain, address, ttl, 0, options...)
 	if err != nil {
 		return nil, fmt.Errorf("Create: %w", err)
 	}
 
+	return record, nil
+}
+
+// CreateLeased creates a record the server removes once lease has passed unless it is renewed
+func (client *RecordsClient) CreateLeased(ctx context.Context, domain string, address string, ttl uint32, lease time.Duration, options ...vinyl.RecordOption) (*vinyl.Record, error) {
+	record, err := client.create(ctx, domain, address, ttl, leaseSeconds(lease), options...)
+	if err != nil {
+		return nil, fmt.Errorf("CreateLeased: %w", err)
+	}
+
+	return record, nil
+}
+
+func (client *RecordsClient) create(ctx context.Context, domain string, address string, ttl uint32, lease uint32, options ...vinyl.RecordOption) (*vinyl.Record, error) {
+	// used to validate record before sending server side
+	record, err := vinyl.NewRecord(domain, address, ttl, options...)
+	if err != nil {
+		return nil, fmt.Errorf("create: %w", err)
+	}
+
 	resp, err := client.CreateRecord(
 		ctx,
 		&proto.CreateRecordRequest{
+			Domain:  record.Domain,
+			Address: record.Address,
+			Ttl:     record.TTL,
+			Labels:  record.Labels,
+			Lease:   lease,
+		},
+		client.Options...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create: %w", err)
+	}
+
+	record = &convertProtoToRecords(resp.Record)[0]
+
+	return record, nil
+}
+
+// Renew extends the lease of the record at domain to lease from now, as long as the record still points at address
+func (client *RecordsClient) Renew(ctx context.Context, domain string, address string, lease time.Duration) (*vinyl.Record, error) {
+	resp, err := client.RenewRecord(
+		ctx,
+		&proto.RenewRecordRequest{
 			Domain:  domain,
 			Address: address,
-			Ttl:     ttl,
+			Lease:   leaseSeconds(lease),
 		},
 		client.Options...,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("Create: %w", err)
+		return nil, fmt.Errorf("Renew: %w", err)
 	}
 
 	record := &convertProtoToRecords(resp.Record)[0]
@@ -65,11 +113,7 @@ func (client *RecordsClient) Remove(ctx context.Context, domain string) (*vinyl.
 		return nil, fmt.Errorf("Remove: %w", err)
 	}
 
-	record := &vinyl.Record{
-		Domain:  resp.Record.Domain,
-		Address: resp.Record.Address,
-		TTL:     resp.Record.Ttl,
-	}
+	record := &convertProtoToRecords(resp.Record)[0]
 
 	return record, nil
 }
@@ -86,28 +130,154 @@ func (client *RecordsClient) Get(ctx context.Context, domain string) (*vinyl.Rec
 		return nil, fmt.Errorf("Get: %w", err)
 	}
 
-	record := &vinyl.Record{
-		Domain:  resp.Record.Domain,
-		Address: resp.Record.Address,
-		TTL:     resp.Record.Ttl,
-	}
+	record := &convertProtoToRecords(resp.Record)[0]
 
 	return record, nil
 }
 
-func (client *RecordsClient) List(ctx context.Context) ([]vinyl.Record, error) {
+// List pages through every record matching filter
+func (client *RecordsClient) List(ctx context.Context, filter vinyl.RecordFilter) ([]vinyl.Record, error) {
+	records := []vinyl.Record{}
+	page := vinyl.Page{}
+
+	for {
+		list, next, err := client.ListPage(ctx, filter, page)
+		if err != nil {
+			return nil, fmt.Errorf("List: %w", err)
+		}
+
+		records = append(records, list...)
+		if next == "" {
+			return records, nil
+		}
+
+		page.Token = next
+	}
+}
+
+// ListPage returns a single page of records matching filter and the token for the next page
+func (client *RecordsClient) ListPage(ctx context.Context, filter vinyl.RecordFilter, page vinyl.Page) ([]vinyl.Record, string, error) {
+	cidr := ""
+	if filter.Network != nil {
+		cidr = filter.Network.String()
+	}
+
 	resp, err := client.ListRecords(
 		ctx,
-		&proto.ListRecordsRequest{},
+		&proto.ListRecordsRequest{
+			PageSize:     int32(page.Size),
+			PageToken:    page.Token,
+			DomainSuffix: filter.DomainSuffix,
+			Type:         filter.Type,
+			Cidr:         cidr,
+			Labels:       filter.Labels,
+			Owner:        filter.Owner,
+		},
 		client.Options...,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("List: %w", err)
+		return nil, "", fmt.Errorf("ListPage: %w", err)
 	}
 
 	records := convertProtoToRecords(resp.Records...)
 
-	return records, nil
+	return records, resp.NextPageToken, nil
+}
+
+// Watch sends record changes to events until ctx is done or the stream ends
+func (client *RecordsClient) Watch(ctx context.Context, events chan<- vinyl.RecordEvent) error {
+	stream, err := client.WatchRecords(
+		ctx,
+		&proto.WatchRecordsRequest{},
+		client.Options...,
+	)
+	if err != nil {
+		return fmt.Errorf("Watch: %w", err)
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("Watch: %w", err)
+		}
+
+		select {
+		case events <- convertProtoToEvent(resp):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Import sends an RFC 1035 master file to the server, returning how many records were stored and the entries
+// that were skipped
+func (client *RecordsClient) Import(ctx context.Context, origin string, file string) (int, []*zone.UnrepresentableRecordError, error) {
+	resp, err := client.ImportZone(
+		ctx,
+		&proto.ImportZoneRequest{
+			Origin: origin,
+			Zone:   file,
+		},
+		client.Options...,
+	)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Import: %w", err)
+	}
+
+	skipped := []*zone.UnrepresentableRecordError{}
+	for _, s := range resp.Skipped {
+		skipped = append(skipped, &zone.UnrepresentableRecordError{
+			Line:   int(s.Line),
+			Name:   s.Name,
+			Type:   s.Type,
+			Reason: s.Reason,
+		})
+	}
+
+	return int(resp.Imported), skipped, nil
+}
+
+// Export returns every record at or below origin as an RFC 1035 master file
+func (client *RecordsClient) Export(ctx context.Context, origin string) (string, error) {
+	resp, err := client.ExportZone(
+		ctx,
+		&proto.ExportZoneRequest{
+			Origin: origin,
+		},
+		client.Options...,
+	)
+	if err != nil {
+		return "", fmt.Errorf("Export: %w", err)
+	}
+
+	return resp.Zone, nil
+}
+
+// leaseSeconds rounds lease up to the whole seconds the server takes leases in
+func leaseSeconds(lease time.Duration) uint32 {
+	return uint32((lease + time.Second - 1) / time.Second)
+}
+
+func convertProtoToEvent(resp *proto.WatchRecordsResponse) vinyl.RecordEvent {
+	eventType := vinyl.RecordCreated
+	switch resp.Type {
+	case proto.EventType_REMOVED:
+		eventType = vinyl.RecordRemoved
+	case proto.EventType_UPDATED:
+		eventType = vinyl.RecordUpdated
+	}
+
+	return vinyl.RecordEvent{
+		Type:   eventType,
+		Record: convertProtoToRecords(resp.Record)[0],
+	}
 }
 
 func convertProtoToRecords(protoRecords ...*proto.Record) []vinyl.Record {
@@ -118,6 +288,9 @@ func convertProtoToRecords(protoRecords ...*proto.Record) []vinyl.Record {
 			Domain:  pr.Domain,
 			Address: pr.Address,
 			TTL:     pr.Ttl,
+			Labels:  pr.Labels,
+			Owner:   pr.Owner,
+			Expires: vinyl.UnixExpiry(pr.Expires),
 		}
 
 		records = append(records, *record)
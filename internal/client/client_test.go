@@ -2,6 +2,7 @@ package client_test
 
 import (
 	"errors"
+	"io"
 	"testing"
 	"time"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/platform-edn/vinyl/internal/client"
 	"github.com/platform-edn/vinyl/internal/client/mocks"
 	"github.com/platform-edn/vinyl/internal/proto"
+	protomocks "github.com/platform-edn/vinyl/internal/proto/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"golang.org/x/net/context"
@@ -94,6 +96,61 @@ func TestRecordsClient_Create(t *testing.T) {
 	}
 }
 
+func TestRecordsClient_CreateLeased(t *testing.T) {
+	assert := assert.New(t)
+	expires := time.Unix(1700000030, 0)
+
+	clienter := mocks.NewClienter(t)
+	clienter.EXPECT().CreateRecord(mock.Anything, &proto.CreateRecordRequest{
+		Domain:  "test.com",
+		Address: "127.0.0.1",
+		Ttl:     30,
+		Lease:   2,
+	}).Return(&proto.CreateRecordResponse{
+		Record: &proto.Record{Domain: "test.com", Address: "127.0.0.1", Ttl: 30, Expires: expires.Unix()},
+	}, nil)
+
+	record, err := client.NewRecordsClient(clienter).CreateLeased(context.Background(), "test.com", "127.0.0.1", 30, 1500*time.Millisecond)
+	assert.NoError(err)
+	assert.True(expires.Equal(*record.Expires))
+}
+
+func TestRecordsClient_Renew(t *testing.T) {
+	tests := map[string]struct {
+		Err error
+	}{
+		"renews the lease": {},
+		"returns error from server side": {
+			Err: errors.New("server side error"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			expires := time.Unix(1700000030, 0)
+
+			clienter := mocks.NewClienter(t)
+			clienter.EXPECT().RenewRecord(mock.Anything, &proto.RenewRecordRequest{
+				Domain:  "test.com",
+				Address: "127.0.0.1",
+				Lease:   30,
+			}).Return(&proto.RenewRecordResponse{
+				Record: &proto.Record{Domain: "test.com", Address: "127.0.0.1", Ttl: 30, Expires: expires.Unix()},
+			}, test.Err)
+
+			record, err := client.NewRecordsClient(clienter).Renew(context.Background(), "test.com", "127.0.0.1", 30*time.Second)
+			if test.Err != nil {
+				assert.ErrorContains(err, test.Err.Error())
+				return
+			}
+
+			assert.NoError(err)
+			assert.True(expires.Equal(*record.Expires))
+		})
+	}
+}
+
 func TestRecordsClient_Remove(t *testing.T) {
 	tests := map[string]struct {
 		Domain  string
@@ -257,7 +314,7 @@ func TestRecordsClient_List(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 			defer cancel()
 
-			records, err := client.List(ctx)
+			records, err := client.List(ctx, vinyl.RecordFilter{})
 			if test.Err != nil {
 				assert.ErrorContains(err, test.Err.Error(), "Err should be the same")
 				return
@@ -269,3 +326,108 @@ func TestRecordsClient_List(t *testing.T) {
 		})
 	}
 }
+
+func TestRecordsClient_Watch(t *testing.T) {
+	tests := map[string]struct {
+		WatchErr error
+		RecvErr  error
+		Err      error
+	}{
+		"sends events until the stream ends": {
+			RecvErr: io.EOF,
+		},
+		"returns error from opening the stream": {
+			WatchErr: errors.New("server side error"),
+			Err:      errors.New("server side error"),
+		},
+		"returns error from the stream": {
+			RecvErr: errors.New("stream error"),
+			Err:     errors.New("stream error"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			clienter := mocks.NewClienter(t)
+			stream := protomocks.NewRecords_WatchRecordsClient(t)
+
+			clienter.EXPECT().WatchRecords(
+				mock.Anything,
+				mock.Anything,
+			).Return(
+				stream,
+				test.WatchErr,
+			)
+
+			resp := &proto.WatchRecordsResponse{
+				Type: proto.EventType_CREATED,
+				Record: &proto.Record{
+					Domain:  "test.com",
+					Address: "127.0.0.1",
+					Ttl:     3000,
+				},
+			}
+
+			if test.WatchErr == nil {
+				stream.EXPECT().Recv().Return(resp, nil).Once()
+				stream.EXPECT().Recv().Return(nil, test.RecvErr).Once()
+			}
+
+			client := client.NewRecordsClient(clienter)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			events := make(chan vinyl.RecordEvent, 1)
+
+			err := client.Watch(ctx, events)
+			if test.Err != nil {
+				assert.ErrorContains(err, test.Err.Error(), "Err should be the same")
+				return
+			}
+
+			assert.NoError(err)
+
+			event := <-events
+			assert.Equal(vinyl.RecordCreated, event.Type)
+			assert.Equal(resp.Record.Domain, event.Record.Domain)
+			assert.Equal(resp.Record.Address, event.Record.Address)
+			assert.Equal(resp.Record.Ttl, event.Record.TTL)
+		})
+	}
+}
+
+func TestRecordsClient_ListPages(t *testing.T) {
+	assert := assert.New(t)
+	clienter := mocks.NewClienter(t)
+
+	clienter.EXPECT().ListRecords(
+		mock.Anything,
+		&proto.ListRecordsRequest{DomainSuffix: "svc.internal."},
+	).Return(
+		&proto.ListRecordsResponse{
+			Records:       []*proto.Record{{Domain: "a.svc.internal.", Address: "127.0.0.1", Ttl: 60}},
+			NextPageToken: "next",
+		},
+		nil,
+	)
+	clienter.EXPECT().ListRecords(
+		mock.Anything,
+		&proto.ListRecordsRequest{DomainSuffix: "svc.internal.", PageToken: "next"},
+	).Return(
+		&proto.ListRecordsResponse{
+			Records: []*proto.Record{{Domain: "b.svc.internal.", Address: "127.0.0.2", Ttl: 60}},
+		},
+		nil,
+	)
+
+	client := client.NewRecordsClient(clienter)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	records, err := client.List(ctx, vinyl.RecordFilter{DomainSuffix: "svc.internal."})
+	assert.NoError(err)
+	assert.Len(records, 2, "every page should be returned")
+	assert.Equal("b.svc.internal.", records[1].Domain)
+}
@@ -0,0 +1,34 @@
+package client
+
+import "fmt"
+
+type RegistrationConflictError struct {
+	Domain  string
+	Address string
+}
+
+func (e *RegistrationConflictError) Error() string {
+	return fmt.Sprintf("%s is registered to %s by someone else", e.Domain, e.Address)
+}
+
+type MissingServersError struct{}
+
+func (e *MissingServersError) Error() string {
+	return "no vinyl servers to resolve with"
+}
+
+type InvalidTargetError struct {
+	Target string
+}
+
+func (e *InvalidTargetError) Error() string {
+	return fmt.Sprintf("%s is not a valid vinyl target, expected vinyl:///<domain>[:port]", e.Target)
+}
+
+type MissingRecordsError struct {
+	Domain string
+}
+
+func (e *MissingRecordsError) Error() string {
+	return fmt.Sprintf("no records at or below %s", e.Domain)
+}
@@ -0,0 +1,231 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	DefaultHeartbeat     = 10 * time.Second
+	DefaultLease         = 30 * time.Second
+	DefaultRemoveTimeout = 5 * time.Second
+)
+
+type Registerer interface {
+	CreateLeased(ctx context.Context, domain string, address string, ttl uint32, lease time.Duration, options ...vinyl.RecordOption) (*vinyl.Record, error)
+	Renew(ctx context.Context, domain string, address string, lease time.Duration) (*vinyl.Record, error)
+	Get(ctx context.Context, domain string) (*vinyl.Record, error)
+	Remove(ctx context.Context, domain string) (*vinyl.Record, error)
+}
+
+// Registration keeps a record registered for as long as the context it was registered with lives. The record is
+// leased and every heartbeat renews the lease, registering the record again if the server lost it, such as after a
+// restart. The server removes the record once the lease runs out, so a process that dies without closing its
+// registration leaves its record behind for at most Lease
+type Registration struct {
+	Client    Registerer
+	Record    vinyl.Record
+	Heartbeat time.Duration
+	// Lease is how long the server keeps the record after the last heartbeat, a few heartbeats so one failed
+	// renewal doesn't lose it
+	Lease         time.Duration
+	RemoveTimeout time.Duration
+	cancel        context.CancelFunc
+	done          chan struct{}
+	err           error
+}
+
+// RegistrationOption sets the optional fields of a Registration
+type RegistrationOption func(*Registration)
+
+// WithHeartbeat sets how often the registration renews the lease of its record
+func WithHeartbeat(heartbeat time.Duration) RegistrationOption {
+	return func(registration *Registration) {
+		registration.Heartbeat = heartbeat
+	}
+}
+
+// WithLease sets how long the server keeps the record without a heartbeat
+func WithLease(lease time.Duration) RegistrationOption {
+	return func(registration *Registration) {
+		registration.Lease = lease
+	}
+}
+
+// WithRemoveTimeout sets how long removing the record may take once the registration ends
+func WithRemoveTimeout(timeout time.Duration) RegistrationOption {
+	return func(registration *Registration) {
+		registration.RemoveTimeout = timeout
+	}
+}
+
+// Register creates record and keeps it registered until ctx is done or Close is called, when it is removed again.
+// A record left behind with the same address, such as by a restarted run of the same service, is taken over. An error
+// is returned if the record can't be created in the first place
+func Register(ctx context.Context, client Registerer, record vinyl.Record, options ...RegistrationOption) (*Registration, error) {
+	registration := &Registration{
+		Client:        client,
+		Record:        record,
+		Heartbeat:     DefaultHeartbeat,
+		Lease:         DefaultLease,
+		RemoveTimeout: DefaultRemoveTimeout,
+		done:          make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(registration)
+	}
+
+	err := registration.register(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Register: %w", err)
+	}
+
+	ctx, registration.cancel = context.WithCancel(ctx)
+	go registration.run(ctx)
+
+	return registration, nil
+}
+
+// Done is closed once the registration has ended and its record was removed
+func (registration *Registration) Done() <-chan struct{} {
+	return registration.done
+}
+
+// Close stops the heartbeats and removes the record, returning any error from the removal
+func (registration *Registration) Close() error {
+	registration.cancel()
+	<-registration.done
+
+	if registration.err != nil {
+		return fmt.Errorf("Close: %w", registration.err)
+	}
+
+	return nil
+}
+
+func (registration *Registration) run(ctx context.Context) {
+	defer close(registration.done)
+
+	ticker := time.NewTicker(registration.Heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			registration.err = registration.remove()
+			return
+		case <-ticker.C:
+			err := registration.beat(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("registration %s: %s", registration.Record.Domain, err)
+			}
+		}
+	}
+}
+
+// beat renews the record's lease and registers the record again if the server no longer has it. Unreachable servers
+// are left to the grpc connection to reconnect to, and the next beat after that renews or registers the record
+func (registration *Registration) beat(ctx context.Context) error {
+	err := registration.renew(ctx)
+	if errorCode(err) == codes.NotFound {
+		err = registration.register(ctx)
+		if err != nil {
+			return fmt.Errorf("beat: %w", err)
+		}
+
+		log.Printf("registration %s: registered again", registration.Record.Domain)
+
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("beat: %w", err)
+	}
+
+	return nil
+}
+
+// register creates the leased record, or takes over the lease of a record that already points at the same address
+func (registration *Registration) register(ctx context.Context) error {
+	record := registration.Record
+
+	_, err := registration.Client.CreateLeased(ctx, record.Domain, record.Address, record.TTL, registration.Lease, vinyl.WithLabels(record.Labels))
+	if errorCode(err) == codes.AlreadyExists {
+		err = registration.renew(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("register: %w", err)
+	}
+
+	return nil
+}
+
+// renew extends the record's lease. The server only renews a record of our own that still points at our address,
+// names a wildcard answers for are reported missing
+func (registration *Registration) renew(ctx context.Context) error {
+	record := registration.Record
+
+	_, err := registration.Client.Renew(ctx, record.Domain, record.Address, registration.Lease)
+	if errorCode(err) == codes.FailedPrecondition {
+		current, getErr := registration.Client.Get(ctx, record.Domain)
+		if getErr == nil && current.Address != record.Address {
+			return fmt.Errorf("renew: %w", &RegistrationConflictError{
+				Domain:  current.Domain,
+				Address: current.Address,
+			})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("renew: %w", err)
+	}
+
+	return nil
+}
+
+// remove deletes the record with a fresh context since the one the registration ran with is already done. A record
+// that points somewhere else by now belongs to someone else and is left alone
+func (registration *Registration) remove() error {
+	ctx, cancel := context.WithTimeout(context.Background(), registration.RemoveTimeout)
+	defer cancel()
+
+	record, err := registration.Client.Get(ctx, registration.Record.Domain)
+	if errorCode(err) == codes.NotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("remove: %w", err)
+	}
+
+	if record.Address != registration.Record.Address {
+		log.Printf("registration %s: not removed, it is registered to %s by someone else", record.Domain, record.Address)
+
+		return nil
+	}
+
+	_, err = registration.Client.Remove(ctx, registration.Record.Domain)
+	if err != nil && errorCode(err) != codes.NotFound {
+		return fmt.Errorf("remove: %w", err)
+	}
+
+	return nil
+}
+
+// errorCode finds the grpc status code of an error that may have been wrapped
+func errorCode(err error) codes.Code {
+	var statusErr interface {
+		GRPCStatus() *status.Status
+	}
+
+	if errors.As(err, &statusErr) {
+		return statusErr.GRPCStatus().Code()
+	}
+
+	return status.Code(err)
+}
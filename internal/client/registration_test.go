@@ -0,0 +1,186 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/client"
+	"github.com/platform-edn/vinyl/internal/client/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRegister(t *testing.T) {
+	record := vinyl.Record{
+		Domain:  "orders.svc.internal.",
+		Address: "10.0.0.5",
+		TTL:     30,
+	}
+	notFound := fmt.Errorf("Renew: %w", status.Error(codes.NotFound, "RenewRecord: missing"))
+	taken := vinyl.Record{Domain: record.Domain, Address: "10.0.0.9", TTL: 30}
+
+	tests := map[string]struct {
+		Renew     error
+		Recreates bool
+		Current   *vinyl.Record
+		RemoveErr error
+		Err       error
+	}{
+		"renews the lease of a registered record": {},
+		"registers again when the server lost the record": {
+			Renew:     notFound,
+			Recreates: true,
+		},
+		"keeps going while the server is unreachable": {
+			Renew: status.Error(codes.Unavailable, "connection refused"),
+		},
+		"ignores records that are already removed": {
+			RemoveErr: fmt.Errorf("Remove: %w", status.Error(codes.NotFound, "RemoveRecord: missing")),
+		},
+		"leaves records registered to someone else": {
+			Current: &taken,
+		},
+		"returns errors removing the record": {
+			RemoveErr: errors.New("bad error"),
+			Err:       errors.New("bad error"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			registerer := mocks.NewRegisterer(t)
+			beat := make(chan struct{}, 1)
+
+			creates := 1
+			if test.Recreates {
+				creates = 2
+			}
+
+			registerer.EXPECT().CreateLeased(mock.Anything, record.Domain, record.Address, record.TTL, time.Second, mock.Anything).Return(&record, nil).Times(creates)
+			registerer.EXPECT().Renew(mock.Anything, record.Domain, record.Address, time.Second).Run(func(context.Context, string, string, time.Duration) {
+				select {
+				case beat <- struct{}{}:
+				default:
+				}
+			}).Return(&record, test.Renew).Once()
+			registerer.EXPECT().Renew(mock.Anything, record.Domain, record.Address, time.Second).Return(&record, nil).Maybe()
+
+			current := &record
+			if test.Current != nil {
+				current = test.Current
+			}
+
+			registerer.EXPECT().Get(mock.Anything, record.Domain).Return(current, nil).Maybe()
+			if test.Current == nil {
+				registerer.EXPECT().Remove(mock.Anything, record.Domain).Return(&record, test.RemoveErr)
+			}
+
+			registration, err := client.Register(context.Background(), registerer, record, client.WithHeartbeat(10*time.Millisecond), client.WithLease(time.Second))
+			assert.NoError(err)
+
+			select {
+			case <-beat:
+			case <-time.After(time.Second):
+				t.Fatal("registration never sent a heartbeat")
+			}
+			// let the heartbeat finish re-registering before closing
+			time.Sleep(20 * time.Millisecond)
+
+			err = registration.Close()
+			if test.Err != nil {
+				assert.ErrorContains(err, test.Err.Error())
+				return
+			}
+
+			assert.NoError(err)
+		})
+	}
+}
+
+func TestRegister_Existing(t *testing.T) {
+	record := vinyl.Record{Domain: "orders.svc.internal.", Address: "10.0.0.5", TTL: 30}
+	exists := fmt.Errorf("CreateLeased: %w", status.Error(codes.AlreadyExists, "CreateRecord: exists"))
+	conflict := fmt.Errorf("Renew: %w", status.Error(codes.FailedPrecondition, "RenewRecord: conflict"))
+
+	tests := map[string]struct {
+		CreateErr error
+		RenewErr  error
+		Existing  *vinyl.Record
+		Err       error
+	}{
+		"takes over the lease of a record with the same address": {
+			CreateErr: exists,
+		},
+		"returns RegistrationConflictError for a record with another address": {
+			CreateErr: exists,
+			RenewErr:  conflict,
+			Existing:  &vinyl.Record{Domain: record.Domain, Address: "10.0.0.9", TTL: 30},
+			Err:       &client.RegistrationConflictError{Domain: record.Domain, Address: "10.0.0.9"},
+		},
+		"returns errors renewing records owned by a source": {
+			CreateErr: exists,
+			RenewErr:  conflict,
+			Existing:  &record,
+			Err:       conflict,
+		},
+		"returns errors creating the record": {
+			CreateErr: errors.New("bad error"),
+			Err:       errors.New("bad error"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			registerer := mocks.NewRegisterer(t)
+			registerer.EXPECT().CreateLeased(mock.Anything, record.Domain, record.Address, record.TTL, client.DefaultLease, mock.Anything).Return(nil, test.CreateErr)
+			if test.CreateErr == exists {
+				registerer.EXPECT().Renew(mock.Anything, record.Domain, record.Address, client.DefaultLease).Return(&record, test.RenewErr)
+			}
+			if test.Existing != nil {
+				registerer.EXPECT().Get(mock.Anything, record.Domain).Return(test.Existing, nil)
+			}
+
+			registration, err := client.Register(context.Background(), registerer, record, client.WithHeartbeat(time.Hour))
+			if test.Err != nil {
+				assert.ErrorContains(err, test.Err.Error())
+				return
+			}
+
+			assert.NoError(err)
+
+			registerer.EXPECT().Get(mock.Anything, record.Domain).Return(&record, nil)
+			registerer.EXPECT().Remove(mock.Anything, record.Domain).Return(&record, nil)
+			assert.NoError(registration.Close())
+		})
+	}
+}
+
+func TestRegister_ContextDone(t *testing.T) {
+	assert := assert.New(t)
+	record := vinyl.Record{Domain: "orders.svc.internal.", Address: "10.0.0.5", TTL: 30}
+
+	registerer := mocks.NewRegisterer(t)
+	registerer.EXPECT().CreateLeased(mock.Anything, record.Domain, record.Address, record.TTL, client.DefaultLease, mock.Anything).Return(&record, nil)
+	registerer.EXPECT().Get(mock.Anything, record.Domain).Return(&record, nil)
+	registerer.EXPECT().Remove(mock.Anything, record.Domain).Return(&record, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	registration, err := client.Register(ctx, registerer, record)
+	assert.NoError(err)
+
+	cancel()
+	select {
+	case <-registration.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the record should be removed when the context is done")
+	}
+}
@@ -0,0 +1,278 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+)
+
+const (
+	// Scheme is the target scheme the resolver is registered for, as in vinyl:///orders.svc.internal:8443
+	Scheme = "vinyl"
+
+	DefaultPort       = "443"
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 10 * time.Second
+)
+
+// ResolverBuilder resolves vinyl:///<domain>[:port] targets to the address of every record at or below domain
+// and keeps the addresses current by watching the store. The vinyl servers are tried in turn so losing one fails
+// over to the next
+type ResolverBuilder struct {
+	Servers     []string
+	DialOptions []grpc.DialOption
+	DefaultPort string
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+// ResolverOption sets the optional fields of a ResolverBuilder
+type ResolverOption func(*ResolverBuilder)
+
+// WithDialOptions sets the options used to connect to the vinyl servers
+func WithDialOptions(options ...grpc.DialOption) ResolverOption {
+	return func(builder *ResolverBuilder) {
+		builder.DialOptions = options
+	}
+}
+
+// WithDefaultPort sets the port of targets that don't have one
+func WithDefaultPort(port string) ResolverOption {
+	return func(builder *ResolverBuilder) {
+		builder.DefaultPort = port
+	}
+}
+
+// WithBackoff sets how long to wait before trying the next server, doubling from min up to max while they keep failing
+func WithBackoff(min time.Duration, max time.Duration) ResolverOption {
+	return func(builder *ResolverBuilder) {
+		builder.MinBackoff = min
+		builder.MaxBackoff = max
+	}
+}
+
+func NewResolverBuilder(servers []string, options ...ResolverOption) *ResolverBuilder {
+	builder := &ResolverBuilder{
+		Servers:     servers,
+		DefaultPort: DefaultPort,
+		MinBackoff:  DefaultMinBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+	}
+
+	for _, option := range options {
+		option(builder)
+	}
+
+	return builder
+}
+
+func (builder *ResolverBuilder) Scheme() string {
+	return Scheme
+}
+
+func (builder *ResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	if len(builder.Servers) == 0 {
+		return nil, fmt.Errorf("Build: %w", &MissingServersError{})
+	}
+
+	domain, port, err := net.SplitHostPort(target.Endpoint)
+	if err != nil {
+		domain, port = target.Endpoint, builder.DefaultPort
+	}
+
+	if domain == "" || port == "" {
+		return nil, fmt.Errorf("Build: %w", &InvalidTargetError{
+			Target: target.Endpoint,
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &vinylResolver{
+		builder:    builder,
+		domain:     strings.ToLower(strings.TrimSuffix(domain, ".")) + ".",
+		port:       port,
+		cc:         cc,
+		cancel:     cancel,
+		resolveNow: make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+
+	go r.run(ctx)
+
+	return r, nil
+}
+
+type vinylResolver struct {
+	builder    *ResolverBuilder
+	domain     string
+	port       string
+	cc         resolver.ClientConn
+	cancel     context.CancelFunc
+	resolveNow chan struct{}
+	done       chan struct{}
+	// resolved is set once addresses were handed to grpc, after which they are kept while servers are unreachable
+	resolved bool
+}
+
+// ResolveNow asks for a relist, which grpc does when connecting to the resolved addresses fails
+func (r *vinylResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolveNow <- struct{}{}:
+	default:
+	}
+}
+
+func (r *vinylResolver) Close() {
+	r.cancel()
+	<-r.done
+}
+
+func (r *vinylResolver) run(ctx context.Context) {
+	defer close(r.done)
+
+	backoff := r.builder.MinBackoff
+	for server := 0; ; server = (server + 1) % len(r.builder.Servers) {
+		healthy, err := r.watch(ctx, r.builder.Servers[server])
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("vinyl resolver %s: %s: %s", r.domain, r.builder.Servers[server], err)
+		if !r.resolved {
+			r.cc.ReportError(err)
+		}
+
+		if healthy {
+			backoff = r.builder.MinBackoff
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+
+		backoff *= 2
+		if backoff > r.builder.MaxBackoff {
+			backoff = r.builder.MaxBackoff
+		}
+	}
+}
+
+// watch lists the records from server and applies its events until ctx is done or the server fails. healthy is
+// true when the server answered at all, so a server that worked for a while doesn't add to the backoff
+func (r *vinylResolver) watch(ctx context.Context, server string) (healthy bool, err error) {
+	conn, err := grpc.DialContext(ctx, server, r.builder.DialOptions...)
+	if err != nil {
+		return false, fmt.Errorf("watch: %w", err)
+	}
+	defer conn.Close()
+
+	client := NewRecordsClient(proto.NewRecordsClient(conn))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events := make(chan vinyl.RecordEvent)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- client.Watch(ctx, events)
+	}()
+
+	addresses, err := r.list(ctx, client)
+	if err != nil {
+		return false, fmt.Errorf("watch: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true, nil
+		case event := <-events:
+			if !r.matches(event.Record) {
+				continue
+			}
+
+			if event.Type == vinyl.RecordRemoved {
+				delete(addresses, event.Record.Domain)
+			} else {
+				addresses[event.Record.Domain] = event.Record.Address
+			}
+
+			r.update(addresses)
+		case <-r.resolveNow:
+			addresses, err = r.list(ctx, client)
+			if err != nil {
+				return true, fmt.Errorf("watch: %w", err)
+			}
+		case err := <-errs:
+			if err == nil {
+				err = fmt.Errorf("watch stream closed")
+			}
+
+			return true, fmt.Errorf("watch: %w", err)
+		}
+	}
+}
+
+// list fetches every matching record and hands their addresses to grpc
+func (r *vinylResolver) list(ctx context.Context, client *RecordsClient) (map[string]string, error) {
+	records, err := client.List(ctx, vinyl.RecordFilter{
+		DomainSuffix: r.domain,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list: %w", err)
+	}
+
+	addresses := map[string]string{}
+	for _, record := range records {
+		if r.matches(record) {
+			addresses[record.Domain] = record.Address
+		}
+	}
+
+	r.update(addresses)
+
+	return addresses, nil
+}
+
+// matches leaves out wildcards since they stand in for names rather than being instances of the service
+func (r *vinylResolver) matches(record vinyl.Record) bool {
+	return vinyl.IsSubdomain(record.Domain, r.domain) && !strings.HasPrefix(record.Domain, "*.")
+}
+
+func (r *vinylResolver) update(addresses map[string]string) {
+	if len(addresses) == 0 {
+		r.cc.ReportError(fmt.Errorf("update: %w", &MissingRecordsError{
+			Domain: r.domain,
+		}))
+		return
+	}
+
+	state := resolver.State{}
+	for _, address := range addresses {
+		state.Addresses = append(state.Addresses, resolver.Address{
+			Addr: net.JoinHostPort(address, r.port),
+		})
+	}
+
+	sort.Slice(state.Addresses, func(i, j int) bool {
+		return state.Addresses[i].Addr < state.Addresses[j].Addr
+	})
+
+	err := r.cc.UpdateState(state)
+	if err != nil {
+		log.Printf("vinyl resolver %s: %s", r.domain, err)
+	}
+
+	r.resolved = true
+}
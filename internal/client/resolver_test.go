@@ -0,0 +1,137 @@
+package client_test
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/client"
+	"github.com/platform-edn/vinyl/internal/discovery"
+	"github.com/platform-edn/vinyl/internal/proto"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+// FakeClientConn records the addresses a resolver hands to grpc
+type FakeClientConn struct {
+	addresses []string
+	mutex     sync.Mutex
+}
+
+func (cc *FakeClientConn) UpdateState(state resolver.State) error {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+
+	cc.addresses = []string{}
+	for _, address := range state.Addresses {
+		cc.addresses = append(cc.addresses, address.Addr)
+	}
+
+	return nil
+}
+
+func (cc *FakeClientConn) ReportError(error) {}
+
+func (cc *FakeClientConn) NewAddress([]resolver.Address) {}
+
+func (cc *FakeClientConn) NewServiceConfig(string) {}
+
+func (cc *FakeClientConn) ParseServiceConfig(string) *serviceconfig.ParseResult {
+	return &serviceconfig.ParseResult{}
+}
+
+func (cc *FakeClientConn) Has(addresses ...string) func() bool {
+	return func() bool {
+		cc.mutex.Lock()
+		defer cc.mutex.Unlock()
+
+		return assert.ObjectsAreEqual(addresses, cc.addresses)
+	}
+}
+
+func serveRecords(t *testing.T, mem *store.Memory) (string, *grpc.Server) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := grpc.NewServer()
+	proto.RegisterRecordsServer(server, discovery.NewRecordsServer(mem))
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	return lis.Addr().String(), server
+}
+
+func TestResolverBuilder_Build(t *testing.T) {
+	assert := assert.New(t)
+
+	primary := store.NewMemory(
+		vinyl.Record{Domain: "a.orders.svc.internal.", Address: "10.0.0.1", TTL: 30},
+		vinyl.Record{Domain: "b.orders.svc.internal.", Address: "10.0.0.2", TTL: 30},
+		vinyl.Record{Domain: "*.orders.svc.internal.", Address: "10.0.0.9", TTL: 30},
+		vinyl.Record{Domain: "users.svc.internal.", Address: "10.0.1.1", TTL: 30},
+	)
+	secondary := store.NewMemory(
+		vinyl.Record{Domain: "a.orders.svc.internal.", Address: "10.0.0.3", TTL: 30},
+	)
+
+	primaryAddress, primaryServer := serveRecords(t, primary)
+	secondaryAddress, _ := serveRecords(t, secondary)
+
+	builder := client.NewResolverBuilder(
+		[]string{primaryAddress, secondaryAddress},
+		client.WithDialOptions(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		client.WithBackoff(10*time.Millisecond, 50*time.Millisecond),
+	)
+	assert.Equal(client.Scheme, builder.Scheme())
+
+	cc := &FakeClientConn{}
+	r, err := builder.Build(resolver.Target{Endpoint: "orders.svc.internal:8443"}, cc, resolver.BuildOptions{})
+	assert.NoError(err)
+	defer r.Close()
+
+	assert.Eventually(cc.Has("10.0.0.1:8443", "10.0.0.2:8443"), time.Second, 10*time.Millisecond, "every record below the domain should be resolved")
+
+	_, err = primary.CreateRecord("c.orders.svc.internal.", "10.0.0.4", 30)
+	assert.NoError(err)
+	_, err = primary.RemoveRecord("a.orders.svc.internal.")
+	assert.NoError(err)
+	assert.Eventually(cc.Has("10.0.0.2:8443", "10.0.0.4:8443"), time.Second, 10*time.Millisecond, "changes should be pushed")
+
+	primaryServer.Stop()
+	assert.Eventually(cc.Has("10.0.0.3:8443"), 2*time.Second, 10*time.Millisecond, "the next server should be used when one fails")
+}
+
+func TestResolverBuilder_BuildErrors(t *testing.T) {
+	tests := map[string]struct {
+		Servers []string
+		Target  string
+		Err     error
+	}{
+		"returns MissingServersError without servers": {
+			Target: "orders.svc.internal",
+			Err:    &client.MissingServersError{},
+		},
+		"returns InvalidTargetError without a domain": {
+			Servers: []string{"localhost:8080"},
+			Target:  ":8443",
+			Err:     &client.InvalidTargetError{Target: ":8443"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			_, err := client.NewResolverBuilder(test.Servers).Build(resolver.Target{Endpoint: test.Target}, &FakeClientConn{}, resolver.BuildOptions{})
+			assert.ErrorContains(err, test.Err.Error())
+		})
+	}
+}
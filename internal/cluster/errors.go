@@ -0,0 +1,65 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/platform-edn/vinyl/internal/proto"
+	"github.com/platform-edn/vinyl/internal/store"
+)
+
+type NoLeaderError struct{}
+
+func (e *NoLeaderError) Error() string {
+	return "the cluster has no leader to accept writes"
+}
+
+type MissingPeerError struct {
+	ID string
+}
+
+func (e *MissingPeerError) Error() string {
+	return fmt.Sprintf("node %s is not one of the cluster peers", e.ID)
+}
+
+type InvalidPeerError struct {
+	Peer string
+}
+
+func (e *InvalidPeerError) Error() string {
+	return fmt.Sprintf("%s is not a valid peer, expected id=raft-address,api-address", e.Peer)
+}
+
+// encodeError turns the store errors a command can fail with into something that can be sent back to a follower
+func encodeError(err error) *proto.ApplyError {
+	var (
+		missing       *store.MissingRecordError
+		existing      *store.ExistingRecordError
+		ownerConflict *store.OwnerConflictError
+	)
+
+	switch {
+	case errors.As(err, &missing):
+		return &proto.ApplyError{Type: proto.ApplyErrorType_MISSING_RECORD, Domain: missing.Domain}
+	case errors.As(err, &existing):
+		return &proto.ApplyError{Type: proto.ApplyErrorType_EXISTING_RECORD, Domain: existing.Domain}
+	case errors.As(err, &ownerConflict):
+		return &proto.ApplyError{Type: proto.ApplyErrorType_OWNER_CONFLICT, Domain: ownerConflict.Domain, Owner: ownerConflict.Owner}
+	}
+
+	return &proto.ApplyError{Type: proto.ApplyErrorType_UNKNOWN_ERROR, Message: err.Error()}
+}
+
+// decodeError rebuilds the store error a forwarded command failed with so callers can match on it
+func decodeError(applyErr *proto.ApplyError) error {
+	switch applyErr.Type {
+	case proto.ApplyErrorType_MISSING_RECORD:
+		return &store.MissingRecordError{Domain: applyErr.Domain}
+	case proto.ApplyErrorType_EXISTING_RECORD:
+		return &store.ExistingRecordError{Domain: applyErr.Domain}
+	case proto.ApplyErrorType_OWNER_CONFLICT:
+		return &store.OwnerConflictError{Domain: applyErr.Domain, Owner: applyErr.Owner}
+	}
+
+	return errors.New(applyErr.Message)
+}
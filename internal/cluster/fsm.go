@@ -0,0 +1,136 @@
+package cluster
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/raft"
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/proto"
+	"github.com/platform-edn/vinyl/internal/store"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// fsm applies committed commands to the local store, so every node serves reads from its own copy
+type fsm struct {
+	store *store.Memory
+}
+
+// applyResult is what applying a command returned, along with the log index it was committed at
+type applyResult struct {
+	record *vinyl.Record
+	index  uint64
+	err    error
+}
+
+func (f *fsm) Apply(entry *raft.Log) interface{} {
+	result := &applyResult{
+		index: entry.Index,
+	}
+
+	command := &proto.Command{}
+	err := protobuf.Unmarshal(entry.Data, command)
+	if err != nil {
+		result.err = fmt.Errorf("Apply: %w", err)
+		return result
+	}
+
+	record := convertProtoToRecord(command.Record)
+	options := []vinyl.RecordOption{vinyl.WithLabels(record.Labels), vinyl.WithOwner(record.Owner)}
+
+	switch command.Type {
+	case proto.CommandType_CREATE_RECORD:
+		result.record, result.err = f.store.CreateRecord(record.Domain, record.Address, record.TTL, options...)
+	case proto.CommandType_UPDATE_RECORD:
+		result.record, result.err = f.store.UpdateRecord(record.Domain, record.Address, record.TTL, options...)
+	case proto.CommandType_REMOVE_RECORD:
+		result.record, result.err = f.store.RemoveRecord(record.Domain)
+	case proto.CommandType_REMOVE_OWNED_RECORD:
+		result.record, result.err = f.store.RemoveOwnedRecord(record.Domain, record.Owner)
+	default:
+		result.err = fmt.Errorf("Apply: unknown command type %v", command.Type)
+	}
+
+	return result
+}
+
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	records, _, err := f.store.ListRecords(vinyl.RecordFilter{}, vinyl.Page{})
+	if err != nil {
+		return nil, fmt.Errorf("Snapshot: %w", err)
+	}
+
+	return &snapshot{
+		records: records,
+	}, nil
+}
+
+func (f *fsm) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return fmt.Errorf("Restore: %w", err)
+	}
+
+	snap := &proto.Snapshot{}
+	err = protobuf.Unmarshal(data, snap)
+	if err != nil {
+		return fmt.Errorf("Restore: %w", err)
+	}
+
+	records := []vinyl.Record{}
+	for _, record := range snap.Records {
+		records = append(records, convertProtoToRecord(record))
+	}
+
+	f.store.Replace(records...)
+
+	return nil
+}
+
+type snapshot struct {
+	records []vinyl.Record
+}
+
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	snap := &proto.Snapshot{}
+	for _, record := range s.records {
+		snap.Records = append(snap.Records, convertRecordToProto(record))
+	}
+
+	data, err := protobuf.Marshal(snap)
+	if err == nil {
+		_, err = sink.Write(data)
+	}
+	if err != nil {
+		sink.Cancel()
+		return fmt.Errorf("Persist: %w", err)
+	}
+
+	return sink.Close()
+}
+
+func (s *snapshot) Release() {}
+
+func convertRecordToProto(record vinyl.Record) *proto.Record {
+	return &proto.Record{
+		Domain:  record.Domain,
+		Address: record.Address,
+		Ttl:     record.TTL,
+		Labels:  record.Labels,
+		Owner:   record.Owner,
+		Expires: record.ExpiresUnix(),
+	}
+}
+
+func convertProtoToRecord(record *proto.Record) vinyl.Record {
+	return vinyl.Record{
+		Domain:  record.GetDomain(),
+		Address: record.GetAddress(),
+		TTL:     record.GetTtl(),
+		Labels:  record.GetLabels(),
+		Owner:   record.GetOwner(),
+		Expires: vinyl.UnixExpiry(record.GetExpires()),
+	}
+}
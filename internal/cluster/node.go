@@ -0,0 +1,456 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb"
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/proto"
+	"github.com/platform-edn/vinyl/internal/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+const (
+	DefaultApplyTimeout = 5 * time.Second
+	// snapshotsRetained is how many raft snapshots are kept in the data directory
+	snapshotsRetained = 2
+	transportPool     = 3
+	transportTimeout  = 10 * time.Second
+)
+
+// Peer is a member of the raft group. RaftAddress is where it replicates the log and APIAddress is its grpc server,
+// which followers forward writes to when it leads
+type Peer struct {
+	ID          string
+	RaftAddress string
+	APIAddress  string
+}
+
+// ParsePeer reads a peer written as id=raft-address,api-address
+func ParsePeer(value string) (Peer, error) {
+	id, addresses, found := strings.Cut(value, "=")
+	raftAddress, apiAddress, _ := strings.Cut(addresses, ",")
+
+	if !found || id == "" || raftAddress == "" || apiAddress == "" {
+		return Peer{}, fmt.Errorf("ParsePeer: %w", &InvalidPeerError{
+			Peer: value,
+		})
+	}
+
+	return Peer{
+		ID:          id,
+		RaftAddress: raftAddress,
+		APIAddress:  apiAddress,
+	}, nil
+}
+
+// Node is a store replicated with raft across three or five vinyl nodes. Reads are served from the local copy of
+// the records while writes go through the leader, being forwarded to it over grpc when this node is a follower
+type Node struct {
+	ID    string
+	Peers []Peer
+	// BindAddress is where the raft transport listens, the node's own peer address when empty
+	BindAddress string
+	// DataDir keeps the raft log and snapshots across restarts, state is only held in memory when empty
+	DataDir      string
+	ApplyTimeout time.Duration
+	DialOptions  []grpc.DialOption
+	Store        *store.Memory
+	raft         *raft.Raft
+	transport    *raft.NetworkTransport
+	closers      []io.Closer
+	conns        map[string]*grpc.ClientConn
+	mutex        sync.Mutex
+}
+
+// NodeOption sets the optional fields of a Node
+type NodeOption func(*Node)
+
+// WithBindAddress sets where the raft transport listens when it differs from the address peers use
+func WithBindAddress(address string) NodeOption {
+	return func(node *Node) {
+		node.BindAddress = address
+	}
+}
+
+// WithDataDir sets the directory the raft log and snapshots are kept in
+func WithDataDir(dir string) NodeOption {
+	return func(node *Node) {
+		node.DataDir = dir
+	}
+}
+
+// WithApplyTimeout sets how long a write may take to be committed
+func WithApplyTimeout(timeout time.Duration) NodeOption {
+	return func(node *Node) {
+		node.ApplyTimeout = timeout
+	}
+}
+
+// WithDialOptions sets the options used to connect to the leader when forwarding writes
+func WithDialOptions(options ...grpc.DialOption) NodeOption {
+	return func(node *Node) {
+		node.DialOptions = options
+	}
+}
+
+func NewNode(id string, peers []Peer, options ...NodeOption) *Node {
+	node := &Node{
+		ID:           id,
+		Peers:        peers,
+		ApplyTimeout: DefaultApplyTimeout,
+		DialOptions:  []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+		Store:        store.NewMemory(),
+		conns:        map[string]*grpc.ClientConn{},
+	}
+
+	for _, option := range options {
+		option(node)
+	}
+
+	return node
+}
+
+// Open starts taking part in the raft group. The group is bootstrapped with every peer the first time a node
+// starts, which is safe since every node is given the same peers
+func (node *Node) Open() error {
+	self, err := node.peer(node.ID)
+	if err != nil {
+		return fmt.Errorf("Open: %w", err)
+	}
+
+	bind := node.BindAddress
+	if bind == "" {
+		bind = self.RaftAddress
+	}
+
+	advertise, err := net.ResolveTCPAddr("tcp", self.RaftAddress)
+	if err != nil {
+		return fmt.Errorf("Open: %w", err)
+	}
+
+	node.transport, err = raft.NewTCPTransport(bind, advertise, transportPool, transportTimeout, os.Stderr)
+	if err != nil {
+		return fmt.Errorf("Open: %w", err)
+	}
+
+	logs, stable, snapshots, err := node.stores()
+	if err != nil {
+		node.transport.Close()
+		return fmt.Errorf("Open: %w", err)
+	}
+
+	config := raft.DefaultConfig()
+	config.LocalID = raft.ServerID(node.ID)
+
+	node.raft, err = raft.NewRaft(config, &fsm{store: node.Store}, logs, stable, snapshots, node.transport)
+	if err != nil {
+		node.closeStores()
+		node.transport.Close()
+		return fmt.Errorf("Open: %w", err)
+	}
+
+	servers := []raft.Server{}
+	for _, peer := range node.Peers {
+		servers = append(servers, raft.Server{
+			ID:      raft.ServerID(peer.ID),
+			Address: raft.ServerAddress(peer.RaftAddress),
+		})
+	}
+
+	err = node.raft.BootstrapCluster(raft.Configuration{Servers: servers}).Error()
+	if err != nil && err != raft.ErrCantBootstrap {
+		node.Close()
+		return fmt.Errorf("Open: %w", err)
+	}
+
+	return nil
+}
+
+// stores keeps the raft log in bolt and snapshots on disk when there is a data directory
+func (node *Node) stores() (raft.LogStore, raft.StableStore, raft.SnapshotStore, error) {
+	if node.DataDir == "" {
+		inmem := raft.NewInmemStore()
+		return inmem, inmem, raft.NewInmemSnapshotStore(), nil
+	}
+
+	err := os.MkdirAll(node.DataDir, 0700)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("stores: %w", err)
+	}
+
+	bolt, err := raftboltdb.NewBoltStore(filepath.Join(node.DataDir, "raft.db"))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("stores: %w", err)
+	}
+	node.closers = append(node.closers, bolt)
+
+	snapshots, err := raft.NewFileSnapshotStore(node.DataDir, snapshotsRetained, os.Stderr)
+	if err != nil {
+		node.closeStores()
+		return nil, nil, nil, fmt.Errorf("stores: %w", err)
+	}
+
+	return bolt, bolt, snapshots, nil
+}
+
+// Close leaves the raft group and closes the connections used for forwarding
+func (node *Node) Close() error {
+	err := node.raft.Shutdown().Error()
+
+	node.transport.Close()
+	node.closeStores()
+
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+
+	for address, conn := range node.conns {
+		conn.Close()
+		delete(node.conns, address)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Close: %w", err)
+	}
+
+	return nil
+}
+
+func (node *Node) closeStores() {
+	for _, closer := range node.closers {
+		closer.Close()
+	}
+
+	node.closers = nil
+}
+
+// Leader returns the peer currently leading the raft group
+func (node *Node) Leader() (Peer, error) {
+	_, id := node.raft.LeaderWithID()
+	if id == "" {
+		return Peer{}, fmt.Errorf("Leader: %w", &NoLeaderError{})
+	}
+
+	peer, err := node.peer(string(id))
+	if err != nil {
+		return Peer{}, fmt.Errorf("Leader: %w", err)
+	}
+
+	return peer, nil
+}
+
+// WaitLeader blocks until the raft group has elected a leader or ctx is done
+func (node *Node) WaitLeader(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		_, err := node.Leader()
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("WaitLeader: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// IsLeader reports whether this node currently accepts writes itself
+func (node *Node) IsLeader() bool {
+	return node.raft.State() == raft.Leader
+}
+
+func (node *Node) peer(id string) (Peer, error) {
+	for _, peer := range node.Peers {
+		if peer.ID == id {
+			return peer, nil
+		}
+	}
+
+	return Peer{}, &MissingPeerError{
+		ID: id,
+	}
+}
+
+func (node *Node) GetRecord(domain string) (*vinyl.Record, error) {
+	return node.Store.GetRecord(domain)
+}
+
+func (node *Node) GetRecordsByAddress(address string) ([]vinyl.Record, error) {
+	return node.Store.GetRecordsByAddress(address)
+}
+
+func (node *Node) ListRecords(filter vinyl.RecordFilter, page vinyl.Page) ([]vinyl.Record, string, error) {
+	return node.Store.ListRecords(filter, page)
+}
+
+// Watch returns the changes applied to this node's copy of the records
+func (node *Node) Watch(ctx context.Context) <-chan vinyl.RecordEvent {
+	return node.Store.Watch(ctx)
+}
+
+func (node *Node) CreateRecord(domain string, address string, ttl uint32, options ...vinyl.RecordOption) (*vinyl.Record, error) {
+	record, err := vinyl.NewRecord(domain, address, ttl, options...)
+	if err != nil {
+		return nil, fmt.Errorf("CreateRecord: %w", err)
+	}
+
+	record, err = node.apply(proto.CommandType_CREATE_RECORD, *record)
+	if err != nil {
+		return nil, fmt.Errorf("CreateRecord: %w", err)
+	}
+
+	return record, nil
+}
+
+func (node *Node) UpdateRecord(domain string, address string, ttl uint32, options ...vinyl.RecordOption) (*vinyl.Record, error) {
+	record, err := vinyl.NewRecord(domain, address, ttl, options...)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateRecord: %w", err)
+	}
+
+	record, err = node.apply(proto.CommandType_UPDATE_RECORD, *record)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateRecord: %w", err)
+	}
+
+	return record, nil
+}
+
+func (node *Node) RemoveRecord(domain string) (*vinyl.Record, error) {
+	record, err := node.apply(proto.CommandType_REMOVE_RECORD, vinyl.Record{Domain: domain})
+	if err != nil {
+		return nil, fmt.Errorf("RemoveRecord: %w", err)
+	}
+
+	return record, nil
+}
+
+func (node *Node) RemoveOwnedRecord(domain string, owner string) (*vinyl.Record, error) {
+	record, err := node.apply(proto.CommandType_REMOVE_OWNED_RECORD, vinyl.Record{Domain: domain, Owner: owner})
+	if err != nil {
+		return nil, fmt.Errorf("RemoveOwnedRecord: %w", err)
+	}
+
+	return record, nil
+}
+
+// apply commits a command through the leader, forwarding it when this node follows
+func (node *Node) apply(commandType proto.CommandType, record vinyl.Record) (*vinyl.Record, error) {
+	command := &proto.Command{
+		Type:   commandType,
+		Record: convertRecordToProto(record),
+	}
+
+	if !node.IsLeader() {
+		return node.forward(command)
+	}
+
+	result, err := node.applyLocal(command)
+	if err != nil {
+		return nil, fmt.Errorf("apply: %w", err)
+	}
+
+	return result.record, result.err
+}
+
+// applyLocal appends a command to the raft log and waits for it to be applied. The returned error is only set when
+// the command couldn't be committed, the store's answer is in the result
+func (node *Node) applyLocal(command *proto.Command) (*applyResult, error) {
+	data, err := protobuf.Marshal(command)
+	if err != nil {
+		return nil, fmt.Errorf("applyLocal: %w", err)
+	}
+
+	future := node.raft.Apply(data, node.ApplyTimeout)
+	err = future.Error()
+	if err != nil {
+		return nil, fmt.Errorf("applyLocal: %w", err)
+	}
+
+	return future.Response().(*applyResult), nil
+}
+
+// forward sends a command to the leader and waits for this node to apply it as well, so callers read their own writes
+func (node *Node) forward(command *proto.Command) (*vinyl.Record, error) {
+	leader, err := node.Leader()
+	if err != nil {
+		return nil, fmt.Errorf("forward: %w", err)
+	}
+
+	conn, err := node.conn(leader.APIAddress)
+	if err != nil {
+		return nil, fmt.Errorf("forward: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), node.ApplyTimeout)
+	defer cancel()
+
+	resp, err := proto.NewClusterClient(conn).Apply(ctx, &proto.ApplyRequest{
+		Command: command,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("forward: %s: %w", leader.ID, err)
+	}
+
+	err = node.waitApplied(ctx, resp.Index)
+	if err != nil {
+		return nil, fmt.Errorf("forward: %w", err)
+	}
+
+	if resp.Error != nil {
+		return nil, fmt.Errorf("forward: %w", decodeError(resp.Error))
+	}
+
+	record := convertProtoToRecord(resp.Record)
+
+	return &record, nil
+}
+
+// waitApplied blocks until the local store has caught up with index
+func (node *Node) waitApplied(ctx context.Context, index uint64) error {
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+
+	for node.raft.AppliedIndex() < index {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waitApplied: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+func (node *Node) conn(address string) (*grpc.ClientConn, error) {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+
+	conn, exist := node.conns[address]
+	if exist {
+		return conn, nil
+	}
+
+	conn, err := grpc.Dial(address, node.DialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("conn: %w", err)
+	}
+	node.conns[address] = conn
+
+	return conn, nil
+}
@@ -0,0 +1,190 @@
+package cluster_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/cluster"
+	"github.com/platform-edn/vinyl/internal/proto"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// freeAddress finds a loopback port nothing is listening on
+func freeAddress(t *testing.T) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	return lis.Addr().String()
+}
+
+// startCluster runs size nodes in process, each with a grpc server on loopback for forwarded writes
+func startCluster(t *testing.T, size int) []*cluster.Node {
+	peers := []cluster.Peer{}
+	listeners := []net.Listener{}
+	for i := 0; i < size; i++ {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		listeners = append(listeners, lis)
+		peers = append(peers, cluster.Peer{
+			ID:          string(rune('a' + i)),
+			RaftAddress: freeAddress(t),
+			APIAddress:  lis.Addr().String(),
+		})
+	}
+
+	nodes := []*cluster.Node{}
+	for i, peer := range peers {
+		node := cluster.NewNode(peer.ID, peers)
+		err := node.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { node.Close() })
+
+		server := grpc.NewServer()
+		proto.RegisterClusterServer(server, cluster.NewClusterServer(node))
+		go server.Serve(listeners[i])
+		t.Cleanup(server.Stop)
+
+		nodes = append(nodes, node)
+	}
+
+	return nodes
+}
+
+// waitLeader returns the leader and its followers once one is elected
+func waitLeader(t *testing.T, nodes []*cluster.Node) (*cluster.Node, []*cluster.Node) {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		for i, node := range nodes {
+			if !node.IsLeader() {
+				continue
+			}
+
+			followers := append(append([]*cluster.Node{}, nodes[:i]...), nodes[i+1:]...)
+			return node, followers
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Fatal("no leader was elected")
+	return nil, nil
+}
+
+func TestNode(t *testing.T) {
+	assert := assert.New(t)
+
+	nodes := startCluster(t, 3)
+	leader, followers := waitLeader(t, nodes)
+
+	created, err := followers[0].CreateRecord("orders.svc.internal.", "10.0.0.1", 30, vinyl.WithOwner("docker"))
+	assert.NoError(err)
+	assert.Equal(&vinyl.Record{Domain: "orders.svc.internal.", Address: "10.0.0.1", TTL: 30, Owner: "docker"}, created)
+
+	record, err := followers[0].GetRecord("orders.svc.internal.")
+	assert.NoError(err, "a forwarded write should be readable on the node it was made on")
+	assert.Equal(created, record)
+
+	for _, node := range nodes {
+		assert.Eventually(func() bool {
+			_, err := node.GetRecord("orders.svc.internal.")
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond, "every node should serve the record")
+	}
+
+	_, err = leader.CreateRecord("orders.svc.internal.", "10.0.0.2", 30)
+	var existing *store.ExistingRecordError
+	assert.True(errors.As(err, &existing), "the leader should return store errors")
+
+	_, err = followers[1].RemoveOwnedRecord("orders.svc.internal.", "kubernetes")
+	var ownerConflict *store.OwnerConflictError
+	assert.True(errors.As(err, &ownerConflict), "forwarded writes should return the leader's store errors")
+	assert.Equal(&store.OwnerConflictError{Domain: "orders.svc.internal.", Owner: "docker"}, ownerConflict)
+
+	updated, err := followers[1].UpdateRecord("orders.svc.internal.", "10.0.0.2", 30, vinyl.WithOwner("docker"))
+	assert.NoError(err)
+	assert.Equal("10.0.0.2", updated.Address)
+
+	_, err = followers[1].RemoveRecord("orders.svc.internal.")
+	assert.NoError(err)
+
+	for _, node := range nodes {
+		assert.Eventually(func() bool {
+			_, err := node.GetRecord("orders.svc.internal.")
+			return err != nil
+		}, 5*time.Second, 10*time.Millisecond, "every node should drop the record")
+	}
+
+	_, err = followers[0].CreateRecord("bad domain", "10.0.0.1", 30)
+	var invalid *vinyl.InvalidRecordDomainError
+	assert.True(errors.As(err, &invalid), "invalid records should be rejected before reaching the leader")
+}
+
+func TestNode_Failover(t *testing.T) {
+	assert := assert.New(t)
+
+	nodes := startCluster(t, 3)
+	leader, followers := waitLeader(t, nodes)
+
+	_, err := leader.CreateRecord("orders.svc.internal.", "10.0.0.1", 30)
+	assert.NoError(err)
+
+	assert.NoError(leader.Close())
+
+	_, followers = waitLeader(t, followers)
+	_, err = followers[0].CreateRecord("users.svc.internal.", "10.0.0.2", 30)
+	assert.NoError(err, "the remaining nodes should elect a new leader and keep accepting writes")
+
+	for _, domain := range []string{"orders.svc.internal.", "users.svc.internal."} {
+		_, err := followers[0].GetRecord(domain)
+		assert.NoError(err)
+	}
+}
+
+func TestParsePeer(t *testing.T) {
+	tests := map[string]struct {
+		Value string
+		Peer  cluster.Peer
+		Err   error
+	}{
+		"parses a peer": {
+			Value: "node-1=10.0.0.1:7000,10.0.0.1:8080",
+			Peer:  cluster.Peer{ID: "node-1", RaftAddress: "10.0.0.1:7000", APIAddress: "10.0.0.1:8080"},
+		},
+		"returns InvalidPeerError without an api address": {
+			Value: "node-1=10.0.0.1:7000",
+			Err:   &cluster.InvalidPeerError{Peer: "node-1=10.0.0.1:7000"},
+		},
+		"returns InvalidPeerError without an id": {
+			Value: "10.0.0.1:7000,10.0.0.1:8080",
+			Err:   &cluster.InvalidPeerError{Peer: "10.0.0.1:7000,10.0.0.1:8080"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			peer, err := cluster.ParsePeer(test.Value)
+			if test.Err != nil {
+				assert.ErrorContains(err, test.Err.Error())
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(test.Peer, peer)
+		})
+	}
+}
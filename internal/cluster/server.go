@@ -0,0 +1,48 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/platform-edn/vinyl/internal/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ClusterServer accepts the writes followers forward to the leader
+type ClusterServer struct {
+	Node *Node
+	proto.UnimplementedClusterServer
+}
+
+func NewClusterServer(node *Node) *ClusterServer {
+	return &ClusterServer{
+		Node: node,
+	}
+}
+
+// Apply commits a forwarded command. Store errors are part of the response so the follower can return the same
+// error its caller would have seen on the leader
+func (server *ClusterServer) Apply(ctx context.Context, req *proto.ApplyRequest) (*proto.ApplyResponse, error) {
+	if !server.Node.IsLeader() {
+		return nil, status.Error(codes.Unavailable, fmt.Sprintf("Apply: node %s is not the leader", server.Node.ID))
+	}
+
+	result, err := server.Node.applyLocal(req.Command)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, fmt.Sprintf("Apply: %s", err))
+	}
+
+	resp := &proto.ApplyResponse{
+		Index: result.index,
+	}
+
+	if result.err != nil {
+		resp.Error = encodeError(result.err)
+		return resp, nil
+	}
+
+	resp.Record = convertRecordToProto(*result.record)
+
+	return resp, nil
+}
@@ -0,0 +1,409 @@
+package ctl
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/zone"
+	"gopkg.in/yaml.v3"
+)
+
+type RecordsClienter interface {
+	Create(ctx context.Context, domain string, address string, ttl uint32, options ...vinyl.RecordOption) (*vinyl.Record, error)
+	Remove(ctx context.Context, domain string) (*vinyl.Record, error)
+	Get(ctx context.Context, domain string) (*vinyl.Record, error)
+	List(ctx context.Context, filter vinyl.RecordFilter) ([]vinyl.Record, error)
+	Watch(ctx context.Context, events chan<- vinyl.RecordEvent) error
+	Import(ctx context.Context, origin string, file string) (int, []*zone.UnrepresentableRecordError, error)
+	Export(ctx context.Context, origin string) (string, error)
+}
+
+// Commander runs the record subcommands of vinylctl against a server
+type Commander struct {
+	Client RecordsClienter
+	Out    io.Writer
+	Format string
+}
+
+func NewCommander(client RecordsClienter, out io.Writer, format string) *Commander {
+	return &Commander{
+		Client: client,
+		Out:    out,
+		Format: format,
+	}
+}
+
+// Run dispatches a subcommand and its arguments
+func (cmd *Commander) Run(ctx context.Context, command string, args []string) error {
+	var err error
+
+	switch command {
+	case "create":
+		err = cmd.Create(ctx, args)
+	case "get":
+		err = cmd.Get(ctx, args)
+	case "list":
+		err = cmd.List(ctx, args)
+	case "remove":
+		err = cmd.Remove(ctx, args)
+	case "watch":
+		err = cmd.Watch(ctx, args)
+	case "import":
+		err = cmd.Import(ctx, args)
+	case "export":
+		err = cmd.Export(ctx, args)
+	default:
+		err = &UnknownCommandError{
+			Command: command,
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Run: %w", err)
+	}
+
+	return nil
+}
+
+func (cmd *Commander) Create(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	ttl := fs.Uint("ttl", 300, "ttl of the record in seconds")
+	labels := LabelFlag{}
+	fs.Var(labels, "l", "label as key=value, can be repeated")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return fmt.Errorf("Create: %w", err)
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("Create: %w", &UsageError{
+			Usage: "vinylctl create [--ttl seconds] [-l key=value] <domain> <address>",
+		})
+	}
+
+	record, err := cmd.Client.Create(ctx, fs.Arg(0), fs.Arg(1), uint32(*ttl), vinyl.WithLabels(labels))
+	if err != nil {
+		return fmt.Errorf("Create: %w", err)
+	}
+
+	return PrintRecord(cmd.Out, cmd.Format, *record)
+}
+
+func (cmd *Commander) Get(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Get: %w", &UsageError{
+			Usage: "vinylctl get <domain>",
+		})
+	}
+
+	record, err := cmd.Client.Get(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("Get: %w", err)
+	}
+
+	return PrintRecord(cmd.Out, cmd.Format, *record)
+}
+
+func (cmd *Commander) List(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	suffix := fs.String("suffix", "", "only list domains under this suffix")
+	recordType := fs.String("type", "", "only list A or AAAA records")
+	cidr := fs.String("cidr", "", "only list addresses inside this network")
+	owner := fs.String("owner", "", "only list records managed by this source")
+	labels := LabelFlag{}
+	fs.Var(labels, "l", "only list records with label key=value, can be repeated")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return fmt.Errorf("List: %w", err)
+	}
+
+	filter, err := vinyl.NewRecordFilter(*suffix, *recordType, *cidr, labels)
+	if err != nil {
+		return fmt.Errorf("List: %w", err)
+	}
+	filter.Owner = *owner
+
+	records, err := cmd.Client.List(ctx, *filter)
+	if err != nil {
+		return fmt.Errorf("List: %w", err)
+	}
+
+	return PrintRecords(cmd.Out, cmd.Format, records...)
+}
+
+func (cmd *Commander) Remove(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Remove: %w", &UsageError{
+			Usage: "vinylctl remove <domain>",
+		})
+	}
+
+	record, err := cmd.Client.Remove(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("Remove: %w", err)
+	}
+
+	return PrintRecord(cmd.Out, cmd.Format, *record)
+}
+
+// Watch prints record changes until ctx is done or the server closes the stream
+func (cmd *Commander) Watch(ctx context.Context, args []string) error {
+	events := make(chan vinyl.RecordEvent)
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- cmd.Client.Watch(ctx, events)
+	}()
+
+	for {
+		select {
+		case event := <-events:
+			err := PrintEvent(cmd.Out, cmd.Format, event)
+			if err != nil {
+				return fmt.Errorf("Watch: %w", err)
+			}
+		case err := <-errs:
+			if err != nil {
+				return fmt.Errorf("Watch: %w", err)
+			}
+
+			return nil
+		}
+	}
+}
+
+// Import creates every record in a yaml or json file, or a zone file when an origin is given, reporting the ones
+// that fail
+func (cmd *Commander) Import(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	file := fs.String("f", "-", "file to read records from, - for stdin")
+	origin := fs.String("zone", "", "read the file as an rfc 1035 zone file for this origin")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return fmt.Errorf("Import: %w", err)
+	}
+
+	data, err := readFile(*file)
+	if err != nil {
+		return fmt.Errorf("Import: %w", err)
+	}
+
+	if *origin != "" {
+		return cmd.importZone(ctx, *origin, data)
+	}
+
+	// yaml is a superset of json so this handles both
+	records := []vinyl.Record{}
+	err = yaml.Unmarshal(data, &records)
+	if err != nil {
+		return fmt.Errorf("Import: %w", err)
+	}
+
+	failed := 0
+	for _, r := range records {
+		_, err := cmd.Client.Create(ctx, r.Domain, r.Address, r.TTL, vinyl.WithLabels(r.Labels))
+		if err != nil {
+			failed++
+			fmt.Fprintf(cmd.Out, "failed to import %s: %s\n", r.Domain, err)
+			continue
+		}
+
+		fmt.Fprintf(cmd.Out, "imported %s\n", r.Domain)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Import: %w", &ImportError{
+			Failed: failed,
+			Total:  len(records),
+		})
+	}
+
+	return nil
+}
+
+func (cmd *Commander) importZone(ctx context.Context, origin string, data []byte) error {
+	imported, skipped, err := cmd.Client.Import(ctx, origin, string(data))
+	if err != nil {
+		return fmt.Errorf("importZone: %w", err)
+	}
+
+	for _, s := range skipped {
+		fmt.Fprintf(cmd.Out, "skipped %s\n", s)
+	}
+	fmt.Fprintf(cmd.Out, "imported %v records\n", imported)
+
+	if len(skipped) > 0 {
+		return fmt.Errorf("importZone: %w", &ImportError{
+			Failed: len(skipped),
+			Total:  imported + len(skipped),
+		})
+	}
+
+	return nil
+}
+
+// Export writes every record as yaml or json so it can be imported again, or the records of one zone as a zone file
+// when an origin is given
+func (cmd *Commander) Export(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	file := fs.String("f", "-", "file to write records to, - for stdout")
+	origin := fs.String("zone", "", "write an rfc 1035 zone file for this origin")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return fmt.Errorf("Export: %w", err)
+	}
+
+	format := cmd.Format
+	if format == TableFormat {
+		format = YAMLFormat
+	}
+
+	out := cmd.Out
+	if *file != "-" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return fmt.Errorf("Export: %w", err)
+		}
+		defer f.Close()
+
+		out = f
+	}
+
+	if *origin != "" {
+		master, err := cmd.Client.Export(ctx, *origin)
+		if err != nil {
+			return fmt.Errorf("Export: %w", err)
+		}
+
+		_, err = io.WriteString(out, master)
+		if err != nil {
+			return fmt.Errorf("Export: %w", err)
+		}
+
+		return nil
+	}
+
+	records, err := cmd.Client.List(ctx, vinyl.RecordFilter{})
+	if err != nil {
+		return fmt.Errorf("Export: %w", err)
+	}
+
+	err = PrintRecords(out, format, records...)
+	if err != nil {
+		return fmt.Errorf("Export: %w", err)
+	}
+
+	return nil
+}
+
+// ContextCommand lists, shows, sets and switches the contexts in a config file
+func ContextCommand(config *Config, path string, out io.Writer, args []string) error {
+	usage := &UsageError{
+		Usage: "vinylctl context list | current | use <name> | set [tls flags] <name> <server>",
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("ContextCommand: %w", usage)
+	}
+
+	switch args[0] {
+	case "list":
+		err := PrintContexts(out, config)
+		if err != nil {
+			return fmt.Errorf("ContextCommand: %w", err)
+		}
+
+		return nil
+	case "current":
+		c, err := config.Get("")
+		if err != nil {
+			return fmt.Errorf("ContextCommand: %w", err)
+		}
+
+		fmt.Fprintln(out, c.Name)
+
+		return nil
+	case "use":
+		if len(args) != 2 {
+			return fmt.Errorf("ContextCommand: %w", usage)
+		}
+
+		err := config.Use(args[1])
+		if err != nil {
+			return fmt.Errorf("ContextCommand: %w", err)
+		}
+	case "set":
+		c := Context{}
+		fs := flag.NewFlagSet("context set", flag.ContinueOnError)
+		TLSFlags(fs, &c)
+
+		err := fs.Parse(args[1:])
+		if err != nil {
+			return fmt.Errorf("ContextCommand: %w", err)
+		}
+		if fs.NArg() != 2 {
+			return fmt.Errorf("ContextCommand: %w", usage)
+		}
+
+		c.Name = fs.Arg(0)
+		c.Server = fs.Arg(1)
+		config.Set(c)
+	default:
+		return fmt.Errorf("ContextCommand: %w", usage)
+	}
+
+	err := config.Save(path)
+	if err != nil {
+		return fmt.Errorf("ContextCommand: %w", err)
+	}
+
+	return nil
+}
+
+// TLSFlags registers the tls settings of a context on a flag set
+func TLSFlags(fs *flag.FlagSet, c *Context) {
+	fs.BoolVar(&c.TLS, "tls", c.TLS, "connect using tls")
+	fs.StringVar(&c.CAFile, "ca-file", c.CAFile, "ca bundle used to verify the server")
+	fs.StringVar(&c.CertFile, "cert-file", c.CertFile, "client certificate for mutual tls")
+	fs.StringVar(&c.KeyFile, "key-file", c.KeyFile, "client key for mutual tls")
+	fs.StringVar(&c.ServerName, "server-name", c.ServerName, "override the server name used to verify the certificate")
+	fs.BoolVar(&c.Insecure, "insecure-skip-verify", c.Insecure, "skip verifying the server certificate")
+}
+
+// LabelFlag collects repeated key=value flags
+type LabelFlag map[string]string
+
+func (labels LabelFlag) String() string {
+	pairs := []string{}
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (labels LabelFlag) Set(pair string) error {
+	key, value, found := strings.Cut(pair, "=")
+	if !found || key == "" {
+		return fmt.Errorf("label %s must be key=value", pair)
+	}
+
+	labels[key] = value
+
+	return nil
+}
+
+func readFile(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+
+	return os.ReadFile(path)
+}
@@ -0,0 +1,182 @@
+package ctl_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/ctl"
+	"github.com/platform-edn/vinyl/internal/ctl/mocks"
+	"github.com/platform-edn/vinyl/internal/zone"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCommander_Run(t *testing.T) {
+	record := &vinyl.Record{
+		Domain:  "test.com",
+		Address: "127.0.0.1",
+		TTL:     60,
+	}
+
+	tests := map[string]struct {
+		Command string
+		Args    []string
+		Setup   func(*mocks.RecordsClienter)
+		Err     error
+	}{
+		"creates a record": {
+			Command: "create",
+			Args:    []string{"--ttl", "60", "-l", "team=edge", "test.com", "127.0.0.1"},
+			Setup: func(c *mocks.RecordsClienter) {
+				c.EXPECT().Create(mock.Anything, "test.com", "127.0.0.1", uint32(60), mock.Anything).Return(record, nil)
+			},
+		},
+		"returns usage for create without an address": {
+			Command: "create",
+			Args:    []string{"test.com"},
+			Err:     &ctl.UsageError{Usage: "vinylctl create [--ttl seconds] [-l key=value] <domain> <address>"},
+		},
+		"gets a record": {
+			Command: "get",
+			Args:    []string{"test.com"},
+			Setup: func(c *mocks.RecordsClienter) {
+				c.EXPECT().Get(mock.Anything, "test.com").Return(record, nil)
+			},
+		},
+		"returns client errors": {
+			Command: "get",
+			Args:    []string{"test.com"},
+			Setup: func(c *mocks.RecordsClienter) {
+				c.EXPECT().Get(mock.Anything, "test.com").Return(nil, errors.New("server side error"))
+			},
+			Err: errors.New("server side error"),
+		},
+		"lists records": {
+			Command: "list",
+			Setup: func(c *mocks.RecordsClienter) {
+				c.EXPECT().List(mock.Anything, vinyl.RecordFilter{Labels: map[string]string{}}).Return([]vinyl.Record{*record}, nil)
+			},
+		},
+		"removes a record": {
+			Command: "remove",
+			Args:    []string{"test.com"},
+			Setup: func(c *mocks.RecordsClienter) {
+				c.EXPECT().Remove(mock.Anything, "test.com").Return(record, nil)
+			},
+		},
+		"watches records": {
+			Command: "watch",
+			Setup: func(c *mocks.RecordsClienter) {
+				c.EXPECT().Watch(mock.Anything, mock.Anything).Call.Return(func(ctx context.Context, events chan<- vinyl.RecordEvent) error {
+					events <- vinyl.RecordEvent{Type: vinyl.RecordCreated, Record: *record}
+					return nil
+				})
+			},
+		},
+		"returns UnknownCommandError": {
+			Command: "frobnicate",
+			Err:     &ctl.UnknownCommandError{Command: "frobnicate"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			client := mocks.NewRecordsClienter(t)
+			if test.Setup != nil {
+				test.Setup(client)
+			}
+
+			out := &bytes.Buffer{}
+			commander := ctl.NewCommander(client, out, ctl.TableFormat)
+
+			err := commander.Run(context.Background(), test.Command, test.Args)
+			if test.Err != nil {
+				assert.ErrorContains(err, test.Err.Error())
+				return
+			}
+
+			assert.NoError(err)
+			assert.Contains(out.String(), record.Domain)
+		})
+	}
+}
+
+func TestCommander_ImportExport(t *testing.T) {
+	assert := assert.New(t)
+	records := []vinyl.Record{
+		{Domain: "test1.com", Address: "127.0.0.1", TTL: 60},
+		{Domain: "test2.com", Address: "127.0.0.2", TTL: 60},
+	}
+	file := filepath.Join(t.TempDir(), "records.json")
+
+	exporter := mocks.NewRecordsClienter(t)
+	exporter.EXPECT().List(mock.Anything, vinyl.RecordFilter{}).Return(records, nil)
+
+	err := ctl.NewCommander(exporter, &bytes.Buffer{}, ctl.JSONFormat).Export(context.Background(), []string{"-f", file})
+	assert.NoError(err)
+
+	importer := mocks.NewRecordsClienter(t)
+	importer.EXPECT().Create(mock.Anything, "test1.com", "127.0.0.1", uint32(60), mock.Anything).Return(&records[0], nil)
+	importer.EXPECT().Create(mock.Anything, "test2.com", "127.0.0.2", uint32(60), mock.Anything).Return(nil, errors.New("exists"))
+
+	out := &bytes.Buffer{}
+	err = ctl.NewCommander(importer, out, ctl.TableFormat).Import(context.Background(), []string{"-f", file})
+	assert.ErrorContains(err, (&ctl.ImportError{Failed: 1, Total: 2}).Error())
+	assert.Contains(out.String(), "imported test1.com")
+	assert.Contains(out.String(), "failed to import test2.com")
+}
+
+func TestCommander_ImportExportZone(t *testing.T) {
+	assert := assert.New(t)
+	master := "$ORIGIN lab.\nweb\t60\tIN\tA\t10.0.0.1\n"
+	file := filepath.Join(t.TempDir(), "db.lab")
+
+	exporter := mocks.NewRecordsClienter(t)
+	exporter.EXPECT().Export(mock.Anything, "lab.").Return(master, nil)
+
+	err := ctl.NewCommander(exporter, &bytes.Buffer{}, ctl.TableFormat).Export(context.Background(), []string{"-f", file, "--zone", "lab."})
+	assert.NoError(err)
+
+	skipped := []*zone.UnrepresentableRecordError{{Line: 3, Name: "lab.", Type: "MX", Reason: "only A and AAAA records can be stored"}}
+	importer := mocks.NewRecordsClienter(t)
+	importer.EXPECT().Import(mock.Anything, "lab.", master).Return(1, skipped, nil)
+
+	out := &bytes.Buffer{}
+	err = ctl.NewCommander(importer, out, ctl.TableFormat).Import(context.Background(), []string{"-f", file, "--zone", "lab."})
+	assert.ErrorContains(err, (&ctl.ImportError{Failed: 1, Total: 2}).Error())
+	assert.Contains(out.String(), "skipped line 3: lab. MX")
+	assert.Contains(out.String(), "imported 1 records")
+}
+
+func TestContextCommand(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := &ctl.Config{}
+	out := &bytes.Buffer{}
+
+	err := ctl.ContextCommand(config, path, out, []string{"set", "--tls", "lab", "lab:8080"})
+	assert.NoError(err)
+
+	err = ctl.ContextCommand(config, path, out, []string{"use", "lab"})
+	assert.NoError(err)
+
+	err = ctl.ContextCommand(config, path, out, []string{"use", "missing"})
+	assert.ErrorContains(err, (&ctl.MissingContextError{Name: "missing"}).Error())
+
+	_, err = os.Stat(path)
+	assert.NoError(err, "config should be saved")
+
+	loaded, err := ctl.LoadConfig(path)
+	assert.NoError(err)
+
+	current, err := loaded.Get("")
+	assert.NoError(err)
+	assert.Equal("lab:8080", current.Server)
+	assert.True(current.TLS)
+}
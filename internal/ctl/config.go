@@ -0,0 +1,133 @@
+package ctl
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultServer = "localhost:8080"
+
+// Context is a named vinyl endpoint and how to connect to it
+type Context struct {
+	Name       string `yaml:"name"`
+	Server     string `yaml:"server"`
+	TLS        bool   `yaml:"tls,omitempty"`
+	CAFile     string `yaml:"ca-file,omitempty"`
+	CertFile   string `yaml:"cert-file,omitempty"`
+	KeyFile    string `yaml:"key-file,omitempty"`
+	ServerName string `yaml:"server-name,omitempty"`
+	Insecure   bool   `yaml:"insecure-skip-verify,omitempty"`
+}
+
+// Config is the vinylctl context file
+type Config struct {
+	CurrentContext string    `yaml:"current-context"`
+	Contexts       []Context `yaml:"contexts"`
+}
+
+// DefaultConfigPath returns $VINYLCONFIG or ~/.vinyl/config.yaml
+func DefaultConfigPath() string {
+	path := os.Getenv("VINYLCONFIG")
+	if path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".vinyl", "config.yaml")
+	}
+
+	return filepath.Join(home, ".vinyl", "config.yaml")
+}
+
+// LoadConfig reads a context file and returns an empty config if it doesn't exist yet
+func LoadConfig(path string) (*Config, error) {
+	config := &Config{}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("LoadConfig: %w", err)
+	}
+
+	err = yaml.Unmarshal(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("LoadConfig: %w", err)
+	}
+
+	return config, nil
+}
+
+func (config *Config) Save(path string) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("Save: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return fmt.Errorf("Save: %w", err)
+	}
+
+	err = os.WriteFile(path, data, 0600)
+	if err != nil {
+		return fmt.Errorf("Save: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the named context or the current context if name is empty. With no contexts configured at all
+// a context pointing at the default server is returned
+func (config *Config) Get(name string) (*Context, error) {
+	if name == "" {
+		name = config.CurrentContext
+	}
+
+	if name == "" && len(config.Contexts) == 0 {
+		return &Context{
+			Name:   "default",
+			Server: DefaultServer,
+		}, nil
+	}
+
+	for _, c := range config.Contexts {
+		if c.Name == name {
+			return &c, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Get: %w", &MissingContextError{
+		Name: name,
+	})
+}
+
+// Set adds a context or replaces the one with the same name
+func (config *Config) Set(context Context) {
+	for i, c := range config.Contexts {
+		if c.Name == context.Name {
+			config.Contexts[i] = context
+			return
+		}
+	}
+
+	config.Contexts = append(config.Contexts, context)
+}
+
+func (config *Config) Use(name string) error {
+	_, err := config.Get(name)
+	if err != nil {
+		return fmt.Errorf("Use: %w", err)
+	}
+
+	config.CurrentContext = name
+
+	return nil
+}
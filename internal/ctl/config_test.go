@@ -0,0 +1,96 @@
+package ctl_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/platform-edn/vinyl/internal/ctl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "vinyl", "config.yaml")
+
+	config, err := ctl.LoadConfig(path)
+	assert.NoError(err, "missing config should not error")
+	assert.Empty(config.Contexts)
+
+	config.Set(ctl.Context{
+		Name:   "lab",
+		Server: "lab:8080",
+		TLS:    true,
+		CAFile: "/etc/vinyl/ca.pem",
+	})
+	assert.NoError(config.Use("lab"))
+	assert.NoError(config.Save(path))
+
+	loaded, err := ctl.LoadConfig(path)
+	assert.NoError(err)
+	assert.Equal(config, loaded, "configs should be the same")
+}
+
+func TestConfig_Get(t *testing.T) {
+	config := &ctl.Config{
+		CurrentContext: "lab",
+		Contexts: []ctl.Context{
+			{Name: "lab", Server: "lab:8080"},
+			{Name: "prod", Server: "prod:8080"},
+		},
+	}
+
+	tests := map[string]struct {
+		Config *ctl.Config
+		Name   string
+		Server string
+		Err    error
+	}{
+		"returns the current context": {
+			Config: config,
+			Name:   "",
+			Server: "lab:8080",
+		},
+		"returns a named context": {
+			Config: config,
+			Name:   "prod",
+			Server: "prod:8080",
+		},
+		"returns the default server with no contexts": {
+			Config: &ctl.Config{},
+			Server: ctl.DefaultServer,
+		},
+		"returns MissingContextError": {
+			Config: config,
+			Name:   "staging",
+			Err: &ctl.MissingContextError{
+				Name: "staging",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			c, err := test.Config.Get(test.Name)
+			if test.Err != nil {
+				assert.ErrorContains(err, test.Err.Error())
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(test.Server, c.Server)
+		})
+	}
+}
+
+func TestConfig_Set(t *testing.T) {
+	assert := assert.New(t)
+	config := &ctl.Config{}
+
+	config.Set(ctl.Context{Name: "lab", Server: "old:8080"})
+	config.Set(ctl.Context{Name: "lab", Server: "new:8080"})
+
+	assert.Len(config.Contexts, 1, "contexts with the same name should be replaced")
+	assert.Equal("new:8080", config.Contexts[0].Server)
+}
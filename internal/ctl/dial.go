@@ -0,0 +1,65 @@
+package ctl
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Dial connects to the server of a context
+func Dial(ctx context.Context, c *Context) (*grpc.ClientConn, error) {
+	creds, err := TransportCredentials(c)
+	if err != nil {
+		return nil, fmt.Errorf("Dial: %w", err)
+	}
+
+	conn, err := grpc.DialContext(ctx, c.Server, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("Dial: %w", err)
+	}
+
+	return conn, nil
+}
+
+// TransportCredentials builds grpc credentials from the tls settings of a context
+func TransportCredentials(c *Context) (credentials.TransportCredentials, error) {
+	if !c.TLS {
+		return insecure.NewCredentials(), nil
+	}
+
+	config := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.Insecure,
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("TransportCredentials: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("TransportCredentials: no certificates found in %s", c.CAFile)
+		}
+
+		config.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("TransportCredentials: %w", err)
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(config), nil
+}
@@ -0,0 +1,44 @@
+package ctl
+
+import "fmt"
+
+type MissingContextError struct {
+	Name string
+}
+
+func (e *MissingContextError) Error() string {
+	return fmt.Sprintf("context %s does not exist", e.Name)
+}
+
+type UnsupportedFormatError struct {
+	Format string
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("output format %s is not supported", e.Format)
+}
+
+type UnknownCommandError struct {
+	Command string
+}
+
+func (e *UnknownCommandError) Error() string {
+	return fmt.Sprintf("unknown command %s", e.Command)
+}
+
+type UsageError struct {
+	Usage string
+}
+
+func (e *UsageError) Error() string {
+	return fmt.Sprintf("usage: %s", e.Usage)
+}
+
+type ImportError struct {
+	Failed int
+	Total  int
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("%v of %v records failed to import", e.Failed, e.Total)
+}
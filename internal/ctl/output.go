@@ -0,0 +1,145 @@
+package ctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	TableFormat = "table"
+	JSONFormat  = "json"
+	YAMLFormat  = "yaml"
+)
+
+func ValidateFormat(format string) error {
+	switch format {
+	case TableFormat, JSONFormat, YAMLFormat:
+		return nil
+	default:
+		return fmt.Errorf("ValidateFormat: %w", &UnsupportedFormatError{
+			Format: format,
+		})
+	}
+}
+
+// PrintRecords writes records as a table, a json array or a yaml list
+func PrintRecords(w io.Writer, format string, records ...vinyl.Record) error {
+	if format == TableFormat {
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(tw, "DOMAIN\tADDRESS\tTTL\tOWNER")
+
+		for _, r := range records {
+			fmt.Fprintf(tw, "%s\t%s\t%v\t%s\n", r.Domain, r.Address, r.TTL, r.Owner)
+		}
+
+		err := tw.Flush()
+		if err != nil {
+			return fmt.Errorf("PrintRecords: %w", err)
+		}
+
+		return nil
+	}
+
+	if records == nil {
+		records = []vinyl.Record{}
+	}
+
+	err := encode(w, format, records)
+	if err != nil {
+		return fmt.Errorf("PrintRecords: %w", err)
+	}
+
+	return nil
+}
+
+// PrintRecord writes a single record as a table row, a json object or a yaml document
+func PrintRecord(w io.Writer, format string, record vinyl.Record) error {
+	if format == TableFormat {
+		return PrintRecords(w, format, record)
+	}
+
+	err := encode(w, format, record)
+	if err != nil {
+		return fmt.Errorf("PrintRecord: %w", err)
+	}
+
+	return nil
+}
+
+// PrintEvent writes a watch event as a table row, a json line or a yaml document so it can be streamed
+func PrintEvent(w io.Writer, format string, event vinyl.RecordEvent) error {
+	var err error
+
+	switch format {
+	case TableFormat:
+		r := event.Record
+		_, err = fmt.Fprintf(w, "%-8s %s %s %v\n", event.Type, r.Domain, r.Address, r.TTL)
+	case YAMLFormat:
+		_, err = fmt.Fprintln(w, "---")
+		if err == nil {
+			err = encode(w, format, event)
+		}
+	case JSONFormat:
+		err = json.NewEncoder(w).Encode(event)
+	default:
+		err = &UnsupportedFormatError{
+			Format: format,
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("PrintEvent: %w", err)
+	}
+
+	return nil
+}
+
+// PrintContexts writes the contexts of a config as a table, marking the current one
+func PrintContexts(w io.Writer, config *Config) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "CURRENT\tNAME\tSERVER\tTLS")
+
+	for _, c := range config.Contexts {
+		current := ""
+		if c.Name == config.CurrentContext {
+			current = "*"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%v\n", current, c.Name, c.Server, c.TLS)
+	}
+
+	err := tw.Flush()
+	if err != nil {
+		return fmt.Errorf("PrintContexts: %w", err)
+	}
+
+	return nil
+}
+
+func encode(w io.Writer, format string, v interface{}) error {
+	switch format {
+	case JSONFormat:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(v)
+	case YAMLFormat:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+
+		err := encoder.Encode(v)
+		if err != nil {
+			return err
+		}
+
+		return encoder.Close()
+	default:
+		return &UnsupportedFormatError{
+			Format: format,
+		}
+	}
+}
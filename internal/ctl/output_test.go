@@ -0,0 +1,108 @@
+package ctl_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/ctl"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestPrintRecords(t *testing.T) {
+	records := []vinyl.Record{
+		{Domain: "test1.com", Address: "127.0.0.1", TTL: 60},
+		{Domain: "test2.com", Address: "::1", TTL: 120},
+	}
+
+	tests := map[string]struct {
+		Format string
+		Decode func([]byte, interface{}) error
+		Err    error
+	}{
+		"prints json": {
+			Format: ctl.JSONFormat,
+			Decode: json.Unmarshal,
+		},
+		"prints yaml": {
+			Format: ctl.YAMLFormat,
+			Decode: yaml.Unmarshal,
+		},
+		"prints a table": {
+			Format: ctl.TableFormat,
+		},
+		"returns UnsupportedFormatError": {
+			Format: "xml",
+			Err: &ctl.UnsupportedFormatError{
+				Format: "xml",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			out := &bytes.Buffer{}
+
+			err := ctl.PrintRecords(out, test.Format, records...)
+			if test.Err != nil {
+				assert.ErrorContains(err, test.Err.Error())
+				return
+			}
+
+			assert.NoError(err)
+
+			if test.Decode == nil {
+				assert.Contains(out.String(), "DOMAIN")
+				for _, r := range records {
+					assert.Contains(out.String(), r.Domain)
+					assert.Contains(out.String(), r.Address)
+				}
+
+				return
+			}
+
+			decoded := []vinyl.Record{}
+			assert.NoError(test.Decode(out.Bytes(), &decoded))
+			assert.Equal(records, decoded, "records should survive a round trip")
+		})
+	}
+}
+
+func TestPrintEvent(t *testing.T) {
+	event := vinyl.RecordEvent{
+		Type:   vinyl.RecordRemoved,
+		Record: vinyl.Record{Domain: "test.com", Address: "127.0.0.1", TTL: 60},
+	}
+
+	tests := map[string]struct {
+		Format   string
+		Contains string
+	}{
+		"prints a table row": {
+			Format:   ctl.TableFormat,
+			Contains: "removed  test.com 127.0.0.1 60",
+		},
+		"prints a json line": {
+			Format:   ctl.JSONFormat,
+			Contains: `{"type":"removed","record":{"domain":"test.com","address":"127.0.0.1","ttl":60}}`,
+		},
+		"prints a yaml document": {
+			Format:   ctl.YAMLFormat,
+			Contains: "---\ntype: removed\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			out := &bytes.Buffer{}
+
+			err := ctl.PrintEvent(out, test.Format, event)
+			assert.NoError(err)
+			assert.Contains(out.String(), test.Contains)
+		})
+	}
+}
@@ -1,90 +1,433 @@
 package discovery
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
 
 	vinyl "github.com/platform-edn/vinyl/internal"
 	"github.com/platform-edn/vinyl/internal/proto"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/platform-edn/vinyl/internal/zone"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	DefaultPageSize       = 100
+	MaxPageSize           = 1000
+	DefaultExpireInterval = 5 * time.Second
 )
 
 type RecordsServer struct {
 	Store RecordStorer
+	// Views are the record stores of the dns views, by name, requests can target instead of Store
+	Views map[string]RecordStorer
+	// ExpireInterval is how often records whose lease ran out are looked for
+	ExpireInterval time.Duration
 	proto.UnimplementedRecordsServer
 }
 
-func NewRecordsServer(store RecordStorer) *RecordsServer {
-	return &RecordsServer{
-		Store: store,
+// RecordsServerOption sets the optional fields of a RecordsServer
+type RecordsServerOption func(*RecordsServer)
+
+// WithViewStore serves the records of the view name from store
+func WithViewStore(name string, store RecordStorer) RecordsServerOption {
+	return func(server *RecordsServer) {
+		server.Views[name] = store
+	}
+}
+
+// WithExpireInterval sets how often records whose lease ran out are removed
+func WithExpireInterval(interval time.Duration) RecordsServerOption {
+	return func(server *RecordsServer) {
+		server.ExpireInterval = interval
+	}
+}
+
+func NewRecordsServer(store RecordStorer, options ...RecordsServerOption) *RecordsServer {
+	server := &RecordsServer{
+		Store:          store,
+		Views:          map[string]RecordStorer{},
+		ExpireInterval: DefaultExpireInterval,
+	}
+
+	for _, option := range options {
+		option(server)
+	}
+
+	return server
+}
+
+// viewStore returns the store of view, or the default store when view is empty
+func (server *RecordsServer) viewStore(view string) (RecordStorer, error) {
+	if view == "" {
+		return server.Store, nil
 	}
+
+	recordStore, exist := server.Views[view]
+	if !exist {
+		return nil, fmt.Errorf("viewStore: %w", &store.MissingViewError{
+			Name: view,
+		})
+	}
+
+	return recordStore, nil
 }
 
 func (server *RecordsServer) CreateRecord(ctx context.Context, req *proto.CreateRecordRequest) (*proto.CreateRecordResponse, error) {
-	record, err := server.Store.CreateRecord(req.Domain, req.Address, req.Ttl)
+	recordStore, err := server.viewStore(req.View)
 	if err != nil {
-		return nil, fmt.Errorf("CreateRecord: %w", err)
+		return nil, NewStatusError("CreateRecord", err)
+	}
+
+	options := []vinyl.RecordOption{vinyl.WithLabels(req.Labels)}
+	if req.Lease > 0 {
+		options = append(options, vinyl.WithExpires(leaseExpiry(req.Lease)))
+	}
+
+	record, err := recordStore.CreateRecord(req.Domain, req.Address, req.Ttl, options...)
+	if err != nil {
+		return nil, NewStatusError("CreateRecord", err)
 	}
 
 	resp := &proto.CreateRecordResponse{
-		Record: &proto.Record{
-			Domain:  record.Domain,
-			Address: record.Address,
-			Ttl:     record.TTL,
-		},
+		Record: convertRecordsToProto(*record)[0],
 	}
 
 	return resp, nil
 }
 
 func (server *RecordsServer) RemoveRecord(ctx context.Context, req *proto.RemoveRecordRequest) (*proto.RemoveRecordResponse, error) {
-	record, err := server.Store.RemoveRecord(req.Domain)
+	recordStore, err := server.viewStore(req.View)
 	if err != nil {
-		return nil, fmt.Errorf("RemoveRecord: %w", err)
+		return nil, NewStatusError("RemoveRecord", err)
+	}
+
+	record, err := recordStore.RemoveRecord(req.Domain)
+	if err != nil {
+		return nil, NewStatusError("RemoveRecord", err)
 	}
 
 	resp := &proto.RemoveRecordResponse{
-		Record: &proto.Record{
-			Domain:  record.Domain,
-			Address: record.Address,
-			Ttl:     record.TTL,
-		},
+		Record: convertRecordsToProto(*record)[0],
+	}
+
+	return resp, nil
+}
+
+// RenewRecord extends the lease of a record for another lease seconds from now. Records pointing at another
+// address than the request's aren't renewed, so a process can't keep a name alive that was taken over by another.
+// A record without a lease gets one
+func (server *RecordsServer) RenewRecord(ctx context.Context, req *proto.RenewRecordRequest) (*proto.RenewRecordResponse, error) {
+	if req.Lease == 0 {
+		return nil, NewStatusError("RenewRecord", &store.InvalidLeaseError{
+			Lease: req.Lease,
+		})
+	}
+
+	recordStore, err := server.viewStore(req.View)
+	if err != nil {
+		return nil, NewStatusError("RenewRecord", err)
+	}
+
+	record, err := recordStore.GetRecord(req.Domain)
+	if err != nil {
+		return nil, NewStatusError("RenewRecord", err)
+	}
+
+	if !net.ParseIP(record.Address).Equal(net.ParseIP(req.Address)) {
+		err = addressConflict(recordStore, req.Domain, record.Address)
+		return nil, NewStatusError("RenewRecord", err)
+	}
+
+	// a record a wildcard answered with isn't stored under domain, so the update fails with a missing record
+	record, err = recordStore.UpdateRecord(req.Domain, record.Address, record.TTL, vinyl.WithLabels(record.Labels), vinyl.WithExpires(leaseExpiry(req.Lease)))
+	if err != nil {
+		return nil, NewStatusError("RenewRecord", err)
+	}
+
+	resp := &proto.RenewRecordResponse{
+		Record: convertRecordsToProto(*record)[0],
 	}
 
 	return resp, nil
 }
 
+// addressConflict is the error for renewing domain when it points at address. Names that only a wildcard answers
+// for have no record of their own and are reported missing instead
+func addressConflict(recordStore RecordStorer, domain string, address string) error {
+	records, _, err := recordStore.ListRecords(vinyl.RecordFilter{DomainSuffix: domain}, vinyl.Page{})
+	if err != nil {
+		return fmt.Errorf("addressConflict: %w", err)
+	}
+
+	for _, record := range records {
+		if record.Domain == domain {
+			return fmt.Errorf("addressConflict: %w", &store.AddressConflictError{
+				Domain:  domain,
+				Address: address,
+			})
+		}
+	}
+
+	return fmt.Errorf("addressConflict: %w", &store.MissingRecordError{
+		Domain: domain,
+	})
+}
+
+// ExpireLeases removes records whose lease ran out from the store and every view until ctx is done
+func (server *RecordsServer) ExpireLeases(ctx context.Context) error {
+	ticker := time.NewTicker(server.ExpireInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			stores := []RecordStorer{server.Store}
+			for _, view := range server.Views {
+				stores = append(stores, view)
+			}
+
+			for _, recordStore := range stores {
+				expire(recordStore, time.Now())
+			}
+		}
+	}
+}
+
+// expire removes the records of recordStore whose lease ran out before now. Records are looked up again before
+// they are removed so one renewed in the meantime is kept
+func expire(recordStore RecordStorer, now time.Time) {
+	var missing *store.MissingRecordError
+
+	records, _, err := recordStore.ListRecords(vinyl.RecordFilter{}, vinyl.Page{})
+	if err != nil {
+		log.Printf("expire: %s", err)
+		return
+	}
+
+	for _, record := range records {
+		if !record.Expired(now) {
+			continue
+		}
+
+		current, err := recordStore.GetRecord(record.Domain)
+		if err != nil || !current.Expired(now) {
+			continue
+		}
+
+		_, err = recordStore.RemoveRecord(record.Domain)
+		if err != nil {
+			if !errors.As(err, &missing) {
+				log.Printf("expire: %s", err)
+			}
+			continue
+		}
+
+		log.Printf("removed %s, its lease expired", record.Domain)
+	}
+}
+
+// leaseExpiry is when a lease of seconds starting now runs out
+func leaseExpiry(seconds uint32) time.Time {
+	return time.Now().Add(time.Duration(seconds) * time.Second).Truncate(time.Second)
+}
+
 func (server *RecordsServer) GetRecord(ctx context.Context, req *proto.GetRecordRequest) (*proto.GetRecordResponse, error) {
-	record, err := server.Store.GetRecord(req.Domain)
+	recordStore, err := server.viewStore(req.View)
+	if err != nil {
+		return nil, NewStatusError("GetRecord", err)
+	}
+
+	record, err := recordStore.GetRecord(req.Domain)
 	if err != nil {
-		return nil, fmt.Errorf("GetRecord: %w", err)
+		return nil, NewStatusError("GetRecord", err)
 	}
 
 	resp := &proto.GetRecordResponse{
-		Record: &proto.Record{
-			Domain:  record.Domain,
-			Address: record.Address,
-			Ttl:     record.TTL,
-		},
+		Record: convertRecordsToProto(*record)[0],
 	}
 
 	return resp, nil
 }
 
-func (server *RecordsServer) ListRecords(sctx context.Context, req *proto.ListRecordsRequest) (*proto.ListRecordsResponse, error) {
-	records, err := server.Store.ListRecords()
+// ListRecords returns one page of records ordered by domain. Page sizes are capped at MaxPageSize so large stores
+// stay under grpc message limits
+func (server *RecordsServer) ListRecords(ctx context.Context, req *proto.ListRecordsRequest) (*proto.ListRecordsResponse, error) {
+	filter, err := vinyl.NewRecordFilter(req.DomainSuffix, req.Type, req.Cidr, req.Labels)
+	if err != nil {
+		return nil, NewStatusError("ListRecords", err)
+	}
+	filter.Owner = req.Owner
+
+	recordStore, err := server.viewStore(req.View)
+	if err != nil {
+		return nil, NewStatusError("ListRecords", err)
+	}
+
+	size := int(req.PageSize)
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	if size > MaxPageSize {
+		size = MaxPageSize
+	}
+
+	records, next, err := recordStore.ListRecords(*filter, vinyl.Page{
+		Size:  size,
+		Token: req.PageToken,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("ListRecords: %w", err)
+		return nil, NewStatusError("ListRecords", err)
 	}
 
 	protoRecords := convertRecordsToProto(records...)
 
 	resp := &proto.ListRecordsResponse{
-		Records: protoRecords,
+		Records:       protoRecords,
+		NextPageToken: next,
 	}
 
 	return resp, nil
 }
 
+func (server *RecordsServer) WatchRecords(req *proto.WatchRecordsRequest, stream proto.Records_WatchRecordsServer) error {
+	ctx := stream.Context()
+
+	recordStore, err := server.viewStore(req.View)
+	if err != nil {
+		return NewStatusError("WatchRecords", err)
+	}
+
+	for event := range recordStore.Watch(ctx) {
+		err := stream.Send(convertEventToProto(event))
+		if err != nil {
+			return NewStatusError("WatchRecords", err)
+		}
+	}
+
+	if ctx.Err() == nil {
+		return status.Error(codes.Aborted, "WatchRecords: watcher fell too far behind and must relist")
+	}
+
+	return nil
+}
+
+// ImportZone stores the records of an RFC 1035 master file. Existing records are replaced unless a source owns them,
+// and every entry that can't be stored is reported with its line instead of failing the whole import
+func (server *RecordsServer) ImportZone(ctx context.Context, req *proto.ImportZoneRequest) (*proto.ImportZoneResponse, error) {
+	records, skipped, err := zone.Parse(strings.NewReader(req.Zone), req.Origin)
+	if err != nil {
+		return nil, NewStatusError("ImportZone", err)
+	}
+
+	recordStore, err := server.viewStore(req.View)
+	if err != nil {
+		return nil, NewStatusError("ImportZone", err)
+	}
+
+	resp := &proto.ImportZoneResponse{
+		Skipped: convertSkippedToProto(skipped...),
+	}
+
+	for _, record := range records {
+		var existing *store.ExistingRecordError
+
+		_, err := recordStore.CreateRecord(record.Domain, record.Address, record.TTL)
+		if errors.As(err, &existing) {
+			_, err = recordStore.UpdateRecord(record.Domain, record.Address, record.TTL)
+		}
+		if err != nil {
+			resp.Skipped = append(resp.Skipped, &proto.SkippedRecord{
+				Line:   int32(record.Line),
+				Name:   record.Domain,
+				Type:   record.Type(),
+				Reason: err.Error(),
+			})
+			continue
+		}
+
+		resp.Imported++
+	}
+
+	sort.SliceStable(resp.Skipped, func(i, j int) bool {
+		return resp.Skipped[i].Line < resp.Skipped[j].Line
+	})
+
+	return resp, nil
+}
+
+// ExportZone writes every record at or below origin as an RFC 1035 master file
+func (server *RecordsServer) ExportZone(ctx context.Context, req *proto.ExportZoneRequest) (*proto.ExportZoneResponse, error) {
+	filter, err := vinyl.NewRecordFilter(req.Origin, "", "", nil)
+	if err != nil {
+		return nil, NewStatusError("ExportZone", err)
+	}
+
+	recordStore, err := server.viewStore(req.View)
+	if err != nil {
+		return nil, NewStatusError("ExportZone", err)
+	}
+
+	records, _, err := recordStore.ListRecords(*filter, vinyl.Page{})
+	if err != nil {
+		return nil, NewStatusError("ExportZone", err)
+	}
+
+	file := &bytes.Buffer{}
+	err = zone.Write(file, req.Origin, records...)
+	if err != nil {
+		return nil, NewStatusError("ExportZone", err)
+	}
+
+	resp := &proto.ExportZoneResponse{
+		Zone: file.String(),
+	}
+
+	return resp, nil
+}
+
+func convertSkippedToProto(skipped ...*zone.UnrepresentableRecordError) []*proto.SkippedRecord {
+	protoSkipped := []*proto.SkippedRecord{}
+
+	for _, s := range skipped {
+		ps := &proto.SkippedRecord{
+			Line:   int32(s.Line),
+			Name:   s.Name,
+			Type:   s.Type,
+			Reason: s.Reason,
+		}
+
+		protoSkipped = append(protoSkipped, ps)
+	}
+
+	return protoSkipped
+}
+
+func convertEventToProto(event vinyl.RecordEvent) *proto.WatchRecordsResponse {
+	eventType := proto.EventType_CREATED
+	switch event.Type {
+	case vinyl.RecordRemoved:
+		eventType = proto.EventType_REMOVED
+	case vinyl.RecordUpdated:
+		eventType = proto.EventType_UPDATED
+	}
+
+	return &proto.WatchRecordsResponse{
+		Type:   eventType,
+		Record: convertRecordsToProto(event.Record)[0],
+	}
+}
+
 func convertRecordsToProto(records ...vinyl.Record) []*proto.Record {
 	protoRecords := []*proto.Record{}
 
@@ -93,6 +436,9 @@ func convertRecordsToProto(records ...vinyl.Record) []*proto.Record {
 			Domain:  record.Domain,
 			Address: record.Address,
 			Ttl:     record.TTL,
+			Labels:  record.Labels,
+			Owner:   record.Owner,
+			Expires: record.ExpiresUnix(),
 		}
 
 		protoRecords = append(protoRecords, pr)
@@ -4,13 +4,18 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	vinyl "github.com/platform-edn/vinyl/internal"
 	"github.com/platform-edn/vinyl/internal/discovery"
 	"github.com/platform-edn/vinyl/internal/discovery/mocks"
 	"github.com/platform-edn/vinyl/internal/proto"
+	protomocks "github.com/platform-edn/vinyl/internal/proto/mocks"
+	"github.com/platform-edn/vinyl/internal/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestRecordsServer_CreateRecord(t *testing.T) {
@@ -39,6 +44,7 @@ func TestRecordsServer_CreateRecord(t *testing.T) {
 				mock.AnythingOfType("string"),
 				mock.AnythingOfType("string"),
 				mock.AnythingOfType("uint32"),
+				mock.AnythingOfType("vinyl.RecordOption"),
 			).Return(
 				record,
 				test.Err,
@@ -180,8 +186,12 @@ func TestRecordsServer_ListRecords(t *testing.T) {
 				TTL:     3000,
 			}
 
-			store.EXPECT().ListRecords().Return(
+			store.EXPECT().ListRecords(
+				vinyl.RecordFilter{},
+				vinyl.Page{Size: discovery.DefaultPageSize},
+			).Return(
 				[]vinyl.Record{record},
+				"",
 				test.Err,
 			)
 
@@ -200,3 +210,308 @@ func TestRecordsServer_ListRecords(t *testing.T) {
 		})
 	}
 }
+
+func TestRecordsServer_WatchRecords(t *testing.T) {
+	tests := map[string]struct {
+		Cancel  bool
+		SendErr error
+		Code    codes.Code
+	}{
+		"streams events until ctx is done": {
+			Cancel: true,
+			Code:   codes.OK,
+		},
+		"returns aborted when the store drops the watcher": {
+			Cancel: false,
+			Code:   codes.Aborted,
+		},
+		"returns an error if send fails": {
+			Cancel:  true,
+			SendErr: errors.New("bad send"),
+			Code:    codes.Internal,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			store := mocks.NewRecordStorer(t)
+			stream := protomocks.NewRecords_WatchRecordsServer(t)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if test.Cancel {
+				cancel()
+			}
+
+			event := vinyl.RecordEvent{
+				Type: vinyl.RecordRemoved,
+				Record: vinyl.Record{
+					Domain:  "test.com",
+					Address: "127.0.0.1",
+					TTL:     3000,
+				},
+			}
+			events := make(chan vinyl.RecordEvent, 1)
+			events <- event
+			close(events)
+
+			stream.EXPECT().Context().Return(ctx)
+			store.EXPECT().Watch(ctx).Return(events)
+			stream.EXPECT().Send(mock.Anything).Call.Return(func(resp *proto.WatchRecordsResponse) error {
+				assert.Equal(proto.EventType_REMOVED, resp.Type, "types should be the same")
+				assert.Equal(event.Record.Domain, resp.Record.Domain, "domains should be the same")
+
+				return test.SendErr
+			})
+
+			server := discovery.NewRecordsServer(store)
+
+			err := server.WatchRecords(&proto.WatchRecordsRequest{}, stream)
+			assert.Equal(test.Code, status.Code(err), "codes should be the same")
+		})
+	}
+}
+
+func TestRecordsServer_ListRecordsRequest(t *testing.T) {
+	tests := map[string]struct {
+		Request *proto.ListRecordsRequest
+		Filter  vinyl.RecordFilter
+		Page    vinyl.Page
+		Code    codes.Code
+	}{
+		"caps the page size": {
+			Request: &proto.ListRecordsRequest{PageSize: 5000, PageToken: "token"},
+			Page:    vinyl.Page{Size: discovery.MaxPageSize, Token: "token"},
+			Code:    codes.OK,
+		},
+		"passes filters to the store": {
+			Request: &proto.ListRecordsRequest{DomainSuffix: "svc.internal.", Type: "aaaa"},
+			Filter:  vinyl.RecordFilter{DomainSuffix: "svc.internal.", Type: vinyl.TypeAAAA},
+			Page:    vinyl.Page{Size: discovery.DefaultPageSize},
+			Code:    codes.OK,
+		},
+		"rejects bad record types": {
+			Request: &proto.ListRecordsRequest{Type: "MX"},
+			Code:    codes.InvalidArgument,
+		},
+		"rejects bad cidrs": {
+			Request: &proto.ListRecordsRequest{Cidr: "10.0.0.0/99"},
+			Code:    codes.InvalidArgument,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			store := mocks.NewRecordStorer(t)
+
+			if test.Code == codes.OK {
+				store.EXPECT().ListRecords(test.Filter, test.Page).Return([]vinyl.Record{}, "next", nil)
+			}
+
+			server := discovery.NewRecordsServer(store)
+
+			resp, err := server.ListRecords(context.Background(), test.Request)
+			assert.Equal(test.Code, status.Code(err), "codes should be the same")
+			if err != nil {
+				return
+			}
+
+			assert.Equal("next", resp.NextPageToken, "next page token should be returned")
+		})
+	}
+}
+
+func TestRecordsServer_ImportZone(t *testing.T) {
+	assert := assert.New(t)
+	mem := store.NewMemory(
+		vinyl.Record{Domain: "web.lab.", Address: "10.0.0.9", TTL: 60},
+		vinyl.Record{Domain: "db.lab.", Address: "10.0.0.8", TTL: 60, Owner: "docker"},
+	)
+	server := discovery.NewRecordsServer(mem)
+
+	resp, err := server.ImportZone(context.Background(), &proto.ImportZoneRequest{
+		Origin: "lab.",
+		Zone:   "$TTL 300\nweb IN A 10.0.0.1\nmail IN MX 10 web\ndb IN A 10.0.0.2\nnew IN AAAA fd00::1\n",
+	})
+	assert.NoError(err)
+	assert.Equal(int32(2), resp.Imported)
+	assert.Equal("10.0.0.1", mem.Records["web.lab."].Address, "api records should be replaced")
+	assert.Equal("10.0.0.8", mem.Records["db.lab."].Address, "records owned by a source should be left alone")
+
+	lines := []int32{}
+	for _, skipped := range resp.Skipped {
+		lines = append(lines, skipped.Line)
+	}
+	assert.Equal([]int32{3, 4}, lines)
+
+	_, err = server.ImportZone(context.Background(), &proto.ImportZoneRequest{
+		Origin: "lab.",
+		Zone:   "web IN A nowhere\n",
+	})
+	assert.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func TestRecordsServer_ExportZone(t *testing.T) {
+	assert := assert.New(t)
+	mem := store.NewMemory(
+		vinyl.Record{Domain: "web.lab.", Address: "10.0.0.1", TTL: 60},
+		vinyl.Record{Domain: "web.other.", Address: "10.0.0.2", TTL: 60},
+	)
+	server := discovery.NewRecordsServer(mem)
+
+	resp, err := server.ExportZone(context.Background(), &proto.ExportZoneRequest{
+		Origin: "lab.",
+	})
+	assert.NoError(err)
+	assert.Equal("$ORIGIN lab.\nweb\t60\tIN\tA\t10.0.0.1\n", resp.Zone)
+}
+
+func TestRecordsServer_Views(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	public := store.NewMemory(vinyl.Record{Domain: "web.lab.", Address: "203.0.113.1", TTL: 60})
+	internal := store.NewMemory()
+	server := discovery.NewRecordsServer(public, discovery.WithViewStore("internal", internal))
+
+	_, err := server.CreateRecord(ctx, &proto.CreateRecordRequest{Domain: "web.lab.", Address: "10.0.0.1", Ttl: 60, View: "internal"})
+	assert.NoError(err)
+	assert.Equal("10.0.0.1", internal.Records["web.lab."].Address)
+	assert.Equal("203.0.113.1", public.Records["web.lab."].Address, "the default records should be left alone")
+
+	get, err := server.GetRecord(ctx, &proto.GetRecordRequest{Domain: "web.lab.", View: "internal"})
+	assert.NoError(err)
+	assert.Equal("10.0.0.1", get.Record.Address)
+
+	get, err = server.GetRecord(ctx, &proto.GetRecordRequest{Domain: "web.lab."})
+	assert.NoError(err)
+	assert.Equal("203.0.113.1", get.Record.Address)
+
+	list, err := server.ListRecords(ctx, &proto.ListRecordsRequest{View: "internal"})
+	assert.NoError(err)
+	assert.Len(list.Records, 1)
+
+	_, err = server.RemoveRecord(ctx, &proto.RemoveRecordRequest{Domain: "web.lab.", View: "internal"})
+	assert.NoError(err)
+	assert.Empty(internal.Records)
+
+	_, err = server.GetRecord(ctx, &proto.GetRecordRequest{Domain: "web.lab.", View: "external"})
+	assert.Equal(codes.NotFound, status.Code(err))
+}
+
+// expiry is a lease running out after lease
+func expiry(lease time.Duration) *time.Time {
+	expires := time.Now().Add(lease)
+
+	return &expires
+}
+
+func TestRecordsServer_RenewRecord(t *testing.T) {
+	tests := map[string]struct {
+		Domain  string
+		Address string
+		Lease   uint32
+		Code    codes.Code
+	}{
+		"renews the lease of a record": {
+			Domain:  "web.lab.",
+			Address: "10.0.0.1",
+			Lease:   60,
+		},
+		"leases records without a lease": {
+			Domain:  "db.lab.",
+			Address: "10.0.0.2",
+			Lease:   60,
+		},
+		"returns NotFound for missing records": {
+			Domain:  "mail.lab.",
+			Address: "10.0.0.1",
+			Lease:   60,
+			Code:    codes.NotFound,
+		},
+		"returns NotFound for names only a wildcard answers for": {
+			Domain:  "api.preview.lab.",
+			Address: "10.0.0.1",
+			Lease:   60,
+			Code:    codes.NotFound,
+		},
+		"returns FailedPrecondition for records pointing at another address": {
+			Domain:  "web.lab.",
+			Address: "10.0.0.9",
+			Lease:   60,
+			Code:    codes.FailedPrecondition,
+		},
+		"returns FailedPrecondition for records owned by a source": {
+			Domain:  "host.lab.",
+			Address: "10.0.0.4",
+			Lease:   60,
+			Code:    codes.FailedPrecondition,
+		},
+		"returns InvalidArgument without a lease": {
+			Domain:  "web.lab.",
+			Address: "10.0.0.1",
+			Code:    codes.InvalidArgument,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			mem := store.NewMemory(
+				vinyl.Record{Domain: "web.lab.", Address: "10.0.0.1", TTL: 60, Expires: expiry(time.Second)},
+				vinyl.Record{Domain: "db.lab.", Address: "10.0.0.2", TTL: 60},
+				vinyl.Record{Domain: "*.preview.lab.", Address: "10.0.0.3", TTL: 60},
+				vinyl.Record{Domain: "host.lab.", Address: "10.0.0.4", TTL: 60, Owner: "hosts"},
+			)
+			server := discovery.NewRecordsServer(mem)
+
+			resp, err := server.RenewRecord(context.Background(), &proto.RenewRecordRequest{
+				Domain:  test.Domain,
+				Address: test.Address,
+				Lease:   test.Lease,
+			})
+			if test.Code != codes.OK {
+				assert.Equal(test.Code, status.Code(err))
+				return
+			}
+
+			assert.NoError(err)
+			assert.InDelta(time.Now().Add(time.Minute).Unix(), resp.Record.Expires, 1)
+			assert.InDelta(time.Now().Add(time.Minute).Unix(), mem.Records[test.Domain].Expires.Unix(), 1)
+		})
+	}
+}
+
+func TestRecordsServer_ExpireLeases(t *testing.T) {
+	assert := assert.New(t)
+	mem := store.NewMemory(
+		vinyl.Record{Domain: "expired.lab.", Address: "10.0.0.1", TTL: 60, Expires: expiry(-time.Second)},
+		vinyl.Record{Domain: "leased.lab.", Address: "10.0.0.2", TTL: 60, Expires: expiry(time.Minute)},
+		vinyl.Record{Domain: "static.lab.", Address: "10.0.0.3", TTL: 60},
+	)
+	internal := store.NewMemory(
+		vinyl.Record{Domain: "expired.lab.", Address: "10.0.0.4", TTL: 60, Expires: expiry(-time.Second)},
+	)
+	server := discovery.NewRecordsServer(mem, discovery.WithViewStore("internal", internal), discovery.WithExpireInterval(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- server.ExpireLeases(ctx)
+	}()
+
+	assert.Eventually(func() bool {
+		_, err := mem.GetRecord("expired.lab.")
+		_, viewErr := internal.GetRecord("expired.lab.")
+		return err != nil && viewErr != nil
+	}, time.Second, 10*time.Millisecond, "expired records should be removed from the store and views")
+
+	cancel()
+	assert.NoError(<-done)
+
+	_, err := mem.GetRecord("leased.lab.")
+	assert.NoError(err)
+	_, err = mem.GetRecord("static.lab.")
+	assert.NoError(err)
+}
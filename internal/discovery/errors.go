@@ -0,0 +1,57 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/cluster"
+	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/platform-edn/vinyl/internal/zone"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NewStatusError maps store and validation errors to grpc status codes so grpc and http callers see the same failures
+func NewStatusError(method string, err error) error {
+	var (
+		missing        *store.MissingRecordError
+		existing       *store.ExistingRecordError
+		invalidDomain  *vinyl.InvalidRecordDomainError
+		invalidAddress *vinyl.InvalidRecordAddressError
+		invalidTTL     *vinyl.InvalidRecordTTLError
+		invalidType    *vinyl.InvalidRecordTypeError
+		invalidNetwork *vinyl.InvalidFilterNetworkError
+		invalidToken   *store.InvalidPageTokenError
+		invalidZone    *zone.InvalidZoneError
+		ownerConflict  *store.OwnerConflictError
+		noLeader       *cluster.NoLeaderError
+		readOnly       *dns.ReadOnlyStoreError
+		expiredZone    *dns.ExpiredZoneError
+		missingKey     *dns.MissingKeyError
+		invalidKey     *dns.InvalidKeyError
+		missingView    *store.MissingViewError
+		addressTaken   *store.AddressConflictError
+		invalidLease   *store.InvalidLeaseError
+	)
+
+	code := codes.Internal
+
+	switch {
+	case errors.As(err, &missing), errors.As(err, &missingKey), errors.As(err, &missingView):
+		code = codes.NotFound
+	case errors.As(err, &existing):
+		code = codes.AlreadyExists
+	case errors.As(err, &ownerConflict), errors.As(err, &readOnly), errors.As(err, &addressTaken):
+		code = codes.FailedPrecondition
+	case errors.As(err, &noLeader), errors.As(err, &expiredZone):
+		code = codes.Unavailable
+	case errors.As(err, &invalidDomain), errors.As(err, &invalidAddress), errors.As(err, &invalidTTL),
+		errors.As(err, &invalidType), errors.As(err, &invalidNetwork), errors.As(err, &invalidToken),
+		errors.As(err, &invalidZone), errors.As(err, &invalidKey), errors.As(err, &invalidLease):
+		code = codes.InvalidArgument
+	}
+
+	return status.Error(code, fmt.Sprintf("%s: %s", method, err))
+}
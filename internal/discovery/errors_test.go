@@ -0,0 +1,75 @@
+package discovery_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/cluster"
+	"github.com/platform-edn/vinyl/internal/discovery"
+	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewStatusError(t *testing.T) {
+	tests := map[string]struct {
+		Err  error
+		Code codes.Code
+	}{
+		"maps missing records to not found": {
+			Err:  &store.MissingRecordError{Domain: "test.com"},
+			Code: codes.NotFound,
+		},
+		"maps existing records to already exists": {
+			Err:  &store.ExistingRecordError{Domain: "test.com"},
+			Code: codes.AlreadyExists,
+		},
+		"maps wrapped validation errors to invalid argument": {
+			Err:  fmt.Errorf("NewRecord: %w", &vinyl.InvalidRecordTTLError{}),
+			Code: codes.InvalidArgument,
+		},
+		"maps a cluster without a leader to unavailable": {
+			Err:  fmt.Errorf("forward: %w", &cluster.NoLeaderError{}),
+			Code: codes.Unavailable,
+		},
+		"maps a read only secondary to failed precondition": {
+			Err:  fmt.Errorf("CreateRecord: %w", &dns.ReadOnlyStoreError{Domain: "web.lab.example."}),
+			Code: codes.FailedPrecondition,
+		},
+		"maps an expired secondary zone to unavailable": {
+			Err:  fmt.Errorf("GetRecord: %w", &dns.ExpiredZoneError{Origin: "lab.example."}),
+			Code: codes.Unavailable,
+		},
+		"maps a missing tsig key to not found": {
+			Err:  fmt.Errorf("Remove: %w", &dns.MissingKeyError{Name: "dhcp."}),
+			Code: codes.NotFound,
+		},
+		"maps an invalid tsig key to invalid argument": {
+			Err:  fmt.Errorf("Set: %w", &dns.InvalidKeyError{Name: "dhcp.", Reason: "the secret is empty"}),
+			Code: codes.InvalidArgument,
+		},
+		"maps a missing view to not found": {
+			Err:  fmt.Errorf("viewStore: %w", &store.MissingViewError{Name: "internal"}),
+			Code: codes.NotFound,
+		},
+		"maps unknown errors to internal": {
+			Err:  errors.New("bad error"),
+			Code: codes.Internal,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			err := discovery.NewStatusError("Method", test.Err)
+
+			assert.Equal(test.Code, status.Code(err), "codes should be the same")
+			assert.ErrorContains(err, test.Err.Error(), "error should be the same")
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/platform-edn/vinyl/internal/proto"
+)
+
+// NewGateway serves the records service as http/json by calling the grpc server directly
+func NewGateway(ctx context.Context, server proto.RecordsServer) (http.Handler, error) {
+	mux := runtime.NewServeMux()
+
+	err := proto.RegisterRecordsHandlerServer(ctx, mux, server)
+	if err != nil {
+		return nil, fmt.Errorf("NewGateway: %w", err)
+	}
+
+	return mux, nil
+}
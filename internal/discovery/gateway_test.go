@@ -0,0 +1,119 @@
+package discovery_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/discovery"
+	"github.com/platform-edn/vinyl/internal/discovery/mocks"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGateway(t *testing.T) {
+	record := &vinyl.Record{
+		Domain:  "test.com.",
+		Address: "127.0.0.1",
+		TTL:     3000,
+	}
+
+	tests := map[string]struct {
+		Method string
+		Path   string
+		Body   string
+		Setup  func(*mocks.RecordStorer)
+		Status int
+	}{
+		"gets a record": {
+			Method: http.MethodGet,
+			Path:   "/v1/records/test.com.",
+			Setup: func(s *mocks.RecordStorer) {
+				s.EXPECT().GetRecord("test.com.").Return(record, nil)
+			},
+			Status: http.StatusOK,
+		},
+		"returns not found for a missing record": {
+			Method: http.MethodGet,
+			Path:   "/v1/records/test.com.",
+			Setup: func(s *mocks.RecordStorer) {
+				s.EXPECT().GetRecord("test.com.").Return(nil, &store.MissingRecordError{Domain: "test.com."})
+			},
+			Status: http.StatusNotFound,
+		},
+		"creates a record": {
+			Method: http.MethodPost,
+			Path:   "/v1/records",
+			Body:   `{"domain":"test.com.","address":"127.0.0.1","ttl":3000}`,
+			Setup: func(s *mocks.RecordStorer) {
+				s.EXPECT().CreateRecord("test.com.", "127.0.0.1", uint32(3000), mock.Anything).Return(record, nil)
+			},
+			Status: http.StatusOK,
+		},
+		"returns bad request for an invalid record": {
+			Method: http.MethodPost,
+			Path:   "/v1/records",
+			Body:   `{"domain":"test.com.","address":"bad","ttl":3000}`,
+			Setup: func(s *mocks.RecordStorer) {
+				s.EXPECT().CreateRecord(mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, &vinyl.InvalidRecordAddressError{Address: "bad"})
+			},
+			Status: http.StatusBadRequest,
+		},
+		"returns conflict for an existing record": {
+			Method: http.MethodPost,
+			Path:   "/v1/records",
+			Body:   `{"domain":"test.com.","address":"127.0.0.1","ttl":3000}`,
+			Setup: func(s *mocks.RecordStorer) {
+				s.EXPECT().CreateRecord(mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, &store.ExistingRecordError{Domain: "test.com."})
+			},
+			Status: http.StatusConflict,
+		},
+		"removes a record": {
+			Method: http.MethodDelete,
+			Path:   "/v1/records/test.com.",
+			Setup: func(s *mocks.RecordStorer) {
+				s.EXPECT().RemoveRecord("test.com.").Return(record, nil)
+			},
+			Status: http.StatusOK,
+		},
+		"lists records": {
+			Method: http.MethodGet,
+			Path:   "/v1/records",
+			Setup: func(s *mocks.RecordStorer) {
+				s.EXPECT().ListRecords(mock.Anything, mock.Anything).Return([]vinyl.Record{*record}, "", nil)
+			},
+			Status: http.StatusOK,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			recordStore := mocks.NewRecordStorer(t)
+			test.Setup(recordStore)
+
+			gateway, err := discovery.NewGateway(context.Background(), discovery.NewRecordsServer(recordStore))
+			assert.NoError(err)
+
+			req := httptest.NewRequest(test.Method, test.Path, strings.NewReader(test.Body))
+			rec := httptest.NewRecorder()
+
+			gateway.ServeHTTP(rec, req)
+
+			assert.Equal(test.Status, rec.Code, "status codes should be the same")
+
+			if test.Status != http.StatusOK {
+				return
+			}
+
+			body := map[string]interface{}{}
+			err = json.Unmarshal(rec.Body.Bytes(), &body)
+			assert.NoError(err, "body should be json")
+		})
+	}
+}
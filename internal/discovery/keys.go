@@ -0,0 +1,83 @@
+package discovery
+
+import (
+	"context"
+
+	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/platform-edn/vinyl/internal/proto"
+)
+
+// KeysServer manages the TSIG keys of the keyring the dns servers verify requests with. Secrets are never returned
+type KeysServer struct {
+	Keyring *dns.Keyring
+	proto.UnimplementedKeysServer
+}
+
+func NewKeysServer(keyring *dns.Keyring) *KeysServer {
+	return &KeysServer{
+		Keyring: keyring,
+	}
+}
+
+// SetKey adds a key or rotates the key with the same name
+func (server *KeysServer) SetKey(ctx context.Context, req *proto.SetKeyRequest) (*proto.SetKeyResponse, error) {
+	key := dns.Key{
+		Name:       req.Name,
+		Algorithm:  req.Algorithm,
+		Secret:     req.Secret,
+		Zones:      req.Zones,
+		Operations: req.Operations,
+	}
+
+	err := server.Keyring.Set(key)
+	if err != nil {
+		return nil, NewStatusError("SetKey", err)
+	}
+
+	set, err := server.Keyring.Key(key.Name)
+	if err != nil {
+		return nil, NewStatusError("SetKey", err)
+	}
+
+	resp := &proto.SetKeyResponse{
+		Key: convertKeysToProto(*set)[0],
+	}
+
+	return resp, nil
+}
+
+func (server *KeysServer) RemoveKey(ctx context.Context, req *proto.RemoveKeyRequest) (*proto.RemoveKeyResponse, error) {
+	key, err := server.Keyring.Remove(req.Name)
+	if err != nil {
+		return nil, NewStatusError("RemoveKey", err)
+	}
+
+	resp := &proto.RemoveKeyResponse{
+		Key: convertKeysToProto(*key)[0],
+	}
+
+	return resp, nil
+}
+
+func (server *KeysServer) ListKeys(ctx context.Context, req *proto.ListKeysRequest) (*proto.ListKeysResponse, error) {
+	resp := &proto.ListKeysResponse{
+		Keys: convertKeysToProto(server.Keyring.Keys()...),
+	}
+
+	return resp, nil
+}
+
+func convertKeysToProto(keys ...dns.Key) []*proto.Key {
+	protoKeys := []*proto.Key{}
+
+	for _, key := range keys {
+		protoKeys = append(protoKeys, &proto.Key{
+			Name:       key.Name,
+			Algorithm:  key.Algorithm,
+			Zones:      key.Zones,
+			Operations: key.Operations,
+		})
+	}
+
+	return protoKeys
+}
@@ -0,0 +1,46 @@
+package discovery_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/platform-edn/vinyl/internal/discovery"
+	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/platform-edn/vinyl/internal/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestKeysServer(t *testing.T) {
+	assert := assert.New(t)
+
+	keyring, err := dns.NewKeyring()
+	assert.NoError(err)
+	server := discovery.NewKeysServer(keyring)
+
+	set, err := server.SetKey(context.Background(), &proto.SetKeyRequest{
+		Name:       "DHCP",
+		Secret:     "c2VjcmV0c2VjcmV0c2VjcmV0c2VjcmV0",
+		Zones:      []string{"lab.example"},
+		Operations: []string{dns.OperationUpdate},
+	})
+	assert.NoError(err)
+	assert.Equal("dhcp.", set.Key.Name)
+	assert.Equal("hmac-sha256.", set.Key.Algorithm)
+	assert.Equal([]string{"lab.example."}, set.Key.Zones)
+
+	_, err = server.SetKey(context.Background(), &proto.SetKeyRequest{Name: "dhcp."})
+	assert.Equal(codes.InvalidArgument, status.Code(err))
+
+	list, err := server.ListKeys(context.Background(), &proto.ListKeysRequest{})
+	assert.NoError(err)
+	assert.Len(list.Keys, 1)
+
+	removed, err := server.RemoveKey(context.Background(), &proto.RemoveKeyRequest{Name: "dhcp."})
+	assert.NoError(err)
+	assert.Equal("dhcp.", removed.Key.Name)
+
+	_, err = server.RemoveKey(context.Background(), &proto.RemoveKeyRequest{Name: "dhcp."})
+	assert.Equal(codes.NotFound, status.Code(err))
+}
@@ -1,10 +1,16 @@
 package discovery
 
-import vinyl "github.com/platform-edn/vinyl/internal"
+import (
+	"context"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+)
 
 type RecordStorer interface {
-	CreateRecord(string, string, uint32) (*vinyl.Record, error)
+	CreateRecord(string, string, uint32, ...vinyl.RecordOption) (*vinyl.Record, error)
+	UpdateRecord(string, string, uint32, ...vinyl.RecordOption) (*vinyl.Record, error)
 	RemoveRecord(string) (*vinyl.Record, error)
-	ListRecords() ([]vinyl.Record, error)
+	ListRecords(vinyl.RecordFilter, vinyl.Page) ([]vinyl.Record, string, error)
 	GetRecord(string) (*vinyl.Record, error)
+	Watch(context.Context) <-chan vinyl.RecordEvent
 }
@@ -0,0 +1,158 @@
+package dns
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+
+	"github.com/miekg/dns"
+	"github.com/platform-edn/vinyl/internal/store"
+)
+
+// the operations an ACL restricts
+const (
+	ACLQuery     = "query"
+	ACLRecursion = "recursion"
+	ACLTransfer  = "transfer"
+)
+
+// refused counts the requests the ACL refused by operation, published at /debug/vars
+var refused = expvar.NewMap("dns_acl_refused")
+
+// ACL restricts which clients can query, have names resolved recursively and transfer zones. Recursion is checked
+// for recursive queries of names vinyl has no records for, the ones only a forwarder could answer. Transfers are
+// checked before the zone's own transfer policy
+type ACL struct {
+	Query     AccessList `yaml:"query"`
+	Recursion AccessList `yaml:"recursion"`
+	Transfer  AccessList `yaml:"transfer"`
+}
+
+// AccessList denies clients in Deny and allows the rest when Allow is empty, or only the clients in Allow
+type AccessList struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// NewACL parses the networks of acl, returning a copy that can be passed to WithACL
+func NewACL(acl ACL) (*ACL, error) {
+	err := acl.validate()
+	if err != nil {
+		return nil, fmt.Errorf("NewACL: %w", err)
+	}
+
+	return &acl, nil
+}
+
+// WithACL refuses the requests of clients acl doesn't allow. The acl comes from NewACL or a validated Config
+func WithACL(acl *ACL) HandlerOption {
+	return func(handler *RecordHandler) {
+		handler.ACL = *acl
+	}
+}
+
+// Allows reports whether the client at addr is allowed by the list. A list whose networks weren't parsed allows no
+// one rather than everyone
+func (list *AccessList) Allows(addr net.Addr) bool {
+	if len(list.allow) != len(list.Allow) || len(list.deny) != len(list.Deny) {
+		return false
+	}
+
+	if containsAddress(list.deny, addr) {
+		return false
+	}
+
+	return len(list.allow) == 0 || containsAddress(list.allow, addr)
+}
+
+func (acl *ACL) validate() error {
+	lists := []struct {
+		operation string
+		list      *AccessList
+	}{
+		{ACLQuery, &acl.Query},
+		{ACLRecursion, &acl.Recursion},
+		{ACLTransfer, &acl.Transfer},
+	}
+
+	for _, l := range lists {
+		var err error
+
+		l.list.allow, err = parseNetworks(l.list.Allow)
+		if err != nil {
+			return &InvalidACLConfigError{Operation: l.operation, Reason: err.Error()}
+		}
+
+		l.list.deny, err = parseNetworks(l.list.Deny)
+		if err != nil {
+			return &InvalidACLConfigError{Operation: l.operation, Reason: err.Error()}
+		}
+	}
+
+	return nil
+}
+
+// aclAllows checks the client against the ACL of operation, and refuses, counts and logs the request when it isn't
+// allowed
+func (handler *RecordHandler) aclAllows(w dns.ResponseWriter, request *dns.Msg, list *AccessList, operation string) bool {
+	if len(list.Allow) == 0 && len(list.Deny) == 0 || list.Allows(w.RemoteAddr()) {
+		return true
+	}
+
+	refused.Add(operation, 1)
+
+	name := ""
+	if len(request.Question) > 0 {
+		name = request.Question[0].Name
+	}
+
+	handler.RefusedResponse(w, NewResponse(request), fmt.Errorf("ServeDNS: %w", &RefusedByACLError{
+		Operation: operation,
+		Name:      name,
+		Client:    w.RemoteAddr().String(),
+	}))
+
+	return false
+}
+
+// needsRecursion reports whether a recursive query failed with err because vinyl has no records for the name and
+// isn't authoritative for it either
+func (handler *RecordHandler) needsRecursion(request *dns.Msg, err error) bool {
+	var (
+		missingRecord  *store.MissingRecordError
+		missingAddress *store.MissingAddressError
+	)
+
+	if !request.RecursionDesired || !errors.As(err, &missingRecord) && !errors.As(err, &missingAddress) {
+		return false
+	}
+
+	if handler.Journal == nil {
+		return true
+	}
+
+	for _, question := range request.Question {
+		if _, err := handler.Journal.Zone(question.Name); err == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+	for _, cidr := range cidrs {
+		network, err := parseNetwork(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
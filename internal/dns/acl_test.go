@@ -0,0 +1,179 @@
+package dns_test
+
+import (
+	"expvar"
+	"net"
+	"testing"
+
+	miekg "github.com/miekg/dns"
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// refusedCount is how many requests the acl has refused for operation
+func refusedCount(operation string) int64 {
+	count, ok := expvar.Get("dns_acl_refused").(*expvar.Map).Get(operation).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+
+	return count.Value()
+}
+
+func TestACL(t *testing.T) {
+	tests := map[string]struct {
+		ACL       dns.ACL
+		Unparsed  bool
+		Name      string
+		Qtype     uint16
+		Recursion bool
+		Rcode     int
+		Refused   string
+	}{
+		"answers every client without an acl": {
+			Name:  "web.lab.example.",
+			Qtype: miekg.TypeA,
+		},
+		"refuses queries from denied clients": {
+			ACL:     dns.ACL{Query: dns.AccessList{Deny: []string{"127.0.0.0/8"}}},
+			Name:    "web.lab.example.",
+			Qtype:   miekg.TypeA,
+			Rcode:   miekg.RcodeRefused,
+			Refused: dns.ACLQuery,
+		},
+		"refuses queries from clients that aren't allowed": {
+			ACL:     dns.ACL{Query: dns.AccessList{Allow: []string{"10.0.0.0/8"}}},
+			Name:    "web.lab.example.",
+			Qtype:   miekg.TypeA,
+			Rcode:   miekg.RcodeRefused,
+			Refused: dns.ACLQuery,
+		},
+		"denies clients the allow list contains": {
+			ACL:     dns.ACL{Query: dns.AccessList{Allow: []string{"127.0.0.0/8"}, Deny: []string{"127.0.0.1"}}},
+			Name:    "web.lab.example.",
+			Qtype:   miekg.TypeA,
+			Rcode:   miekg.RcodeRefused,
+			Refused: dns.ACLQuery,
+		},
+		"refuses every client of acls that weren't parsed": {
+			ACL:      dns.ACL{Query: dns.AccessList{Allow: []string{"127.0.0.0/8"}}},
+			Unparsed: true,
+			Name:     "web.lab.example.",
+			Qtype:    miekg.TypeA,
+			Rcode:    miekg.RcodeRefused,
+			Refused:  dns.ACLQuery,
+		},
+		"refuses recursion for names without records": {
+			ACL:       dns.ACL{Recursion: dns.AccessList{Deny: []string{"127.0.0.0/8"}}},
+			Name:      "example.com.",
+			Qtype:     miekg.TypeA,
+			Recursion: true,
+			Rcode:     miekg.RcodeRefused,
+			Refused:   dns.ACLRecursion,
+		},
+		"answers recursive queries for names with records": {
+			ACL:       dns.ACL{Recursion: dns.AccessList{Deny: []string{"127.0.0.0/8"}}},
+			Name:      "web.lab.example.",
+			Qtype:     miekg.TypeA,
+			Recursion: true,
+		},
+		"leaves names in the zones to the query acl": {
+			ACL:       dns.ACL{Recursion: dns.AccessList{Deny: []string{"127.0.0.0/8"}}},
+			Name:      "missing.lab.example.",
+			Qtype:     miekg.TypeA,
+			Recursion: true,
+			Rcode:     miekg.RcodeServerFailure,
+		},
+		"refuses transfers from denied clients": {
+			ACL:     dns.ACL{Transfer: dns.AccessList{Deny: []string{"127.0.0.0/8"}}},
+			Name:    "lab.example.",
+			Qtype:   miekg.TypeIXFR,
+			Rcode:   miekg.RcodeRefused,
+			Refused: dns.ACLTransfer,
+		},
+		"leaves allowed transfers to the zone's policy": {
+			ACL:   dns.ACL{Transfer: dns.AccessList{Allow: []string{"127.0.0.0/8"}}},
+			Name:  "lab.example.",
+			Qtype: miekg.TypeIXFR,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			config := &dns.Config{
+				Zones: []dns.Zone{{
+					Origin:      "lab.example.",
+					Nameservers: []string{"ns1.lab.example."},
+					Transfer:    dns.TransferPolicy{Allow: []string{"127.0.0.1"}},
+				}},
+			}
+			assert.NoError(config.Validate())
+
+			acl := &test.ACL
+			if !test.Unparsed {
+				var err error
+				acl, err = dns.NewACL(test.ACL)
+				assert.NoError(err)
+			}
+
+			mem := store.NewMemory(vinyl.Record{Domain: "web.lab.example.", Address: "10.0.0.1", TTL: 60})
+			journal := startJournal(t, mem, config.Zones)
+			address, _ := serve(t, dns.NewRecordHandler(mem, dns.WithJournal(journal), dns.WithACL(acl)))
+
+			request := &miekg.Msg{}
+			request.SetQuestion(test.Name, test.Qtype)
+			request.RecursionDesired = test.Recursion
+			if test.Qtype == miekg.TypeIXFR {
+				request.SetIxfr(test.Name, 0, "ns1.lab.example.", "hostmaster.lab.example.")
+			}
+
+			before := refusedCount(test.Refused)
+
+			client := &miekg.Client{Net: "tcp"}
+			response, _, err := client.Exchange(request, address)
+			assert.NoError(err)
+			assert.Equal(miekg.RcodeToString[test.Rcode], miekg.RcodeToString[response.Rcode])
+
+			if test.Refused != "" {
+				assert.Equal(before+1, refusedCount(test.Refused))
+			}
+		})
+	}
+}
+
+func TestNewACL(t *testing.T) {
+	tests := map[string]struct {
+		ACL dns.ACL
+		Err error
+	}{
+		"parses acls": {
+			ACL: dns.ACL{Query: dns.AccessList{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.1"}}},
+		},
+		"returns InvalidACLConfigError for bad networks": {
+			ACL: dns.ACL{Transfer: dns.AccessList{Deny: []string{"10.0.0.0/33"}}},
+			Err: &dns.InvalidACLConfigError{Operation: dns.ACLTransfer, Reason: "10.0.0.0/33 is not a valid cidr"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			acl, err := dns.NewACL(test.ACL)
+			if test.Err != nil {
+				assert.ErrorContains(err, test.Err.Error())
+				assert.Nil(acl)
+				return
+			}
+
+			assert.NoError(err)
+			assert.False(acl.Query.Allows(&net.TCPAddr{IP: net.ParseIP("10.0.0.1")}))
+			assert.True(acl.Query.Allows(&net.TCPAddr{IP: net.ParseIP("10.0.0.2")}))
+			assert.False(test.ACL.Query.Allows(&net.TCPAddr{IP: net.ParseIP("10.0.0.2")}))
+		})
+	}
+}
@@ -0,0 +1,433 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultZoneTTL = 3600
+	DefaultRefresh = 3600
+	DefaultRetry   = 600
+	DefaultExpire  = 604800
+	DefaultMinimum = 60
+
+	DefaultKSKLifetime       = 365 * 24 * time.Hour
+	DefaultZSKLifetime       = 30 * 24 * time.Hour
+	DefaultSignatureValidity = 14 * 24 * time.Hour
+)
+
+// the algorithms zones can be signed with
+const (
+	AlgorithmECDSAP256SHA256 = "ecdsap256sha256"
+	AlgorithmEd25519         = "ed25519"
+)
+
+// Config describes the zones vinyl is authoritative for, the TSIG keys clients can sign requests with, the views
+// clients are answered from and the clients that are answered at all
+type Config struct {
+	Zones       []Zone          `yaml:"zones"`
+	Secondaries []SecondaryZone `yaml:"secondaries"`
+	Keys        []Key           `yaml:"keys"`
+	Views       []View          `yaml:"views"`
+	ACL         ACL             `yaml:"acl"`
+}
+
+// Zone is served with a generated SOA and NS records around the store's records below Origin
+type Zone struct {
+	Origin      string         `yaml:"origin"`
+	Nameservers []string       `yaml:"nameservers"`
+	Mailbox     string         `yaml:"mailbox"`
+	TTL         uint32         `yaml:"ttl"`
+	Refresh     uint32         `yaml:"refresh"`
+	Retry       uint32         `yaml:"retry"`
+	Expire      uint32         `yaml:"expire"`
+	Minimum     uint32         `yaml:"minimum"`
+	Transfer    TransferPolicy `yaml:"transfer"`
+	Update      UpdatePolicy   `yaml:"update"`
+	// Notify are the host:port addresses of the secondaries sent a NOTIFY when the zone changes, the port
+	// defaults to 53
+	Notify []string     `yaml:"notify"`
+	DNSSEC DNSSECPolicy `yaml:"dnssec"`
+}
+
+// TransferPolicy restricts zone transfers to clients in Allow and requests signed with one of Keys. When both are
+// set both must match, and a zone without either can't be transferred
+type TransferPolicy struct {
+	Allow    []string `yaml:"allow"`
+	Keys     []string `yaml:"keys"`
+	networks []*net.IPNet
+}
+
+// SecondaryZone is transferred from Primary, a host:port where the port defaults to 53, and served read only.
+// Requests to the primary are signed with Key when it is set
+type SecondaryZone struct {
+	Origin  string `yaml:"origin"`
+	Primary string `yaml:"primary"`
+	Key     string `yaml:"key"`
+}
+
+// UpdatePolicy lists the keys dynamic updates to a zone can be signed with. Unsigned updates are always refused
+type UpdatePolicy struct {
+	Keys []string `yaml:"keys"`
+}
+
+// DNSSECPolicy signs a zone as it is served. Keys are generated when the zone has none and replaced when their
+// lifetime is up, with the successor published a signature validity before it takes over and the old key kept
+// published for a signature validity after. An old KSK is only removed once the parent publishes the DS of its
+// successor. Keys are kept in KeyDirectory, which is required so they survive a restart. Every client is answered
+// from the same signed copy of the zone, so views don't apply to names inside it
+type DNSSECPolicy struct {
+	Enabled           bool          `yaml:"enabled"`
+	Algorithm         string        `yaml:"algorithm"`
+	NSEC3             bool          `yaml:"nsec3"`
+	KSKLifetime       time.Duration `yaml:"ksk_lifetime"`
+	ZSKLifetime       time.Duration `yaml:"zsk_lifetime"`
+	SignatureValidity time.Duration `yaml:"signature_validity"`
+	KeyDirectory      string        `yaml:"key_directory"`
+}
+
+// Key is a TSIG key. Names are domain names, secrets are base64 and the algorithm is hmac-sha256 or hmac-sha512.
+// A key can be bound to Zones and to Operations, transfer, update or notify, and can be used for every zone or
+// operation when they are empty
+type Key struct {
+	Name       string   `yaml:"name"`
+	Algorithm  string   `yaml:"algorithm"`
+	Secret     string   `yaml:"secret"`
+	Zones      []string `yaml:"zones"`
+	Operations []string `yaml:"operations"`
+}
+
+// View answers the clients that match it from its own records instead of the store's. A client matches when its
+// address is in one of Sources, its request is signed with one of Keys or it was received on one of Listeners, given
+// as :port since servers listen on every address of a port. Views are tried in order and clients that match none are answered from the store. An
+// Overlay view falls back to the store for names it has no record for. Names inside signed zones are answered from
+// the store whatever view a client matches
+type View struct {
+	Name      string   `yaml:"name"`
+	Sources   []string `yaml:"sources"`
+	Keys      []string `yaml:"keys"`
+	Listeners []string `yaml:"listeners"`
+	Overlay   bool     `yaml:"overlay"`
+	networks  []*net.IPNet
+}
+
+// LoadConfig reads a yaml config file and fills in the defaults
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("LoadConfig: %w", err)
+	}
+
+	config := &Config{}
+	err = yaml.Unmarshal(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("LoadConfig: %w", err)
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("LoadConfig: %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// Validate checks the config, puts names in canonical form and fills in defaults
+func (config *Config) Validate() error {
+	keys := map[string]struct{}{}
+	for i := range config.Keys {
+		key := &config.Keys[i]
+
+		err := key.Validate()
+		if err != nil {
+			return fmt.Errorf("Validate: %w", err)
+		}
+
+		keys[key.Name] = struct{}{}
+	}
+
+	origins := map[string]struct{}{}
+	for i := range config.Zones {
+		err := config.Zones[i].validate(keys)
+		if err != nil {
+			return fmt.Errorf("Validate: %w", err)
+		}
+
+		origins[config.Zones[i].Origin] = struct{}{}
+	}
+
+	for i := range config.Secondaries {
+		zone := &config.Secondaries[i]
+
+		err := zone.validate(keys)
+		if err != nil {
+			return fmt.Errorf("Validate: %w", err)
+		}
+
+		if _, exist := origins[zone.Origin]; exist {
+			return fmt.Errorf("Validate: %w", &InvalidZoneConfigError{Origin: zone.Origin, Reason: "a zone can't be both primary and secondary"})
+		}
+		origins[zone.Origin] = struct{}{}
+	}
+
+	names := map[string]struct{}{}
+	for i := range config.Views {
+		view := &config.Views[i]
+
+		err := view.validate(keys)
+		if err != nil {
+			return fmt.Errorf("Validate: %w", err)
+		}
+
+		if _, exist := names[view.Name]; exist {
+			return fmt.Errorf("Validate: %w", &InvalidViewConfigError{Name: view.Name, Reason: "the name is used by another view"})
+		}
+		names[view.Name] = struct{}{}
+	}
+
+	err := config.ACL.validate()
+	if err != nil {
+		return fmt.Errorf("Validate: %w", err)
+	}
+
+	return nil
+}
+
+func (zone *Zone) validate(keys map[string]struct{}) error {
+	zone.Origin = canonicalName(zone.Origin)
+	if _, ok := dns.IsDomainName(zone.Origin); !ok || zone.Origin == "." {
+		return &InvalidZoneConfigError{Origin: zone.Origin, Reason: "the origin is not a domain name"}
+	}
+
+	if len(zone.Nameservers) == 0 {
+		return &InvalidZoneConfigError{Origin: zone.Origin, Reason: "at least one nameserver is needed"}
+	}
+	for i, ns := range zone.Nameservers {
+		zone.Nameservers[i] = canonicalName(ns)
+	}
+
+	if zone.Mailbox == "" {
+		zone.Mailbox = "hostmaster." + zone.Origin
+	}
+	zone.Mailbox = dns.Fqdn(zone.Mailbox)
+
+	defaults := []struct {
+		value    *uint32
+		fallback uint32
+	}{
+		{&zone.TTL, DefaultZoneTTL},
+		{&zone.Refresh, DefaultRefresh},
+		{&zone.Retry, DefaultRetry},
+		{&zone.Expire, DefaultExpire},
+		{&zone.Minimum, DefaultMinimum},
+	}
+	for _, d := range defaults {
+		if *d.value == 0 {
+			*d.value = d.fallback
+		}
+	}
+
+	zone.Transfer.networks = []*net.IPNet{}
+	for _, cidr := range zone.Transfer.Allow {
+		network, err := parseNetwork(cidr)
+		if err != nil {
+			return &InvalidZoneConfigError{Origin: zone.Origin, Reason: err.Error()}
+		}
+
+		zone.Transfer.networks = append(zone.Transfer.networks, network)
+	}
+
+	for i, address := range zone.Notify {
+		if address == "" {
+			return &InvalidZoneConfigError{Origin: zone.Origin, Reason: "a notify address is empty"}
+		}
+
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			zone.Notify[i] = net.JoinHostPort(address, "53")
+		}
+	}
+
+	for i, name := range zone.Transfer.Keys {
+		name = canonicalName(name)
+		if _, exist := keys[name]; !exist {
+			return &InvalidZoneConfigError{Origin: zone.Origin, Reason: fmt.Sprintf("transfer key %s is not defined", name)}
+		}
+
+		zone.Transfer.Keys[i] = name
+	}
+
+	for i, name := range zone.Update.Keys {
+		name = canonicalName(name)
+		if _, exist := keys[name]; !exist {
+			return &InvalidZoneConfigError{Origin: zone.Origin, Reason: fmt.Sprintf("update key %s is not defined", name)}
+		}
+
+		zone.Update.Keys[i] = name
+	}
+
+	err := zone.DNSSEC.validate(zone.Origin)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (policy *DNSSECPolicy) validate(origin string) error {
+	if !policy.Enabled {
+		return nil
+	}
+
+	// ptr answers are made up from the forward records so there is nothing to build the denial chain from
+	if vinyl.IsSubdomain(origin, "in-addr.arpa.") || vinyl.IsSubdomain(origin, "ip6.arpa.") {
+		return &InvalidZoneConfigError{Origin: origin, Reason: "reverse zones can't be signed"}
+	}
+
+	policy.Algorithm = strings.ToLower(policy.Algorithm)
+	if policy.Algorithm == "" {
+		policy.Algorithm = AlgorithmECDSAP256SHA256
+	}
+	if policy.Algorithm != AlgorithmECDSAP256SHA256 && policy.Algorithm != AlgorithmEd25519 {
+		return &InvalidZoneConfigError{Origin: origin, Reason: fmt.Sprintf("dnssec algorithm %s is not %s or %s", policy.Algorithm, AlgorithmECDSAP256SHA256, AlgorithmEd25519)}
+	}
+
+	defaults := []struct {
+		value    *time.Duration
+		fallback time.Duration
+	}{
+		{&policy.KSKLifetime, DefaultKSKLifetime},
+		{&policy.ZSKLifetime, DefaultZSKLifetime},
+		{&policy.SignatureValidity, DefaultSignatureValidity},
+	}
+	for _, d := range defaults {
+		if *d.value == 0 {
+			*d.value = d.fallback
+		}
+	}
+
+	// a key has to outlive the overlap with the keys before and after it
+	if policy.KSKLifetime < 2*policy.SignatureValidity || policy.ZSKLifetime < 2*policy.SignatureValidity {
+		return &InvalidZoneConfigError{Origin: origin, Reason: "dnssec key lifetimes must be at least twice the signature validity"}
+	}
+
+	// keys generated again on every start wouldn't match the DS the parent publishes
+	if policy.KeyDirectory == "" {
+		return &InvalidZoneConfigError{Origin: origin, Reason: "dnssec needs a key_directory"}
+	}
+
+	return nil
+}
+
+func (zone *SecondaryZone) validate(keys map[string]struct{}) error {
+	zone.Origin = canonicalName(zone.Origin)
+	if _, ok := dns.IsDomainName(zone.Origin); !ok || zone.Origin == "." {
+		return &InvalidZoneConfigError{Origin: zone.Origin, Reason: "the origin is not a domain name"}
+	}
+
+	if zone.Primary == "" {
+		return &InvalidZoneConfigError{Origin: zone.Origin, Reason: "the primary is not set"}
+	}
+	if _, _, err := net.SplitHostPort(zone.Primary); err != nil {
+		zone.Primary = net.JoinHostPort(zone.Primary, "53")
+	}
+
+	if zone.Key != "" {
+		zone.Key = canonicalName(zone.Key)
+		if _, exist := keys[zone.Key]; !exist {
+			return &InvalidZoneConfigError{Origin: zone.Origin, Reason: fmt.Sprintf("key %s is not defined", zone.Key)}
+		}
+	}
+
+	return nil
+}
+
+func (view *View) validate(keys map[string]struct{}) error {
+	err := view.parse()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range view.Keys {
+		if _, exist := keys[name]; !exist {
+			return &InvalidViewConfigError{Name: view.Name, Reason: fmt.Sprintf("key %s is not defined", name)}
+		}
+	}
+
+	return nil
+}
+
+// parse checks view selects some clients and reads its sources, so it can be matched against requests
+func (view *View) parse() error {
+	if view.Name == "" {
+		return &InvalidViewConfigError{Name: view.Name, Reason: "the name is not set"}
+	}
+
+	if len(view.Sources) == 0 && len(view.Keys) == 0 && len(view.Listeners) == 0 {
+		return &InvalidViewConfigError{Name: view.Name, Reason: "at least one source, key or listener is needed"}
+	}
+
+	networks := []*net.IPNet{}
+	for _, cidr := range view.Sources {
+		network, err := parseNetwork(cidr)
+		if err != nil {
+			return &InvalidViewConfigError{Name: view.Name, Reason: err.Error()}
+		}
+
+		networks = append(networks, network)
+	}
+
+	for i, listener := range view.Listeners {
+		host, port, err := net.SplitHostPort(listener)
+		number, parseErr := strconv.ParseUint(port, 10, 16)
+		if err != nil || host != "" || parseErr != nil {
+			return &InvalidViewConfigError{Name: view.Name, Reason: fmt.Sprintf("listener %s is not a :port", listener)}
+		}
+
+		view.Listeners[i] = fmt.Sprintf(":%d", number)
+	}
+
+	for i, name := range view.Keys {
+		view.Keys[i] = canonicalName(name)
+	}
+	view.networks = networks
+
+	return nil
+}
+
+// parseNetwork reads a cidr, taking a bare address as a network of just that address
+func parseNetwork(cidr string) (*net.IPNet, error) {
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return nil, fmt.Errorf("%s is not a valid cidr", cidr)
+		}
+
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid cidr", cidr)
+	}
+
+	return network, nil
+}
+
+// canonicalName puts a name in the lowercase fully qualified form RFC 4034 compares names in
+func canonicalName(name string) string {
+	return strings.ToLower(dns.Fqdn(name))
+}
@@ -0,0 +1,164 @@
+package dns_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, config string) string {
+	path := filepath.Join(t.TempDir(), "dns.yaml")
+
+	err := os.WriteFile(path, []byte(config), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	config, err := dns.LoadConfig(writeConfig(t, `
+zones:
+  - origin: Lab.Example
+    nameservers: [ns1.lab.example]
+    refresh: 900
+    notify: [10.0.0.54, "[fd00::54]:5353"]
+    transfer:
+      allow: [10.0.0.0/8, 192.168.1.53]
+      keys: [secondary]
+    dnssec:
+      enabled: true
+      zsk_lifetime: 720h
+      key_directory: /var/lib/vinyl/keys
+secondaries:
+  - origin: remote.example
+    primary: 10.1.0.53
+    key: secondary
+keys:
+  - name: secondary
+    secret: c2VjcmV0c2VjcmV0c2VjcmV0c2VjcmV0
+views:
+  - name: internal
+    sources: [10.0.0.0/8]
+    keys: [Secondary]
+    listeners: [":853"]
+    overlay: true
+`))
+	assert.NoError(err)
+
+	zone := config.Zones[0]
+	assert.Equal("lab.example.", zone.Origin)
+	assert.Equal([]string{"ns1.lab.example."}, zone.Nameservers)
+	assert.Equal("hostmaster.lab.example.", zone.Mailbox)
+	assert.Equal(uint32(900), zone.Refresh)
+	assert.Equal(uint32(dns.DefaultExpire), zone.Expire)
+	assert.Equal([]string{"secondary."}, zone.Transfer.Keys)
+	assert.Equal([]string{"10.0.0.54:53", "[fd00::54]:5353"}, zone.Notify)
+	assert.Equal(dns.AlgorithmECDSAP256SHA256, zone.DNSSEC.Algorithm)
+	assert.Equal(dns.DefaultKSKLifetime, zone.DNSSEC.KSKLifetime)
+	assert.Equal(720*time.Hour, zone.DNSSEC.ZSKLifetime)
+	assert.Equal("hmac-sha256.", config.Keys[0].Algorithm)
+	assert.Equal([]dns.SecondaryZone{{Origin: "remote.example.", Primary: "10.1.0.53:53", Key: "secondary."}}, config.Secondaries)
+	assert.Equal([]string{"secondary."}, config.Views[0].Keys)
+	assert.True(config.Views[0].Overlay)
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := map[string]struct {
+		Config string
+		Err    error
+	}{
+		"returns InvalidZoneConfigError without nameservers": {
+			Config: "zones: [{origin: lab.example}]",
+			Err:    &dns.InvalidZoneConfigError{Origin: "lab.example.", Reason: "at least one nameserver is needed"},
+		},
+		"returns InvalidZoneConfigError for bad networks": {
+			Config: "zones: [{origin: lab.example, nameservers: [ns1.lab.example], transfer: {allow: [10.0.0.0/33]}}]",
+			Err:    &dns.InvalidZoneConfigError{Origin: "lab.example.", Reason: "10.0.0.0/33 is not a valid cidr"},
+		},
+		"returns InvalidZoneConfigError for undefined keys": {
+			Config: "zones: [{origin: lab.example, nameservers: [ns1.lab.example], transfer: {keys: [missing]}}]",
+			Err:    &dns.InvalidZoneConfigError{Origin: "lab.example.", Reason: "transfer key missing. is not defined"},
+		},
+		"returns InvalidZoneConfigError for undefined update keys": {
+			Config: "zones: [{origin: lab.example, nameservers: [ns1.lab.example], update: {keys: [dhcp]}}]",
+			Err:    &dns.InvalidZoneConfigError{Origin: "lab.example.", Reason: "update key dhcp. is not defined"},
+		},
+		"returns InvalidZoneConfigError for secondaries without a primary": {
+			Config: "secondaries: [{origin: remote.example}]",
+			Err:    &dns.InvalidZoneConfigError{Origin: "remote.example.", Reason: "the primary is not set"},
+		},
+		"returns InvalidZoneConfigError for zones that are primary and secondary": {
+			Config: "zones: [{origin: lab.example, nameservers: [ns1.lab.example]}]\nsecondaries: [{origin: lab.example, primary: 10.1.0.53}]",
+			Err:    &dns.InvalidZoneConfigError{Origin: "lab.example.", Reason: "a zone can't be both primary and secondary"},
+		},
+		"returns InvalidZoneConfigError for unknown dnssec algorithms": {
+			Config: "zones: [{origin: lab.example, nameservers: [ns1.lab.example], dnssec: {enabled: true, algorithm: rsasha1}}]",
+			Err:    &dns.InvalidZoneConfigError{Origin: "lab.example.", Reason: "dnssec algorithm rsasha1 is not ecdsap256sha256 or ed25519"},
+		},
+		"returns InvalidZoneConfigError for keys outliving their signatures by too little": {
+			Config: "zones: [{origin: lab.example, nameservers: [ns1.lab.example], dnssec: {enabled: true, zsk_lifetime: 24h}}]",
+			Err:    &dns.InvalidZoneConfigError{Origin: "lab.example.", Reason: "dnssec key lifetimes must be at least twice the signature validity"},
+		},
+		"returns InvalidZoneConfigError for signed zones without a key directory": {
+			Config: "zones: [{origin: lab.example, nameservers: [ns1.lab.example], dnssec: {enabled: true}}]",
+			Err:    &dns.InvalidZoneConfigError{Origin: "lab.example.", Reason: "dnssec needs a key_directory"},
+		},
+		"returns InvalidZoneConfigError for signed reverse zones": {
+			Config: "zones: [{origin: 10.in-addr.arpa, nameservers: [ns1.lab.example], dnssec: {enabled: true}}]",
+			Err:    &dns.InvalidZoneConfigError{Origin: "10.in-addr.arpa.", Reason: "reverse zones can't be signed"},
+		},
+		"returns InvalidViewConfigError for views without a name": {
+			Config: "views: [{sources: [10.0.0.0/8]}]",
+			Err:    &dns.InvalidViewConfigError{Reason: "the name is not set"},
+		},
+		"returns InvalidViewConfigError for views nothing matches": {
+			Config: "views: [{name: internal}]",
+			Err:    &dns.InvalidViewConfigError{Name: "internal", Reason: "at least one source, key or listener is needed"},
+		},
+		"returns InvalidViewConfigError for bad view sources": {
+			Config: "views: [{name: internal, sources: [10.0.0.0/33]}]",
+			Err:    &dns.InvalidViewConfigError{Name: "internal", Reason: "10.0.0.0/33 is not a valid cidr"},
+		},
+		"returns InvalidViewConfigError for undefined view keys": {
+			Config: "views: [{name: internal, keys: [missing]}]",
+			Err:    &dns.InvalidViewConfigError{Name: "internal", Reason: "key missing. is not defined"},
+		},
+		"returns InvalidViewConfigError for bad listeners": {
+			Config: "views: [{name: internal, listeners: [ns1.lab.example]}]",
+			Err:    &dns.InvalidViewConfigError{Name: "internal", Reason: "listener ns1.lab.example is not a :port"},
+		},
+		"returns InvalidViewConfigError for listeners with an address": {
+			Config: "views: [{name: internal, listeners: [\"10.0.0.53:53\"]}]",
+			Err:    &dns.InvalidViewConfigError{Name: "internal", Reason: "listener 10.0.0.53:53 is not a :port"},
+		},
+		"returns InvalidViewConfigError for views with the same name": {
+			Config: "views: [{name: internal, sources: [10.0.0.0/8]}, {name: internal, sources: [fd00::/8]}]",
+			Err:    &dns.InvalidViewConfigError{Name: "internal", Reason: "the name is used by another view"},
+		},
+		"returns InvalidACLConfigError for bad networks": {
+			Config: "acl: {recursion: {deny: [0.0.0.0/40]}}",
+			Err:    &dns.InvalidACLConfigError{Operation: "recursion", Reason: "0.0.0.0/40 is not a valid cidr"},
+		},
+		"returns InvalidKeyError for empty secrets": {
+			Config: "keys: [{name: secondary}]",
+			Err:    &dns.InvalidKeyError{Name: "secondary.", Reason: "the secret is empty"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			_, err := dns.LoadConfig(writeConfig(t, test.Config))
+			assert.ErrorContains(err, test.Err.Error())
+		})
+	}
+}
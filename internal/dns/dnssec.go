@@ -0,0 +1,487 @@
+package dns
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+	vinyl "github.com/platform-edn/vinyl/internal"
+)
+
+// ednsSize is the udp payload size advertised in answers from signed zones, small enough to avoid fragmentation
+const ednsSize = 1232
+
+// zoneChain is a zone's names in canonical order, RFC 4034 section 6.1, which the NSEC or NSEC3 records proving
+// what isn't in the zone are made from. It is rebuilt whenever the zone's serial changes
+type zoneChain struct {
+	zone    Zone
+	serial  uint32
+	records map[string]vinyl.Record
+	// names are the apex and the names holding records, nodes also include the empty non-terminals between them
+	names  []string
+	nodes  map[string]struct{}
+	hashes []string
+	hashed map[string]string
+}
+
+func newZoneChain(zone Zone, records []vinyl.Record, serial uint32) *zoneChain {
+	chain := &zoneChain{
+		zone:    zone,
+		serial:  serial,
+		records: map[string]vinyl.Record{},
+		names:   []string{zone.Origin},
+		nodes:   map[string]struct{}{zone.Origin: {}},
+		hashes:  []string{},
+		hashed:  map[string]string{},
+	}
+
+	for _, record := range records {
+		name := canonicalName(record.Domain)
+		if name == zone.Origin {
+			chain.records[name] = record
+			continue
+		}
+
+		if _, exist := chain.records[name]; !exist {
+			chain.names = append(chain.names, name)
+		}
+		chain.records[name] = record
+
+		for node := name; node != zone.Origin; node = parentName(node) {
+			chain.nodes[node] = struct{}{}
+		}
+	}
+
+	sort.Slice(chain.names, func(i, j int) bool {
+		return canonicalLess(chain.names[i], chain.names[j])
+	})
+
+	if zone.DNSSEC.NSEC3 {
+		for node := range chain.nodes {
+			hash := nsec3Hash(node)
+			chain.hashes = append(chain.hashes, hash)
+			chain.hashed[hash] = node
+		}
+
+		sort.Strings(chain.hashes)
+	}
+
+	return chain
+}
+
+// types returns the types of the rrsets at name
+func (chain *zoneChain) types(name string) []uint16 {
+	types := []uint16{}
+	if name == chain.zone.Origin {
+		types = append(types, dns.TypeSOA, dns.TypeNS, dns.TypeDNSKEY)
+		if chain.zone.DNSSEC.NSEC3 {
+			types = append(types, dns.TypeNSEC3PARAM)
+		}
+	}
+
+	if record, exist := chain.records[name]; exist {
+		types = append(types, recordType(record))
+	}
+
+	return types
+}
+
+// closestEncloser returns the closest ancestor of name in the zone
+func (chain *zoneChain) closestEncloser(name string) string {
+	for name != chain.zone.Origin {
+		name = parentName(name)
+		if _, exist := chain.nodes[name]; exist {
+			return name
+		}
+	}
+
+	return chain.zone.Origin
+}
+
+// deny returns the records proving why an answer is missing or synthesized, RFC 4035 section 3.1.3 and RFC 5155
+// section 7.2. Closest is only set when name doesn't exist and wildcard when an rrset was synthesized from it
+func (chain *zoneChain) deny(name string, closest string, wildcard string, nodata bool) []dns.RR {
+	if chain.zone.DNSSEC.NSEC3 {
+		return chain.denyNSEC3(name, closest, wildcard, nodata)
+	}
+
+	rrs := []dns.RR{}
+	switch {
+	case closest == "":
+		if _, exist := chain.records[name]; exist || name == chain.zone.Origin {
+			rrs = append(rrs, chain.nsec(name))
+		} else {
+			// an empty non-terminal sorts before the names below it so the NSEC in front of it proves it is empty
+			rrs = append(rrs, chain.coveringNSEC(name))
+		}
+	case wildcard == "":
+		rrs = append(rrs, chain.coveringNSEC(name), chain.coveringNSEC("*."+closest))
+	case nodata:
+		rrs = append(rrs, chain.coveringNSEC(name), chain.nsec(wildcard))
+	default:
+		rrs = append(rrs, chain.coveringNSEC(name))
+	}
+
+	return uniqueOwners(rrs)
+}
+
+func (chain *zoneChain) denyNSEC3(name string, closest string, wildcard string, nodata bool) []dns.RR {
+	rrs := []dns.RR{}
+	switch {
+	case closest == "":
+		rrs = append(rrs, chain.nsec3(nsec3Hash(name)))
+	case wildcard == "":
+		rrs = append(rrs, chain.nsec3(nsec3Hash(closest)), chain.coveringNSEC3(nextCloser(name, closest)), chain.coveringNSEC3("*."+closest))
+	case nodata:
+		rrs = append(rrs, chain.nsec3(nsec3Hash(closest)), chain.coveringNSEC3(nextCloser(name, closest)), chain.nsec3(nsec3Hash(wildcard)))
+	default:
+		rrs = append(rrs, chain.coveringNSEC3(nextCloser(name, closest)))
+	}
+
+	return uniqueOwners(rrs)
+}
+
+// nsec returns the NSEC record of a name in the chain
+func (chain *zoneChain) nsec(name string) dns.RR {
+	i := sort.Search(len(chain.names), func(i int) bool {
+		return !canonicalLess(chain.names[i], name)
+	})
+
+	return chain.nsecAt(i)
+}
+
+// coveringNSEC returns the NSEC record whose owner and next name are either side of a name that isn't in the chain
+func (chain *zoneChain) coveringNSEC(name string) dns.RR {
+	i := sort.Search(len(chain.names), func(i int) bool {
+		return !canonicalLess(chain.names[i], name)
+	})
+
+	return chain.nsecAt((i - 1 + len(chain.names)) % len(chain.names))
+}
+
+func (chain *zoneChain) nsecAt(i int) dns.RR {
+	name := chain.names[i]
+
+	return &dns.NSEC{
+		Hdr: dns.RR_Header{
+			Name:   name,
+			Rrtype: dns.TypeNSEC,
+			Class:  dns.ClassINET,
+			Ttl:    chain.negativeTTL(),
+		},
+		NextDomain: chain.names[(i+1)%len(chain.names)],
+		TypeBitMap: typeBitmap(append(chain.types(name), dns.TypeRRSIG, dns.TypeNSEC)),
+	}
+}
+
+// nsec3 returns the NSEC3 record of a hash in the chain
+func (chain *zoneChain) nsec3(hash string) dns.RR {
+	return chain.nsec3At(sort.SearchStrings(chain.hashes, hash))
+}
+
+// coveringNSEC3 returns the NSEC3 record whose owner and next hash are either side of the hash of a name that isn't
+// in the zone
+func (chain *zoneChain) coveringNSEC3(name string) dns.RR {
+	i := sort.SearchStrings(chain.hashes, nsec3Hash(name))
+
+	return chain.nsec3At((i - 1 + len(chain.hashes)) % len(chain.hashes))
+}
+
+func (chain *zoneChain) nsec3At(i int) dns.RR {
+	hash := chain.hashes[i]
+
+	types := chain.types(chain.hashed[hash])
+	if len(types) > 0 {
+		types = append(types, dns.TypeRRSIG)
+	}
+
+	return &dns.NSEC3{
+		Hdr: dns.RR_Header{
+			Name:   strings.ToLower(hash) + "." + chain.zone.Origin,
+			Rrtype: dns.TypeNSEC3,
+			Class:  dns.ClassINET,
+			Ttl:    chain.negativeTTL(),
+		},
+		Hash:       dns.SHA1,
+		HashLength: 20,
+		NextDomain: chain.hashes[(i+1)%len(chain.hashes)],
+		TypeBitMap: typeBitmap(types),
+	}
+}
+
+// negativeTTL is how long answers that something doesn't exist are cached, RFC 2308 and RFC 9077
+func (chain *zoneChain) negativeTTL() uint32 {
+	if chain.zone.Minimum < chain.zone.TTL {
+		return chain.zone.Minimum
+	}
+
+	return chain.zone.TTL
+}
+
+// signedZone returns the signed zone a single question is for
+func (handler *RecordHandler) signedZone(request *dns.Msg) *Zone {
+	if handler.Signer == nil || handler.Journal == nil || len(request.Question) != 1 {
+		return nil
+	}
+
+	zone, err := handler.Journal.Zone(request.Question[0].Name)
+	if err != nil || !handler.Signer.Signs(zone.Origin) {
+		return nil
+	}
+
+	return zone
+}
+
+// SignedAnswer answers a question for a signed zone from the journal's copy of the zone, so answers and the
+// proofs of what isn't in the zone agree. Names that don't exist get NXDOMAIN and types that don't exist an empty
+// answer, and RRSIG and NSEC or NSEC3 records are added when the request sets the DO bit. Views aren't consulted
+// since there is only one signed copy of the zone
+func (handler *RecordHandler) SignedAnswer(w dns.ResponseWriter, request *dns.Msg, zone *Zone) {
+	response := NewResponse(request)
+	response.Authoritative = true
+
+	dnssecOK := false
+	size := dns.MinMsgSize
+	if opt := request.IsEdns0(); opt != nil {
+		dnssecOK = opt.Do()
+		if int(opt.UDPSize()) > size {
+			size = int(opt.UDPSize())
+		}
+
+		response.SetEdns0(ednsSize, dnssecOK)
+	}
+
+	err := handler.signedAnswer(response, zone, request.Question[0], dnssecOK)
+	if err != nil {
+		handler.ServerErrorResponse(w, response, err)
+		return
+	}
+
+	if _, udp := w.RemoteAddr().(*net.UDPAddr); udp {
+		response.Truncate(size)
+	}
+	setTsig(w, request, response)
+
+	err = w.WriteMsg(response)
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func (handler *RecordHandler) signedAnswer(response *dns.Msg, zone *Zone, question dns.Question, dnssecOK bool) error {
+	chain, err := handler.Signer.chain(zone.Origin)
+	if err != nil {
+		return fmt.Errorf("signedAnswer: %w", err)
+	}
+
+	name := canonicalName(question.Name)
+	owner := name
+	closest, wildcard := "", ""
+
+	if _, exist := chain.nodes[name]; !exist {
+		closest = chain.closestEncloser(name)
+		wildcard = "*." + closest
+		owner = wildcard
+
+		if _, exist := chain.nodes[wildcard]; !exist {
+			response.Rcode = dns.RcodeNameError
+			return handler.negativeAnswer(response, chain, chain.deny(name, closest, "", false), dnssecOK)
+		}
+	}
+
+	rrset, err := handler.rrset(chain, owner, question.Qtype)
+	if err != nil {
+		return fmt.Errorf("signedAnswer: %w", err)
+	}
+
+	if len(rrset) == 0 {
+		return handler.negativeAnswer(response, chain, chain.deny(name, closest, wildcard, true), dnssecOK)
+	}
+
+	answer, err := handler.signed(zone.Origin, rrset, dnssecOK)
+	if err != nil {
+		return fmt.Errorf("signedAnswer: %w", err)
+	}
+
+	if wildcard != "" {
+		for i, rr := range answer {
+			answer[i] = dns.Copy(rr)
+			answer[i].Header().Name = question.Name
+		}
+
+		if dnssecOK {
+			for _, rr := range chain.deny(name, closest, wildcard, false) {
+				signed, err := handler.signed(zone.Origin, []dns.RR{rr}, dnssecOK)
+				if err != nil {
+					return fmt.Errorf("signedAnswer: %w", err)
+				}
+
+				response.Ns = append(response.Ns, signed...)
+			}
+		}
+	}
+
+	response.Answer = answer
+
+	return nil
+}
+
+// negativeAnswer puts the SOA in the authority section, along with the denial records when the client asked for
+// DNSSEC records
+func (handler *RecordHandler) negativeAnswer(response *dns.Msg, chain *zoneChain, denial []dns.RR, dnssecOK bool) error {
+	soa, err := handler.Journal.SOA(chain.zone.Origin)
+	if err != nil {
+		return fmt.Errorf("negativeAnswer: %w", err)
+	}
+
+	// the SOA is signed with its own ttl and served with the negative ttl
+	authority, err := handler.signed(chain.zone.Origin, []dns.RR{soa}, dnssecOK)
+	if err != nil {
+		return fmt.Errorf("negativeAnswer: %w", err)
+	}
+	for _, rr := range authority {
+		rr.Header().Ttl = chain.negativeTTL()
+	}
+
+	if dnssecOK {
+		for _, rr := range denial {
+			signed, err := handler.signed(chain.zone.Origin, []dns.RR{rr}, dnssecOK)
+			if err != nil {
+				return fmt.Errorf("negativeAnswer: %w", err)
+			}
+
+			authority = append(authority, signed...)
+		}
+	}
+
+	response.Ns = authority
+
+	return nil
+}
+
+// rrset returns the rrset of a type at a name of the zone
+func (handler *RecordHandler) rrset(chain *zoneChain, name string, qtype uint16) ([]dns.RR, error) {
+	origin := chain.zone.Origin
+
+	if name == origin {
+		switch qtype {
+		case dns.TypeSOA:
+			soa, err := handler.Journal.SOA(origin)
+			if err != nil {
+				return nil, fmt.Errorf("rrset: %w", err)
+			}
+
+			return []dns.RR{soa}, nil
+		case dns.TypeNS:
+			rrs, err := handler.Journal.NS(origin)
+			if err != nil {
+				return nil, fmt.Errorf("rrset: %w", err)
+			}
+
+			return rrs, nil
+		case dns.TypeDNSKEY:
+			rrs, err := handler.Signer.DNSKEY(origin)
+			if err != nil {
+				return nil, fmt.Errorf("rrset: %w", err)
+			}
+
+			return rrs, nil
+		case dns.TypeNSEC3PARAM:
+			if chain.zone.DNSSEC.NSEC3 {
+				return []dns.RR{&dns.NSEC3PARAM{
+					Hdr: dns.RR_Header{
+						Name:   origin,
+						Rrtype: dns.TypeNSEC3PARAM,
+						Class:  dns.ClassINET,
+						Ttl:    chain.zone.TTL,
+					},
+					Hash: dns.SHA1,
+				}}, nil
+			}
+		}
+	}
+
+	record, exist := chain.records[name]
+	if !exist || recordType(record) != qtype {
+		return []dns.RR{}, nil
+	}
+
+	record.Domain = name
+
+	return recordRRs([]vinyl.Record{record}), nil
+}
+
+// signed returns rrset followed by its signatures when the client asked for DNSSEC records
+func (handler *RecordHandler) signed(origin string, rrset []dns.RR, dnssecOK bool) ([]dns.RR, error) {
+	if !dnssecOK {
+		return rrset, nil
+	}
+
+	rrsigs, err := handler.Signer.Sign(origin, rrset)
+	if err != nil {
+		return nil, fmt.Errorf("signed: %w", err)
+	}
+
+	return append(rrset, rrsigs...), nil
+}
+
+// canonicalLess orders names the way RFC 4034 section 6.1 does, comparing labels from the root down
+func canonicalLess(a string, b string) bool {
+	x, y := dns.SplitDomainName(strings.ToLower(a)), dns.SplitDomainName(strings.ToLower(b))
+
+	for i, j := len(x)-1, len(y)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if x[i] != y[j] {
+			return x[i] < y[j]
+		}
+	}
+
+	return len(x) < len(y)
+}
+
+// nsec3Hash hashes a name without a salt or extra iterations, as RFC 9276 recommends
+func nsec3Hash(name string) string {
+	return dns.HashName(name, dns.SHA1, 0, "")
+}
+
+// nextCloser is the ancestor of name one label below its closest encloser
+func nextCloser(name string, closest string) string {
+	labels := dns.Split(name)
+
+	return name[labels[len(labels)-dns.CountLabel(closest)-1]:]
+}
+
+func parentName(name string) string {
+	labels := dns.Split(name)
+	if len(labels) < 2 {
+		return "."
+	}
+
+	return name[labels[1]:]
+}
+
+func typeBitmap(types []uint16) []uint16 {
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
+
+	return types
+}
+
+func uniqueOwners(rrs []dns.RR) []dns.RR {
+	unique := []dns.RR{}
+	seen := map[string]struct{}{}
+
+	for _, rr := range rrs {
+		if _, exist := seen[rr.Header().Name]; exist {
+			continue
+		}
+
+		seen[rr.Header().Name] = struct{}{}
+		unique = append(unique, rr)
+	}
+
+	return unique
+}
@@ -0,0 +1,292 @@
+package dns_test
+
+import (
+	"testing"
+	"time"
+
+	miekg "github.com/miekg/dns"
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func signedZone(t *testing.T, origin string, policy dns.DNSSECPolicy) dns.Zone {
+	policy.Enabled = true
+	if policy.KeyDirectory == "" {
+		policy.KeyDirectory = t.TempDir()
+	}
+	config := &dns.Config{
+		Zones: []dns.Zone{{Origin: origin, Nameservers: []string{"ns1." + origin}, DNSSEC: policy}},
+	}
+
+	err := config.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return config.Zones[0]
+}
+
+// query asks address over tcp so signed answers aren't truncated
+func query(t *testing.T, address string, name string, qtype uint16, dnssecOK bool) *miekg.Msg {
+	request := &miekg.Msg{}
+	request.SetQuestion(name, qtype)
+	request.SetEdns0(4096, dnssecOK)
+
+	client := &miekg.Client{Net: "tcp"}
+	response, _, err := client.Exchange(request, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return response
+}
+
+// verifySignatures checks every rrset in rrs is signed by one of keys and returns the types of the rrsets
+func verifySignatures(t *testing.T, keys []miekg.RR, rrs []miekg.RR) []string {
+	rrsets := map[string][]miekg.RR{}
+	rrsigs := map[string][]*miekg.RRSIG{}
+	order := []string{}
+
+	for _, rr := range rrs {
+		if rrsig, ok := rr.(*miekg.RRSIG); ok {
+			set := rrsig.Hdr.Name + "/" + miekg.TypeToString[rrsig.TypeCovered]
+			rrsigs[set] = append(rrsigs[set], rrsig)
+			continue
+		}
+
+		set := rr.Header().Name + "/" + miekg.TypeToString[rr.Header().Rrtype]
+		if _, exist := rrsets[set]; !exist {
+			order = append(order, set)
+		}
+		rrsets[set] = append(rrsets[set], rr)
+	}
+
+	for _, set := range order {
+		assert.NotEmpty(t, rrsigs[set], "%s should be signed", set)
+
+		for _, rrsig := range rrsigs[set] {
+			verified := false
+			for _, key := range keys {
+				dnskey := key.(*miekg.DNSKEY)
+				if dnskey.KeyTag() == rrsig.KeyTag && rrsig.Verify(dnskey, rrsets[set]) == nil {
+					verified = true
+				}
+			}
+
+			assert.True(t, verified, "the signature of %s should verify", set)
+		}
+	}
+
+	return order
+}
+
+func TestSignedAnswer(t *testing.T) {
+	tests := map[string]struct {
+		Name      string
+		Qtype     uint16
+		NSEC3     bool
+		Algorithm string
+		Unsigned  bool
+		Rcode     int
+		Answer    []string
+		Authority []string
+		Proves    func(*assert.Assertions, []miekg.RR)
+	}{
+		"signs answers": {
+			Name:   "web.lab.example.",
+			Qtype:  miekg.TypeA,
+			Answer: []string{"web.lab.example./A"},
+		},
+		"signs answers with ed25519": {
+			Name:      "web.lab.example.",
+			Qtype:     miekg.TypeA,
+			Algorithm: dns.AlgorithmEd25519,
+			Answer:    []string{"web.lab.example./A"},
+		},
+		"leaves signatures out when the client doesn't ask for them": {
+			Name:     "web.lab.example.",
+			Qtype:    miekg.TypeA,
+			Unsigned: true,
+			Answer:   []string{"web.lab.example./A"},
+		},
+		"publishes the zone's keys": {
+			Name:   "lab.example.",
+			Qtype:  miekg.TypeDNSKEY,
+			Answer: []string{"lab.example./DNSKEY"},
+		},
+		"proves names don't exist with NSEC": {
+			Name:      "missing.lab.example.",
+			Qtype:     miekg.TypeA,
+			Rcode:     miekg.RcodeNameError,
+			Authority: []string{"lab.example./SOA", "*.dev.lab.example./NSEC", "lab.example./NSEC"},
+		},
+		"proves types don't exist with NSEC": {
+			Name:      "web.lab.example.",
+			Qtype:     miekg.TypeAAAA,
+			Authority: []string{"lab.example./SOA", "web.lab.example./NSEC"},
+			Proves: func(assert *assert.Assertions, rrs []miekg.RR) {
+				nsec := rrs[1].(*miekg.NSEC)
+				assert.Equal([]uint16{miekg.TypeA, miekg.TypeRRSIG, miekg.TypeNSEC}, nsec.TypeBitMap)
+			},
+		},
+		"proves empty non-terminals have no records with NSEC": {
+			Name:      "b.lab.example.",
+			Qtype:     miekg.TypeA,
+			Authority: []string{"lab.example./SOA", "lab.example./NSEC"},
+			Proves: func(assert *assert.Assertions, rrs []miekg.RR) {
+				assert.Equal("a.b.lab.example.", rrs[1].(*miekg.NSEC).NextDomain)
+			},
+		},
+		"expands wildcards and proves the name doesn't exist": {
+			Name:      "x.dev.lab.example.",
+			Qtype:     miekg.TypeA,
+			Answer:    []string{"x.dev.lab.example./A"},
+			Authority: []string{"*.dev.lab.example./NSEC"},
+			Proves: func(assert *assert.Assertions, rrs []miekg.RR) {
+				assert.Equal("web.lab.example.", rrs[0].(*miekg.NSEC).NextDomain)
+			},
+		},
+		"proves names don't exist with NSEC3": {
+			Name:  "missing.lab.example.",
+			Qtype: miekg.TypeA,
+			NSEC3: true,
+			Rcode: miekg.RcodeNameError,
+			Proves: func(assert *assert.Assertions, rrs []miekg.RR) {
+				matches, _ := nsec3Proofs(rrs, "lab.example.", "missing.lab.example.", "*.lab.example.")
+				assert.Equal([]bool{true, false, false}, matches)
+
+				_, covers := nsec3Proofs(rrs, "missing.lab.example.", "*.lab.example.")
+				assert.Equal([]bool{true, true}, covers)
+			},
+		},
+		"proves types don't exist with NSEC3": {
+			Name:  "b.lab.example.",
+			Qtype: miekg.TypeA,
+			NSEC3: true,
+			Proves: func(assert *assert.Assertions, rrs []miekg.RR) {
+				matches, _ := nsec3Proofs(rrs, "b.lab.example.")
+				assert.Equal([]bool{true}, matches)
+			},
+		},
+		"publishes the NSEC3 parameters": {
+			Name:   "lab.example.",
+			Qtype:  miekg.TypeNSEC3PARAM,
+			NSEC3:  true,
+			Answer: []string{"lab.example./NSEC3PARAM"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			mem := store.NewMemory(
+				vinyl.Record{Domain: "web.lab.example.", Address: "10.0.0.1", TTL: 60},
+				vinyl.Record{Domain: "*.dev.lab.example.", Address: "10.0.0.9", TTL: 60},
+				vinyl.Record{Domain: "a.b.lab.example.", Address: "fd00::1", TTL: 60},
+			)
+			zone := signedZone(t, "lab.example.", dns.DNSSECPolicy{NSEC3: test.NSEC3, Algorithm: test.Algorithm})
+			journal := startJournal(t, mem, []dns.Zone{zone})
+
+			signer, err := dns.NewSigner(journal, []dns.Zone{zone})
+			assert.NoError(err)
+			address, _ := serve(t, dns.NewRecordHandler(mem, dns.WithJournal(journal), dns.WithSigner(signer)))
+
+			keys, err := signer.DNSKEY("lab.example.")
+			assert.NoError(err)
+
+			response := query(t, address, test.Name, test.Qtype, !test.Unsigned)
+			assert.Equal(miekg.RcodeToString[test.Rcode], miekg.RcodeToString[response.Rcode])
+			assert.True(response.Authoritative)
+
+			if test.Unsigned {
+				assert.Equal(test.Answer, rrTypes(response.Answer))
+				return
+			}
+
+			assert.Equal(emptyIfNil(test.Answer), emptyIfNil(verifySignatures(t, keys, response.Answer)))
+
+			authority := verifySignatures(t, keys, response.Ns)
+			if test.Authority != nil {
+				assert.Equal(test.Authority, authority)
+			}
+
+			if test.Proves != nil {
+				test.Proves(assert, withoutSignatures(response.Ns))
+			}
+		})
+	}
+}
+
+func TestSignedAnswer_TSIG(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := store.NewMemory(vinyl.Record{Domain: "web.lab.example.", Address: "10.0.0.1", TTL: 60})
+	zone := signedZone(t, "lab.example.", dns.DNSSECPolicy{})
+	journal := startJournal(t, mem, []dns.Zone{zone})
+
+	signer, err := dns.NewSigner(journal, []dns.Zone{zone})
+	assert.NoError(err)
+	address, _ := serve(t, dns.NewRecordHandler(mem, dns.WithJournal(journal), dns.WithSigner(signer)))
+
+	request := &miekg.Msg{}
+	request.SetQuestion("web.lab.example.", miekg.TypeA)
+	request.SetEdns0(4096, true)
+	request.SetTsig("transfer.", miekg.HmacSHA256, 300, time.Now().Unix())
+
+	client := &miekg.Client{Net: "tcp", TsigSecret: map[string]string{"transfer.": transferSecret}}
+	response, _, err := client.Exchange(request, address)
+	assert.NoError(err)
+	assert.Equal(miekg.RcodeSuccess, response.Rcode)
+	assert.NotNil(response.IsTsig(), "answers to signed queries should be signed")
+}
+
+// nsec3Proofs reports which of names are matched and which are covered by the NSEC3 records in rrs
+func nsec3Proofs(rrs []miekg.RR, names ...string) ([]bool, []bool) {
+	matches, covers := []bool{}, []bool{}
+
+	for _, name := range names {
+		match, cover := false, false
+		for _, rr := range rrs {
+			if nsec3, ok := rr.(*miekg.NSEC3); ok {
+				match = match || nsec3.Match(name)
+				cover = cover || nsec3.Cover(name)
+			}
+		}
+
+		matches = append(matches, match)
+		covers = append(covers, cover)
+	}
+
+	return matches, covers
+}
+
+func rrTypes(rrs []miekg.RR) []string {
+	types := []string{}
+	for _, rr := range rrs {
+		types = append(types, rr.Header().Name+"/"+miekg.TypeToString[rr.Header().Rrtype])
+	}
+
+	return types
+}
+
+func withoutSignatures(rrs []miekg.RR) []miekg.RR {
+	unsigned := []miekg.RR{}
+	for _, rr := range rrs {
+		if _, ok := rr.(*miekg.RRSIG); !ok {
+			unsigned = append(unsigned, rr)
+		}
+	}
+
+	return unsigned
+}
+
+func emptyIfNil(strings []string) []string {
+	if strings == nil {
+		return []string{}
+	}
+
+	return strings
+}
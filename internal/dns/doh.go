@@ -0,0 +1,403 @@
+package dns
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// DoHPath is where DNS over HTTPS queries are sent, RFC 8484
+	DoHPath = "/dns-query"
+	// JSONPath answers queries as json for debugging
+	JSONPath = "/resolve"
+
+	dnsMessageType = "application/dns-message"
+)
+
+// DoHHandler serves DNS over HTTPS, RFC 8484, by handing queries to a dns handler. Zone transfers are refused since
+// they take more than one message, and TSIG signatures can't be verified so signed requests are treated as unsigned
+type DoHHandler struct {
+	Handler Handler
+	// JSON answers GET requests to JSONPath with the json format of the dns-json apis, which is easier to read
+	// while debugging
+	JSON bool
+	// TrustedProxies are the proxies whose Forwarded and X-Forwarded-For headers are believed, so acls and views
+	// see the client behind them instead of the proxy
+	TrustedProxies []*net.IPNet
+}
+
+// DoHOption sets the optional fields of a DoHHandler
+type DoHOption func(*DoHHandler)
+
+// WithJSON answers queries to JSONPath as json
+func WithJSON(enabled bool) DoHOption {
+	return func(doh *DoHHandler) {
+		doh.JSON = enabled
+	}
+}
+
+// WithTrustedProxies takes the client address from the forwarding headers of requests coming through the proxies
+func WithTrustedProxies(proxies []*net.IPNet) DoHOption {
+	return func(doh *DoHHandler) {
+		doh.TrustedProxies = proxies
+	}
+}
+
+func NewDoHHandler(handler Handler, options ...DoHOption) *DoHHandler {
+	doh := &DoHHandler{
+		Handler: handler,
+	}
+
+	for _, option := range options {
+		option(doh)
+	}
+
+	return doh
+}
+
+// ServeHTTP implements http.Handler
+func (doh *DoHHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == DoHPath:
+		doh.serveMessage(w, r)
+	case r.URL.Path == JSONPath && doh.JSON:
+		doh.serveJSON(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveMessage answers a query sent in wire format, base64url encoded in the dns parameter of a GET or as the body
+// of a POST
+func (doh *DoHHandler) serveMessage(w http.ResponseWriter, r *http.Request) {
+	var (
+		data []byte
+		err  error
+	)
+
+	switch r.Method {
+	case http.MethodGet:
+		data, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(r.URL.Query().Get("dns"), "="))
+	case http.MethodPost:
+		if r.Header.Get("Content-Type") != dnsMessageType {
+			http.Error(w, fmt.Sprintf("the body must be %s", dnsMessageType), http.StatusUnsupportedMediaType)
+			return
+		}
+
+		data, err = io.ReadAll(http.MaxBytesReader(w, r.Body, dns.MaxMsgSize))
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil || len(data) == 0 {
+		http.Error(w, "the query is missing or not encoded correctly", http.StatusBadRequest)
+		return
+	}
+
+	request := &dns.Msg{}
+	err = request.Unpack(data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("the query is not a dns message: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	response := doh.exchange(r, request)
+
+	packed, err := response.Pack()
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", dnsMessageType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%v", minimumTTL(response)))
+	w.Write(packed)
+}
+
+// serveJSON answers a query for the name and type parameters as json
+func (doh *DoHHandler) serveJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	name := query.Get("name")
+	if _, ok := dns.IsDomainName(name); name == "" || !ok {
+		http.Error(w, "name must be a domain name", http.StatusBadRequest)
+		return
+	}
+
+	qtype := dns.TypeA
+	if value := query.Get("type"); value != "" {
+		parsed, exist := dns.StringToType[strings.ToUpper(value)]
+		if number, err := strconv.ParseUint(value, 10, 16); err == nil {
+			parsed, exist = uint16(number), true
+		}
+		if !exist {
+			http.Error(w, fmt.Sprintf("%s is not a record type", value), http.StatusBadRequest)
+			return
+		}
+
+		qtype = parsed
+	}
+
+	request := &dns.Msg{}
+	request.SetQuestion(dns.Fqdn(name), qtype)
+	if query.Get("do") == "1" || query.Get("do") == "true" {
+		request.SetEdns0(dns.MaxMsgSize, true)
+	}
+
+	response := doh.exchange(r, request)
+
+	body, err := json.Marshal(newJSONMessage(response))
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%v", minimumTTL(response)))
+	w.Write(body)
+}
+
+// exchange hands a query to the dns handler and returns its response, or a server failure when it didn't write one
+func (doh *DoHHandler) exchange(r *http.Request, request *dns.Msg) *dns.Msg {
+	if len(request.Question) == 1 && isTransfer(request.Question[0].Qtype) {
+		response := NewResponse(request)
+		response.Rcode = dns.RcodeRefused
+
+		return response
+	}
+
+	writer := &httpResponseWriter{
+		request: r,
+		remote:  doh.clientAddr(r),
+	}
+	doh.Handler.ServeDNS(writer, request)
+
+	if writer.response == nil {
+		response := NewResponse(request)
+		response.Rcode = dns.RcodeServerFailure
+
+		return response
+	}
+
+	return writer.response
+}
+
+// clientAddr is the address the request came from. Forwarding headers are only followed while the hop they were
+// added by is a trusted proxy, so a client can't pick its own address by sending them
+func (doh *DoHHandler) clientAddr(r *http.Request) net.Addr {
+	addr := tcpAddr(r.RemoteAddr)
+
+	hops := forwardedFor(r.Header)
+	for i := len(hops) - 1; i >= 0 && containsAddress(doh.TrustedProxies, addr); i-- {
+		ip := net.ParseIP(hops[i])
+		if ip == nil {
+			break
+		}
+
+		addr = &net.TCPAddr{IP: ip}
+	}
+
+	return addr
+}
+
+// forwardedFor lists the addresses a request was forwarded for, the client first, from the Forwarded header,
+// RFC 7239, or X-Forwarded-For when it isn't set
+func forwardedFor(header http.Header) []string {
+	hops := []string{}
+
+	if values := header.Values("Forwarded"); len(values) > 0 {
+		for _, element := range strings.Split(strings.Join(values, ","), ",") {
+			hop := ""
+			for _, pair := range strings.Split(element, ";") {
+				key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(key, "for") {
+					hop = forwardedHost(strings.Trim(value, `"`))
+				}
+			}
+
+			hops = append(hops, hop)
+		}
+
+		return hops
+	}
+
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, forwardedHost(strings.TrimSpace(hop)))
+		}
+	}
+
+	return hops
+}
+
+// forwardedHost strips the port and brackets from a forwarded address
+func forwardedHost(value string) string {
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		return host
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+}
+
+// tcpAddr parses the host:port address of an http request
+func tcpAddr(address string) *net.TCPAddr {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return &net.TCPAddr{}
+	}
+
+	number, _ := strconv.Atoi(port)
+
+	return &net.TCPAddr{IP: net.ParseIP(host), Port: number}
+}
+
+// minimumTTL is how long a response can be cached, RFC 8484 section 5.1. Negative answers are cached as long as
+// their SOA says, RFC 2308
+func minimumTTL(response *dns.Msg) uint32 {
+	var minimum *uint32
+
+	for _, rr := range append(append([]dns.RR{}, response.Answer...), response.Ns...) {
+		ttl := rr.Header().Ttl
+		if soa, ok := rr.(*dns.SOA); ok && soa.Minttl < ttl {
+			ttl = soa.Minttl
+		}
+
+		if minimum == nil || ttl < *minimum {
+			minimum = &ttl
+		}
+	}
+
+	if minimum == nil || response.Rcode != dns.RcodeSuccess && response.Rcode != dns.RcodeNameError {
+		return 0
+	}
+
+	return *minimum
+}
+
+// httpResponseWriter keeps the response a dns handler writes for an http request
+type httpResponseWriter struct {
+	request  *http.Request
+	remote   net.Addr
+	response *dns.Msg
+}
+
+func (w *httpResponseWriter) LocalAddr() net.Addr {
+	if addr, ok := w.request.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		return addr
+	}
+
+	return &net.TCPAddr{}
+}
+
+// RemoteAddr is the client's address, behind any trusted proxies
+func (w *httpResponseWriter) RemoteAddr() net.Addr {
+	return w.remote
+}
+
+func (w *httpResponseWriter) WriteMsg(msg *dns.Msg) error {
+	w.response = msg
+
+	return nil
+}
+
+func (w *httpResponseWriter) Write(data []byte) (int, error) {
+	msg := &dns.Msg{}
+	err := msg.Unpack(data)
+	if err != nil {
+		return 0, err
+	}
+
+	w.response = msg
+
+	return len(data), nil
+}
+
+func (w *httpResponseWriter) Close() error {
+	return nil
+}
+
+// TsigStatus fails since signed requests can't be verified over http
+func (w *httpResponseWriter) TsigStatus() error {
+	return dns.ErrSecret
+}
+
+func (w *httpResponseWriter) TsigTimersOnly(bool) {}
+
+func (w *httpResponseWriter) Hijack() {}
+
+// jsonMessage is a dns message in the json format of the dns-json apis
+type jsonMessage struct {
+	Status    int            `json:"Status"`
+	TC        bool           `json:"TC"`
+	RD        bool           `json:"RD"`
+	RA        bool           `json:"RA"`
+	AD        bool           `json:"AD"`
+	CD        bool           `json:"CD"`
+	Question  []jsonQuestion `json:"Question"`
+	Answer    []jsonRR       `json:"Answer,omitempty"`
+	Authority []jsonRR       `json:"Authority,omitempty"`
+}
+
+type jsonQuestion struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+}
+
+type jsonRR struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+	TTL  uint32 `json:"TTL"`
+	Data string `json:"data"`
+}
+
+func newJSONMessage(msg *dns.Msg) jsonMessage {
+	message := jsonMessage{
+		Status:    msg.Rcode,
+		TC:        msg.Truncated,
+		RD:        msg.RecursionDesired,
+		RA:        msg.RecursionAvailable,
+		AD:        msg.AuthenticatedData,
+		CD:        msg.CheckingDisabled,
+		Question:  []jsonQuestion{},
+		Answer:    jsonRRs(msg.Answer),
+		Authority: jsonRRs(msg.Ns),
+	}
+
+	for _, question := range msg.Question {
+		message.Question = append(message.Question, jsonQuestion{Name: question.Name, Type: question.Qtype})
+	}
+
+	return message
+}
+
+func jsonRRs(rrs []dns.RR) []jsonRR {
+	converted := []jsonRR{}
+	for _, rr := range rrs {
+		header := rr.Header()
+		converted = append(converted, jsonRR{
+			Name: header.Name,
+			Type: header.Rrtype,
+			TTL:  header.Ttl,
+			Data: strings.TrimPrefix(rr.String(), header.String()),
+		})
+	}
+
+	return converted
+}
@@ -0,0 +1,291 @@
+package dns_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	miekg "github.com/miekg/dns"
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveDoH(t *testing.T, options ...dns.DoHOption) *httptest.Server {
+	mem := store.NewMemory(
+		vinyl.Record{Domain: "web.lab.example.", Address: "10.0.0.1", TTL: 60},
+		vinyl.Record{Domain: "db.lab.example.", Address: "10.0.0.2", TTL: 30},
+	)
+	server := httptest.NewServer(dns.NewDoHHandler(dns.NewRecordHandler(mem), options...))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func packQuery(t *testing.T, name string, qtype uint16) []byte {
+	request := &miekg.Msg{}
+	request.SetQuestion(name, qtype)
+	request.Id = 0
+
+	packed, err := request.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return packed
+}
+
+func TestDoHHandler(t *testing.T) {
+	tests := map[string]struct {
+		Method      string
+		Path        string
+		ContentType string
+		Body        []byte
+		Status      int
+		Rcode       int
+		Answers     int
+		MaxAge      string
+	}{
+		"answers GET queries": {
+			Method:  http.MethodGet,
+			Path:    dns.DoHPath + "?dns=" + base64.RawURLEncoding.EncodeToString(packQuery(t, "web.lab.example.", miekg.TypeA)),
+			Status:  http.StatusOK,
+			Answers: 1,
+			MaxAge:  "max-age=60",
+		},
+		"answers POST queries": {
+			Method:      http.MethodPost,
+			Path:        dns.DoHPath,
+			ContentType: "application/dns-message",
+			Body:        packQuery(t, "db.lab.example.", miekg.TypeA),
+			Status:      http.StatusOK,
+			Answers:     1,
+			MaxAge:      "max-age=30",
+		},
+		"refuses zone transfers": {
+			Method:      http.MethodPost,
+			Path:        dns.DoHPath,
+			ContentType: "application/dns-message",
+			Body:        packQuery(t, "lab.example.", miekg.TypeAXFR),
+			Status:      http.StatusOK,
+			Rcode:       miekg.RcodeRefused,
+			MaxAge:      "max-age=0",
+		},
+		"rejects bodies that aren't dns messages": {
+			Method:      http.MethodPost,
+			Path:        dns.DoHPath,
+			ContentType: "application/json",
+			Body:        []byte("{}"),
+			Status:      http.StatusUnsupportedMediaType,
+		},
+		"rejects queries that don't decode": {
+			Method: http.MethodGet,
+			Path:   dns.DoHPath + "?dns=not+base64",
+			Status: http.StatusBadRequest,
+		},
+		"rejects other methods": {
+			Method: http.MethodPut,
+			Path:   dns.DoHPath,
+			Status: http.StatusMethodNotAllowed,
+		},
+		"leaves the json api off by default": {
+			Method: http.MethodGet,
+			Path:   dns.JSONPath + "?name=web.lab.example.",
+			Status: http.StatusNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			server := serveDoH(t)
+
+			request, err := http.NewRequest(test.Method, server.URL+test.Path, bytes.NewReader(test.Body))
+			assert.NoError(err)
+			if test.ContentType != "" {
+				request.Header.Set("Content-Type", test.ContentType)
+			}
+
+			response, err := server.Client().Do(request)
+			assert.NoError(err)
+			defer response.Body.Close()
+
+			assert.Equal(test.Status, response.StatusCode)
+			if test.Status != http.StatusOK {
+				return
+			}
+
+			assert.Equal("application/dns-message", response.Header.Get("Content-Type"))
+			assert.Equal(test.MaxAge, response.Header.Get("Cache-Control"))
+
+			body, err := io.ReadAll(response.Body)
+			assert.NoError(err)
+
+			msg := &miekg.Msg{}
+			assert.NoError(msg.Unpack(body))
+			assert.Equal(test.Rcode, msg.Rcode)
+			assert.Len(msg.Answer, test.Answers)
+		})
+	}
+}
+
+func TestDoHJSON(t *testing.T) {
+	tests := map[string]struct {
+		Query  string
+		Status int
+		Data   []string
+	}{
+		"answers a records by default": {
+			Query:  "?name=web.lab.example",
+			Status: http.StatusOK,
+			Data:   []string{"10.0.0.1"},
+		},
+		"takes type names": {
+			Query:  "?name=web.lab.example.&type=aaaa",
+			Status: http.StatusOK,
+			Data:   []string{},
+		},
+		"takes type numbers": {
+			Query:  "?name=web.lab.example.&type=1",
+			Status: http.StatusOK,
+			Data:   []string{"10.0.0.1"},
+		},
+		"rejects unknown types": {
+			Query:  "?name=web.lab.example.&type=BOGUS",
+			Status: http.StatusBadRequest,
+		},
+		"rejects missing names": {
+			Query:  "",
+			Status: http.StatusBadRequest,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			server := serveDoH(t, dns.WithJSON(true))
+
+			response, err := server.Client().Get(server.URL + dns.JSONPath + test.Query)
+			assert.NoError(err)
+			defer response.Body.Close()
+
+			assert.Equal(test.Status, response.StatusCode)
+			if test.Status != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Status int
+				Answer []struct {
+					Name string `json:"name"`
+					TTL  uint32
+					Data string `json:"data"`
+				}
+			}
+			assert.NoError(json.NewDecoder(response.Body).Decode(&body))
+			assert.Equal(miekg.RcodeSuccess, body.Status)
+
+			data := []string{}
+			for _, answer := range body.Answer {
+				assert.Equal("web.lab.example.", answer.Name)
+				data = append(data, answer.Data)
+			}
+			assert.ElementsMatch(test.Data, data)
+		})
+	}
+}
+
+// remoteHandler answers with the address the query came from
+type remoteHandler struct {
+	remote string
+}
+
+func (handler *remoteHandler) ServeDNS(w miekg.ResponseWriter, request *miekg.Msg) {
+	handler.remote = w.RemoteAddr().(*net.TCPAddr).IP.String()
+
+	response := &miekg.Msg{}
+	response.SetReply(request)
+	w.WriteMsg(response)
+}
+
+func TestDoHHandler_TrustedProxies(t *testing.T) {
+	loopback := &net.IPNet{IP: net.IPv4(127, 0, 0, 0), Mask: net.CIDRMask(8, 32)}
+	proxy := &net.IPNet{IP: net.IPv4(192, 0, 2, 1), Mask: net.CIDRMask(32, 32)}
+
+	tests := map[string]struct {
+		Proxies []*net.IPNet
+		Header  string
+		Value   string
+		Remote  string
+	}{
+		"ignores headers without trusted proxies": {
+			Header: "X-Forwarded-For",
+			Value:  "198.51.100.1",
+			Remote: "127.0.0.1",
+		},
+		"uses X-Forwarded-For from a trusted proxy": {
+			Proxies: []*net.IPNet{loopback},
+			Header:  "X-Forwarded-For",
+			Value:   "198.51.100.1",
+			Remote:  "198.51.100.1",
+		},
+		"uses Forwarded from a trusted proxy": {
+			Proxies: []*net.IPNet{loopback},
+			Header:  "Forwarded",
+			Value:   `for="[2001:db8::1]:4711";proto=https`,
+			Remote:  "2001:db8::1",
+		},
+		"follows a chain of trusted proxies": {
+			Proxies: []*net.IPNet{loopback, proxy},
+			Header:  "X-Forwarded-For",
+			Value:   "198.51.100.1, 192.0.2.1",
+			Remote:  "198.51.100.1",
+		},
+		"stops at the first untrusted hop": {
+			Proxies: []*net.IPNet{loopback},
+			Header:  "X-Forwarded-For",
+			Value:   "198.51.100.1, 203.0.113.1",
+			Remote:  "203.0.113.1",
+		},
+		"keeps the proxy when the header isn't an address": {
+			Proxies: []*net.IPNet{loopback},
+			Header:  "Forwarded",
+			Value:   "for=unknown",
+			Remote:  "127.0.0.1",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			handler := &remoteHandler{}
+			server := httptest.NewServer(dns.NewDoHHandler(handler, dns.WithTrustedProxies(test.Proxies)))
+			t.Cleanup(server.Close)
+
+			request, err := http.NewRequest(http.MethodPost, server.URL+dns.DoHPath, bytes.NewReader(packQuery(t, "web.lab.example.", miekg.TypeA)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			request.Header.Set("Content-Type", "application/dns-message")
+			request.Header.Set(test.Header, test.Value)
+
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+
+			assert.Equal(http.StatusOK, response.StatusCode)
+			assert.Equal(test.Remote, handler.remote)
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package dns
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/miekg/dns"
+)
 
 type UnsupportedOpCodeError struct {
 	Opcode int
@@ -17,3 +21,127 @@ type UnsupportedRecordTypeError struct {
 func (e *UnsupportedRecordTypeError) Error() string {
 	return fmt.Sprintf("record type %v is not supported at this time", e.Type)
 }
+
+type InvalidReverseNameError struct {
+	Name string
+}
+
+func (e *InvalidReverseNameError) Error() string {
+	return fmt.Sprintf("%s is not a reverse lookup name for a single address", e.Name)
+}
+
+type InvalidZoneConfigError struct {
+	Origin string
+	Reason string
+}
+
+func (e *InvalidZoneConfigError) Error() string {
+	return fmt.Sprintf("zone %s is not configured correctly: %s", e.Origin, e.Reason)
+}
+
+type InvalidKeyError struct {
+	Name   string
+	Reason string
+}
+
+func (e *InvalidKeyError) Error() string {
+	return fmt.Sprintf("tsig key %s is not valid: %s", e.Name, e.Reason)
+}
+
+type MissingZoneError struct {
+	Name string
+}
+
+func (e *MissingZoneError) Error() string {
+	return fmt.Sprintf("%s is not in a zone vinyl is authoritative for", e.Name)
+}
+
+type RefusedTransferError struct {
+	Origin string
+	Client string
+}
+
+func (e *RefusedTransferError) Error() string {
+	return fmt.Sprintf("transfer of %s to %s is not allowed", e.Origin, e.Client)
+}
+
+type ReadOnlyStoreError struct {
+	Domain string
+}
+
+func (e *ReadOnlyStoreError) Error() string {
+	return fmt.Sprintf("domain %s can't be changed, records are transferred from the zone's primary", e.Domain)
+}
+
+type ExpiredZoneError struct {
+	Origin string
+}
+
+func (e *ExpiredZoneError) Error() string {
+	return fmt.Sprintf("zone %s has not been transferred from its primary recently enough to be served", e.Origin)
+}
+
+type RefusedNotifyError struct {
+	Origin string
+	Client string
+}
+
+func (e *RefusedNotifyError) Error() string {
+	return fmt.Sprintf("NOTIFY for %s from %s is not from the zone's primary", e.Origin, e.Client)
+}
+
+type MissingKeyError struct {
+	Name string
+}
+
+func (e *MissingKeyError) Error() string {
+	return fmt.Sprintf("tsig key %s does not exist", e.Name)
+}
+
+type RefusedUpdateError struct {
+	Origin string
+	Client string
+}
+
+func (e *RefusedUpdateError) Error() string {
+	return fmt.Sprintf("update of %s from %s is not signed with one of the zone's update keys", e.Origin, e.Client)
+}
+
+// FailedUpdateError is a dynamic update that was rejected with Rcode before anything was changed
+type FailedUpdateError struct {
+	Name   string
+	Rcode  int
+	Reason string
+}
+
+func (e *FailedUpdateError) Error() string {
+	return fmt.Sprintf("update of %s failed with %s: %s", e.Name, dns.RcodeToString[e.Rcode], e.Reason)
+}
+
+type InvalidViewConfigError struct {
+	Name   string
+	Reason string
+}
+
+func (e *InvalidViewConfigError) Error() string {
+	return fmt.Sprintf("view %s is not configured correctly: %s", e.Name, e.Reason)
+}
+
+type InvalidACLConfigError struct {
+	Operation string
+	Reason    string
+}
+
+func (e *InvalidACLConfigError) Error() string {
+	return fmt.Sprintf("%s acl is not configured correctly: %s", e.Operation, e.Reason)
+}
+
+type RefusedByACLError struct {
+	Operation string
+	Name      string
+	Client    string
+}
+
+func (e *RefusedByACLError) Error() string {
+	return fmt.Sprintf("%s of %s from %s is not allowed by the acl", e.Operation, e.Name, e.Client)
+}
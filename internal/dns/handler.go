@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/miekg/dns"
 	vinyl "github.com/platform-edn/vinyl/internal"
@@ -11,6 +14,7 @@ import (
 
 type RecordStorer interface {
 	GetRecord(string) (*vinyl.Record, error)
+	GetRecordsByAddress(string) ([]vinyl.Record, error)
 }
 
 type ResponseWriter interface {
@@ -35,13 +39,82 @@ type ResponseWriter interface {
 
 type RecordHandler struct {
 	RecordStore RecordStorer
+	// CanonicalPTR answers reverse lookups with only the first domain pointing at an address
+	CanonicalPTR bool
+	// Journal holds the zones vinyl is authoritative for, answering SOA and NS questions and zone transfers
+	Journal *Journal
+	// Secondary holds the zones transferred from other primaries, answering the names inside them and taking their
+	// NOTIFY messages
+	Secondary *Secondary
+	// Updater is the store dynamic updates to the journal's zones are applied to, updates are refused without it
+	Updater RecordUpdater
+	// Keyring restricts the zones and operations TSIG keys can be used for, keys aren't restricted without it
+	Keyring *Keyring
+	// Signer signs the answers for the journal's zones that have DNSSEC enabled
+	Signer *Signer
+	// Views answer the clients that match them from their own records. Signed zones, transfers and updates always
+	// use RecordStore
+	Views []ViewStore
+	// ACL refuses queries, recursion and transfers from the clients it doesn't allow
+	ACL         ACL
+	updateMutex sync.Mutex
 }
 
-func NewRecordHandler(store RecordStorer) *RecordHandler {
+// HandlerOption sets the optional behavior of a RecordHandler
+type HandlerOption func(*RecordHandler)
+
+// WithCanonicalPTR answers reverse lookups with only the first domain pointing at an address
+func WithCanonicalPTR(canonical bool) HandlerOption {
+	return func(handler *RecordHandler) {
+		handler.CanonicalPTR = canonical
+	}
+}
+
+// WithJournal serves the SOA, NS and transfers of the journal's zones
+func WithJournal(journal *Journal) HandlerOption {
+	return func(handler *RecordHandler) {
+		handler.Journal = journal
+	}
+}
+
+// WithSecondary answers the names inside the secondary's zones from it and refreshes the zones when their primaries
+// send a NOTIFY
+func WithSecondary(secondary *Secondary) HandlerOption {
+	return func(handler *RecordHandler) {
+		handler.Secondary = secondary
+	}
+}
+
+// WithUpdater applies dynamic updates to the journal's zones to store
+func WithUpdater(store RecordUpdater) HandlerOption {
+	return func(handler *RecordHandler) {
+		handler.Updater = store
+	}
+}
+
+// WithKeyring only accepts signed requests for the zones and operations their key is bound to
+func WithKeyring(keyring *Keyring) HandlerOption {
+	return func(handler *RecordHandler) {
+		handler.Keyring = keyring
+	}
+}
+
+// WithSigner answers questions for signed zones from the journal and signs the answers
+func WithSigner(signer *Signer) HandlerOption {
+	return func(handler *RecordHandler) {
+		handler.Signer = signer
+	}
+}
+
+func NewRecordHandler(store RecordStorer, options ...HandlerOption) *RecordHandler {
 	handler := &RecordHandler{
 		RecordStore: store,
 	}
 
+	for _, option := range options {
+		option(handler)
+	}
+
 	return handler
 }
 
@@ -50,6 +123,16 @@ func (handler *RecordHandler) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
 	response := NewResponse(request)
 	log.Println(request)
 
+	if request.Opcode == dns.OpcodeNotify {
+		handler.Notify(w, request)
+		return
+	}
+
+	if request.Opcode == dns.OpcodeUpdate {
+		handler.Update(w, request)
+		return
+	}
+
 	if request.Opcode != dns.OpcodeQuery {
 		handler.ServerErrorResponse(w, response, fmt.Errorf("ServeDNS: %w", &UnsupportedOpCodeError{
 			Opcode: request.Opcode,
@@ -57,13 +140,33 @@ func (handler *RecordHandler) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
 		return
 	}
 
-	answers, err := handler.ParseQuestion(request.Question)
+	if len(request.Question) == 1 && isTransfer(request.Question[0].Qtype) {
+		if handler.aclAllows(w, request, &handler.ACL.Transfer, ACLTransfer) {
+			handler.Transfer(w, request)
+		}
+		return
+	}
+
+	if !handler.aclAllows(w, request, &handler.ACL.Query, ACLQuery) {
+		return
+	}
+
+	if zone := handler.signedZone(request); zone != nil {
+		handler.SignedAnswer(w, request, zone)
+		return
+	}
+
+	answers, err := handler.ParseQuestion(handler.recordStore(w, request), request.Question)
+	if handler.needsRecursion(request, err) && !handler.aclAllows(w, request, &handler.ACL.Recursion, ACLRecursion) {
+		return
+	}
 	if err != nil {
 		handler.ServerErrorResponse(w, response, err)
 		return
 	}
 
 	response.Answer = answers
+	setTsig(w, request, response)
 
 	log.Println(response)
 
@@ -73,6 +176,13 @@ func (handler *RecordHandler) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
 	}
 }
 
+// setTsig has the server sign response with the key request was signed with, once the request's signature verified
+func setTsig(w dns.ResponseWriter, request *dns.Msg, response *dns.Msg) {
+	if tsig := request.IsTsig(); tsig != nil && w.TsigStatus() == nil {
+		response.SetTsig(tsig.Hdr.Name, tsig.Algorithm, tsig.Fudge, time.Now().Unix())
+	}
+}
+
 // ServerErrorResponse returns a server error and logs the error that caused it
 func (handler *RecordHandler) ServerErrorResponse(w dns.ResponseWriter, response *dns.Msg, err error) {
 	log.Println(err.Error())
@@ -85,28 +195,102 @@ func (handler *RecordHandler) ServerErrorResponse(w dns.ResponseWriter, response
 	}
 }
 
-// ParseQuestion loops through questions and creates answers based on what is in the record store
-func (handler *RecordHandler) ParseQuestion(questions []dns.Question) ([]dns.RR, error) {
+// RefusedResponse refuses a request and logs why
+func (handler *RecordHandler) RefusedResponse(w dns.ResponseWriter, response *dns.Msg, err error) {
+	log.Println(err.Error())
+
+	response.Rcode = dns.RcodeRefused
+
+	err = w.WriteMsg(response)
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// ParseQuestion loops through questions and creates answers based on what is in store
+func (handler *RecordHandler) ParseQuestion(store RecordStorer, questions []dns.Question) ([]dns.RR, error) {
 	answers := []dns.RR{}
 
 	for _, question := range questions {
-		if question.Qtype != dns.TypeA {
+		var (
+			rrs []dns.RR
+			err error
+		)
+
+		switch question.Qtype {
+		case dns.TypeA, dns.TypeAAAA:
+			rrs, err = handler.ForwardAnswers(store, question)
+		case dns.TypePTR:
+			rrs, err = handler.ReverseAnswers(store, question)
+		case dns.TypeSOA, dns.TypeNS:
+			rrs, err = handler.ZoneAnswers(question)
+		default:
 			return nil, &UnsupportedRecordTypeError{
 				Type: question.Qtype,
 			}
 		}
-
-		record, err := handler.RecordStore.GetRecord(question.Name)
 		if err != nil {
 			return nil, fmt.Errorf("ParseQuery: %w", err)
 		}
 
-		rr, err := NewARecord(record)
+		answers = append(answers, rrs...)
+	}
+
+	return answers, nil
+}
+
+// ForwardAnswers answers A and AAAA questions. A record of the other address family is left out of the answer
+func (handler *RecordHandler) ForwardAnswers(store RecordStorer, question dns.Question) ([]dns.RR, error) {
+	record, err := store.GetRecord(question.Name)
+	if err != nil {
+		return nil, fmt.Errorf("ForwardAnswers: %w", err)
+	}
+
+	var rr dns.RR
+
+	switch {
+	case question.Qtype == dns.TypeAAAA && record.Type() == vinyl.TypeAAAA:
+		rr, err = NewAAAARecord(record)
+	case question.Qtype == dns.TypeA && record.Type() == vinyl.TypeA:
+		rr, err = NewARecord(record)
+	default:
+		return []dns.RR{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ForwardAnswers: %w", err)
+	}
+
+	return []dns.RR{rr}, nil
+}
+
+// ReverseAnswers synthesizes PTR answers from the forward records pointing at the address in the question
+func (handler *RecordHandler) ReverseAnswers(store RecordStorer, question dns.Question) ([]dns.RR, error) {
+	address, err := ReverseAddress(question.Name)
+	if err != nil {
+		return nil, fmt.Errorf("ReverseAnswers: %w", err)
+	}
+
+	records, err := store.GetRecordsByAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("ReverseAnswers: %w", err)
+	}
+
+	answers := []dns.RR{}
+	for _, record := range records {
+		// a wildcard owner name is not a real host so it never makes sense as a ptr target
+		if strings.HasPrefix(record.Domain, "*.") {
+			continue
+		}
+
+		rr, err := NewPTRRecord(question.Name, &record)
 		if err != nil {
-			return nil, fmt.Errorf("ParseQuery: %w", err)
+			return nil, fmt.Errorf("ReverseAnswers: %w", err)
 		}
 
 		answers = append(answers, rr)
+		if handler.CanonicalPTR {
+			break
+		}
 	}
 
 	return answers, nil
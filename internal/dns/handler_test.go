@@ -283,7 +283,7 @@ func TestHandler_ParseQuestion(t *testing.T) {
 				})
 			}
 
-			rrs, err := handler.ParseQuestion(questions)
+			rrs, err := handler.ParseQuestion(store, questions)
 			if test.Err != nil {
 				assert.ErrorContains(err, test.Err.Error(), "error should be the same")
 				return
@@ -307,3 +307,93 @@ func TestHandler_ParseQuestion(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_ForwardAnswers(t *testing.T) {
+	tests := map[string]struct {
+		Qtype   uint16
+		Address string
+		Rrtype  uint16
+	}{
+		"answers A questions with ipv4 records": {
+			Qtype:   miekg.TypeA,
+			Address: "10.0.0.1",
+			Rrtype:  miekg.TypeA,
+		},
+		"answers AAAA questions with ipv6 records": {
+			Qtype:   miekg.TypeAAAA,
+			Address: "fd00::1",
+			Rrtype:  miekg.TypeAAAA,
+		},
+		"leaves ipv6 records out of A answers": {
+			Qtype:   miekg.TypeA,
+			Address: "fd00::1",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			store := mocks.NewRecordStorer(t)
+			store.EXPECT().GetRecord("test.com.").Return(&vinyl.Record{
+				Domain:  "test.com.",
+				Address: test.Address,
+				TTL:     60,
+			}, nil)
+
+			handler := dns.NewRecordHandler(store)
+
+			rrs, err := handler.ForwardAnswers(store, miekg.Question{Name: "test.com.", Qtype: test.Qtype})
+			assert.NoError(err)
+
+			if test.Rrtype == 0 {
+				assert.Empty(rrs)
+				return
+			}
+
+			assert.Len(rrs, 1)
+			assert.Equal(test.Rrtype, rrs[0].Header().Rrtype)
+		})
+	}
+}
+
+func TestHandler_ReverseAnswers(t *testing.T) {
+	records := []vinyl.Record{
+		{Domain: "*.test.com.", Address: "10.0.0.1", TTL: 60},
+		{Domain: "a.test.com.", Address: "10.0.0.1", TTL: 60},
+		{Domain: "b.test.com.", Address: "10.0.0.1", TTL: 60},
+	}
+
+	tests := map[string]struct {
+		Canonical bool
+		Ptrs      []string
+	}{
+		"answers with every non wildcard domain sharing the address": {
+			Canonical: false,
+			Ptrs:      []string{"a.test.com.", "b.test.com."},
+		},
+		"answers with only the canonical domain": {
+			Canonical: true,
+			Ptrs:      []string{"a.test.com."},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			store := mocks.NewRecordStorer(t)
+			store.EXPECT().GetRecordsByAddress("10.0.0.1").Return(records, nil)
+
+			handler := dns.NewRecordHandler(store, dns.WithCanonicalPTR(test.Canonical))
+
+			rrs, err := handler.ReverseAnswers(store, miekg.Question{Name: "1.0.0.10.in-addr.arpa.", Qtype: miekg.TypePTR})
+			assert.NoError(err)
+
+			ptrs := []string{}
+			for _, rr := range rrs {
+				ptrs = append(ptrs, rr.(*miekg.PTR).Ptr)
+			}
+
+			assert.Equal(test.Ptrs, ptrs)
+		})
+	}
+}
@@ -0,0 +1,468 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	vinyl "github.com/platform-edn/vinyl/internal"
+)
+
+// DefaultJournalSize is how many changes are kept per zone for incremental transfers
+const DefaultJournalSize = 1000
+
+// ZoneNotifier is told about every zone change, and must not block
+type ZoneNotifier interface {
+	Changed(origin string)
+}
+
+type JournalStorer interface {
+	ListRecords(vinyl.RecordFilter, vinyl.Page) ([]vinyl.Record, string, error)
+	Watch(context.Context) <-chan vinyl.RecordEvent
+}
+
+// Journal follows the store's changes to the configured zones. Every change bumps the zone's SOA serial and is kept
+// so secondaries can catch up with an incremental transfer instead of transferring the whole zone
+type Journal struct {
+	Store JournalStorer
+	Zones []Zone
+	Size  int
+	// Notifier is told whenever a zone's serial is bumped
+	Notifier ZoneNotifier
+	zones    map[string]*zoneJournal
+	ready    chan struct{}
+	mutex    sync.RWMutex
+}
+
+// Change is what changed in a zone to go from serial From to serial To
+type Change struct {
+	From    uint32
+	To      uint32
+	Removed []vinyl.Record
+	Added   []vinyl.Record
+}
+
+type zoneJournal struct {
+	zone    Zone
+	serial  uint32
+	records map[string]vinyl.Record
+	changes []Change
+}
+
+// JournalOption sets the optional fields of a Journal
+type JournalOption func(*Journal)
+
+// WithJournalSize sets how many changes are kept per zone
+func WithJournalSize(size int) JournalOption {
+	return func(journal *Journal) {
+		journal.Size = size
+	}
+}
+
+// WithNotifier tells notifier about every change to a zone
+func WithNotifier(notifier ZoneNotifier) JournalOption {
+	return func(journal *Journal) {
+		journal.Notifier = notifier
+	}
+}
+
+// NewJournal creates a journal for zones. Serials start at the current unix time so they keep increasing across
+// restarts, as long as fewer changes are made than seconds pass
+func NewJournal(store JournalStorer, zones []Zone, options ...JournalOption) *Journal {
+	journal := &Journal{
+		Store: store,
+		Zones: zones,
+		Size:  DefaultJournalSize,
+		zones: map[string]*zoneJournal{},
+		ready: make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(journal)
+	}
+
+	serial := uint32(time.Now().Unix())
+	for _, zone := range zones {
+		journal.zones[zone.Origin] = &zoneJournal{
+			zone:    zone,
+			serial:  serial,
+			records: map[string]vinyl.Record{},
+		}
+	}
+
+	return journal
+}
+
+// Run keeps the journal in step with the store until ctx is done. When the watch falls behind the store is listed
+// again and the difference is journaled as one change
+func (journal *Journal) Run(ctx context.Context) error {
+	loaded := false
+
+	for {
+		events := journal.Store.Watch(ctx)
+
+		err := journal.load(loaded)
+		if err != nil {
+			return fmt.Errorf("Run: %w", err)
+		}
+
+		if !loaded {
+			loaded = true
+			close(journal.ready)
+		}
+
+		for event := range events {
+			journal.apply(event)
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		log.Println("zone journal fell behind the store, relisting")
+	}
+}
+
+// Ready is closed once the journal has loaded the zones
+func (journal *Journal) Ready() <-chan struct{} {
+	return journal.ready
+}
+
+// load lists the store and journals how every zone differs from it. The first load only sets the starting records
+func (journal *Journal) load(journaled bool) error {
+	records, _, err := journal.Store.ListRecords(vinyl.RecordFilter{}, vinyl.Page{})
+	if err != nil {
+		return fmt.Errorf("load: %w", err)
+	}
+
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	listed := map[string]map[string]vinyl.Record{}
+	for origin := range journal.zones {
+		listed[origin] = map[string]vinyl.Record{}
+	}
+
+	for _, record := range records {
+		zj := journal.find(record.Domain)
+		if zj != nil {
+			listed[zj.zone.Origin][canonicalName(record.Domain)] = record
+		}
+	}
+
+	for origin, zj := range journal.zones {
+		if !journaled {
+			zj.records = listed[origin]
+			continue
+		}
+
+		change := Change{}
+		for domain, record := range zj.records {
+			current, exist := listed[origin][domain]
+			if !exist || !sameRecord(record, current) {
+				change.Removed = append(change.Removed, record)
+			}
+		}
+		for domain, record := range listed[origin] {
+			previous, exist := zj.records[domain]
+			if !exist || !sameRecord(previous, record) {
+				change.Added = append(change.Added, record)
+			}
+		}
+
+		zj.records = listed[origin]
+		journal.commit(zj, change)
+	}
+
+	return nil
+}
+
+// apply journals a single store event. Events that don't change what is served, such as a record created again
+// while relisting, are skipped
+func (journal *Journal) apply(event vinyl.RecordEvent) {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	zj := journal.find(event.Record.Domain)
+	if zj == nil {
+		return
+	}
+
+	domain := canonicalName(event.Record.Domain)
+	previous, exist := zj.records[domain]
+	change := Change{}
+
+	if event.Type == vinyl.RecordRemoved {
+		if !exist {
+			return
+		}
+
+		delete(zj.records, domain)
+		change.Removed = []vinyl.Record{previous}
+		journal.commit(zj, change)
+
+		return
+	}
+
+	if exist && sameRecord(previous, event.Record) {
+		return
+	}
+
+	if exist {
+		change.Removed = []vinyl.Record{previous}
+	}
+	change.Added = []vinyl.Record{event.Record}
+	zj.records[domain] = event.Record
+	journal.commit(zj, change)
+}
+
+// commit bumps the serial for a change and must be called while holding the write lock
+func (journal *Journal) commit(zj *zoneJournal, change Change) {
+	if len(change.Removed) == 0 && len(change.Added) == 0 {
+		return
+	}
+
+	change.From = zj.serial
+	zj.serial++
+	change.To = zj.serial
+
+	zj.changes = append(zj.changes, change)
+	if len(zj.changes) > journal.Size {
+		zj.changes = zj.changes[len(zj.changes)-journal.Size:]
+	}
+
+	if journal.Notifier != nil {
+		journal.Notifier.Changed(zj.zone.Origin)
+	}
+}
+
+// find returns the closest zone containing name and must be called while holding the lock
+func (journal *Journal) find(name string) *zoneJournal {
+	var closest *zoneJournal
+
+	for origin, zj := range journal.zones {
+		if !vinyl.IsSubdomain(name, origin) {
+			continue
+		}
+
+		if closest == nil || len(origin) > len(closest.zone.Origin) {
+			closest = zj
+		}
+	}
+
+	return closest
+}
+
+// Zone returns the closest configured zone containing name
+func (journal *Journal) Zone(name string) (*Zone, error) {
+	journal.mutex.RLock()
+	defer journal.mutex.RUnlock()
+
+	zj := journal.find(name)
+	if zj == nil {
+		return nil, fmt.Errorf("Zone: %w", &MissingZoneError{
+			Name: name,
+		})
+	}
+
+	zone := zj.zone
+
+	return &zone, nil
+}
+
+// SOA returns the zone's SOA record with its current serial
+func (journal *Journal) SOA(origin string) (*dns.SOA, error) {
+	journal.mutex.RLock()
+	defer journal.mutex.RUnlock()
+
+	zj, exist := journal.zones[canonicalName(origin)]
+	if !exist {
+		return nil, fmt.Errorf("SOA: %w", &MissingZoneError{
+			Name: origin,
+		})
+	}
+
+	return zj.soa(zj.serial), nil
+}
+
+// NS returns the zone's NS records
+func (journal *Journal) NS(origin string) ([]dns.RR, error) {
+	journal.mutex.RLock()
+	defer journal.mutex.RUnlock()
+
+	zj, exist := journal.zones[canonicalName(origin)]
+	if !exist {
+		return nil, fmt.Errorf("NS: %w", &MissingZoneError{
+			Name: origin,
+		})
+	}
+
+	return zj.ns(), nil
+}
+
+// Transfer returns the whole zone as sent in an AXFR: the SOA, the zone's records and the SOA again
+func (journal *Journal) Transfer(origin string) ([]dns.RR, error) {
+	journal.mutex.RLock()
+	defer journal.mutex.RUnlock()
+
+	zj, exist := journal.zones[canonicalName(origin)]
+	if !exist {
+		return nil, fmt.Errorf("Transfer: %w", &MissingZoneError{
+			Name: origin,
+		})
+	}
+
+	records := []vinyl.Record{}
+	for _, record := range zj.records {
+		records = append(records, record)
+	}
+
+	soa := zj.soa(zj.serial)
+	rrs := []dns.RR{soa}
+	rrs = append(rrs, zj.ns()...)
+	rrs = append(rrs, recordRRs(records)...)
+	rrs = append(rrs, soa)
+
+	return rrs, nil
+}
+
+// Records returns the records of a zone along with its current serial
+func (journal *Journal) Records(origin string) ([]vinyl.Record, uint32, error) {
+	journal.mutex.RLock()
+	defer journal.mutex.RUnlock()
+
+	zj, exist := journal.zones[canonicalName(origin)]
+	if !exist {
+		return nil, 0, fmt.Errorf("Records: %w", &MissingZoneError{
+			Name: origin,
+		})
+	}
+
+	records := []vinyl.Record{}
+	for _, record := range zj.records {
+		records = append(records, record)
+	}
+
+	return records, zj.serial, nil
+}
+
+// Changes returns the changes since serial as sent in an IXFR, RFC 1995. Each change is the SOA it starts from, the
+// removed records, the SOA it ends at and the added records, all between the current SOA. False is returned when
+// the journal no longer goes back to serial so the whole zone has to be transferred instead
+func (journal *Journal) Changes(origin string, serial uint32) ([]dns.RR, bool, error) {
+	journal.mutex.RLock()
+	defer journal.mutex.RUnlock()
+
+	zj, exist := journal.zones[canonicalName(origin)]
+	if !exist {
+		return nil, false, fmt.Errorf("Changes: %w", &MissingZoneError{
+			Name: origin,
+		})
+	}
+
+	current := zj.soa(zj.serial)
+	if serial == zj.serial {
+		return []dns.RR{current}, true, nil
+	}
+
+	start := -1
+	for i, change := range zj.changes {
+		if change.From == serial {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, false, nil
+	}
+
+	rrs := []dns.RR{current}
+	for _, change := range zj.changes[start:] {
+		rrs = append(rrs, zj.soa(change.From))
+		rrs = append(rrs, recordRRs(change.Removed)...)
+		rrs = append(rrs, zj.soa(change.To))
+		rrs = append(rrs, recordRRs(change.Added)...)
+	}
+	rrs = append(rrs, current)
+
+	return rrs, true, nil
+}
+
+func (zj *zoneJournal) soa(serial uint32) *dns.SOA {
+	zone := zj.zone
+
+	return &dns.SOA{
+		Hdr: dns.RR_Header{
+			Name:   zone.Origin,
+			Rrtype: dns.TypeSOA,
+			Class:  dns.ClassINET,
+			Ttl:    zone.TTL,
+		},
+		Ns:      zone.Nameservers[0],
+		Mbox:    zone.Mailbox,
+		Serial:  serial,
+		Refresh: zone.Refresh,
+		Retry:   zone.Retry,
+		Expire:  zone.Expire,
+		Minttl:  zone.Minimum,
+	}
+}
+
+func (zj *zoneJournal) ns() []dns.RR {
+	rrs := []dns.RR{}
+	for _, ns := range zj.zone.Nameservers {
+		rrs = append(rrs, &dns.NS{
+			Hdr: dns.RR_Header{
+				Name:   zj.zone.Origin,
+				Rrtype: dns.TypeNS,
+				Class:  dns.ClassINET,
+				Ttl:    zj.zone.TTL,
+			},
+			Ns: ns,
+		})
+	}
+
+	return rrs
+}
+
+// recordRRs turns records into A and AAAA records ordered by name. It sorts a copy because the records can be a
+// change stored in the journal that other transfers are reading
+func recordRRs(records []vinyl.Record) []dns.RR {
+	records = append([]vinyl.Record{}, records...)
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Domain < records[j].Domain
+	})
+
+	rrs := []dns.RR{}
+	for _, record := range records {
+		record.Domain = dns.Fqdn(record.Domain)
+
+		var (
+			rr  dns.RR
+			err error
+		)
+		if record.Type() == vinyl.TypeAAAA {
+			rr, err = NewAAAARecord(&record)
+		} else {
+			rr, err = NewARecord(&record)
+		}
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		rrs = append(rrs, rr)
+	}
+
+	return rrs
+}
+
+// sameRecord reports whether two records would be served the same way
+func sameRecord(a vinyl.Record, b vinyl.Record) bool {
+	return strings.EqualFold(a.Domain, b.Domain) && a.Address == b.Address && a.TTL == b.TTL
+}
@@ -0,0 +1,209 @@
+package dns_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	miekg "github.com/miekg/dns"
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func configZone(t *testing.T, origin string, transfer dns.TransferPolicy) dns.Zone {
+	config := &dns.Config{
+		Zones: []dns.Zone{{Origin: origin, Nameservers: []string{"ns1." + origin}, Transfer: transfer}},
+		Keys:  []dns.Key{{Name: "transfer.", Secret: "c2VjcmV0c2VjcmV0c2VjcmV0c2VjcmV0"}},
+	}
+
+	err := config.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return config.Zones[0]
+}
+
+func startJournal(t *testing.T, mem *store.Memory, zones []dns.Zone, options ...dns.JournalOption) *dns.Journal {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	journal := dns.NewJournal(mem, zones, options...)
+	go journal.Run(ctx)
+
+	select {
+	case <-journal.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("journal never loaded")
+	}
+
+	return journal
+}
+
+func serial(t *testing.T, journal *dns.Journal, origin string) func() uint32 {
+	return func() uint32 {
+		soa, err := journal.SOA(origin)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return soa.Serial
+	}
+}
+
+func rrStrings(rrs []miekg.RR) []string {
+	strings := []string{}
+	for _, rr := range rrs {
+		strings = append(strings, rr.String())
+	}
+
+	return strings
+}
+
+func TestJournal(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := store.NewMemory(
+		vinyl.Record{Domain: "web.lab.example.", Address: "10.0.0.1", TTL: 60},
+		vinyl.Record{Domain: "other.example.", Address: "10.0.0.9", TTL: 60},
+	)
+	journal := startJournal(t, mem, []dns.Zone{configZone(t, "lab.example.", dns.TransferPolicy{})})
+	current := serial(t, journal, "lab.example.")
+	start := current()
+
+	rrs, err := journal.Transfer("lab.example.")
+	assert.NoError(err)
+	assert.Equal([]string{
+		"lab.example.\t3600\tIN\tSOA\tns1.lab.example. hostmaster.lab.example. " + strconv.FormatUint(uint64(start), 10) + " 3600 600 604800 60",
+		"lab.example.\t3600\tIN\tNS\tns1.lab.example.",
+		"web.lab.example.\t60\tIN\tA\t10.0.0.1",
+		"lab.example.\t3600\tIN\tSOA\tns1.lab.example. hostmaster.lab.example. " + strconv.FormatUint(uint64(start), 10) + " 3600 600 604800 60",
+	}, rrStrings(rrs), "records outside the zone should be left out")
+
+	_, err = mem.CreateRecord("db.lab.example.", "10.0.0.2", 60)
+	assert.NoError(err)
+	assert.Eventually(func() bool { return current() == start+1 }, time.Second, 10*time.Millisecond)
+
+	_, err = mem.UpdateRecord("web.lab.example.", "10.0.0.3", 60)
+	assert.NoError(err)
+	assert.Eventually(func() bool { return current() == start+2 }, time.Second, 10*time.Millisecond)
+
+	_, err = mem.CreateRecord("api.other.example.", "10.0.0.4", 60)
+	assert.NoError(err)
+	_, err = mem.UpdateRecord("web.lab.example.", "10.0.0.3", 60, vinyl.WithLabels(map[string]string{"team": "web"}))
+	assert.NoError(err)
+
+	rrs, found, err := journal.Changes("lab.example.", start)
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(start+2, current(), "changes outside the zone or to labels should not bump the serial")
+
+	soa := func(serial uint32) string {
+		return "lab.example.\t3600\tIN\tSOA\tns1.lab.example. hostmaster.lab.example. " + strconv.FormatUint(uint64(serial), 10) + " 3600 600 604800 60"
+	}
+	assert.Equal([]string{
+		soa(start + 2),
+		soa(start),
+		soa(start + 1),
+		"db.lab.example.\t60\tIN\tA\t10.0.0.2",
+		soa(start + 1),
+		"web.lab.example.\t60\tIN\tA\t10.0.0.1",
+		soa(start + 2),
+		"web.lab.example.\t60\tIN\tA\t10.0.0.3",
+		soa(start + 2),
+	}, rrStrings(rrs))
+
+	rrs, found, err = journal.Changes("lab.example.", start+2)
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal([]string{soa(start + 2)}, rrStrings(rrs), "an up to date secondary should only get the SOA")
+}
+
+func TestJournal_Size(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := store.NewMemory()
+	journal := startJournal(t, mem, []dns.Zone{configZone(t, "lab.example.", dns.TransferPolicy{})}, dns.WithJournalSize(1))
+	current := serial(t, journal, "lab.example.")
+	start := current()
+
+	for _, domain := range []string{"a.lab.example.", "b.lab.example."} {
+		_, err := mem.CreateRecord(domain, "10.0.0.1", 60)
+		assert.NoError(err)
+	}
+	assert.Eventually(func() bool { return current() == start+2 }, time.Second, 10*time.Millisecond)
+
+	_, found, err := journal.Changes("lab.example.", start)
+	assert.NoError(err)
+	assert.False(found, "changes past the journal size should be forgotten")
+
+	_, found, err = journal.Changes("lab.example.", start+1)
+	assert.NoError(err)
+	assert.True(found)
+
+	_, _, err = journal.Changes("missing.example.", start)
+	assert.ErrorContains(err, (&dns.MissingZoneError{Name: "missing.example."}).Error())
+}
+
+// relistStore is a memory store whose watches only end when the test asks for a relist
+type relistStore struct {
+	*store.Memory
+	relist chan struct{}
+}
+
+func (store relistStore) Watch(ctx context.Context) <-chan vinyl.RecordEvent {
+	events := make(chan vinyl.RecordEvent)
+	go func() {
+		select {
+		case <-store.relist:
+		case <-ctx.Done():
+		}
+		close(events)
+	}()
+
+	return events
+}
+
+func TestJournal_ChangesConcurrently(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mem := relistStore{Memory: store.NewMemory(), relist: make(chan struct{})}
+	journal := dns.NewJournal(mem, []dns.Zone{configZone(t, "lab.example.", dns.TransferPolicy{})})
+	go journal.Run(ctx)
+	<-journal.Ready()
+	current := serial(t, journal, "lab.example.")
+	start := current()
+
+	for _, domain := range []string{"c.lab.example.", "a.lab.example.", "d.lab.example.", "b.lab.example."} {
+		_, err := mem.CreateRecord(domain, "10.0.0.1", 60)
+		assert.NoError(err)
+	}
+	mem.relist <- struct{}{}
+	assert.Eventually(func() bool { return current() == start+1 }, time.Second, 10*time.Millisecond)
+
+	transfers := make([][]string, 10)
+	var wg sync.WaitGroup
+	for i := range transfers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			rrs, found, err := journal.Changes("lab.example.", start)
+			assert.NoError(err)
+			assert.True(found)
+			transfers[i] = rrStrings(rrs)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, transfer := range transfers {
+		assert.Equal(transfers[0], transfer, "transfers replaying the same change should agree")
+	}
+}
@@ -0,0 +1,220 @@
+package dns
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// the operations a key can be bound to
+const (
+	OperationTransfer = "transfer"
+	OperationUpdate   = "update"
+	OperationNotify   = "notify"
+)
+
+// Validate checks the key and puts its names in canonical form, defaulting the algorithm to hmac-sha256
+func (key *Key) Validate() error {
+	key.Name = canonicalName(key.Name)
+
+	if key.Algorithm == "" {
+		key.Algorithm = dns.HmacSHA256
+	}
+	key.Algorithm = canonicalName(key.Algorithm)
+	if key.Algorithm != dns.HmacSHA256 && key.Algorithm != dns.HmacSHA512 {
+		return &InvalidKeyError{Name: key.Name, Reason: fmt.Sprintf("%s is not hmac-sha256 or hmac-sha512", key.Algorithm)}
+	}
+
+	if key.Secret == "" {
+		return &InvalidKeyError{Name: key.Name, Reason: "the secret is empty"}
+	}
+	if _, err := base64.StdEncoding.DecodeString(key.Secret); err != nil {
+		return &InvalidKeyError{Name: key.Name, Reason: "the secret is not base64"}
+	}
+
+	for i, zone := range key.Zones {
+		key.Zones[i] = canonicalName(zone)
+	}
+
+	for _, operation := range key.Operations {
+		switch operation {
+		case OperationTransfer, OperationUpdate, OperationNotify:
+		default:
+			return &InvalidKeyError{Name: key.Name, Reason: fmt.Sprintf("%s is not transfer, update or notify", operation)}
+		}
+	}
+
+	return nil
+}
+
+// allows reports whether the key is bound to zone and operation
+func (key Key) allows(zone string, operation string) bool {
+	return bound(key.Zones, canonicalName(zone)) && bound(key.Operations, operation)
+}
+
+// bound reports whether value is in bindings, where no bindings match every value
+func bound(bindings []string, value string) bool {
+	if len(bindings) == 0 {
+		return true
+	}
+
+	for _, binding := range bindings {
+		if binding == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Keyring holds the TSIG keys requests are signed and verified with. Keys can be added, rotated and removed while
+// the servers are running since the servers look keys up as they verify each request
+type Keyring struct {
+	keys  map[string]Key
+	mutex sync.RWMutex
+}
+
+// NewKeyring creates a keyring holding keys
+func NewKeyring(keys ...Key) (*Keyring, error) {
+	keyring := &Keyring{
+		keys: map[string]Key{},
+	}
+
+	for _, key := range keys {
+		err := keyring.Set(key)
+		if err != nil {
+			return nil, fmt.Errorf("NewKeyring: %w", err)
+		}
+	}
+
+	return keyring, nil
+}
+
+// Set adds a key, or replaces the key with the same name such as when its secret is rotated
+func (keyring *Keyring) Set(key Key) error {
+	err := key.Validate()
+	if err != nil {
+		return fmt.Errorf("Set: %w", err)
+	}
+
+	keyring.mutex.Lock()
+	defer keyring.mutex.Unlock()
+
+	keyring.keys[key.Name] = key
+
+	return nil
+}
+
+// Remove removes the key called name
+func (keyring *Keyring) Remove(name string) (*Key, error) {
+	keyring.mutex.Lock()
+	defer keyring.mutex.Unlock()
+
+	key, exist := keyring.keys[canonicalName(name)]
+	if !exist {
+		return nil, fmt.Errorf("Remove: %w", &MissingKeyError{
+			Name: name,
+		})
+	}
+
+	delete(keyring.keys, key.Name)
+
+	return &key, nil
+}
+
+// Key returns the key called name
+func (keyring *Keyring) Key(name string) (*Key, error) {
+	keyring.mutex.RLock()
+	defer keyring.mutex.RUnlock()
+
+	key, exist := keyring.keys[canonicalName(name)]
+	if !exist {
+		return nil, fmt.Errorf("Key: %w", &MissingKeyError{
+			Name: name,
+		})
+	}
+
+	return &key, nil
+}
+
+// Keys returns every key ordered by name
+func (keyring *Keyring) Keys() []Key {
+	keyring.mutex.RLock()
+	defer keyring.mutex.RUnlock()
+
+	keys := []Key{}
+	for _, key := range keyring.keys {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+
+	return keys
+}
+
+// Allows reports whether the key called name exists and is bound to zone and operation
+func (keyring *Keyring) Allows(name string, zone string, operation string) bool {
+	key, err := keyring.Key(name)
+	if err != nil {
+		return false
+	}
+
+	return key.allows(zone, operation)
+}
+
+// Generate implements dns.TsigProvider, signing msg with the key named in t
+func (keyring *Keyring) Generate(msg []byte, t *dns.TSIG) ([]byte, error) {
+	key, err := keyring.Key(t.Hdr.Name)
+	if err != nil {
+		return nil, dns.ErrSecret
+	}
+
+	if canonicalName(t.Algorithm) != key.Algorithm {
+		return nil, dns.ErrKeyAlg
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(key.Secret)
+	if err != nil {
+		return nil, dns.ErrSecret
+	}
+
+	var h hash.Hash
+	if key.Algorithm == dns.HmacSHA512 {
+		h = hmac.New(sha512.New, secret)
+	} else {
+		h = hmac.New(sha256.New, secret)
+	}
+
+	h.Write(msg)
+
+	return h.Sum(nil), nil
+}
+
+// Verify implements dns.TsigProvider, checking the signature in t against the key named in t
+func (keyring *Keyring) Verify(msg []byte, t *dns.TSIG) error {
+	expected, err := keyring.Generate(msg, t)
+	if err != nil {
+		return err
+	}
+
+	mac, err := hex.DecodeString(t.MAC)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal(expected, mac) {
+		return dns.ErrSig
+	}
+
+	return nil
+}
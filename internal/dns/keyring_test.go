@@ -0,0 +1,188 @@
+package dns_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	miekg "github.com/miekg/dns"
+	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+const rotatedSecret = "cm90YXRlZHJvdGF0ZWRyb3RhdGVkcm90YXRlZA=="
+
+func TestKeyValidate(t *testing.T) {
+	tests := map[string]struct {
+		Key      dns.Key
+		Expected dns.Key
+		Reason   string
+	}{
+		"defaults the algorithm and canonicalises names": {
+			Key:      dns.Key{Name: "DHCP", Secret: transferSecret, Zones: []string{"Lab.Example"}},
+			Expected: dns.Key{Name: "dhcp.", Algorithm: miekg.HmacSHA256, Secret: transferSecret, Zones: []string{"lab.example."}},
+		},
+		"accepts hmac-sha512": {
+			Key:      dns.Key{Name: "dhcp.", Algorithm: "hmac-sha512", Secret: transferSecret, Operations: []string{dns.OperationUpdate}},
+			Expected: dns.Key{Name: "dhcp.", Algorithm: miekg.HmacSHA512, Secret: transferSecret, Operations: []string{dns.OperationUpdate}},
+		},
+		"rejects other algorithms": {
+			Key:    dns.Key{Name: "dhcp.", Algorithm: miekg.HmacSHA1, Secret: transferSecret},
+			Reason: "is not hmac-sha256 or hmac-sha512",
+		},
+		"rejects empty secrets": {
+			Key:    dns.Key{Name: "dhcp."},
+			Reason: "the secret is empty",
+		},
+		"rejects secrets that aren't base64": {
+			Key:    dns.Key{Name: "dhcp.", Secret: "not base64!"},
+			Reason: "the secret is not base64",
+		},
+		"rejects unknown operations": {
+			Key:    dns.Key{Name: "dhcp.", Secret: transferSecret, Operations: []string{"query"}},
+			Reason: "query is not transfer, update or notify",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			err := test.Key.Validate()
+			if test.Reason != "" {
+				var invalid *dns.InvalidKeyError
+				assert.True(errors.As(err, &invalid))
+				assert.ErrorContains(err, test.Reason)
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(test.Expected, test.Key)
+		})
+	}
+}
+
+func TestKeyringAllows(t *testing.T) {
+	tests := map[string]struct {
+		Key       string
+		Zone      string
+		Operation string
+		Allowed   bool
+	}{
+		"allows unbound keys everything": {
+			Key:       "transfer.",
+			Zone:      "other.example.",
+			Operation: dns.OperationNotify,
+			Allowed:   true,
+		},
+		"allows bound keys their zones and operations": {
+			Key:       "dhcp.",
+			Zone:      "Lab.Example",
+			Operation: dns.OperationUpdate,
+			Allowed:   true,
+		},
+		"refuses bound keys other zones": {
+			Key:       "dhcp.",
+			Zone:      "other.example.",
+			Operation: dns.OperationUpdate,
+		},
+		"refuses bound keys other operations": {
+			Key:       "dhcp.",
+			Zone:      "lab.example.",
+			Operation: dns.OperationTransfer,
+		},
+		"refuses missing keys": {
+			Key:       "missing.",
+			Zone:      "lab.example.",
+			Operation: dns.OperationUpdate,
+		},
+	}
+
+	keyring, err := dns.NewKeyring(
+		dns.Key{Name: "transfer.", Secret: transferSecret},
+		dns.Key{Name: "dhcp.", Secret: transferSecret, Zones: []string{"lab.example."}, Operations: []string{dns.OperationUpdate}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.Allowed, keyring.Allows(test.Key, test.Zone, test.Operation))
+		})
+	}
+}
+
+func TestKeyringRemove(t *testing.T) {
+	assert := assert.New(t)
+
+	keyring, err := dns.NewKeyring(dns.Key{Name: "dhcp.", Secret: transferSecret}, dns.Key{Name: "transfer.", Secret: transferSecret})
+	assert.NoError(err)
+
+	key, err := keyring.Remove("DHCP")
+	assert.NoError(err)
+	assert.Equal("dhcp.", key.Name)
+
+	_, err = keyring.Remove("dhcp.")
+	var missing *dns.MissingKeyError
+	assert.True(errors.As(err, &missing))
+
+	assert.Equal([]dns.Key{{Name: "transfer.", Algorithm: miekg.HmacSHA256, Secret: transferSecret}}, keyring.Keys())
+}
+
+// TestKeyringRotation signs requests to a running server while its key is rotated and removed
+func TestKeyringRotation(t *testing.T) {
+	assert := assert.New(t)
+
+	keyring, err := dns.NewKeyring(dns.Key{Name: "dhcp.", Algorithm: miekg.HmacSHA512, Secret: transferSecret})
+	assert.NoError(err)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := miekg.HandlerFunc(func(w miekg.ResponseWriter, request *miekg.Msg) {
+		response := &miekg.Msg{}
+		response.SetReply(request)
+		if tsig := request.IsTsig(); tsig != nil && w.TsigStatus() == nil {
+			response.SetTsig(tsig.Hdr.Name, tsig.Algorithm, 300, time.Now().Unix())
+		} else {
+			response.Rcode = miekg.RcodeRefused
+		}
+
+		w.WriteMsg(response)
+	})
+
+	started := make(chan struct{})
+	server := &miekg.Server{Listener: lis, Handler: handler, TsigProvider: keyring, NotifyStartedFunc: func() { close(started) }}
+	go server.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { server.Shutdown() })
+
+	exchange := func(secret string) int {
+		request := &miekg.Msg{}
+		request.SetQuestion("web.lab.example.", miekg.TypeA)
+		request.SetTsig("dhcp.", miekg.HmacSHA512, 300, time.Now().Unix())
+
+		client := &miekg.Client{Net: "tcp", TsigSecret: map[string]string{"dhcp.": secret}}
+		response, _, err := client.Exchange(request, lis.Addr().String())
+		if err != nil {
+			return miekg.RcodeBadSig
+		}
+
+		return response.Rcode
+	}
+
+	assert.Equal(miekg.RcodeSuccess, exchange(transferSecret))
+
+	err = keyring.Set(dns.Key{Name: "dhcp.", Algorithm: miekg.HmacSHA512, Secret: rotatedSecret})
+	assert.NoError(err)
+	assert.Equal(miekg.RcodeSuccess, exchange(rotatedSecret))
+	assert.Equal(miekg.RcodeRefused, exchange(transferSecret))
+
+	_, err = keyring.Remove("dhcp.")
+	assert.NoError(err)
+	assert.Equal(miekg.RcodeRefused, exchange(rotatedSecret))
+}
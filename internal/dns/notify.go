@@ -0,0 +1,267 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// DefaultNotifyDelay is how long changes are collected before the secondaries are notified
+	DefaultNotifyDelay = time.Second
+	// DefaultNotifyTimeout is how long a secondary has to acknowledge a NOTIFY
+	DefaultNotifyTimeout = 2 * time.Second
+	// DefaultNotifyBackoff is how long to wait before the first retry, doubling with every retry after it
+	DefaultNotifyBackoff = 2 * time.Second
+	// DefaultNotifyRetries is how many times an unacknowledged NOTIFY is sent again
+	DefaultNotifyRetries = 5
+)
+
+// Notifier sends NOTIFY messages, RFC 1996, to the secondaries of a zone when it changes so they don't wait for the
+// refresh interval. Changes made within Delay of each other are sent as one NOTIFY, and a NOTIFY a secondary
+// doesn't acknowledge is sent again with an exponential backoff
+type Notifier struct {
+	Zones   []Zone
+	Delay   time.Duration
+	Timeout time.Duration
+	Backoff time.Duration
+	Retries int
+	// Keyring holds the keys NOTIFY messages are signed with
+	Keyring *Keyring
+	changed map[string]chan struct{}
+}
+
+// NotifierOption sets the optional fields of a Notifier
+type NotifierOption func(*Notifier)
+
+// WithNotifyDelay sets how long changes are collected before the secondaries are notified
+func WithNotifyDelay(delay time.Duration) NotifierOption {
+	return func(notifier *Notifier) {
+		notifier.Delay = delay
+	}
+}
+
+// WithNotifyRetries sets how long a secondary has to acknowledge a NOTIFY, the wait before the first retry and how
+// many times it is sent again
+func WithNotifyRetries(timeout time.Duration, backoff time.Duration, retries int) NotifierOption {
+	return func(notifier *Notifier) {
+		notifier.Timeout = timeout
+		notifier.Backoff = backoff
+		notifier.Retries = retries
+	}
+}
+
+// NewNotifier creates a notifier for the zones that have secondaries to notify, signing NOTIFY messages with the key
+// from keyring bound to notify for the zone
+func NewNotifier(zones []Zone, keyring *Keyring, options ...NotifierOption) *Notifier {
+	notifier := &Notifier{
+		Zones:   []Zone{},
+		Delay:   DefaultNotifyDelay,
+		Timeout: DefaultNotifyTimeout,
+		Backoff: DefaultNotifyBackoff,
+		Retries: DefaultNotifyRetries,
+		Keyring: keyring,
+		changed: map[string]chan struct{}{},
+	}
+
+	for _, option := range options {
+		option(notifier)
+	}
+
+	for _, zone := range zones {
+		if len(zone.Notify) == 0 {
+			continue
+		}
+
+		notifier.Zones = append(notifier.Zones, zone)
+		notifier.changed[zone.Origin] = make(chan struct{}, 1)
+	}
+
+	return notifier
+}
+
+// Changed marks a zone as changed without blocking, the secondaries are notified once Delay passes
+func (notifier *Notifier) Changed(origin string) {
+	changed, exist := notifier.changed[canonicalName(origin)]
+	if !exist {
+		return
+	}
+
+	select {
+	case changed <- struct{}{}:
+	default:
+	}
+}
+
+// Run notifies the secondaries of changed zones until ctx is done
+func (notifier *Notifier) Run(ctx context.Context) error {
+	wg := sync.WaitGroup{}
+
+	for _, zone := range notifier.Zones {
+		wg.Add(1)
+		go func(zone Zone) {
+			defer wg.Done()
+			notifier.follow(ctx, zone)
+		}(zone)
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// follow waits for a zone to change and notifies every secondary of it in parallel. Changes made while the
+// secondaries are being notified are sent in the next round
+func (notifier *Notifier) follow(ctx context.Context, zone Zone) {
+	changed := notifier.changed[zone.Origin]
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		}
+
+		if !sleep(ctx, notifier.Delay) {
+			return
+		}
+
+		// changes made during the delay are covered by this round
+		select {
+		case <-changed:
+		default:
+		}
+
+		wg := sync.WaitGroup{}
+		for _, address := range zone.Notify {
+			wg.Add(1)
+			go func(address string) {
+				defer wg.Done()
+
+				err := notifier.Send(ctx, zone.Origin, address)
+				if err != nil {
+					log.Println(err.Error())
+				}
+			}(address)
+		}
+		wg.Wait()
+	}
+}
+
+// Send sends a NOTIFY for origin to the secondary at address over udp, retrying with a backoff until it is
+// acknowledged. A secondary answering with an error isn't retried
+func (notifier *Notifier) Send(ctx context.Context, origin string, address string) error {
+	client := &dns.Client{Timeout: notifier.Timeout}
+	backoff := notifier.Backoff
+
+	for attempt := 0; ; attempt++ {
+		// every attempt is signed again so its time stays within the key's fudge
+		request := &dns.Msg{}
+		request.SetNotify(origin)
+		request.Authoritative = true
+		client.TsigProvider = notifier.sign(origin, request)
+
+		response, _, err := client.ExchangeContext(ctx, request, address)
+		if err == nil && response.Rcode != dns.RcodeSuccess {
+			return fmt.Errorf("Send: %s answered the NOTIFY for %s with %s", address, origin, dns.RcodeToString[response.Rcode])
+		}
+		if err == nil {
+			log.Printf("notified %s of changes to %s", address, origin)
+			return nil
+		}
+
+		if attempt == notifier.Retries {
+			return fmt.Errorf("Send: NOTIFY for %s was never acknowledged by %s: %w", origin, address, err)
+		}
+
+		if !sleep(ctx, backoff) {
+			return fmt.Errorf("Send: %w", ctx.Err())
+		}
+		backoff *= 2
+	}
+}
+
+// sign signs request with the first key explicitly bound to notify for origin, looked up for every request so
+// rotated secrets are used right away, and returns the provider to verify the response with. The NOTIFY is sent
+// unsigned when no key is bound to it
+func (notifier *Notifier) sign(origin string, request *dns.Msg) dns.TsigProvider {
+	if notifier.Keyring == nil {
+		return nil
+	}
+
+	for _, key := range notifier.Keyring.Keys() {
+		if len(key.Operations) == 0 || !key.allows(origin, OperationNotify) {
+			continue
+		}
+
+		request.SetTsig(key.Name, key.Algorithm, 300, time.Now().Unix())
+
+		return notifier.Keyring
+	}
+
+	return nil
+}
+
+// sleep waits for d and returns false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Notify answers a NOTIFY from a zone's primary, RFC 1996, and starts a refresh of the zone. NOTIFY for zones that
+// aren't secondary zones gets NOTAUTH and NOTIFY from anyone but the primary is refused, as is a signed NOTIFY whose
+// key isn't bound to notify for the zone
+func (handler *RecordHandler) Notify(w dns.ResponseWriter, request *dns.Msg) {
+	response := NewResponse(request)
+
+	if len(request.Question) != 1 || request.Question[0].Qtype != dns.TypeSOA {
+		response.Rcode = dns.RcodeFormatError
+		handler.writeTransfer(w, response, fmt.Errorf("Notify: NOTIFY needs a single SOA question"))
+		return
+	}
+	origin := request.Question[0].Name
+
+	if handler.Secondary == nil {
+		response.Rcode = dns.RcodeNotAuth
+		handler.writeTransfer(w, response, fmt.Errorf("Notify: %w", &MissingZoneError{
+			Name: origin,
+		}))
+		return
+	}
+
+	if tsig := request.IsTsig(); tsig != nil && (w.TsigStatus() != nil || !handler.keyAllows(tsig.Hdr.Name, origin, OperationNotify)) {
+		handler.RefusedResponse(w, response, fmt.Errorf("Notify: %w", &RefusedNotifyError{
+			Origin: origin,
+			Client: w.RemoteAddr().String(),
+		}))
+		return
+	}
+
+	err := handler.Secondary.Notify(origin, w.RemoteAddr())
+
+	var missing *MissingZoneError
+	switch {
+	case errors.As(err, &missing):
+		response.Rcode = dns.RcodeNotAuth
+		handler.writeTransfer(w, response, err)
+	case err != nil:
+		handler.RefusedResponse(w, response, err)
+	default:
+		log.Printf("%s sent a NOTIFY for %s", w.RemoteAddr(), origin)
+
+		response.Authoritative = true
+		handler.writeTransfer(w, response, nil)
+	}
+}
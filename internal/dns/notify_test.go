@@ -0,0 +1,168 @@
+package dns_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	miekg "github.com/miekg/dns"
+	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSecondary counts the NOTIFY messages it receives and how many were signed, leaving the first Drop unanswered
+// and answering the rest with Rcode
+type fakeSecondary struct {
+	Drop     int
+	Rcode    int
+	received int
+	signed   int
+	mutex    sync.Mutex
+}
+
+func (secondary *fakeSecondary) ServeDNS(w miekg.ResponseWriter, request *miekg.Msg) {
+	secondary.mutex.Lock()
+	defer secondary.mutex.Unlock()
+
+	if request.Opcode != miekg.OpcodeNotify {
+		return
+	}
+
+	secondary.received++
+	tsig := request.IsTsig()
+	if tsig != nil && w.TsigStatus() == nil {
+		secondary.signed++
+	}
+
+	if secondary.received <= secondary.Drop {
+		return
+	}
+
+	response := &miekg.Msg{}
+	response.SetRcode(request, secondary.Rcode)
+	if tsig != nil {
+		response.SetTsig(tsig.Hdr.Name, tsig.Algorithm, tsig.Fudge, time.Now().Unix())
+	}
+	w.WriteMsg(response)
+}
+
+func (secondary *fakeSecondary) Received() int {
+	secondary.mutex.Lock()
+	defer secondary.mutex.Unlock()
+
+	return secondary.received
+}
+
+func (secondary *fakeSecondary) Signed() int {
+	secondary.mutex.Lock()
+	defer secondary.mutex.Unlock()
+
+	return secondary.signed
+}
+
+func TestNotifier(t *testing.T) {
+	assert := assert.New(t)
+
+	secondary := &fakeSecondary{}
+	address, _ := serve(t, secondary)
+
+	zone := configZone(t, "lab.example.", dns.TransferPolicy{})
+	zone.Notify = []string{address}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	notifier := dns.NewNotifier([]dns.Zone{zone, configZone(t, "quiet.example.", dns.TransferPolicy{})}, nil, dns.WithNotifyDelay(50*time.Millisecond))
+	go notifier.Run(ctx)
+
+	mem := store.NewMemory()
+	startJournal(t, mem, []dns.Zone{zone}, dns.WithNotifier(notifier))
+
+	for _, domain := range []string{"a.lab.example.", "b.lab.example.", "c.lab.example."} {
+		_, err := mem.CreateRecord(domain, "10.0.0.1", 60)
+		assert.NoError(err)
+	}
+
+	assert.Eventually(func() bool { return secondary.Received() == 1 }, time.Second, 10*time.Millisecond)
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(1, secondary.Received(), "rapid changes should be sent as one NOTIFY")
+
+	_, err := mem.RemoveRecord("a.lab.example.")
+	assert.NoError(err)
+	assert.Eventually(func() bool { return secondary.Received() == 2 }, time.Second, 10*time.Millisecond)
+}
+
+func TestNotifier_Send(t *testing.T) {
+	notifyKey := dns.Key{Name: "transfer.", Secret: transferSecret, Zones: []string{"lab.example."}, Operations: []string{dns.OperationNotify}}
+	transferKey := dns.Key{Name: "transfer.", Secret: transferSecret, Operations: []string{dns.OperationTransfer}}
+
+	tests := map[string]struct {
+		Secondary *fakeSecondary
+		Keys      []dns.Key
+		Received  int
+		Signed    int
+		Err       string
+	}{
+		"sends the NOTIFY once when it is acknowledged": {
+			Secondary: &fakeSecondary{},
+			Received:  1,
+		},
+		"signs the NOTIFY with the key bound to notify for the zone": {
+			Secondary: &fakeSecondary{},
+			Keys:      []dns.Key{notifyKey},
+			Received:  1,
+			Signed:    1,
+		},
+		"signs every retry": {
+			Secondary: &fakeSecondary{Drop: 1},
+			Keys:      []dns.Key{notifyKey},
+			Received:  2,
+			Signed:    2,
+		},
+		"doesn't sign with keys bound to other operations": {
+			Secondary: &fakeSecondary{},
+			Keys:      []dns.Key{transferKey},
+			Received:  1,
+		},
+		"retries until the NOTIFY is acknowledged": {
+			Secondary: &fakeSecondary{Drop: 2},
+			Received:  3,
+		},
+		"gives up once the retries run out": {
+			Secondary: &fakeSecondary{Drop: 10},
+			Received:  4,
+			Err:       "was never acknowledged",
+		},
+		"doesn't retry secondaries answering with an error": {
+			Secondary: &fakeSecondary{Rcode: miekg.RcodeRefused},
+			Received:  1,
+			Err:       "REFUSED",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			keyring, err := dns.NewKeyring(test.Keys...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			address, _ := serve(t, test.Secondary)
+			notifier := dns.NewNotifier(nil, keyring, dns.WithNotifyRetries(50*time.Millisecond, 10*time.Millisecond, 3))
+
+			err = notifier.Send(context.Background(), "lab.example.", address)
+			if test.Err != "" {
+				assert.ErrorContains(err, test.Err)
+			} else {
+				assert.NoError(err)
+			}
+
+			assert.Equal(test.Received, test.Secondary.Received())
+			assert.Equal(test.Signed, test.Secondary.Signed())
+		})
+	}
+}
@@ -3,6 +3,7 @@ package dns
 import (
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/miekg/dns"
 	vinyl "github.com/platform-edn/vinyl/internal"
@@ -26,3 +27,96 @@ func NewARecord(record *vinyl.Record) (*dns.A, error) {
 
 	return rr, nil
 }
+
+func NewAAAARecord(record *vinyl.Record) (*dns.AAAA, error) {
+	err := vinyl.ValidateRecord(record)
+	if err != nil {
+		return nil, fmt.Errorf("NewAAAARecord: %w", err)
+	}
+
+	rr := &dns.AAAA{
+		Hdr: dns.RR_Header{
+			Name:   record.Domain,
+			Rrtype: dns.TypeAAAA,
+			Class:  dns.ClassINET,
+			Ttl:    record.TTL,
+		},
+		AAAA: net.ParseIP(record.Address),
+	}
+
+	return rr, nil
+}
+
+// NewPTRRecord points a reverse lookup name back at the domain of a record
+func NewPTRRecord(name string, record *vinyl.Record) (*dns.PTR, error) {
+	err := vinyl.ValidateRecord(record)
+	if err != nil {
+		return nil, fmt.Errorf("NewPTRRecord: %w", err)
+	}
+
+	rr := &dns.PTR{
+		Hdr: dns.RR_Header{
+			Name:   name,
+			Rrtype: dns.TypePTR,
+			Class:  dns.ClassINET,
+			Ttl:    record.TTL,
+		},
+		Ptr: dns.Fqdn(record.Domain),
+	}
+
+	return rr, nil
+}
+
+// ReverseAddress turns an in-addr.arpa or ip6.arpa name into the address it was made from
+func ReverseAddress(name string) (string, error) {
+	fqdn := strings.ToLower(dns.Fqdn(name))
+	invalid := fmt.Errorf("ReverseAddress: %w", &InvalidReverseNameError{
+		Name: name,
+	})
+
+	switch {
+	case strings.HasSuffix(fqdn, ".in-addr.arpa."):
+		labels := dns.SplitDomainName(strings.TrimSuffix(fqdn, ".in-addr.arpa."))
+		if len(labels) != net.IPv4len {
+			return "", invalid
+		}
+
+		octets := []string{}
+		for i := len(labels) - 1; i >= 0; i-- {
+			octets = append(octets, labels[i])
+		}
+
+		ip := net.ParseIP(strings.Join(octets, "."))
+		if ip == nil || ip.To4() == nil {
+			return "", invalid
+		}
+
+		return ip.String(), nil
+	case strings.HasSuffix(fqdn, ".ip6.arpa."):
+		labels := dns.SplitDomainName(strings.TrimSuffix(fqdn, ".ip6.arpa."))
+		if len(labels) != net.IPv6len*2 {
+			return "", invalid
+		}
+
+		address := strings.Builder{}
+		for i := len(labels) - 1; i >= 0; i-- {
+			if len(labels[i]) != 1 {
+				return "", invalid
+			}
+
+			address.WriteString(labels[i])
+			if i%4 == 0 && i != 0 {
+				address.WriteString(":")
+			}
+		}
+
+		ip := net.ParseIP(address.String())
+		if ip == nil {
+			return "", invalid
+		}
+
+		return ip.String(), nil
+	default:
+		return "", invalid
+	}
+}
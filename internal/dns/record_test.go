@@ -22,6 +22,24 @@ func TestNewARecord(t *testing.T) {
 			},
 			Err: nil,
 		},
+		"creates a new A record for a wildcard": {
+			Record: &vinyl.Record{
+				Domain:  "*.test.com",
+				Address: "127.0.0.1",
+				TTL:     1000,
+			},
+			Err: nil,
+		},
+		"returns an error for a wildcard that isn't the first label": {
+			Record: &vinyl.Record{
+				Domain:  "api.*.test.com",
+				Address: "127.0.0.1",
+				TTL:     1000,
+			},
+			Err: &vinyl.InvalidRecordDomainError{
+				Domain: "api.*.test.com",
+			},
+		},
 		"returns an error for bad domain": {
 			Record: &vinyl.Record{
 				Domain:  "test!.com",
@@ -68,3 +86,88 @@ func TestNewARecord(t *testing.T) {
 		})
 	}
 }
+
+func TestNewAAAARecord(t *testing.T) {
+	assert := assert.New(t)
+	record := &vinyl.Record{
+		Domain:  "test.com",
+		Address: "fd00::1",
+		TTL:     1000,
+	}
+
+	rr, err := dns.NewAAAARecord(record)
+	assert.NoError(err)
+	assert.Equal(record.Domain, rr.Hdr.Name)
+	assert.Equal(net.ParseIP(record.Address), rr.AAAA)
+	assert.Equal(record.TTL, rr.Hdr.Ttl)
+
+	_, err = dns.NewAAAARecord(&vinyl.Record{Domain: "test.com", Address: "bad", TTL: 1000})
+	assert.ErrorContains(err, (&vinyl.InvalidRecordAddressError{Address: "bad"}).Error())
+}
+
+func TestNewPTRRecord(t *testing.T) {
+	assert := assert.New(t)
+	record := &vinyl.Record{
+		Domain:  "test.com",
+		Address: "10.0.0.1",
+		TTL:     1000,
+	}
+
+	rr, err := dns.NewPTRRecord("1.0.0.10.in-addr.arpa.", record)
+	assert.NoError(err)
+	assert.Equal("1.0.0.10.in-addr.arpa.", rr.Hdr.Name)
+	assert.Equal("test.com.", rr.Ptr, "ptr should be fully qualified")
+	assert.Equal(record.TTL, rr.Hdr.Ttl)
+}
+
+func TestReverseAddress(t *testing.T) {
+	tests := map[string]struct {
+		Name    string
+		Address string
+		Err     bool
+	}{
+		"parses ipv4 names": {
+			Name:    "1.0.0.10.in-addr.arpa.",
+			Address: "10.0.0.1",
+		},
+		"parses names without a trailing dot and in any case": {
+			Name:    "1.0.0.10.IN-ADDR.ARPA",
+			Address: "10.0.0.1",
+		},
+		"parses ipv6 names": {
+			Name:    "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa.",
+			Address: "fd00::1",
+		},
+		"rejects partial ipv4 names": {
+			Name: "0.10.in-addr.arpa.",
+			Err:  true,
+		},
+		"rejects bad ipv4 octets": {
+			Name: "1.0.0.300.in-addr.arpa.",
+			Err:  true,
+		},
+		"rejects multi character ipv6 labels": {
+			Name: "01.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa.",
+			Err:  true,
+		},
+		"rejects forward names": {
+			Name: "test.com.",
+			Err:  true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			address, err := dns.ReverseAddress(test.Name)
+			if test.Err {
+				assert.ErrorContains(err, (&dns.InvalidReverseNameError{Name: test.Name}).Error())
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(test.Address, address)
+		})
+	}
+}
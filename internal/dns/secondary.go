@@ -0,0 +1,644 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/store"
+)
+
+const (
+	// SecondaryOwnerPrefix is joined with the origin to form the owner of the records transferred into a zone
+	SecondaryOwnerPrefix = "secondary:"
+	// DefaultStartRetry is how long to wait before trying again when a zone has never been transferred, since there
+	// is no SOA to take the retry interval from yet
+	DefaultStartRetry = 30 * time.Second
+)
+
+// SecondaryStorer is the local store transferred zones are loaded into
+type SecondaryStorer interface {
+	GetRecord(string) (*vinyl.Record, error)
+	GetRecordsByAddress(string) ([]vinyl.Record, error)
+	ListRecords(vinyl.RecordFilter, vinyl.Page) ([]vinyl.Record, string, error)
+	Watch(context.Context) <-chan vinyl.RecordEvent
+	CreateRecord(string, string, uint32, ...vinyl.RecordOption) (*vinyl.Record, error)
+	UpdateRecord(string, string, uint32, ...vinyl.RecordOption) (*vinyl.Record, error)
+	PutRecord(vinyl.Record) (*vinyl.Record, error)
+	RemoveRecord(string) (*vinyl.Record, error)
+	RemoveOwnedRecord(string, string) (*vinyl.Record, error)
+}
+
+// Secondary keeps read only copies of zones transferred from their primaries. A zone is refreshed when the primary's
+// SOA serial changes, checked every refresh interval or when the primary sends a NOTIFY. When the primary can't be
+// reached the copy is served until the zone's expire interval passes without a successful refresh. Store can hold
+// other records too, which are read and written through the secondary as they are
+type Secondary struct {
+	Store SecondaryStorer
+	Zones []SecondaryZone
+	// StartRetry is how long to wait between attempts at a zone's first transfer
+	StartRetry time.Duration
+	// Keyring holds the keys requests to the primaries are signed with
+	Keyring *Keyring
+	zones   map[string]*secondaryZone
+	mutex   sync.RWMutex
+}
+
+type secondaryZone struct {
+	zone SecondaryZone
+	// soa is nil until the zone is first transferred
+	soa       *dns.SOA
+	records   map[string]vinyl.Record
+	refreshed time.Time
+	notify    chan struct{}
+}
+
+// SecondaryOption sets the optional fields of a Secondary
+type SecondaryOption func(*Secondary)
+
+// WithStartRetry sets how long to wait between attempts at a zone's first transfer
+func WithStartRetry(retry time.Duration) SecondaryOption {
+	return func(secondary *Secondary) {
+		secondary.StartRetry = retry
+	}
+}
+
+// NewSecondary creates a secondary for zones, signing requests with the zone's key from keyring
+func NewSecondary(store SecondaryStorer, zones []SecondaryZone, keyring *Keyring, options ...SecondaryOption) *Secondary {
+	secondary := &Secondary{
+		Store:      store,
+		Zones:      zones,
+		StartRetry: DefaultStartRetry,
+		Keyring:    keyring,
+		zones:      map[string]*secondaryZone{},
+	}
+
+	for _, option := range options {
+		option(secondary)
+	}
+
+	for _, zone := range zones {
+		secondary.zones[zone.Origin] = &secondaryZone{
+			zone:    zone,
+			records: map[string]vinyl.Record{},
+			notify:  make(chan struct{}, 1),
+		}
+	}
+
+	return secondary
+}
+
+// Run keeps every zone refreshed until ctx is done
+func (secondary *Secondary) Run(ctx context.Context) error {
+	wg := sync.WaitGroup{}
+
+	for _, sz := range secondary.zones {
+		wg.Add(1)
+		go func(sz *secondaryZone) {
+			defer wg.Done()
+			secondary.follow(ctx, sz)
+		}(sz)
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// follow refreshes a zone every refresh interval, or every retry interval while refreshing fails
+func (secondary *Secondary) follow(ctx context.Context, sz *secondaryZone) {
+	for {
+		err := secondary.Refresh(sz.zone.Origin)
+		if err != nil {
+			log.Println(err.Error())
+		}
+
+		wait := secondary.StartRetry
+		secondary.mutex.RLock()
+		switch {
+		case sz.soa != nil && err != nil:
+			wait = time.Duration(sz.soa.Retry) * time.Second
+		case sz.soa != nil:
+			wait = time.Duration(sz.soa.Refresh) * time.Second
+		}
+		secondary.mutex.RUnlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-sz.notify:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Refresh checks the primary's SOA serial and transfers the zone when it is newer than the local copy. IXFR is
+// asked for once the zone has been transferred, and the primary may answer it with the whole zone
+func (secondary *Secondary) Refresh(origin string) error {
+	sz, err := secondary.zone(origin)
+	if err != nil {
+		return fmt.Errorf("Refresh: %w", err)
+	}
+
+	serial, err := secondary.primarySerial(sz)
+	if err != nil {
+		return fmt.Errorf("Refresh: %s: %w", sz.zone.Origin, err)
+	}
+
+	secondary.mutex.RLock()
+	current := sz.soa
+	secondary.mutex.RUnlock()
+
+	if current != nil && !serialNewer(serial, current.Serial) {
+		secondary.mutex.Lock()
+		sz.refreshed = time.Now()
+		secondary.mutex.Unlock()
+
+		return nil
+	}
+
+	request := &dns.Msg{}
+	if current != nil {
+		request.SetIxfr(sz.zone.Origin, current.Serial, current.Ns, current.Mbox)
+	} else {
+		request.SetAxfr(sz.zone.Origin)
+	}
+
+	rrs, err := secondary.transfer(sz, request)
+	if err != nil {
+		return fmt.Errorf("Refresh: %s: %w", sz.zone.Origin, err)
+	}
+
+	err = secondary.load(sz, rrs, current != nil)
+	if err != nil {
+		return fmt.Errorf("Refresh: %w", err)
+	}
+
+	return nil
+}
+
+// primarySerial asks the zone's primary for its SOA serial
+func (secondary *Secondary) primarySerial(sz *secondaryZone) (uint32, error) {
+	request := &dns.Msg{}
+	request.SetQuestion(sz.zone.Origin, dns.TypeSOA)
+
+	client := &dns.Client{}
+	client.TsigProvider = secondary.sign(sz, request)
+
+	response, _, err := client.Exchange(request, sz.zone.Primary)
+	if err != nil {
+		return 0, fmt.Errorf("primarySerial: %w", err)
+	}
+
+	if response.Rcode != dns.RcodeSuccess {
+		return 0, fmt.Errorf("primarySerial: %s answered the SOA query with %s", sz.zone.Primary, dns.RcodeToString[response.Rcode])
+	}
+
+	for _, rr := range response.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial, nil
+		}
+	}
+
+	return 0, fmt.Errorf("primarySerial: %s answered the SOA query without a SOA", sz.zone.Primary)
+}
+
+// transfer runs a zone transfer from the zone's primary over tcp and returns every record received
+func (secondary *Secondary) transfer(sz *secondaryZone, request *dns.Msg) ([]dns.RR, error) {
+	conn, err := dns.Dial("tcp", sz.zone.Primary)
+	if err != nil {
+		return nil, fmt.Errorf("transfer: %w", err)
+	}
+	defer conn.Close()
+
+	transfer := &dns.Transfer{Conn: conn}
+	transfer.TsigProvider = secondary.sign(sz, request)
+
+	envelopes, err := transfer.In(request, sz.zone.Primary)
+	if err != nil {
+		return nil, fmt.Errorf("transfer: %w", err)
+	}
+
+	rrs := []dns.RR{}
+	for envelope := range envelopes {
+		if envelope.Error != nil {
+			return nil, fmt.Errorf("transfer: %w", envelope.Error)
+		}
+
+		rrs = append(rrs, envelope.RR...)
+	}
+
+	if len(rrs) == 0 {
+		return nil, fmt.Errorf("transfer: %s sent an empty transfer", sz.zone.Primary)
+	}
+	if _, ok := rrs[0].(*dns.SOA); !ok {
+		return nil, fmt.Errorf("transfer: %s sent a transfer that doesn't start with a SOA", sz.zone.Primary)
+	}
+
+	return rrs, nil
+}
+
+// sign signs request with the zone's key, looked up for every request so rotated secrets are used right away, and
+// returns the provider to verify the response with
+func (secondary *Secondary) sign(sz *secondaryZone, request *dns.Msg) dns.TsigProvider {
+	if sz.zone.Key == "" || secondary.Keyring == nil {
+		return nil
+	}
+
+	key, err := secondary.Keyring.Key(sz.zone.Key)
+	if err != nil {
+		log.Println(err.Error())
+		return nil
+	}
+
+	request.SetTsig(key.Name, key.Algorithm, 300, time.Now().Unix())
+
+	return secondary.Keyring
+}
+
+// load applies a transfer to the zone's records and the store. An incremental transfer is the new SOA followed by
+// sequences of the SOA a change starts from, the removed records, the SOA it ends at and the added records, as
+// RFC 1995 describes. Anything else is the whole zone. Only A and AAAA records are kept, and only the last address
+// of a name since the store holds one record per name
+func (secondary *Secondary) load(sz *secondaryZone, rrs []dns.RR, ixfr bool) error {
+	soa := rrs[0].(*dns.SOA)
+	owner := SecondaryOwnerPrefix + sz.zone.Origin
+
+	secondary.mutex.Lock()
+	defer secondary.mutex.Unlock()
+
+	records := map[string]vinyl.Record{}
+	incremental := ixfr && len(rrs) > 2
+	if incremental {
+		_, incremental = rrs[1].(*dns.SOA)
+	}
+
+	switch {
+	case len(rrs) == 1:
+		// the primary has nothing newer than the local copy
+		records = sz.records
+	case incremental:
+		for domain, record := range sz.records {
+			records[domain] = record
+		}
+
+		adding := true
+		for _, rr := range rrs[1 : len(rrs)-1] {
+			if _, ok := rr.(*dns.SOA); ok {
+				adding = !adding
+				continue
+			}
+
+			record, ok := rrRecord(rr, owner, sz.zone.Origin)
+			if !ok {
+				continue
+			}
+
+			if adding {
+				records[record.Domain] = record
+			} else if records[record.Domain].Address == record.Address {
+				delete(records, record.Domain)
+			}
+		}
+	default:
+		for _, rr := range rrs[1 : len(rrs)-1] {
+			record, ok := rrRecord(rr, owner, sz.zone.Origin)
+			if ok {
+				records[record.Domain] = record
+			}
+		}
+	}
+
+	for domain := range sz.records {
+		if _, exist := records[domain]; exist {
+			continue
+		}
+
+		_, err := secondary.Store.RemoveRecord(domain)
+		if err != nil {
+			return fmt.Errorf("load: %w", err)
+		}
+	}
+
+	for domain, record := range records {
+		if previous, exist := sz.records[domain]; exist && sameRecord(previous, record) {
+			continue
+		}
+
+		_, err := secondary.Store.PutRecord(record)
+		if err != nil {
+			return fmt.Errorf("load: %w", err)
+		}
+	}
+
+	if sz.soa == nil || sz.soa.Serial != soa.Serial {
+		log.Printf("transferred %s at serial %v from %s", sz.zone.Origin, soa.Serial, sz.zone.Primary)
+	}
+
+	sz.soa = soa
+	sz.records = records
+	sz.refreshed = time.Now()
+
+	return nil
+}
+
+// Notify starts a refresh of the zone when addr is the zone's primary, RFC 1996
+func (secondary *Secondary) Notify(origin string, addr net.Addr) error {
+	sz, err := secondary.zone(origin)
+	if err != nil {
+		return fmt.Errorf("Notify: %w", err)
+	}
+
+	if !isPrimary(sz.zone.Primary, addr) {
+		return fmt.Errorf("Notify: %w", &RefusedNotifyError{
+			Origin: sz.zone.Origin,
+			Client: addr.String(),
+		})
+	}
+
+	select {
+	case sz.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// zone returns the secondary zone with origin
+func (secondary *Secondary) zone(origin string) (*secondaryZone, error) {
+	sz, exist := secondary.zones[canonicalName(origin)]
+	if !exist {
+		return nil, fmt.Errorf("zone: %w", &MissingZoneError{
+			Name: origin,
+		})
+	}
+
+	return sz, nil
+}
+
+// servedZone returns the secondary zone containing domain and an ExpiredZoneError when it can't be served. Domains
+// outside every secondary zone return neither
+func (secondary *Secondary) servedZone(domain string) (*secondaryZone, error) {
+	secondary.mutex.RLock()
+	defer secondary.mutex.RUnlock()
+
+	var closest *secondaryZone
+	for origin, sz := range secondary.zones {
+		if vinyl.IsSubdomain(domain, origin) && (closest == nil || len(origin) > len(closest.zone.Origin)) {
+			closest = sz
+		}
+	}
+	if closest == nil {
+		return nil, nil
+	}
+
+	if closest.soa == nil || time.Since(closest.refreshed) > time.Duration(closest.soa.Expire)*time.Second {
+		return closest, &ExpiredZoneError{
+			Origin: closest.zone.Origin,
+		}
+	}
+
+	return closest, nil
+}
+
+// GetRecord returns the record for domain unless its zone has expired
+func (secondary *Secondary) GetRecord(domain string) (*vinyl.Record, error) {
+	_, err := secondary.servedZone(domain)
+	if err != nil {
+		return nil, fmt.Errorf("GetRecord: %w", err)
+	}
+
+	record, err := secondary.Store.GetRecord(domain)
+	if err != nil {
+		return nil, fmt.Errorf("GetRecord: %w", err)
+	}
+
+	return record, nil
+}
+
+// GetRecordsByAddress returns the records pointing at address, leaving out those in expired zones
+func (secondary *Secondary) GetRecordsByAddress(address string) ([]vinyl.Record, error) {
+	records, err := secondary.Store.GetRecordsByAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("GetRecordsByAddress: %w", err)
+	}
+
+	served := []vinyl.Record{}
+	for _, record := range records {
+		_, expired := secondary.servedZone(record.Domain)
+		if expired == nil {
+			served = append(served, record)
+		}
+		err = expired
+	}
+
+	if len(served) == 0 {
+		return nil, fmt.Errorf("GetRecordsByAddress: %w", err)
+	}
+
+	return served, nil
+}
+
+// ListRecords lists every record held, including those of expired zones
+func (secondary *Secondary) ListRecords(filter vinyl.RecordFilter, page vinyl.Page) ([]vinyl.Record, string, error) {
+	records, token, err := secondary.Store.ListRecords(filter, page)
+	if err != nil {
+		return nil, "", fmt.Errorf("ListRecords: %w", err)
+	}
+
+	return records, token, nil
+}
+
+func (secondary *Secondary) Watch(ctx context.Context) <-chan vinyl.RecordEvent {
+	return secondary.Store.Watch(ctx)
+}
+
+// CreateRecord creates the record in the store unless domain is inside a secondary zone, which only its primary
+// changes
+func (secondary *Secondary) CreateRecord(domain string, address string, ttl uint32, options ...vinyl.RecordOption) (*vinyl.Record, error) {
+	err := secondary.writable(domain)
+	if err != nil {
+		return nil, fmt.Errorf("CreateRecord: %w", err)
+	}
+
+	record, err := secondary.Store.CreateRecord(domain, address, ttl, options...)
+	if err != nil {
+		return nil, fmt.Errorf("CreateRecord: %w", err)
+	}
+
+	return record, nil
+}
+
+func (secondary *Secondary) UpdateRecord(domain string, address string, ttl uint32, options ...vinyl.RecordOption) (*vinyl.Record, error) {
+	err := secondary.writable(domain)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateRecord: %w", err)
+	}
+
+	record, err := secondary.Store.UpdateRecord(domain, address, ttl, options...)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateRecord: %w", err)
+	}
+
+	return record, nil
+}
+
+func (secondary *Secondary) RemoveRecord(domain string) (*vinyl.Record, error) {
+	err := secondary.writable(domain)
+	if err != nil {
+		return nil, fmt.Errorf("RemoveRecord: %w", err)
+	}
+
+	record, err := secondary.Store.RemoveRecord(domain)
+	if err != nil {
+		return nil, fmt.Errorf("RemoveRecord: %w", err)
+	}
+
+	return record, nil
+}
+
+func (secondary *Secondary) RemoveOwnedRecord(domain string, owner string) (*vinyl.Record, error) {
+	err := secondary.writable(domain)
+	if err != nil {
+		return nil, fmt.Errorf("RemoveOwnedRecord: %w", err)
+	}
+
+	record, err := secondary.Store.RemoveOwnedRecord(domain, owner)
+	if err != nil {
+		return nil, fmt.Errorf("RemoveOwnedRecord: %w", err)
+	}
+
+	return record, nil
+}
+
+// contains reports whether domain is inside one of the secondary zones
+func (secondary *Secondary) contains(domain string) bool {
+	sz, _ := secondary.servedZone(domain)
+
+	return sz != nil
+}
+
+// writable returns a ReadOnlyStoreError for domains inside a secondary zone
+func (secondary *Secondary) writable(domain string) error {
+	if secondary.contains(domain) {
+		return &ReadOnlyStoreError{
+			Domain: domain,
+		}
+	}
+
+	return nil
+}
+
+// secondaryStore answers names inside the secondary's zones from the secondary and every other name from base
+type secondaryStore struct {
+	secondary *Secondary
+	base      RecordStorer
+}
+
+func (s *secondaryStore) GetRecord(domain string) (*vinyl.Record, error) {
+	if s.secondary.contains(domain) {
+		return s.secondary.GetRecord(domain)
+	}
+
+	return s.base.GetRecord(domain)
+}
+
+// GetRecordsByAddress returns base's records pointing at address outside the secondary zones and the secondary's
+// records inside them
+func (s *secondaryStore) GetRecordsByAddress(address string) ([]vinyl.Record, error) {
+	var (
+		missing *store.MissingAddressError
+		expired *ExpiredZoneError
+	)
+
+	base, err := s.base.GetRecordsByAddress(address)
+	if err != nil && !errors.As(err, &missing) {
+		return nil, err
+	}
+
+	transferred, err := s.secondary.GetRecordsByAddress(address)
+	if err != nil && !errors.As(err, &missing) && !errors.As(err, &expired) {
+		return nil, err
+	}
+
+	records := []vinyl.Record{}
+	for _, record := range base {
+		if !s.secondary.contains(record.Domain) {
+			records = append(records, record)
+		}
+	}
+	for _, record := range transferred {
+		if s.secondary.contains(record.Domain) {
+			records = append(records, record)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("GetRecordsByAddress: %w", &store.MissingAddressError{
+			Address: address,
+		})
+	}
+
+	return records, nil
+}
+
+// rrRecord turns an A or AAAA record inside origin into a vinyl record
+func rrRecord(rr dns.RR, owner string, origin string) (vinyl.Record, bool) {
+	record := vinyl.Record{
+		Domain: strings.ToLower(rr.Header().Name),
+		TTL:    rr.Header().Ttl,
+		Owner:  owner,
+	}
+
+	switch rr := rr.(type) {
+	case *dns.A:
+		record.Address = rr.A.String()
+	case *dns.AAAA:
+		record.Address = rr.AAAA.String()
+	default:
+		return record, false
+	}
+
+	return record, vinyl.IsSubdomain(record.Domain, origin)
+}
+
+// serialNewer compares serials with the wrap around of RFC 1982
+func serialNewer(serial uint32, than uint32) bool {
+	return int32(serial-than) > 0
+}
+
+// isPrimary reports whether addr is one of the addresses primary, a host:port, resolves to
+func isPrimary(primary string, addr net.Addr) bool {
+	host, _, err := net.SplitHostPort(primary)
+	if err != nil {
+		return false
+	}
+
+	client, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return false
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return false
+	}
+
+	for _, ip := range ips {
+		if ip.Equal(net.ParseIP(client)) {
+			return true
+		}
+	}
+
+	return false
+}
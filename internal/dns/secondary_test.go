@@ -0,0 +1,161 @@
+package dns_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	miekg "github.com/miekg/dns"
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecondary(t *testing.T) {
+	assert := assert.New(t)
+
+	zone := configZone(t, "lab.example.", dns.TransferPolicy{Allow: []string{"127.0.0.0/8"}})
+	zone.Retry = 1
+	zone.Expire = 2
+	primary := store.NewMemory(vinyl.Record{Domain: "web.lab.example.", Address: "10.0.0.1", TTL: 60})
+	address, stop := serveZones(t, primary, zone)
+
+	local := store.NewMemory()
+	secondary := dns.NewSecondary(
+		local,
+		[]dns.SecondaryZone{{Origin: "lab.example.", Primary: address}},
+		nil,
+		dns.WithStartRetry(10*time.Millisecond),
+	)
+	secondaryAddress, _ := serve(t, dns.NewRecordHandler(local, dns.WithSecondary(secondary)))
+
+	var expired *dns.ExpiredZoneError
+	_, err := secondary.GetRecord("web.lab.example.")
+	assert.True(errors.As(err, &expired), "zones should not be served before they are transferred")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go secondary.Run(ctx)
+
+	has := func(domain string, address string) func() bool {
+		return func() bool {
+			record, err := secondary.GetRecord(domain)
+			return err == nil && record.Address == address
+		}
+	}
+	assert.Eventually(has("web.lab.example.", "10.0.0.1"), time.Second, 10*time.Millisecond)
+
+	record, err := secondary.GetRecord("web.lab.example.")
+	assert.NoError(err)
+	assert.Equal(dns.SecondaryOwnerPrefix+"lab.example.", record.Owner)
+
+	// the refresh interval is an hour so only the NOTIFY can bring in the changes
+	_, err = primary.CreateRecord("db.lab.example.", "10.0.0.2", 60)
+	assert.NoError(err)
+	_, err = primary.RemoveRecord("web.lab.example.")
+	assert.NoError(err)
+
+	notify := &miekg.Msg{}
+	notify.SetNotify("lab.example.")
+	response, err := miekg.Exchange(notify, secondaryAddress)
+	assert.NoError(err)
+	assert.Equal(miekg.RcodeSuccess, response.Rcode)
+	assert.True(response.Authoritative)
+
+	assert.Eventually(has("db.lab.example.", "10.0.0.2"), time.Second, 10*time.Millisecond)
+	assert.Eventually(func() bool {
+		_, err := secondary.GetRecord("web.lab.example.")
+		return err != nil
+	}, time.Second, 10*time.Millisecond, "records removed on the primary should be removed")
+
+	records, err := secondary.GetRecordsByAddress("10.0.0.2")
+	assert.NoError(err)
+	assert.Len(records, 1)
+
+	_, err = secondary.CreateRecord("api.other.example.", "10.0.0.3", 60)
+	assert.NoError(err, "names outside the secondary zones should still be writable")
+
+	response = query(t, secondaryAddress, "api.other.example.", miekg.TypeA, false)
+	assert.Equal(miekg.RcodeSuccess, response.Rcode)
+	assert.Equal([]string{"api.other.example.\t60\tIN\tA\t10.0.0.3"}, rrStrings(response.Answer))
+
+	stop()
+
+	assert.True(has("db.lab.example.", "10.0.0.2")(), "records should be served while the primary is unreachable")
+	assert.Eventually(func() bool {
+		_, err := secondary.GetRecord("db.lab.example.")
+		return errors.As(err, &expired)
+	}, 3*time.Second, 50*time.Millisecond, "records should stop being served once the zone expires")
+
+	_, err = secondary.GetRecordsByAddress("10.0.0.2")
+	assert.True(errors.As(err, &expired))
+
+	response = query(t, secondaryAddress, "api.other.example.", miekg.TypeA, false)
+	assert.Equal(miekg.RcodeSuccess, response.Rcode, "names outside an expired zone should still be answered")
+}
+
+func TestSecondary_Errors(t *testing.T) {
+	tests := map[string]struct {
+		Change func(*dns.Secondary) error
+		Err    error
+	}{
+		"returns ReadOnlyStoreError creating records": {
+			Change: func(secondary *dns.Secondary) error {
+				_, err := secondary.CreateRecord("web.lab.example.", "10.0.0.1", 60)
+				return err
+			},
+			Err: &dns.ReadOnlyStoreError{Domain: "web.lab.example."},
+		},
+		"returns ReadOnlyStoreError updating records": {
+			Change: func(secondary *dns.Secondary) error {
+				_, err := secondary.UpdateRecord("web.lab.example.", "10.0.0.1", 60)
+				return err
+			},
+			Err: &dns.ReadOnlyStoreError{Domain: "web.lab.example."},
+		},
+		"returns ReadOnlyStoreError removing records": {
+			Change: func(secondary *dns.Secondary) error {
+				_, err := secondary.RemoveRecord("web.lab.example.")
+				return err
+			},
+			Err: &dns.ReadOnlyStoreError{Domain: "web.lab.example."},
+		},
+		"creates records outside the secondary zones": {
+			Change: func(secondary *dns.Secondary) error {
+				_, err := secondary.CreateRecord("web.other.example.", "10.0.0.1", 60)
+				return err
+			},
+		},
+		"returns RefusedNotifyError for NOTIFY from anyone but the primary": {
+			Change: func(secondary *dns.Secondary) error {
+				return secondary.Notify("lab.example.", &net.UDPAddr{IP: net.ParseIP("10.0.0.9"), Port: 53})
+			},
+			Err: &dns.RefusedNotifyError{Origin: "lab.example.", Client: "10.0.0.9:53"},
+		},
+		"returns MissingZoneError for NOTIFY of other zones": {
+			Change: func(secondary *dns.Secondary) error {
+				return secondary.Notify("other.example.", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 53})
+			},
+			Err: &dns.MissingZoneError{Name: "other.example."},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			secondary := dns.NewSecondary(store.NewMemory(), []dns.SecondaryZone{{Origin: "lab.example.", Primary: "127.0.0.1:53"}}, nil)
+
+			err := test.Change(secondary)
+			if test.Err == nil {
+				assert.NoError(err)
+				return
+			}
+
+			assert.ErrorContains(err, test.Err.Error())
+		})
+	}
+}
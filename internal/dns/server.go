@@ -1,7 +1,9 @@
 package dns
 
 import (
+	"crypto/tls"
 	"fmt"
+	"time"
 
 	"github.com/miekg/dns"
 )
@@ -18,17 +20,72 @@ type Handler interface {
 	ServeDNS(dns.ResponseWriter, *dns.Msg)
 }
 
-func NewServer(handler dns.Handler, port int, protocol string) *DNSServer {
+// ServerOption sets the optional fields of a DNSServer
+type ServerOption func(*DNSServer)
+
+// WithTsigKeyring verifies signed requests with the keys in keyring
+func WithTsigKeyring(keyring *Keyring) ServerOption {
+	return func(server *DNSServer) {
+		server.TsigProvider = keyring
+	}
+}
+
+// WithTLS serves DNS over TLS, RFC 7858, with config. The server's protocol has to be tcp-tls
+func WithTLS(config *tls.Config) ServerOption {
+	return func(server *DNSServer) {
+		server.TLSConfig = config
+	}
+}
+
+// WithIdleTimeout closes tcp and tls connections no query was read from for timeout
+func WithIdleTimeout(timeout time.Duration) ServerOption {
+	return func(server *DNSServer) {
+		server.IdleTimeout = func() time.Duration {
+			return timeout
+		}
+	}
+}
+
+// WithMaxQueries sets how many queries a client can pipeline on a tcp or tls connection before it is closed, with
+// no limit when queries is -1
+func WithMaxQueries(queries int) ServerOption {
+	return func(server *DNSServer) {
+		server.MaxTCPQueries = queries
+	}
+}
+
+func NewServer(handler dns.Handler, port int, protocol string, options ...ServerOption) *DNSServer {
 	srv := &dns.Server{Addr: fmt.Sprintf(":%v", port), Net: protocol}
 
 	srv.Handler = handler
+	srv.MsgAcceptFunc = AcceptMsg
 	server := &DNSServer{
 		Server: srv,
 	}
 
+	for _, option := range options {
+		option(server)
+	}
+
 	return server
 }
 
+// AcceptMsg accepts the messages dns.DefaultMsgAcceptFunc does as well as dynamic updates, whose prerequisite and
+// update sections can hold any number of records
+func AcceptMsg(header dns.Header) dns.MsgAcceptAction {
+	opcode := int(header.Bits>>11) & 0xF
+	response := header.Bits&(1<<15) != 0
+	if opcode == dns.OpcodeUpdate && !response {
+		if header.Qdcount != 1 {
+			return dns.MsgReject
+		}
+
+		return dns.MsgAccept
+	}
+
+	return dns.DefaultMsgAcceptFunc(header)
+}
+
 func Start(server Server) error {
 	err := server.ListenAndServe()
 	if err != nil {
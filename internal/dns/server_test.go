@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"testing"
 
+	miekg "github.com/miekg/dns"
 	"github.com/platform-edn/vinyl/internal/dns"
 	"github.com/platform-edn/vinyl/internal/dns/mocks"
 	"github.com/stretchr/testify/assert"
@@ -16,10 +17,14 @@ func TestNewServer(t *testing.T) {
 	port := 53
 	protocol := "udp"
 
-	server := dns.NewServer(handler, port, protocol)
+	keyring, err := dns.NewKeyring()
+	assert.NoError(err)
+
+	server := dns.NewServer(handler, port, protocol, dns.WithTsigKeyring(keyring))
 
 	assert.Contains(server.Addr, fmt.Sprint(port), "should contain assigned port")
 	assert.Equal(server.Net, protocol, "should be the same protocol")
+	assert.Equal(keyring, server.TsigProvider, "should verify requests with the keyring")
 }
 
 func TestStart(t *testing.T) {
@@ -51,3 +56,47 @@ func TestStart(t *testing.T) {
 		})
 	}
 }
+
+func TestAcceptMsg(t *testing.T) {
+	tests := map[string]struct {
+		Opcode int
+		Answer int
+		Ns     int
+		Action miekg.MsgAcceptAction
+	}{
+		"accepts updates with many records": {
+			Opcode: miekg.OpcodeUpdate,
+			Answer: 3,
+			Ns:     5,
+			Action: miekg.MsgAccept,
+		},
+		"accepts queries": {
+			Opcode: miekg.OpcodeQuery,
+			Action: miekg.MsgAccept,
+		},
+		"rejects queries with many records": {
+			Opcode: miekg.OpcodeQuery,
+			Ns:     2,
+			Action: miekg.MsgReject,
+		},
+		"rejects other opcodes": {
+			Opcode: miekg.OpcodeStatus,
+			Action: miekg.MsgRejectNotImplemented,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			header := miekg.Header{
+				Bits:    uint16(test.Opcode) << 11,
+				Qdcount: 1,
+				Ancount: uint16(test.Answer),
+				Nscount: uint16(test.Ns),
+			}
+
+			assert.Equal(test.Action, dns.AcceptMsg(header))
+		})
+	}
+}
@@ -0,0 +1,862 @@
+package dns
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// DefaultKeyCheckInterval is how often keys are checked for rollover
+	DefaultKeyCheckInterval = time.Hour
+	// DefaultSignatureCacheSize is how many signatures are cached before the cache is emptied
+	DefaultSignatureCacheSize = 10000
+)
+
+// keyTimeFormat is how key timings are written in key files, the same way BIND writes them
+const keyTimeFormat = "20060102150405"
+
+// Signer holds the DNSSEC keys of the signed zones and signs their answers as they are served. Keys are rolled over
+// on schedule, a KSK with a double signature of the DNSKEY set and a ZSK by publishing its successor ahead of time.
+// An old KSK stays published past its removal time until the parent publishes the DS of its successor and the old DS
+// set has had its TTL to expire from caches. Signatures are cached until a quarter of their validity is left
+type Signer struct {
+	Journal *Journal
+	Zones   []Zone
+	// Interval is how often keys are checked for rollover and stale signatures dropped from the cache
+	Interval  time.Duration
+	CacheSize int
+	// LookupDS returns the DS records the parent publishes for a zone. Their TTL is how long an old KSK is kept
+	// once the DS of its successor shows up
+	LookupDS func(origin string) ([]dns.RR, error)
+	clock    func() time.Time
+	zones    map[string]*zoneSigner
+	cache    map[string]cachedSignature
+	mutex    sync.Mutex
+}
+
+type zoneSigner struct {
+	zone  Zone
+	keys  []*signingKey
+	chain *zoneChain
+}
+
+// signingKey is a KSK or ZSK along with when it is published, starts signing, stops signing and is removed
+type signingKey struct {
+	dnskey   *dns.DNSKEY
+	private  crypto.Signer
+	publish  time.Time
+	activate time.Time
+	inactive time.Time
+	remove   time.Time
+	// successorDS is when the parent was first seen publishing the DS of this KSK's successor
+	successorDS time.Time
+}
+
+type cachedSignature struct {
+	rrsig   *dns.RRSIG
+	refresh time.Time
+}
+
+// SignerOption sets the optional fields of a Signer
+type SignerOption func(*Signer)
+
+// WithKeyCheckInterval sets how often keys are checked for rollover
+func WithKeyCheckInterval(interval time.Duration) SignerOption {
+	return func(signer *Signer) {
+		signer.Interval = interval
+	}
+}
+
+// WithSignatureCacheSize sets how many signatures are cached
+func WithSignatureCacheSize(size int) SignerOption {
+	return func(signer *Signer) {
+		signer.CacheSize = size
+	}
+}
+
+// WithDSLookup replaces how the DS records the parent publishes for a zone are looked up, which is otherwise a
+// query to the resolvers in /etc/resolv.conf
+func WithDSLookup(lookup func(origin string) ([]dns.RR, error)) SignerOption {
+	return func(signer *Signer) {
+		signer.LookupDS = lookup
+	}
+}
+
+// WithSignerClock replaces the clock keys are rolled over and signatures made with, so tests don't have to wait
+func WithSignerClock(clock func() time.Time) SignerOption {
+	return func(signer *Signer) {
+		signer.clock = clock
+	}
+}
+
+// NewSigner creates a signer for the zones with DNSSEC enabled, loading their keys from their key directory and
+// generating the keys they are missing
+func NewSigner(journal *Journal, zones []Zone, options ...SignerOption) (*Signer, error) {
+	signer := &Signer{
+		Journal:   journal,
+		Zones:     []Zone{},
+		Interval:  DefaultKeyCheckInterval,
+		CacheSize: DefaultSignatureCacheSize,
+		LookupDS:  lookupDS,
+		clock:     time.Now,
+		zones:     map[string]*zoneSigner{},
+		cache:     map[string]cachedSignature{},
+	}
+
+	for _, option := range options {
+		option(signer)
+	}
+
+	for _, zone := range zones {
+		if !zone.DNSSEC.Enabled {
+			continue
+		}
+
+		zs := &zoneSigner{
+			zone: zone,
+		}
+
+		if zone.DNSSEC.KeyDirectory != "" {
+			keys, err := loadKeys(zone)
+			if err != nil {
+				return nil, fmt.Errorf("NewSigner: %w", err)
+			}
+
+			zs.keys = keys
+		}
+
+		var published []dns.RR
+		if zs.awaitingDS(signer.clock()) {
+			published = signer.parentDS(zs.zone.Origin)
+		}
+
+		err := signer.roll(zs, signer.clock(), published)
+		if err != nil {
+			return nil, fmt.Errorf("NewSigner: %w", err)
+		}
+
+		signer.Zones = append(signer.Zones, zone)
+		signer.zones[zone.Origin] = zs
+	}
+
+	return signer, nil
+}
+
+// Run rolls keys over until ctx is done
+func (signer *Signer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(signer.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			err := signer.Rollover()
+			if err != nil {
+				log.Println(err.Error())
+			}
+		}
+	}
+}
+
+// Rollover generates the successors of keys nearing the end of their lifetime, removes keys past it and drops
+// signatures that are due to be refreshed from the cache
+func (signer *Signer) Rollover() error {
+	now := signer.clock()
+	published := signer.lookupDueDS(now)
+
+	signer.mutex.Lock()
+	defer signer.mutex.Unlock()
+
+	for key, cached := range signer.cache {
+		if !now.Before(cached.refresh) {
+			delete(signer.cache, key)
+		}
+	}
+
+	for _, zone := range signer.Zones {
+		err := signer.roll(signer.zones[zone.Origin], now, published[zone.Origin])
+		if err != nil {
+			return fmt.Errorf("Rollover: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// lookupDueDS looks up the DS records the parent publishes for every zone with a KSK waiting on the DS of its
+// successor. The lock is only held to find the zones, since resolvers can take seconds to answer or time out
+func (signer *Signer) lookupDueDS(now time.Time) map[string][]dns.RR {
+	signer.mutex.Lock()
+	due := []string{}
+	for _, zone := range signer.Zones {
+		if signer.zones[zone.Origin].awaitingDS(now) {
+			due = append(due, zone.Origin)
+		}
+	}
+	signer.mutex.Unlock()
+
+	published := map[string][]dns.RR{}
+	for _, origin := range due {
+		published[origin] = signer.parentDS(origin)
+	}
+
+	return published
+}
+
+// parentDS returns the DS records the parent publishes for origin, or none when they can't be looked up
+func (signer *Signer) parentDS(origin string) []dns.RR {
+	published, err := signer.LookupDS(origin)
+	if err != nil {
+		log.Printf("looking up the DS of %s: %s", origin, err)
+		return nil
+	}
+
+	return published
+}
+
+// awaitingDS reports whether a KSK of the zone is due for removal before the parent was seen publishing the DS of
+// its successor
+func (zs *zoneSigner) awaitingDS(now time.Time) bool {
+	for _, key := range zs.keys {
+		if key.ksk() && !now.Before(key.remove) && key.successorDS.IsZero() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// roll brings a zone's keys up to date with its schedule and must be called while holding the lock, or before the
+// zone is added. published are the DS records the parent publishes for the zone, looked up beforehand
+func (signer *Signer) roll(zs *zoneSigner, now time.Time, published []dns.RR) error {
+	policy := zs.zone.DNSSEC
+	kskChanged := false
+
+	for _, ksk := range []bool{true, false} {
+		lifetime := policy.ZSKLifetime
+		if ksk {
+			lifetime = policy.KSKLifetime
+		}
+
+		var newest *signingKey
+		for _, key := range zs.keys {
+			if key.ksk() == ksk && (newest == nil || key.activate.After(newest.activate)) {
+				newest = key
+			}
+		}
+
+		activate := now
+		switch {
+		case newest == nil:
+		case !now.Before(newest.inactive.Add(-policy.SignatureValidity)):
+			// the successor is published now and takes over once the newest key's lifetime is up
+			if newest.inactive.After(now) {
+				activate = newest.inactive
+			}
+		default:
+			continue
+		}
+
+		key, err := generateKey(zs, ksk, now, activate, lifetime)
+		if err != nil {
+			return fmt.Errorf("roll: %w", err)
+		}
+
+		err = saveKey(zs.zone, key)
+		if err != nil {
+			return fmt.Errorf("roll: %w", err)
+		}
+
+		zs.keys = append(zs.keys, key)
+		kskChanged = kskChanged || ksk
+		log.Printf("generated %s %v for %s, signing from %s", key.role(), key.dnskey.KeyTag(), zs.zone.Origin, key.activate.Format(time.RFC3339))
+	}
+
+	kept := []*signingKey{}
+	for _, key := range zs.keys {
+		if now.Before(key.remove) {
+			kept = append(kept, key)
+			continue
+		}
+
+		// resolvers can only validate the zone through a KSK whose DS the parent publishes, and keep the old DS set
+		// cached for its TTL after the parent replaced it
+		if key.ksk() && key.successorDS.IsZero() {
+			ttl, ok := zs.successorDS(key, published, now)
+			if ok {
+				key.successorDS = now
+				key.remove = now.Add(time.Duration(ttl) * time.Second)
+				log.Printf("the parent of %s publishes the DS of the successor of KSK %v, removing it at %s", zs.zone.Origin, key.dnskey.KeyTag(), key.remove.Format(time.RFC3339))
+			} else {
+				key.remove = now.Add(signer.Interval)
+				log.Printf("keeping KSK %v of %s until the parent publishes the DS of its successor", key.dnskey.KeyTag(), zs.zone.Origin)
+			}
+
+			err := saveKey(zs.zone, key)
+			if err != nil {
+				return fmt.Errorf("roll: %w", err)
+			}
+
+			kept = append(kept, key)
+			continue
+		}
+
+		err := removeKey(zs.zone, key)
+		if err != nil {
+			return fmt.Errorf("roll: %w", err)
+		}
+
+		kskChanged = kskChanged || key.ksk()
+		log.Printf("removed %s %v of %s", key.role(), key.dnskey.KeyTag(), zs.zone.Origin)
+	}
+	zs.keys = kept
+
+	if kskChanged {
+		ds := zs.ds(now)
+		for _, rr := range ds {
+			log.Printf("the parent of %s should publish %s", zs.zone.Origin, rr)
+		}
+
+		err := saveDS(zs.zone, ds)
+		if err != nil {
+			return fmt.Errorf("roll: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// successorDS finds the DS of a KSK of the zone other than old among the DS records the parent publishes, and
+// returns the TTL of the DS set
+func (zs *zoneSigner) successorDS(old *signingKey, published []dns.RR, now time.Time) (uint32, bool) {
+	for _, key := range zs.keys {
+		if key == old || !key.ksk() || !key.published(now) {
+			continue
+		}
+
+		for _, rr := range published {
+			ds, ok := rr.(*dns.DS)
+			if !ok || ds.KeyTag != key.dnskey.KeyTag() || ds.Algorithm != key.dnskey.Algorithm {
+				continue
+			}
+
+			if own := key.dnskey.ToDS(ds.DigestType); own != nil && strings.EqualFold(own.Digest, ds.Digest) {
+				return dsTTL(published), true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// dsTTL is the longest TTL of the DS records
+func dsTTL(published []dns.RR) uint32 {
+	var ttl uint32
+	for _, rr := range published {
+		if _, ok := rr.(*dns.DS); ok && rr.Header().Ttl > ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+
+	return ttl
+}
+
+// lookupDS asks the resolvers in /etc/resolv.conf for the DS records of origin
+func lookupDS(origin string) ([]dns.RR, error) {
+	config, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil {
+		return nil, fmt.Errorf("lookupDS: %w", err)
+	}
+
+	request := &dns.Msg{}
+	request.SetQuestion(origin, dns.TypeDS)
+	client := &dns.Client{}
+
+	for _, server := range config.Servers {
+		response, _, err := client.Exchange(request, net.JoinHostPort(server, config.Port))
+		if err != nil || response.Rcode != dns.RcodeSuccess {
+			continue
+		}
+
+		return response.Answer, nil
+	}
+
+	return nil, fmt.Errorf("lookupDS: no resolver answered for %s", origin)
+}
+
+// Signs reports whether the zone at origin is signed
+func (signer *Signer) Signs(origin string) bool {
+	_, exist := signer.zones[canonicalName(origin)]
+
+	return exist
+}
+
+// DNSKEY returns the published keys of a zone
+func (signer *Signer) DNSKEY(origin string) ([]dns.RR, error) {
+	signer.mutex.Lock()
+	defer signer.mutex.Unlock()
+
+	zs, exist := signer.zones[canonicalName(origin)]
+	if !exist {
+		return nil, fmt.Errorf("DNSKEY: %w", &MissingZoneError{
+			Name: origin,
+		})
+	}
+
+	return zs.dnskeys(signer.clock()), nil
+}
+
+// DS returns the DS records of a zone's published KSKs, which belong in the parent zone
+func (signer *Signer) DS(origin string) ([]dns.RR, error) {
+	signer.mutex.Lock()
+	defer signer.mutex.Unlock()
+
+	zs, exist := signer.zones[canonicalName(origin)]
+	if !exist {
+		return nil, fmt.Errorf("DS: %w", &MissingZoneError{
+			Name: origin,
+		})
+	}
+
+	return zs.ds(signer.clock()), nil
+}
+
+// Sign returns the signatures of an rrset of a zone. The DNSKEY set is signed with every published KSK so
+// resolvers validate it with the DS of either the old or the new KSK during a rollover, everything else is signed
+// with the active ZSK
+func (signer *Signer) Sign(origin string, rrset []dns.RR) ([]dns.RR, error) {
+	signer.mutex.Lock()
+	defer signer.mutex.Unlock()
+
+	zs, exist := signer.zones[canonicalName(origin)]
+	if !exist {
+		return nil, fmt.Errorf("Sign: %w", &MissingZoneError{
+			Name: origin,
+		})
+	}
+
+	if len(rrset) == 0 {
+		return []dns.RR{}, nil
+	}
+
+	now := signer.clock()
+	keys := []*signingKey{}
+	if rrset[0].Header().Rrtype == dns.TypeDNSKEY {
+		for _, key := range zs.keys {
+			if key.ksk() && key.published(now) {
+				keys = append(keys, key)
+			}
+		}
+	} else if key := zs.activeZSK(now); key != nil {
+		keys = append(keys, key)
+	}
+
+	rrsigs := []dns.RR{}
+	for _, key := range keys {
+		rrsig, err := signer.signature(zs, key, rrset, now)
+		if err != nil {
+			return nil, fmt.Errorf("Sign: %w", err)
+		}
+
+		rrsigs = append(rrsigs, rrsig)
+	}
+
+	return rrsigs, nil
+}
+
+// signature signs rrset with key, or returns the cached signature when it isn't due to be refreshed. It must be
+// called while holding the lock
+func (signer *Signer) signature(zs *zoneSigner, key *signingKey, rrset []dns.RR, now time.Time) (*dns.RRSIG, error) {
+	rrs := []string{}
+	for _, rr := range rrset {
+		rrs = append(rrs, rr.String())
+	}
+	sort.Strings(rrs)
+	cacheKey := fmt.Sprintf("%v/%s", key.dnskey.KeyTag(), strings.Join(rrs, "\n"))
+
+	if cached, exist := signer.cache[cacheKey]; exist && now.Before(cached.refresh) {
+		return dns.Copy(cached.rrsig).(*dns.RRSIG), nil
+	}
+
+	validity := zs.zone.DNSSEC.SignatureValidity
+	rrsig := &dns.RRSIG{
+		Hdr: dns.RR_Header{
+			Ttl: rrset[0].Header().Ttl,
+		},
+		Algorithm:  key.dnskey.Algorithm,
+		KeyTag:     key.dnskey.KeyTag(),
+		SignerName: zs.zone.Origin,
+		// an hour back so clients with a clock a little behind accept it
+		Inception:  uint32(now.Add(-time.Hour).Unix()),
+		Expiration: uint32(now.Add(validity).Unix()),
+	}
+
+	err := rrsig.Sign(key.private, rrset)
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+
+	if len(signer.cache) >= signer.CacheSize {
+		signer.cache = map[string]cachedSignature{}
+	}
+	signer.cache[cacheKey] = cachedSignature{
+		rrsig:   rrsig,
+		refresh: now.Add(validity - validity/4),
+	}
+
+	return dns.Copy(rrsig).(*dns.RRSIG), nil
+}
+
+// chain returns the zone's denial chain, rebuilding it when the journal's serial moved on
+func (signer *Signer) chain(origin string) (*zoneChain, error) {
+	soa, err := signer.Journal.SOA(origin)
+	if err != nil {
+		return nil, fmt.Errorf("chain: %w", err)
+	}
+
+	signer.mutex.Lock()
+	zs := signer.zones[canonicalName(origin)]
+	chain := zs.chain
+	signer.mutex.Unlock()
+
+	if chain != nil && chain.serial == soa.Serial {
+		return chain, nil
+	}
+
+	records, serial, err := signer.Journal.Records(origin)
+	if err != nil {
+		return nil, fmt.Errorf("chain: %w", err)
+	}
+	chain = newZoneChain(zs.zone, records, serial)
+
+	signer.mutex.Lock()
+	zs.chain = chain
+	signer.mutex.Unlock()
+
+	return chain, nil
+}
+
+func (zs *zoneSigner) dnskeys(now time.Time) []dns.RR {
+	rrs := []dns.RR{}
+	for _, key := range zs.keys {
+		if key.published(now) {
+			rrs = append(rrs, dns.Copy(key.dnskey))
+		}
+	}
+
+	return rrs
+}
+
+func (zs *zoneSigner) ds(now time.Time) []dns.RR {
+	rrs := []dns.RR{}
+	for _, key := range zs.keys {
+		if key.ksk() && key.published(now) {
+			rrs = append(rrs, key.dnskey.ToDS(dns.SHA256))
+		}
+	}
+
+	return rrs
+}
+
+// activeZSK returns the ZSK that started signing last
+func (zs *zoneSigner) activeZSK(now time.Time) *signingKey {
+	var active *signingKey
+	for _, key := range zs.keys {
+		if key.ksk() || now.Before(key.activate) || !key.published(now) {
+			continue
+		}
+
+		if active == nil || key.activate.After(active.activate) {
+			active = key
+		}
+	}
+
+	return active
+}
+
+func (key *signingKey) ksk() bool {
+	return key.dnskey.Flags&dns.SEP != 0
+}
+
+func (key *signingKey) role() string {
+	if key.ksk() {
+		return "KSK"
+	}
+
+	return "ZSK"
+}
+
+func (key *signingKey) published(now time.Time) bool {
+	return !now.Before(key.publish) && now.Before(key.remove)
+}
+
+// generateKey creates a key published at publish that signs for lifetime from activate and stays published for a
+// signature validity after
+func generateKey(zs *zoneSigner, ksk bool, publish time.Time, activate time.Time, lifetime time.Duration) (*signingKey, error) {
+	policy := zs.zone.DNSSEC
+
+	dnskey := &dns.DNSKEY{
+		Hdr: dns.RR_Header{
+			Name:   zs.zone.Origin,
+			Rrtype: dns.TypeDNSKEY,
+			Class:  dns.ClassINET,
+			Ttl:    zs.zone.TTL,
+		},
+		Flags:     dns.ZONE,
+		Protocol:  3,
+		Algorithm: policy.algorithm(),
+	}
+	if ksk {
+		dnskey.Flags |= dns.SEP
+	}
+
+	// key tags only have to be unique within the zone's keys, which is just a matter of trying again
+	for {
+		private, err := dnskey.Generate(256)
+		if err != nil {
+			return nil, fmt.Errorf("generateKey: %w", err)
+		}
+
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("generateKey: %T can't sign", private)
+		}
+
+		if zs.hasTag(dnskey.KeyTag()) {
+			continue
+		}
+
+		inactive := activate.Add(lifetime)
+
+		return &signingKey{
+			dnskey:   dnskey,
+			private:  signer,
+			publish:  publish,
+			activate: activate,
+			inactive: inactive,
+			remove:   inactive.Add(policy.SignatureValidity),
+		}, nil
+	}
+}
+
+func (zs *zoneSigner) hasTag(tag uint16) bool {
+	for _, key := range zs.keys {
+		if key.dnskey.KeyTag() == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (policy DNSSECPolicy) algorithm() uint8 {
+	if policy.Algorithm == AlgorithmEd25519 {
+		return dns.ED25519
+	}
+
+	return dns.ECDSAP256SHA256
+}
+
+// keyFile is the path of a key without its extension, named the way BIND names key files
+func keyFile(zone Zone, dnskey *dns.DNSKEY) string {
+	return filepath.Join(zone.DNSSEC.KeyDirectory, fmt.Sprintf("K%s+%03d+%05d", zone.Origin, dnskey.Algorithm, dnskey.KeyTag()))
+}
+
+// saveKey writes a key to the zone's key directory as a .key file holding the DNSKEY and its timings and a .private
+// file holding the private key
+func saveKey(zone Zone, key *signingKey) error {
+	if zone.DNSSEC.KeyDirectory == "" {
+		return nil
+	}
+
+	err := os.MkdirAll(zone.DNSSEC.KeyDirectory, 0o700)
+	if err != nil {
+		return fmt.Errorf("saveKey: %w", err)
+	}
+
+	path := keyFile(zone, key.dnskey)
+	successorDS := ""
+	if !key.successorDS.IsZero() {
+		successorDS = fmt.Sprintf("; SuccessorDS: %s\n", key.successorDS.UTC().Format(keyTimeFormat))
+	}
+
+	public := fmt.Sprintf("; This is a %s, keyid %v, for %s\n; Publish: %s\n; Activate: %s\n; Inactive: %s\n; Delete: %s\n%s%s\n",
+		key.role(), key.dnskey.KeyTag(), zone.Origin,
+		key.publish.UTC().Format(keyTimeFormat),
+		key.activate.UTC().Format(keyTimeFormat),
+		key.inactive.UTC().Format(keyTimeFormat),
+		key.remove.UTC().Format(keyTimeFormat),
+		successorDS,
+		key.dnskey,
+	)
+
+	err = os.WriteFile(path+".private", []byte(key.dnskey.PrivateKeyString(key.private)), 0o600)
+	if err != nil {
+		return fmt.Errorf("saveKey: %w", err)
+	}
+
+	err = os.WriteFile(path+".key", []byte(public), 0o644)
+	if err != nil {
+		return fmt.Errorf("saveKey: %w", err)
+	}
+
+	return nil
+}
+
+func removeKey(zone Zone, key *signingKey) error {
+	if zone.DNSSEC.KeyDirectory == "" {
+		return nil
+	}
+
+	path := keyFile(zone, key.dnskey)
+	for _, file := range []string{path + ".key", path + ".private"} {
+		err := os.Remove(file)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removeKey: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// saveDS writes the DS records for the parent zone to a dsset file in the zone's key directory
+func saveDS(zone Zone, ds []dns.RR) error {
+	if zone.DNSSEC.KeyDirectory == "" {
+		return nil
+	}
+
+	lines := []string{}
+	for _, rr := range ds {
+		lines = append(lines, rr.String())
+	}
+
+	err := os.WriteFile(filepath.Join(zone.DNSSEC.KeyDirectory, "dsset-"+zone.Origin), []byte(strings.Join(lines, "\n")+"\n"), 0o644)
+	if err != nil {
+		return fmt.Errorf("saveDS: %w", err)
+	}
+
+	return nil
+}
+
+// loadKeys reads the zone's keys from its key directory. Keys of another algorithm than the zone's are skipped
+func loadKeys(zone Zone) ([]*signingKey, error) {
+	entries, err := os.ReadDir(zone.DNSSEC.KeyDirectory)
+	if os.IsNotExist(err) {
+		return []*signingKey{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loadKeys: %w", err)
+	}
+
+	keys := []*signingKey{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "K"+zone.Origin+"+") || !strings.HasSuffix(name, ".key") {
+			continue
+		}
+
+		key, err := loadKey(filepath.Join(zone.DNSSEC.KeyDirectory, strings.TrimSuffix(name, ".key")))
+		if err != nil {
+			return nil, fmt.Errorf("loadKeys: %w", err)
+		}
+
+		if key.dnskey.Algorithm != zone.DNSSEC.algorithm() {
+			log.Printf("skipping %s %v of %s since the zone is signed with %s", key.role(), key.dnskey.KeyTag(), zone.Origin, zone.DNSSEC.Algorithm)
+			continue
+		}
+
+		key.dnskey.Hdr.Ttl = zone.TTL
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func loadKey(path string) (*signingKey, error) {
+	file, err := os.Open(path + ".key")
+	if err != nil {
+		return nil, fmt.Errorf("loadKey: %w", err)
+	}
+	defer file.Close()
+
+	key := &signingKey{}
+	timings := map[string]*time.Time{
+		"Publish":     &key.publish,
+		"Activate":    &key.activate,
+		"Inactive":    &key.inactive,
+		"Delete":      &key.remove,
+		"SuccessorDS": &key.successorDS,
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, ";"):
+			field, value, _ := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, ";")), ":")
+			timing, exist := timings[field]
+			values := strings.Fields(value)
+			if !exist || len(values) == 0 {
+				continue
+			}
+
+			*timing, err = time.Parse(keyTimeFormat, values[0])
+			if err != nil {
+				return nil, fmt.Errorf("loadKey: %s: %w", path, err)
+			}
+		default:
+			rr, err := dns.NewRR(line)
+			if err != nil {
+				return nil, fmt.Errorf("loadKey: %s: %w", path, err)
+			}
+
+			dnskey, ok := rr.(*dns.DNSKEY)
+			if !ok {
+				return nil, fmt.Errorf("loadKey: %s holds a %s record instead of a DNSKEY", path, dns.TypeToString[rr.Header().Rrtype])
+			}
+			key.dnskey = dnskey
+		}
+	}
+	if scanner.Err() != nil {
+		return nil, fmt.Errorf("loadKey: %w", scanner.Err())
+	}
+
+	if key.dnskey == nil || key.remove.IsZero() {
+		return nil, fmt.Errorf("loadKey: %s is missing its DNSKEY or timings", path)
+	}
+
+	private, err := os.Open(path + ".private")
+	if err != nil {
+		return nil, fmt.Errorf("loadKey: %w", err)
+	}
+	defer private.Close()
+
+	privateKey, err := key.dnskey.ReadPrivateKey(private, path+".private")
+	if err != nil {
+		return nil, fmt.Errorf("loadKey: %w", err)
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("loadKey: %T can't sign", privateKey)
+	}
+	key.private = signer
+
+	return key, nil
+}
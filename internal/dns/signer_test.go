@@ -0,0 +1,216 @@
+package dns_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	miekg "github.com/miekg/dns"
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// testClock is a clock tests move forward by hand
+type testClock struct {
+	now time.Time
+}
+
+func (clock *testClock) Now() time.Time {
+	return clock.now
+}
+
+func keyTags(rrs []miekg.RR) map[bool][]uint16 {
+	tags := map[bool][]uint16{}
+	for _, rr := range rrs {
+		switch rr := rr.(type) {
+		case *miekg.DNSKEY:
+			ksk := rr.Flags&miekg.SEP != 0
+			tags[ksk] = append(tags[ksk], rr.KeyTag())
+		case *miekg.RRSIG:
+			tags[true] = append(tags[true], rr.KeyTag)
+		}
+	}
+
+	return tags
+}
+
+func TestSignerRollover(t *testing.T) {
+	assert := assert.New(t)
+
+	zone := signedZone(t, "lab.example.", dns.DNSSECPolicy{
+		KSKLifetime:       100 * time.Hour,
+		ZSKLifetime:       40 * time.Hour,
+		SignatureValidity: 10 * time.Hour,
+	})
+	journal := startJournal(t, store.NewMemory(), []dns.Zone{zone})
+	clock := &testClock{now: time.Now()}
+	start := clock.now
+	parent := []miekg.RR{}
+
+	var signer *dns.Signer
+	signer, err := dns.NewSigner(journal, []dns.Zone{zone}, dns.WithSignerClock(clock.Now), dns.WithDSLookup(func(string) ([]miekg.RR, error) {
+		// the signer keeps answering while the parent is looked up
+		answered := make(chan struct{})
+		go func() {
+			signer.DNSKEY("lab.example.")
+			close(answered)
+		}()
+
+		select {
+		case <-answered:
+		case <-time.After(time.Second):
+			t.Error("the DS lookup blocks the signer")
+		}
+
+		return parent, nil
+	}))
+	assert.NoError(err)
+
+	rrset := []miekg.RR{rr(t, "web.lab.example. 60 IN A 10.0.0.1")}
+	signingZSK := func() uint16 {
+		rrsigs, err := signer.Sign("lab.example.", rrset)
+		assert.NoError(err)
+		assert.Len(rrsigs, 1)
+
+		return rrsigs[0].(*miekg.RRSIG).KeyTag
+	}
+	published := func() map[bool][]uint16 {
+		assert.NoError(signer.Rollover())
+
+		keys, err := signer.DNSKEY("lab.example.")
+		assert.NoError(err)
+
+		return keyTags(keys)
+	}
+
+	keys := published()
+	assert.Len(keys[true], 1)
+	assert.Len(keys[false], 1)
+	firstKSK := keys[true][0]
+	first := keys[false][0]
+	assert.Equal(first, signingZSK())
+
+	// the successor is published a signature validity before the zsk's lifetime is up
+	clock.now = start.Add(30 * time.Hour)
+	keys = published()
+	assert.Len(keys[false], 2)
+	assert.Equal(first, signingZSK())
+
+	clock.now = start.Add(40 * time.Hour)
+	keys = published()
+	assert.Len(keys[false], 2)
+	second := signingZSK()
+	assert.NotEqual(first, second)
+
+	clock.now = start.Add(50 * time.Hour)
+	keys = published()
+	assert.Equal([]uint16{second}, keys[false])
+
+	// both ksks sign the DNSKEY set until the old one is removed
+	clock.now = start.Add(90 * time.Hour)
+	keys = published()
+	assert.Len(keys[true], 2)
+
+	dnskeys, err := signer.DNSKEY("lab.example.")
+	assert.NoError(err)
+	rrsigs, err := signer.Sign("lab.example.", dnskeys)
+	assert.NoError(err)
+	assert.ElementsMatch(keys[true], keyTags(rrsigs)[true])
+
+	ds, err := signer.DS("lab.example.")
+	assert.NoError(err)
+	assert.Len(ds, 2)
+
+	// the old ksk stays until the parent publishes the ds of its successor
+	clock.now = start.Add(110 * time.Hour)
+	keys = published()
+	assert.Len(keys[true], 2)
+
+	for _, rr := range ds {
+		if rr.(*miekg.DS).KeyTag != firstKSK {
+			successor := miekg.Copy(rr)
+			successor.Header().Ttl = 7200
+			parent = append(parent, successor)
+		}
+	}
+
+	// and then until the old ds set has expired from caches
+	clock.now = start.Add(112 * time.Hour)
+	keys = published()
+	assert.Len(keys[true], 2)
+
+	clock.now = start.Add(113 * time.Hour)
+	keys = published()
+	assert.Len(keys[true], 2)
+
+	clock.now = start.Add(114 * time.Hour)
+	keys = published()
+	assert.Len(keys[true], 1)
+	assert.NotEqual(firstKSK, keys[true][0])
+}
+
+func TestSignerCache(t *testing.T) {
+	assert := assert.New(t)
+
+	zone := signedZone(t, "lab.example.", dns.DNSSECPolicy{SignatureValidity: 8 * time.Hour})
+	journal := startJournal(t, store.NewMemory(), []dns.Zone{zone})
+	clock := &testClock{now: time.Now()}
+
+	signer, err := dns.NewSigner(journal, []dns.Zone{zone}, dns.WithSignerClock(clock.Now))
+	assert.NoError(err)
+
+	rrset := []miekg.RR{rr(t, "web.lab.example. 60 IN A 10.0.0.1")}
+	sign := func() string {
+		rrsigs, err := signer.Sign("lab.example.", rrset)
+		assert.NoError(err)
+
+		return rrsigs[0].(*miekg.RRSIG).Signature
+	}
+
+	// ecdsa signatures differ every time so an equal one came from the cache
+	cached := sign()
+	clock.now = clock.now.Add(5 * time.Hour)
+	assert.Equal(cached, sign())
+
+	clock.now = clock.now.Add(time.Hour)
+	assert.NotEqual(cached, sign())
+
+	rrset = []miekg.RR{rr(t, "web.lab.example. 60 IN A 10.0.0.2")}
+	assert.NotEqual(cached, sign())
+}
+
+func TestSignerKeyDirectory(t *testing.T) {
+	assert := assert.New(t)
+
+	directory := filepath.Join(t.TempDir(), "keys")
+	zone := signedZone(t, "lab.example.", dns.DNSSECPolicy{KeyDirectory: directory})
+	journal := startJournal(t, store.NewMemory(vinyl.Record{Domain: "web.lab.example.", Address: "10.0.0.1", TTL: 60}), []dns.Zone{zone})
+
+	signer, err := dns.NewSigner(journal, []dns.Zone{zone})
+	assert.NoError(err)
+	keys, err := signer.DNSKEY("lab.example.")
+	assert.NoError(err)
+
+	files, err := filepath.Glob(filepath.Join(directory, "Klab.example.+013+*"))
+	assert.NoError(err)
+	assert.Len(files, 4)
+
+	ds, err := os.ReadFile(filepath.Join(directory, "dsset-lab.example."))
+	assert.NoError(err)
+	assert.Contains(string(ds), "IN\tDS\t")
+
+	restarted, err := dns.NewSigner(journal, []dns.Zone{zone})
+	assert.NoError(err)
+	reloaded, err := restarted.DNSKEY("lab.example.")
+	assert.NoError(err)
+	assert.ElementsMatch(rrStrings(keys), rrStrings(reloaded))
+
+	// signatures from the reloaded keys verify with the published keys
+	rrset := []miekg.RR{rr(t, "web.lab.example. 60 IN A 10.0.0.1")}
+	rrsigs, err := restarted.Sign("lab.example.", rrset)
+	assert.NoError(err)
+	verifySignatures(t, keys, append(rrset, rrsigs...))
+}
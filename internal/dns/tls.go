@@ -0,0 +1,80 @@
+package dns
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDoTPort is the port DNS over TLS is served on, RFC 7858
+	DefaultDoTPort = 853
+	// DefaultIdleTimeout is how long an idle tcp or tls connection is kept open
+	DefaultIdleTimeout = 30 * time.Second
+)
+
+// certificateFile is a certificate read from disk that is read again whenever the certificate file changes
+type certificateFile struct {
+	CertFile    string
+	KeyFile     string
+	modified    time.Time
+	certificate *tls.Certificate
+	mutex       sync.Mutex
+}
+
+// NewTLSConfig serves the certificate in certFile with the key in keyFile to clients that speak alpn protocols.
+// The files are read again whenever the certificate file changes, so renewed certificates are served without a
+// restart
+func NewTLSConfig(certFile string, keyFile string, protocols ...string) (*tls.Config, error) {
+	certificate := &certificateFile{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	}
+
+	_, err := certificate.get(nil)
+	if err != nil {
+		return nil, fmt.Errorf("NewTLSConfig: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     protocols,
+		GetCertificate: certificate.get,
+	}
+
+	return config, nil
+}
+
+// get returns the certificate, reading it again when the file changed. The last certificate keeps being served
+// when a changed one can't be read, such as while it is half written
+func (c *certificateFile) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	info, err := os.Stat(c.CertFile)
+	if err == nil && c.certificate != nil && info.ModTime().Equal(c.modified) {
+		return c.certificate, nil
+	}
+
+	if err == nil {
+		var certificate tls.Certificate
+		certificate, err = tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err == nil {
+			c.certificate = &certificate
+			c.modified = info.ModTime()
+
+			return c.certificate, nil
+		}
+	}
+
+	if c.certificate == nil {
+		return nil, fmt.Errorf("get: %w", err)
+	}
+
+	log.Printf("serving the previous certificate since %s can't be read: %s", c.CertFile, err)
+
+	return c.certificate, nil
+}
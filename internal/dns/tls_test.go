@@ -0,0 +1,150 @@
+package dns_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	miekg "github.com/miekg/dns"
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCertificate writes a self signed certificate for name and returns the paths of the certificate and its key
+func writeCertificate(t *testing.T, directory string, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(directory, "tls.crt"), filepath.Join(directory, "tls.key")
+	for path, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return certFile, keyFile
+}
+
+// serveTLS starts a DNS over TLS server on a random loopback port and returns its address
+func serveTLS(t *testing.T, handler miekg.Handler, options ...dns.ServerOption) string {
+	server := dns.NewServer(handler, 0, "tcp-tls", options...)
+	server.Addr = "127.0.0.1:0"
+
+	started := make(chan struct{})
+	server.NotifyStartedFunc = func() { close(started) }
+	go server.ListenAndServe()
+	<-started
+	t.Cleanup(func() { server.Shutdown() })
+
+	return server.Listener.Addr().String()
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	directory := t.TempDir()
+	certFile, keyFile := writeCertificate(t, directory, "ns1.lab.example")
+
+	config, err := dns.NewTLSConfig(certFile, keyFile, "dot")
+	assert.NoError(err)
+	assert.Equal([]string{"dot"}, config.NextProtos)
+
+	first, err := config.GetCertificate(nil)
+	assert.NoError(err)
+
+	// a renewed certificate is picked up once the file changes
+	writeCertificate(t, directory, "ns1.lab.example")
+	later := time.Now().Add(time.Minute)
+	assert.NoError(os.Chtimes(certFile, later, later))
+
+	renewed, err := config.GetCertificate(nil)
+	assert.NoError(err)
+	assert.NotEqual(first.Certificate, renewed.Certificate)
+
+	// and the last certificate is kept while the file can't be read
+	assert.NoError(os.WriteFile(certFile, []byte("half written"), 0o600))
+	kept, err := config.GetCertificate(nil)
+	assert.NoError(err)
+	assert.Equal(renewed.Certificate, kept.Certificate)
+
+	_, err = dns.NewTLSConfig(filepath.Join(directory, "missing.crt"), keyFile)
+	assert.Error(err)
+}
+
+func TestDNSOverTLS(t *testing.T) {
+	assert := assert.New(t)
+
+	certFile, keyFile := writeCertificate(t, t.TempDir(), "ns1.lab.example")
+	config, err := dns.NewTLSConfig(certFile, keyFile, "dot")
+	assert.NoError(err)
+
+	mem := store.NewMemory(
+		vinyl.Record{Domain: "web.lab.example.", Address: "10.0.0.1", TTL: 60},
+		vinyl.Record{Domain: "db.lab.example.", Address: "10.0.0.2", TTL: 60},
+	)
+	address := serveTLS(t, dns.NewRecordHandler(mem), dns.WithTLS(config), dns.WithIdleTimeout(200*time.Millisecond), dns.WithMaxQueries(-1))
+
+	client := &miekg.Client{Net: "tcp-tls", TLSConfig: &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"dot"}}}
+	conn, err := client.Dial(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	assert.Equal("dot", conn.Conn.(*tls.Conn).ConnectionState().NegotiatedProtocol)
+
+	// queries are pipelined on the connection without waiting for their answers
+	names := []string{"web.lab.example.", "db.lab.example.", "web.lab.example."}
+	for _, name := range names {
+		request := &miekg.Msg{}
+		request.SetQuestion(name, miekg.TypeA)
+		assert.NoError(conn.WriteMsg(request))
+	}
+
+	for _, name := range names {
+		response, err := conn.ReadMsg()
+		assert.NoError(err)
+		assert.Equal(name, response.Answer[0].Header().Name)
+	}
+
+	// idle connections are closed
+	time.Sleep(400 * time.Millisecond)
+	request := &miekg.Msg{}
+	request.SetQuestion("web.lab.example.", miekg.TypeA)
+	conn.WriteMsg(request)
+	_, err = conn.ReadMsg()
+	assert.Error(err)
+}
@@ -0,0 +1,238 @@
+package dns
+
+import (
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// transferChunk is how many records are sent per message of a zone transfer
+const transferChunk = 200
+
+func isTransfer(qtype uint16) bool {
+	return qtype == dns.TypeAXFR || qtype == dns.TypeIXFR
+}
+
+// ZoneAnswers answers SOA and NS questions for the apex of a configured zone
+func (handler *RecordHandler) ZoneAnswers(question dns.Question) ([]dns.RR, error) {
+	if handler.Journal == nil {
+		return nil, fmt.Errorf("ZoneAnswers: %w", &UnsupportedRecordTypeError{
+			Type: question.Qtype,
+		})
+	}
+
+	zone, err := handler.Journal.Zone(question.Name)
+	if err != nil {
+		return nil, fmt.Errorf("ZoneAnswers: %w", err)
+	}
+
+	if canonicalName(question.Name) != zone.Origin {
+		return []dns.RR{}, nil
+	}
+
+	if question.Qtype == dns.TypeNS {
+		rrs, err := handler.Journal.NS(zone.Origin)
+		if err != nil {
+			return nil, fmt.Errorf("ZoneAnswers: %w", err)
+		}
+
+		return rrs, nil
+	}
+
+	soa, err := handler.Journal.SOA(zone.Origin)
+	if err != nil {
+		return nil, fmt.Errorf("ZoneAnswers: %w", err)
+	}
+
+	return []dns.RR{soa}, nil
+}
+
+// Transfer answers AXFR and IXFR requests for configured zones. AXFR is only served over tcp, and IXFR over udp only
+// gets the current SOA so the secondary retries over tcp as RFC 1995 describes. IXFR falls back to the whole zone
+// when the journal doesn't go back far enough
+func (handler *RecordHandler) Transfer(w dns.ResponseWriter, request *dns.Msg) {
+	response := NewResponse(request)
+	question := request.Question[0]
+
+	if handler.Journal == nil {
+		handler.RefusedResponse(w, response, fmt.Errorf("Transfer: %w", &MissingZoneError{
+			Name: question.Name,
+		}))
+		return
+	}
+
+	zone, err := handler.Journal.Zone(question.Name)
+	if err != nil || zone.Origin != canonicalName(question.Name) {
+		response.Rcode = dns.RcodeNotAuth
+		handler.writeTransfer(w, response, fmt.Errorf("Transfer: %w", &MissingZoneError{
+			Name: question.Name,
+		}))
+		return
+	}
+
+	if !handler.TransferAllowed(w, request, zone) {
+		handler.RefusedResponse(w, response, fmt.Errorf("Transfer: %w", &RefusedTransferError{
+			Origin: zone.Origin,
+			Client: w.RemoteAddr().String(),
+		}))
+		return
+	}
+
+	_, udp := w.RemoteAddr().(*net.UDPAddr)
+
+	var rrs []dns.RR
+	switch {
+	case question.Qtype == dns.TypeAXFR && udp:
+		handler.RefusedResponse(w, response, fmt.Errorf("Transfer: AXFR of %s was requested over udp", zone.Origin))
+		return
+	case question.Qtype == dns.TypeAXFR:
+		rrs, err = handler.Journal.Transfer(zone.Origin)
+	default:
+		rrs, err = handler.incremental(zone.Origin, request, udp)
+	}
+	if err != nil {
+		handler.ServerErrorResponse(w, response, err)
+		return
+	}
+
+	if udp {
+		response.Authoritative = true
+		response.Answer = rrs
+		handler.writeTransfer(w, response, nil)
+		return
+	}
+
+	envelopes := make(chan *dns.Envelope, len(rrs)/transferChunk+1)
+	for start := 0; start < len(rrs); start += transferChunk {
+		end := start + transferChunk
+		if end > len(rrs) {
+			end = len(rrs)
+		}
+
+		envelopes <- &dns.Envelope{RR: rrs[start:end]}
+	}
+	close(envelopes)
+
+	transfer := &dns.Transfer{}
+	err = transfer.Out(w, request, envelopes)
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	log.Printf("transferred %s to %s in %v records", zone.Origin, w.RemoteAddr(), len(rrs))
+}
+
+// incremental builds an IXFR answer from the serial in the request's authority section
+func (handler *RecordHandler) incremental(origin string, request *dns.Msg, udp bool) ([]dns.RR, error) {
+	var serial *uint32
+	for _, rr := range request.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			serial = &soa.Serial
+		}
+	}
+	if serial == nil {
+		return nil, fmt.Errorf("incremental: IXFR of %s has no SOA in its authority section", origin)
+	}
+
+	if udp {
+		soa, err := handler.Journal.SOA(origin)
+		if err != nil {
+			return nil, fmt.Errorf("incremental: %w", err)
+		}
+
+		return []dns.RR{soa}, nil
+	}
+
+	rrs, found, err := handler.Journal.Changes(origin, *serial)
+	if err != nil {
+		return nil, fmt.Errorf("incremental: %w", err)
+	}
+
+	if !found {
+		rrs, err = handler.Journal.Transfer(origin)
+		if err != nil {
+			return nil, fmt.Errorf("incremental: %w", err)
+		}
+	}
+
+	return rrs, nil
+}
+
+// TransferAllowed checks the client's address and TSIG key against the zone's transfer policy. TSIG signatures are
+// verified by the server, which knows every key a zone's policy can name
+func (handler *RecordHandler) TransferAllowed(w dns.ResponseWriter, request *dns.Msg, zone *Zone) bool {
+	policy := zone.Transfer
+	if len(policy.networks) == 0 && len(policy.Keys) == 0 {
+		return false
+	}
+
+	if len(policy.networks) > 0 && !containsAddress(policy.networks, w.RemoteAddr()) {
+		return false
+	}
+
+	if len(policy.Keys) == 0 {
+		return true
+	}
+
+	tsig := request.IsTsig()
+	if tsig == nil || w.TsigStatus() != nil {
+		return false
+	}
+
+	for _, key := range policy.Keys {
+		if canonicalName(tsig.Hdr.Name) == key {
+			return handler.keyAllows(key, zone.Origin, OperationTransfer)
+		}
+	}
+
+	return false
+}
+
+// keyAllows checks the key is bound to zone and operation in the keyring, when there is one
+func (handler *RecordHandler) keyAllows(name string, zone string, operation string) bool {
+	if handler.Keyring == nil {
+		return true
+	}
+
+	return handler.Keyring.Allows(name, zone, operation)
+}
+
+func (handler *RecordHandler) writeTransfer(w dns.ResponseWriter, response *dns.Msg, err error) {
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	err = w.WriteMsg(response)
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// containsAddress reports whether the ip of addr is in one of networks
+func containsAddress(networks []*net.IPNet, addr net.Addr) bool {
+	var ip net.IP
+
+	switch addr := addr.(type) {
+	case *net.UDPAddr:
+		ip = addr.IP
+	case *net.TCPAddr:
+		ip = addr.IP
+	default:
+		host, _, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			return false
+		}
+
+		ip = net.ParseIP(host)
+	}
+
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
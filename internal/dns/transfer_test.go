@@ -0,0 +1,203 @@
+package dns_test
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	miekg "github.com/miekg/dns"
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+const transferSecret = "c2VjcmV0c2VjcmV0c2VjcmV0c2VjcmV0"
+
+// serveZones serves the zones over tcp and udp on loopback and returns their address
+func serveZones(t *testing.T, mem *store.Memory, zones ...dns.Zone) (string, func()) {
+	journal := startJournal(t, mem, zones)
+
+	return serve(t, dns.NewRecordHandler(mem, dns.WithJournal(journal)))
+}
+
+// serve serves handler over tcp and udp on loopback and returns their address and a function stopping both
+func serve(t *testing.T, handler miekg.Handler) (string, func()) {
+	secrets := map[string]string{"transfer.": transferSecret}
+	servers := []*miekg.Server{}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenPacket("udp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, server := range []*miekg.Server{
+		{Listener: lis, Handler: handler, TsigSecret: secrets, MsgAcceptFunc: dns.AcceptMsg},
+		{PacketConn: conn, Handler: handler, TsigSecret: secrets, MsgAcceptFunc: dns.AcceptMsg},
+	} {
+		started := make(chan struct{})
+		server.NotifyStartedFunc = func() { close(started) }
+
+		go server.ActivateAndServe()
+		<-started
+		servers = append(servers, server)
+	}
+
+	stopped := false
+	stop := func() {
+		if stopped {
+			return
+		}
+
+		stopped = true
+		for _, server := range servers {
+			server.Shutdown()
+		}
+	}
+	t.Cleanup(stop)
+
+	return lis.Addr().String(), stop
+}
+
+// transfer runs a zone transfer and returns every record received, or the first error
+func transfer(address string, request *miekg.Msg) ([]string, error) {
+	conn, err := miekg.DialTimeout("tcp", address, time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := &miekg.Transfer{Conn: conn, TsigSecret: map[string]string{"transfer.": transferSecret}}
+	envelopes, err := transfer.In(request, address)
+	if err != nil {
+		return nil, err
+	}
+
+	rrs := []string{}
+	for envelope := range envelopes {
+		if envelope.Error != nil {
+			return nil, envelope.Error
+		}
+
+		rrs = append(rrs, rrStrings(envelope.RR)...)
+	}
+
+	return rrs, nil
+}
+
+func TestTransfer(t *testing.T) {
+	mem := store.NewMemory(vinyl.Record{Domain: "web.lab.example.", Address: "10.0.0.1", TTL: 60})
+	address, _ := serveZones(t, mem,
+		configZone(t, "lab.example.", dns.TransferPolicy{Allow: []string{"127.0.0.1"}}),
+		configZone(t, "signed.example.", dns.TransferPolicy{Keys: []string{"transfer"}}),
+		configZone(t, "remote.example.", dns.TransferPolicy{Allow: []string{"10.0.0.0/8"}}),
+		configZone(t, "closed.example.", dns.TransferPolicy{}),
+	)
+
+	axfr := func(zone string, signed bool) *miekg.Msg {
+		request := &miekg.Msg{}
+		request.SetAxfr(zone)
+		if signed {
+			request.SetTsig("transfer.", miekg.HmacSHA256, 300, time.Now().Unix())
+		}
+
+		return request
+	}
+
+	tests := map[string]struct {
+		Request *miekg.Msg
+		Records int
+		Rcode   int
+	}{
+		"transfers a zone to an allowed address": {
+			Request: axfr("lab.example.", false),
+			Records: 4,
+		},
+		"transfers a zone to a signed request": {
+			Request: axfr("signed.example.", true),
+			Records: 3,
+		},
+		"refuses unsigned requests for a zone needing a key": {
+			Request: axfr("signed.example.", false),
+			Rcode:   miekg.RcodeRefused,
+		},
+		"refuses addresses outside the allowed networks": {
+			Request: axfr("remote.example.", false),
+			Rcode:   miekg.RcodeRefused,
+		},
+		"refuses zones without a transfer policy": {
+			Request: axfr("closed.example.", false),
+			Rcode:   miekg.RcodeRefused,
+		},
+		"answers NOTAUTH for names that aren't zones": {
+			Request: axfr("web.lab.example.", false),
+			Rcode:   miekg.RcodeNotAuth,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			rrs, err := transfer(address, test.Request)
+			if test.Rcode != miekg.RcodeSuccess {
+				assert.ErrorContains(err, fmt.Sprintf("rcode: %d", test.Rcode))
+				return
+			}
+
+			assert.NoError(err)
+			assert.Len(rrs, test.Records)
+		})
+	}
+}
+
+func TestTransfer_Incremental(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := store.NewMemory(vinyl.Record{Domain: "web.lab.example.", Address: "10.0.0.1", TTL: 60})
+	address, _ := serveZones(t, mem, configZone(t, "lab.example.", dns.TransferPolicy{Allow: []string{"127.0.0.0/8"}}))
+
+	soa := func() *miekg.SOA {
+		request := &miekg.Msg{}
+		request.SetQuestion("lab.example.", miekg.TypeSOA)
+
+		response, err := miekg.Exchange(request, address)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return response.Answer[0].(*miekg.SOA)
+	}
+	start := soa()
+
+	_, err := mem.CreateRecord("db.lab.example.", "10.0.0.2", 60)
+	assert.NoError(err)
+	assert.Eventually(func() bool { return soa().Serial == start.Serial+1 }, time.Second, 10*time.Millisecond)
+
+	ixfr := &miekg.Msg{}
+	ixfr.SetIxfr("lab.example.", start.Serial, start.Ns, start.Mbox)
+
+	rrs, err := transfer(address, ixfr)
+	assert.NoError(err)
+	assert.Len(rrs, 5, "the change should be sent between the current SOA")
+	assert.Contains(rrs, "db.lab.example.\t60\tIN\tA\t10.0.0.2")
+
+	response, err := miekg.Exchange(ixfr, address)
+	assert.NoError(err)
+	assert.Equal([]miekg.RR{soa()}, response.Answer, "IXFR over udp should only get the current SOA")
+
+	ixfr.SetIxfr("lab.example.", start.Serial-1, start.Ns, start.Mbox)
+	rrs, err = transfer(address, ixfr)
+	assert.NoError(err)
+	assert.Len(rrs, 5, "serials the journal doesn't know should get the whole zone")
+
+	axfr := &miekg.Msg{}
+	axfr.SetAxfr("lab.example.")
+	response, err = miekg.Exchange(axfr, address)
+	assert.NoError(err)
+	assert.Equal(miekg.RcodeRefused, response.Rcode, "AXFR over udp should be refused")
+}
@@ -0,0 +1,426 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/store"
+)
+
+// RecordUpdater is the store dynamic updates are applied to
+type RecordUpdater interface {
+	GetRecord(string) (*vinyl.Record, error)
+	ListRecords(vinyl.RecordFilter, vinyl.Page) ([]vinyl.Record, string, error)
+	CreateRecord(string, string, uint32, ...vinyl.RecordOption) (*vinyl.Record, error)
+	UpdateRecord(string, string, uint32, ...vinyl.RecordOption) (*vinyl.Record, error)
+	RemoveOwnedRecord(string, string) (*vinyl.Record, error)
+}
+
+// recordChange replaces Before with After, either of which is nil when the record is created or removed
+type recordChange struct {
+	Before *vinyl.Record
+	After  *vinyl.Record
+}
+
+// Update applies a dynamic update, RFC 2136, to a configured zone. The update has to be signed with one of the
+// zone's update keys. Every prerequisite is checked and every change worked out before the store is touched, and
+// changes already made are undone if a later one fails, so an update is applied completely or not at all. Updates
+// are serialized against each other but not against the api and sources, which can change a record between the
+// prerequisites being checked and the update being applied, or while a failed update is being undone. Records are
+// changed the way the api changes them, so records owned by a source can't be updated
+func (handler *RecordHandler) Update(w dns.ResponseWriter, request *dns.Msg) {
+	response := NewResponse(request)
+
+	if len(request.Question) != 1 || request.Question[0].Qtype != dns.TypeSOA {
+		response.Rcode = dns.RcodeFormatError
+		handler.writeTransfer(w, response, fmt.Errorf("Update: an update needs a single SOA zone"))
+		return
+	}
+	origin := canonicalName(request.Question[0].Name)
+
+	if handler.Journal == nil || handler.Updater == nil {
+		response.Rcode = dns.RcodeNotAuth
+		handler.writeTransfer(w, response, fmt.Errorf("Update: %w", &MissingZoneError{
+			Name: origin,
+		}))
+		return
+	}
+
+	zone, err := handler.Journal.Zone(origin)
+	if err != nil || zone.Origin != origin {
+		response.Rcode = dns.RcodeNotAuth
+		handler.writeTransfer(w, response, fmt.Errorf("Update: %w", &MissingZoneError{
+			Name: origin,
+		}))
+		return
+	}
+
+	if !handler.updateAllowed(w, request, zone) {
+		handler.RefusedResponse(w, response, fmt.Errorf("Update: %w", &RefusedUpdateError{
+			Origin: zone.Origin,
+			Client: w.RemoteAddr().String(),
+		}))
+		return
+	}
+
+	handler.updateMutex.Lock()
+	defer handler.updateMutex.Unlock()
+
+	changes, err := handler.planUpdate(zone, request)
+	if err == nil {
+		err = handler.applyUpdate(changes)
+	}
+
+	var failed *FailedUpdateError
+	switch {
+	case errors.As(err, &failed):
+		response.Rcode = failed.Rcode
+		handler.writeTransfer(w, response, err)
+	case err != nil:
+		handler.ServerErrorResponse(w, response, err)
+	default:
+		log.Printf("%s updated %v records in %s", w.RemoteAddr(), len(changes), zone.Origin)
+		handler.writeTransfer(w, response, nil)
+	}
+}
+
+// updateAllowed checks the update is signed with one of the zone's update keys. TSIG signatures are verified by
+// the server
+func (handler *RecordHandler) updateAllowed(w dns.ResponseWriter, request *dns.Msg, zone *Zone) bool {
+	tsig := request.IsTsig()
+	if tsig == nil || w.TsigStatus() != nil {
+		return false
+	}
+
+	for _, key := range zone.Update.Keys {
+		if canonicalName(tsig.Hdr.Name) == key {
+			return handler.keyAllows(key, zone.Origin, OperationUpdate)
+		}
+	}
+
+	return false
+}
+
+// planUpdate checks the prerequisites against the store and works out the changes the update section makes
+func (handler *RecordHandler) planUpdate(zone *Zone, request *dns.Msg) ([]recordChange, error) {
+	current := map[string]*vinyl.Record{}
+	lookup := func(name string) (*vinyl.Record, error) {
+		if record, exist := current[name]; exist {
+			return record, nil
+		}
+
+		record, err := handler.exactRecord(name)
+		if err != nil {
+			return nil, err
+		}
+
+		current[name] = record
+		return record, nil
+	}
+
+	for _, rr := range request.Answer {
+		err := checkPrerequisite(zone, rr, lookup)
+		if err != nil {
+			return nil, fmt.Errorf("planUpdate: %w", err)
+		}
+	}
+
+	err := prescanUpdate(zone, request.Ns)
+	if err != nil {
+		return nil, fmt.Errorf("planUpdate: %w", err)
+	}
+
+	updated := map[string]*vinyl.Record{}
+	order := []string{}
+	for _, rr := range request.Ns {
+		name := strings.ToLower(rr.Header().Name)
+		if _, seen := updated[name]; !seen {
+			record, err := lookup(name)
+			if err != nil {
+				return nil, fmt.Errorf("planUpdate: %w", err)
+			}
+
+			updated[name] = record
+			order = append(order, name)
+		}
+
+		record, err := updateRecord(updated[name], rr)
+		if err != nil {
+			return nil, fmt.Errorf("planUpdate: %w", err)
+		}
+
+		updated[name] = record
+	}
+
+	changes := []recordChange{}
+	for _, name := range order {
+		before, after := current[name], updated[name]
+		if before == nil && after == nil || before != nil && after != nil && sameRecord(*before, *after) {
+			continue
+		}
+
+		changes = append(changes, recordChange{Before: before, After: after})
+	}
+
+	return changes, nil
+}
+
+// exactRecord returns the record at name without expanding wildcards, or nil when there isn't one. The store renames
+// the records it answers from a wildcard, so when a wildcard above name exists the name is looked for in a listing
+func (handler *RecordHandler) exactRecord(name string) (*vinyl.Record, error) {
+	var missing *store.MissingRecordError
+
+	record, err := handler.Updater.GetRecord(name)
+	if errors.As(err, &missing) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("exactRecord: %w", err)
+	}
+
+	if !strings.EqualFold(dns.Fqdn(record.Domain), name) {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(name, "*.") || !handler.underWildcard(name) {
+		return record, nil
+	}
+
+	records, _, err := handler.Updater.ListRecords(vinyl.RecordFilter{DomainSuffix: name}, vinyl.Page{})
+	if err != nil {
+		return nil, fmt.Errorf("exactRecord: %w", err)
+	}
+
+	for _, record := range records {
+		if strings.EqualFold(dns.Fqdn(record.Domain), name) {
+			return &record, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// underWildcard reports whether a wildcard record exists at any ancestor of name
+func (handler *RecordHandler) underWildcard(name string) bool {
+	for ancestor := parentName(name); ancestor != "."; ancestor = parentName(ancestor) {
+		if _, err := handler.Updater.GetRecord("*." + ancestor); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// prescanUpdate checks the update section is well formed before any of it is worked out, RFC 2136 section 3.4.1.3
+func prescanUpdate(zone *Zone, rrs []dns.RR) error {
+	for _, rr := range rrs {
+		header := rr.Header()
+		name := strings.ToLower(header.Name)
+
+		if !vinyl.IsSubdomain(name, zone.Origin) {
+			return &FailedUpdateError{Name: name, Rcode: dns.RcodeNotZone, Reason: "the name is outside the zone"}
+		}
+
+		switch header.Class {
+		case dns.ClassINET:
+			if metaType(header.Rrtype) || header.Rrtype == dns.TypeANY {
+				return &FailedUpdateError{Name: name, Rcode: dns.RcodeFormatError, Reason: fmt.Sprintf("%s records can't be added", dns.TypeToString[header.Rrtype])}
+			}
+			if header.Ttl == 0 || header.Ttl > math.MaxInt32 {
+				return &FailedUpdateError{Name: name, Rcode: dns.RcodeFormatError, Reason: fmt.Sprintf("%v is not a valid ttl", header.Ttl)}
+			}
+		case dns.ClassANY:
+			if header.Ttl != 0 || header.Rdlength != 0 || metaType(header.Rrtype) {
+				return &FailedUpdateError{Name: name, Rcode: dns.RcodeFormatError, Reason: "deleting an rrset needs a zero ttl and no rdata"}
+			}
+		case dns.ClassNONE:
+			if header.Ttl != 0 || metaType(header.Rrtype) || header.Rrtype == dns.TypeANY {
+				return &FailedUpdateError{Name: name, Rcode: dns.RcodeFormatError, Reason: "deleting a record needs a zero ttl and a record type"}
+			}
+		default:
+			return &FailedUpdateError{Name: name, Rcode: dns.RcodeFormatError, Reason: "the update has an unknown class"}
+		}
+	}
+
+	return nil
+}
+
+// metaType reports whether rrtype only has a meaning in queries, leaving out ANY which deletes every rrset
+func metaType(rrtype uint16) bool {
+	switch rrtype {
+	case dns.TypeAXFR, dns.TypeIXFR, dns.TypeMAILA, dns.TypeMAILB, dns.TypeOPT, dns.TypeTSIG:
+		return true
+	}
+
+	return false
+}
+
+// checkPrerequisite checks a single prerequisite, RFC 2136 section 3.2
+func checkPrerequisite(zone *Zone, rr dns.RR, lookup func(string) (*vinyl.Record, error)) error {
+	header := rr.Header()
+	name := strings.ToLower(header.Name)
+
+	if !vinyl.IsSubdomain(name, zone.Origin) {
+		return &FailedUpdateError{Name: name, Rcode: dns.RcodeNotZone, Reason: "the prerequisite is outside the zone"}
+	}
+
+	record, err := lookup(name)
+	if err != nil {
+		return fmt.Errorf("checkPrerequisite: %w", err)
+	}
+
+	apex := name == zone.Origin
+	inUse := record != nil || apex
+	exists := func(rrtype uint16) bool {
+		if apex && (rrtype == dns.TypeSOA || rrtype == dns.TypeNS) {
+			return true
+		}
+
+		return record != nil && rrtype == recordType(*record)
+	}
+
+	switch {
+	case header.Class == dns.ClassANY && header.Rrtype == dns.TypeANY:
+		if !inUse {
+			return &FailedUpdateError{Name: name, Rcode: dns.RcodeNameError, Reason: "the name is not in use"}
+		}
+	case header.Class == dns.ClassANY:
+		if !exists(header.Rrtype) {
+			return &FailedUpdateError{Name: name, Rcode: dns.RcodeNXRrset, Reason: fmt.Sprintf("there is no %s record", dns.TypeToString[header.Rrtype])}
+		}
+	case header.Class == dns.ClassNONE && header.Rrtype == dns.TypeANY:
+		if inUse {
+			return &FailedUpdateError{Name: name, Rcode: dns.RcodeYXDomain, Reason: "the name is in use"}
+		}
+	case header.Class == dns.ClassNONE:
+		if exists(header.Rrtype) {
+			return &FailedUpdateError{Name: name, Rcode: dns.RcodeYXRrset, Reason: fmt.Sprintf("there is a %s record", dns.TypeToString[header.Rrtype])}
+		}
+	case header.Class == dns.ClassINET:
+		address, ok := rrAddress(rr)
+		if !ok || record == nil || !sameAddress(record.Address, address) {
+			return &FailedUpdateError{Name: name, Rcode: dns.RcodeNXRrset, Reason: "the record doesn't match"}
+		}
+	default:
+		return &FailedUpdateError{Name: name, Rcode: dns.RcodeFormatError, Reason: "the prerequisite has an unknown class"}
+	}
+
+	return nil
+}
+
+// updateRecord applies a single update, RFC 2136 section 3.4.2, to the record at its name. Since a name holds a
+// single record adding an address replaces the one already there. The generated SOA and NS records can't be changed
+func updateRecord(record *vinyl.Record, rr dns.RR) (*vinyl.Record, error) {
+	header := rr.Header()
+	name := strings.ToLower(header.Name)
+
+	switch header.Class {
+	case dns.ClassINET:
+		address, ok := rrAddress(rr)
+		if !ok {
+			return nil, &FailedUpdateError{Name: name, Rcode: dns.RcodeNotImplemented, Reason: fmt.Sprintf("%s records can't be updated", dns.TypeToString[header.Rrtype])}
+		}
+
+		updated := &vinyl.Record{Domain: name, Address: address, TTL: header.Ttl}
+		if record != nil {
+			updated.Labels = record.Labels
+		}
+
+		return updated, nil
+	case dns.ClassANY:
+		if record != nil && (header.Rrtype == dns.TypeANY || header.Rrtype == recordType(*record)) {
+			return nil, nil
+		}
+	case dns.ClassNONE:
+		address, ok := rrAddress(rr)
+		if record != nil && ok && sameAddress(record.Address, address) {
+			return nil, nil
+		}
+	default:
+		return nil, &FailedUpdateError{Name: name, Rcode: dns.RcodeFormatError, Reason: "the update has an unknown class"}
+	}
+
+	return record, nil
+}
+
+// applyUpdate makes the changes, undoing the ones already made when one fails
+func (handler *RecordHandler) applyUpdate(changes []recordChange) error {
+	for i, change := range changes {
+		err := handler.applyChange(change)
+		if err == nil {
+			continue
+		}
+
+		for j := i - 1; j >= 0; j-- {
+			undo := handler.applyChange(recordChange{Before: changes[j].After, After: changes[j].Before})
+			if undo != nil {
+				log.Println(undo.Error())
+			}
+		}
+
+		var ownerConflict *store.OwnerConflictError
+		if errors.As(err, &ownerConflict) {
+			return fmt.Errorf("applyUpdate: %w", &FailedUpdateError{Name: recordName(change), Rcode: dns.RcodeRefused, Reason: ownerConflict.Error()})
+		}
+
+		return fmt.Errorf("applyUpdate: %w", err)
+	}
+
+	return nil
+}
+
+func (handler *RecordHandler) applyChange(change recordChange) error {
+	var err error
+
+	switch {
+	case change.Before == nil:
+		_, err = handler.Updater.CreateRecord(change.After.Domain, change.After.Address, change.After.TTL, vinyl.WithLabels(change.After.Labels))
+	case change.After == nil:
+		_, err = handler.Updater.RemoveOwnedRecord(change.Before.Domain, "")
+	default:
+		_, err = handler.Updater.UpdateRecord(change.Before.Domain, change.After.Address, change.After.TTL, vinyl.WithLabels(change.After.Labels))
+	}
+	if err != nil {
+		return fmt.Errorf("applyChange: %w", err)
+	}
+
+	return nil
+}
+
+func recordName(change recordChange) string {
+	if change.Before != nil {
+		return change.Before.Domain
+	}
+
+	return change.After.Domain
+}
+
+func recordType(record vinyl.Record) uint16 {
+	if record.Type() == vinyl.TypeAAAA {
+		return dns.TypeAAAA
+	}
+
+	return dns.TypeA
+}
+
+// rrAddress returns the address of an A or AAAA record
+func rrAddress(rr dns.RR) (string, bool) {
+	switch rr := rr.(type) {
+	case *dns.A:
+		return rr.A.String(), rr.A != nil
+	case *dns.AAAA:
+		return rr.AAAA.String(), rr.AAAA != nil
+	default:
+		return "", false
+	}
+}
+
+func sameAddress(a string, b string) bool {
+	return net.ParseIP(a).Equal(net.ParseIP(b))
+}
@@ -0,0 +1,247 @@
+package dns_test
+
+import (
+	"testing"
+	"time"
+
+	miekg "github.com/miekg/dns"
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func rr(t *testing.T, s string) miekg.RR {
+	rr, err := miekg.NewRR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rr
+}
+
+func TestUpdate(t *testing.T) {
+	tests := map[string]struct {
+		Zone     string
+		Unsigned bool
+		Update   func(*miekg.Msg)
+		Rcode    int
+		Wildcard bool
+		Records  []vinyl.Record
+	}{
+		"adds records": {
+			Update: func(m *miekg.Msg) {
+				m.Insert([]miekg.RR{rr(t, "new.lab.example. 60 IN A 10.0.0.5")})
+			},
+			Records: []vinyl.Record{
+				{Domain: "docker.lab.example.", Address: "10.0.0.2", TTL: 60, Owner: "docker"},
+				{Domain: "new.lab.example.", Address: "10.0.0.5", TTL: 60},
+				{Domain: "web.lab.example.", Address: "10.0.0.1", TTL: 60, Labels: map[string]string{"team": "web"}},
+			},
+		},
+		"adds names covered by a wildcard": {
+			Wildcard: true,
+			Update: func(m *miekg.Msg) {
+				m.NameNotUsed([]miekg.RR{rr(t, "new.lab.example. 0 IN A 0.0.0.0")})
+				m.Insert([]miekg.RR{rr(t, "new.lab.example. 60 IN A 10.0.0.5")})
+			},
+			Records: []vinyl.Record{
+				{Domain: "*.lab.example.", Address: "10.0.0.3", TTL: 60},
+				{Domain: "docker.lab.example.", Address: "10.0.0.2", TTL: 60, Owner: "docker"},
+				{Domain: "new.lab.example.", Address: "10.0.0.5", TTL: 60},
+				{Domain: "web.lab.example.", Address: "10.0.0.1", TTL: 60, Labels: map[string]string{"team": "web"}},
+			},
+		},
+		"replaces the address of a name keeping its labels": {
+			Update: func(m *miekg.Msg) {
+				m.Insert([]miekg.RR{rr(t, "web.lab.example. 30 IN A 10.0.0.9")})
+			},
+			Records: []vinyl.Record{
+				{Domain: "docker.lab.example.", Address: "10.0.0.2", TTL: 60, Owner: "docker"},
+				{Domain: "web.lab.example.", Address: "10.0.0.9", TTL: 30, Labels: map[string]string{"team": "web"}},
+			},
+		},
+		"removes names": {
+			Update: func(m *miekg.Msg) {
+				m.RemoveName([]miekg.RR{rr(t, "web.lab.example. 0 IN A 0.0.0.0")})
+			},
+			Records: []vinyl.Record{
+				{Domain: "docker.lab.example.", Address: "10.0.0.2", TTL: 60, Owner: "docker"},
+			},
+		},
+		"removes records matching the address": {
+			Update: func(m *miekg.Msg) {
+				m.Remove([]miekg.RR{rr(t, "web.lab.example. 0 IN A 10.0.0.1")})
+			},
+			Records: []vinyl.Record{
+				{Domain: "docker.lab.example.", Address: "10.0.0.2", TTL: 60, Owner: "docker"},
+			},
+		},
+		"leaves records of another type": {
+			Update: func(m *miekg.Msg) {
+				m.RemoveRRset([]miekg.RR{rr(t, "web.lab.example. 0 IN AAAA ::")})
+			},
+		},
+		"applies updates whose prerequisites hold": {
+			Update: func(m *miekg.Msg) {
+				m.NameNotUsed([]miekg.RR{rr(t, "new.lab.example. 0 IN A 0.0.0.0")})
+				m.Used([]miekg.RR{rr(t, "web.lab.example. 0 IN A 10.0.0.1")})
+				m.RRsetUsed([]miekg.RR{rr(t, "lab.example. 0 IN NS ns1.lab.example.")})
+				m.Insert([]miekg.RR{rr(t, "new.lab.example. 60 IN AAAA fd00::5")})
+			},
+			Records: []vinyl.Record{
+				{Domain: "docker.lab.example.", Address: "10.0.0.2", TTL: 60, Owner: "docker"},
+				{Domain: "new.lab.example.", Address: "fd00::5", TTL: 60},
+				{Domain: "web.lab.example.", Address: "10.0.0.1", TTL: 60, Labels: map[string]string{"team": "web"}},
+			},
+		},
+		"answers YXDOMAIN when a name that must not be used is": {
+			Update: func(m *miekg.Msg) {
+				m.NameNotUsed([]miekg.RR{rr(t, "web.lab.example. 0 IN A 0.0.0.0")})
+				m.Insert([]miekg.RR{rr(t, "new.lab.example. 60 IN A 10.0.0.5")})
+			},
+			Rcode: miekg.RcodeYXDomain,
+		},
+		"answers NXRRSET when a record doesn't match": {
+			Update: func(m *miekg.Msg) {
+				m.Used([]miekg.RR{rr(t, "web.lab.example. 0 IN A 10.0.0.7")})
+				m.Insert([]miekg.RR{rr(t, "new.lab.example. 60 IN A 10.0.0.5")})
+			},
+			Rcode: miekg.RcodeNXRrset,
+		},
+		"undoes every change when one is refused": {
+			Update: func(m *miekg.Msg) {
+				m.RemoveName([]miekg.RR{rr(t, "web.lab.example. 0 IN A 0.0.0.0")})
+				m.Insert([]miekg.RR{rr(t, "docker.lab.example. 60 IN A 10.0.0.8")})
+			},
+			Rcode: miekg.RcodeRefused,
+		},
+		"answers NOTZONE for names outside the zone": {
+			Update: func(m *miekg.Msg) {
+				m.Insert([]miekg.RR{rr(t, "web.other.example. 60 IN A 10.0.0.5")})
+			},
+			Rcode: miekg.RcodeNotZone,
+		},
+		"answers NOTIMP for record types vinyl doesn't store": {
+			Update: func(m *miekg.Msg) {
+				m.Insert([]miekg.RR{rr(t, "lab.example. 60 IN MX 10 web.lab.example.")})
+			},
+			Rcode: miekg.RcodeNotImplemented,
+		},
+		"answers FORMERR for additions without a ttl": {
+			Update: func(m *miekg.Msg) {
+				m.Insert([]miekg.RR{rr(t, "new.lab.example. 60 IN A 10.0.0.5"), rr(t, "other.lab.example. 0 IN A 10.0.0.6")})
+			},
+			Rcode: miekg.RcodeFormatError,
+		},
+		"answers FORMERR for additions with a ttl past 2^31-1": {
+			Update: func(m *miekg.Msg) {
+				m.Insert([]miekg.RR{rr(t, "new.lab.example. 2147483648 IN A 10.0.0.5")})
+			},
+			Rcode: miekg.RcodeFormatError,
+		},
+		"answers FORMERR for additions of meta types": {
+			Update: func(m *miekg.Msg) {
+				m.Insert([]miekg.RR{&miekg.ANY{Hdr: miekg.RR_Header{Name: "new.lab.example.", Rrtype: miekg.TypeAXFR, Class: miekg.ClassINET, Ttl: 60}}})
+			},
+			Rcode: miekg.RcodeFormatError,
+		},
+		"answers FORMERR for record deletes with a ttl": {
+			Update: func(m *miekg.Msg) {
+				deletion := rr(t, "web.lab.example. 60 IN A 10.0.0.1")
+				deletion.Header().Class = miekg.ClassNONE
+				m.Ns = append(m.Ns, deletion)
+			},
+			Rcode: miekg.RcodeFormatError,
+		},
+		"answers FORMERR for rrset deletes with a ttl": {
+			Update: func(m *miekg.Msg) {
+				m.Ns = append(m.Ns, &miekg.ANY{Hdr: miekg.RR_Header{Name: "web.lab.example.", Rrtype: miekg.TypeA, Class: miekg.ClassANY, Ttl: 60}})
+			},
+			Rcode: miekg.RcodeFormatError,
+		},
+		"answers FORMERR for rrset deletes with rdata": {
+			Update: func(m *miekg.Msg) {
+				deletion := rr(t, "web.lab.example. 0 IN A 10.0.0.1")
+				deletion.Header().Class = miekg.ClassANY
+				m.Ns = append(m.Ns, deletion)
+			},
+			Rcode: miekg.RcodeFormatError,
+		},
+		"answers FORMERR for unknown classes": {
+			Update: func(m *miekg.Msg) {
+				addition := rr(t, "new.lab.example. 60 IN A 10.0.0.5")
+				addition.Header().Class = miekg.ClassCHAOS
+				m.Ns = append(m.Ns, addition)
+			},
+			Rcode: miekg.RcodeFormatError,
+		},
+		"refuses unsigned updates": {
+			Unsigned: true,
+			Update: func(m *miekg.Msg) {
+				m.Insert([]miekg.RR{rr(t, "new.lab.example. 60 IN A 10.0.0.5")})
+			},
+			Rcode: miekg.RcodeRefused,
+		},
+		"refuses updates to zones without update keys": {
+			Zone: "closed.example.",
+			Update: func(m *miekg.Msg) {
+				m.Insert([]miekg.RR{rr(t, "new.closed.example. 60 IN A 10.0.0.5")})
+			},
+			Rcode: miekg.RcodeRefused,
+		},
+		"answers NOTAUTH for zones vinyl isn't authoritative for": {
+			Zone: "web.lab.example.",
+			Update: func(m *miekg.Msg) {
+				m.Insert([]miekg.RR{rr(t, "web.lab.example. 60 IN A 10.0.0.5")})
+			},
+			Rcode: miekg.RcodeNotAuth,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			records := []vinyl.Record{
+				{Domain: "docker.lab.example.", Address: "10.0.0.2", TTL: 60, Owner: "docker"},
+				{Domain: "web.lab.example.", Address: "10.0.0.1", TTL: 60, Labels: map[string]string{"team": "web"}},
+			}
+			mem := store.NewMemory(records...)
+			if test.Wildcard {
+				_, err := mem.CreateRecord("*.lab.example.", "10.0.0.3", 60)
+				assert.NoError(err)
+			}
+
+			zone := configZone(t, "lab.example.", dns.TransferPolicy{})
+			zone.Update.Keys = []string{"transfer."}
+			journal := startJournal(t, mem, []dns.Zone{zone, configZone(t, "closed.example.", dns.TransferPolicy{})})
+			address, _ := serve(t, dns.NewRecordHandler(mem, dns.WithJournal(journal), dns.WithUpdater(mem)))
+
+			request := &miekg.Msg{}
+			origin := test.Zone
+			if origin == "" {
+				origin = "lab.example."
+			}
+			request.SetUpdate(origin)
+			test.Update(request)
+
+			client := &miekg.Client{TsigSecret: map[string]string{"transfer.": transferSecret}}
+			if !test.Unsigned {
+				request.SetTsig("transfer.", miekg.HmacSHA256, 300, time.Now().Unix())
+			}
+
+			response, _, err := client.Exchange(request, address)
+			assert.NoError(err)
+			assert.Equal(miekg.RcodeToString[test.Rcode], miekg.RcodeToString[response.Rcode])
+
+			if test.Records == nil {
+				test.Records = records
+			}
+
+			listed, _, err := mem.ListRecords(vinyl.RecordFilter{}, vinyl.Page{})
+			assert.NoError(err)
+			assert.Equal(test.Records, listed)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/miekg/dns"
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/store"
+)
+
+// ViewStore is a view and the records its clients are answered from
+type ViewStore struct {
+	View  View
+	Store RecordStorer
+}
+
+// NewView checks view and reads its sources, returning a copy that can be passed to WithView
+func NewView(view View) (*View, error) {
+	view.Keys = append([]string{}, view.Keys...)
+	view.Listeners = append([]string{}, view.Listeners...)
+
+	err := view.parse()
+	if err != nil {
+		return nil, fmt.Errorf("NewView: %w", err)
+	}
+
+	return &view, nil
+}
+
+// WithView answers the clients that match view from store. The view comes from NewView or a validated Config. Views
+// are tried in the order they are added
+func WithView(view *View, store RecordStorer) HandlerOption {
+	return func(handler *RecordHandler) {
+		handler.Views = append(handler.Views, ViewStore{View: *view, Store: store})
+	}
+}
+
+// recordStore is the store of the first view the request matches, or the handler's store when it matches none.
+// Names inside the secondary's zones are answered from the secondary
+func (handler *RecordHandler) recordStore(w dns.ResponseWriter, request *dns.Msg) RecordStorer {
+	store := handler.RecordStore
+	for _, view := range handler.Views {
+		if !view.View.matches(w, request) {
+			continue
+		}
+
+		store = view.Store
+		if view.View.Overlay {
+			store = &overlayStore{view: view.Store, base: handler.RecordStore}
+		}
+
+		break
+	}
+
+	if handler.Secondary != nil {
+		return &secondaryStore{secondary: handler.Secondary, base: store}
+	}
+
+	return store
+}
+
+// matches reports whether the client's address, TSIG key or the port it was received on selects the view. TSIG
+// signatures are verified by the server. Listeners are only compared by port since udp servers bound to every address
+// can't tell which one a packet was sent to
+func (view *View) matches(w dns.ResponseWriter, request *dns.Msg) bool {
+	if containsAddress(view.networks, w.RemoteAddr()) {
+		return true
+	}
+
+	if tsig := request.IsTsig(); tsig != nil && w.TsigStatus() == nil {
+		for _, key := range view.Keys {
+			if canonicalName(tsig.Hdr.Name) == key {
+				return true
+			}
+		}
+	}
+
+	_, port, err := net.SplitHostPort(w.LocalAddr().String())
+	if err != nil {
+		return false
+	}
+
+	for _, listener := range view.Listeners {
+		if listener == ":"+port {
+			return true
+		}
+	}
+
+	return false
+}
+
+// overlayStore answers from view and falls back to base for names view has no record for
+type overlayStore struct {
+	view RecordStorer
+	base RecordStorer
+}
+
+func (overlay *overlayStore) GetRecord(domain string) (*vinyl.Record, error) {
+	var missing *store.MissingRecordError
+
+	record, err := overlay.view.GetRecord(domain)
+	if errors.As(err, &missing) {
+		return overlay.base.GetRecord(domain)
+	}
+
+	return record, err
+}
+
+// GetRecordsByAddress returns the view's records pointing at address and the base records whose names the view
+// doesn't override
+func (overlay *overlayStore) GetRecordsByAddress(address string) ([]vinyl.Record, error) {
+	var missing *store.MissingAddressError
+
+	records, err := overlay.view.GetRecordsByAddress(address)
+	if err != nil && !errors.As(err, &missing) {
+		return nil, err
+	}
+
+	base, err := overlay.base.GetRecordsByAddress(address)
+	if err != nil && !errors.As(err, &missing) {
+		return nil, err
+	}
+
+	for _, record := range base {
+		if _, err := overlay.view.GetRecord(record.Domain); err == nil {
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("GetRecordsByAddress: %w", &store.MissingAddressError{
+			Address: address,
+		})
+	}
+
+	return records, nil
+}
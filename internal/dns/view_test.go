@@ -0,0 +1,214 @@
+package dns_test
+
+import (
+	"net"
+	"testing"
+
+	miekg "github.com/miekg/dns"
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/dns"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func newView(t *testing.T, view dns.View) *dns.View {
+	parsed, err := dns.NewView(view)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return parsed
+}
+
+func TestNewView(t *testing.T) {
+	tests := map[string]struct {
+		View dns.View
+		Err  error
+	}{
+		"parses views": {
+			View: dns.View{Name: "internal", Sources: []string{"10.0.0.0/8"}, Keys: []string{"office"}, Listeners: []string{":853"}},
+		},
+		"returns InvalidViewConfigError for views nothing matches": {
+			View: dns.View{Name: "internal"},
+			Err:  &dns.InvalidViewConfigError{Name: "internal", Reason: "at least one source, key or listener is needed"},
+		},
+		"returns InvalidViewConfigError for bad sources": {
+			View: dns.View{Name: "internal", Sources: []string{"10.0.0.0/33"}},
+			Err:  &dns.InvalidViewConfigError{Name: "internal", Reason: "10.0.0.0/33 is not a valid cidr"},
+		},
+		"returns InvalidViewConfigError for listeners with an address": {
+			View: dns.View{Name: "internal", Listeners: []string{"127.0.0.1:53"}},
+			Err:  &dns.InvalidViewConfigError{Name: "internal", Reason: "listener 127.0.0.1:53 is not a :port"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			view, err := dns.NewView(test.View)
+			if test.Err != nil {
+				assert.ErrorContains(err, test.Err.Error())
+				assert.Nil(view)
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal([]string{"office."}, view.Keys)
+			assert.Equal([]string{"office"}, test.View.Keys)
+		})
+	}
+}
+
+func TestViews(t *testing.T) {
+	tests := map[string]struct {
+		View   dns.View
+		Net    string
+		Listen bool
+		Signed bool
+		Name   string
+		Qtype  uint16
+		Answer []string
+	}{
+		"answers clients in the view's sources from the view": {
+			View:   dns.View{Name: "internal", Sources: []string{"127.0.0.0/8"}},
+			Name:   "web.lab.example.",
+			Qtype:  miekg.TypeA,
+			Answer: []string{"10.0.0.1"},
+		},
+		"answers other clients from the store": {
+			View:   dns.View{Name: "internal", Sources: []string{"10.0.0.0/8"}},
+			Name:   "web.lab.example.",
+			Qtype:  miekg.TypeA,
+			Answer: []string{"203.0.113.1"},
+		},
+		"answers requests signed with the view's key from the view": {
+			View:   dns.View{Name: "internal", Keys: []string{"transfer"}},
+			Signed: true,
+			Name:   "web.lab.example.",
+			Qtype:  miekg.TypeA,
+			Answer: []string{"10.0.0.1"},
+		},
+		"answers unsigned requests from the store": {
+			View:   dns.View{Name: "internal", Keys: []string{"transfer"}},
+			Name:   "web.lab.example.",
+			Qtype:  miekg.TypeA,
+			Answer: []string{"203.0.113.1"},
+		},
+		"answers requests to the view's listeners from the view": {
+			View:   dns.View{Name: "internal"},
+			Listen: true,
+			Name:   "web.lab.example.",
+			Qtype:  miekg.TypeA,
+			Answer: []string{"10.0.0.1"},
+		},
+		"answers udp requests to the view's listeners from the view": {
+			View:   dns.View{Name: "internal"},
+			Net:    "udp",
+			Listen: true,
+			Name:   "web.lab.example.",
+			Qtype:  miekg.TypeA,
+			Answer: []string{"10.0.0.1"},
+		},
+		"answers requests to other listeners from the store": {
+			View:   dns.View{Name: "internal", Listeners: []string{":1"}},
+			Name:   "web.lab.example.",
+			Qtype:  miekg.TypeA,
+			Answer: []string{"203.0.113.1"},
+		},
+		"leaves names only in the store out of views": {
+			View:   dns.View{Name: "internal", Sources: []string{"127.0.0.0/8"}},
+			Name:   "mail.lab.example.",
+			Qtype:  miekg.TypeA,
+			Answer: []string{},
+		},
+		"falls back to the store in overlays": {
+			View:   dns.View{Name: "internal", Sources: []string{"127.0.0.0/8"}, Overlay: true},
+			Name:   "mail.lab.example.",
+			Qtype:  miekg.TypeA,
+			Answer: []string{"203.0.113.2"},
+		},
+		"answers reverse lookups from overlays and the store": {
+			View:   dns.View{Name: "internal", Sources: []string{"127.0.0.0/8"}, Overlay: true},
+			Name:   "2.113.0.203.in-addr.arpa.",
+			Qtype:  miekg.TypePTR,
+			Answer: []string{"mail.lab.example."},
+		},
+		"leaves records the overlay replaces out of reverse lookups": {
+			View:   dns.View{Name: "internal", Sources: []string{"127.0.0.0/8"}, Overlay: true},
+			Name:   "1.113.0.203.in-addr.arpa.",
+			Qtype:  miekg.TypePTR,
+			Answer: []string{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			public := store.NewMemory(
+				vinyl.Record{Domain: "web.lab.example.", Address: "203.0.113.1", TTL: 60},
+				vinyl.Record{Domain: "mail.lab.example.", Address: "203.0.113.2", TTL: 60},
+			)
+			internal := store.NewMemory(
+				vinyl.Record{Domain: "web.lab.example.", Address: "10.0.0.1", TTL: 60},
+			)
+
+			// the listener is the port the test server picks, so the handler is made once it listens
+			var handler miekg.Handler
+			ready := make(chan struct{})
+			address, _ := serve(t, miekg.HandlerFunc(func(w miekg.ResponseWriter, request *miekg.Msg) {
+				<-ready
+				handler.ServeDNS(w, request)
+			}))
+
+			view := test.View
+			if test.Listen {
+				_, port, _ := net.SplitHostPort(address)
+				view.Listeners = []string{":" + port}
+			}
+			handler = dns.NewRecordHandler(public, dns.WithView(newView(t, view), internal))
+			close(ready)
+
+			request := &miekg.Msg{}
+			request.SetQuestion(test.Name, test.Qtype)
+
+			client := &miekg.Client{Net: "tcp"}
+			if test.Net != "" {
+				client.Net = test.Net
+			}
+			if test.Signed {
+				request.SetTsig("transfer.", miekg.HmacSHA256, 300, 0)
+				client.TsigSecret = map[string]string{"transfer.": transferSecret}
+			}
+
+			response, _, err := client.Exchange(request, address)
+			assert.NoError(err)
+
+			answer := []string{}
+			for _, rr := range response.Answer {
+				switch rr := rr.(type) {
+				case *miekg.A:
+					answer = append(answer, rr.A.String())
+				case *miekg.PTR:
+					answer = append(answer, rr.Ptr)
+				}
+			}
+			assert.Equal(test.Answer, answer)
+		})
+	}
+}
+
+func TestViewsOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := dns.NewRecordHandler(store.NewMemory(),
+		dns.WithView(newView(t, dns.View{Name: "first", Sources: []string{"127.0.0.1"}}), store.NewMemory(vinyl.Record{Domain: "web.lab.example.", Address: "10.0.0.1", TTL: 60})),
+		dns.WithView(newView(t, dns.View{Name: "second", Sources: []string{"127.0.0.0/8"}}), store.NewMemory(vinyl.Record{Domain: "web.lab.example.", Address: "10.0.0.2", TTL: 60})),
+	)
+	address, _ := serve(t, handler)
+
+	response := query(t, address, "web.lab.example.", miekg.TypeA, false)
+	assert.Len(response.Answer, 1)
+	assert.Equal(net.ParseIP("10.0.0.1").To4(), response.Answer[0].(*miekg.A).A.To4())
+}
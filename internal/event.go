@@ -0,0 +1,32 @@
+package vinyl
+
+type EventType int
+
+const (
+	RecordCreated EventType = iota
+	RecordRemoved
+	RecordUpdated
+)
+
+func (t EventType) String() string {
+	switch t {
+	case RecordCreated:
+		return "created"
+	case RecordRemoved:
+		return "removed"
+	case RecordUpdated:
+		return "updated"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText lets events be written as json and yaml with readable types
+func (t EventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+type RecordEvent struct {
+	Type   EventType `json:"type" yaml:"type"`
+	Record Record    `json:"record" yaml:"record"`
+}
@@ -0,0 +1,102 @@
+package vinyl
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// RecordFilter narrows down listed records. Zero values match every record
+type RecordFilter struct {
+	DomainSuffix string
+	Type         string
+	Network      *net.IPNet
+	Labels       map[string]string
+	// Owner only matches records managed by this source when set
+	Owner string
+}
+
+// Page selects a window of listed records. A Size of zero returns every record after Token
+type Page struct {
+	Size  int
+	Token string
+}
+
+type InvalidRecordTypeError struct {
+	Type string
+}
+
+func (e *InvalidRecordTypeError) Error() string {
+	return fmt.Sprintf("%s is not a valid record type", e.Type)
+}
+
+type InvalidFilterNetworkError struct {
+	CIDR string
+}
+
+func (e *InvalidFilterNetworkError) Error() string {
+	return fmt.Sprintf("%s is not a valid cidr", e.CIDR)
+}
+
+func NewRecordFilter(suffix string, recordType string, cidr string, labels map[string]string) (*RecordFilter, error) {
+	filter := &RecordFilter{
+		DomainSuffix: suffix,
+		Type:         strings.ToUpper(recordType),
+		Labels:       labels,
+	}
+
+	if filter.Type != "" && filter.Type != TypeA && filter.Type != TypeAAAA {
+		return nil, fmt.Errorf("NewRecordFilter: %w", &InvalidRecordTypeError{
+			Type: recordType,
+		})
+	}
+
+	if cidr != "" {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("NewRecordFilter: %w", &InvalidFilterNetworkError{
+				CIDR: cidr,
+			})
+		}
+
+		filter.Network = network
+	}
+
+	return filter, nil
+}
+
+// Match reports whether a record passes every part of the filter
+func (filter RecordFilter) Match(record Record) bool {
+	if filter.DomainSuffix != "" && !IsSubdomain(record.Domain, filter.DomainSuffix) {
+		return false
+	}
+
+	if filter.Type != "" && filter.Type != record.Type() {
+		return false
+	}
+
+	if filter.Network != nil && !filter.Network.Contains(net.ParseIP(record.Address)) {
+		return false
+	}
+
+	if filter.Owner != "" && filter.Owner != record.Owner {
+		return false
+	}
+
+	for key, value := range filter.Labels {
+		v, exist := record.Labels[key]
+		if !exist || v != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsSubdomain reports whether domain is parent or sits below it, ignoring case and trailing dots
+func IsSubdomain(domain string, parent string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	parent = strings.ToLower(strings.TrimSuffix(parent, "."))
+
+	return parent == "" || domain == parent || strings.HasSuffix(domain, "."+parent)
+}
@@ -0,0 +1,670 @@
+package gossip
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/proto"
+	"github.com/platform-edn/vinyl/internal/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	DefaultInterval     = time.Second
+	DefaultFanout       = 3
+	DefaultDeadAfter    = 30 * time.Second
+	DefaultTombstoneTTL = 24 * time.Hour
+	requestTimeout      = 5 * time.Second
+)
+
+// Member is another node taking part in the gossip. LastSeen is when this node, or another node that passed the
+// member along, last heard from it directly
+type Member struct {
+	ID       string
+	Address  string
+	LastSeen time.Time
+}
+
+// Node is an eventually consistent store shared by gossiping with other nodes. Changes are written locally and pushed
+// to a few random members, which push them on in turn, while a periodic sync with a random member or seed repairs
+// anything the pushes missed, such as changes made on both sides of a partition. Conflicting changes to a domain are
+// settled by the latest timestamp
+type Node struct {
+	ID string
+	// Address is where other nodes reach this node's grpc server
+	Address string
+	// Seeds are contacted to join the cluster and are kept being synced with so partitions heal
+	Seeds     []string
+	Interval  time.Duration
+	Fanout    int
+	DeadAfter time.Duration
+	// TombstoneTTL is how long removals are remembered. A node partitioned for longer than this may bring removed
+	// records back
+	TombstoneTTL time.Duration
+	DialOptions  []grpc.DialOption
+	Store        *store.Memory
+	entries      map[string]*entry
+	members      map[string]*Member
+	clock        int64
+	conns        map[string]*grpc.ClientConn
+	mutex        sync.Mutex
+}
+
+// entry is the latest change to a domain. Removals are kept as deleted entries until they expire
+type entry struct {
+	record  vinyl.Record
+	version version
+	deleted bool
+}
+
+type version struct {
+	timestamp int64
+	node      string
+}
+
+func (v version) newer(other version) bool {
+	if v.timestamp != other.timestamp {
+		return v.timestamp > other.timestamp
+	}
+
+	return v.node > other.node
+}
+
+// NodeOption sets the optional fields of a Node
+type NodeOption func(*Node)
+
+// WithSeeds sets the addresses contacted to join the cluster
+func WithSeeds(seeds ...string) NodeOption {
+	return func(node *Node) {
+		node.Seeds = seeds
+	}
+}
+
+// WithInterval sets how often the node syncs with another node
+func WithInterval(interval time.Duration) NodeOption {
+	return func(node *Node) {
+		node.Interval = interval
+	}
+}
+
+// WithFanout sets how many members every change is pushed to
+func WithFanout(fanout int) NodeOption {
+	return func(node *Node) {
+		node.Fanout = fanout
+	}
+}
+
+// WithDeadAfter sets how long a member can go without being heard from before it is forgotten
+func WithDeadAfter(deadAfter time.Duration) NodeOption {
+	return func(node *Node) {
+		node.DeadAfter = deadAfter
+	}
+}
+
+// WithTombstoneTTL sets how long removals are remembered
+func WithTombstoneTTL(ttl time.Duration) NodeOption {
+	return func(node *Node) {
+		node.TombstoneTTL = ttl
+	}
+}
+
+// WithDialOptions sets the options used to connect to other nodes
+func WithDialOptions(options ...grpc.DialOption) NodeOption {
+	return func(node *Node) {
+		node.DialOptions = options
+	}
+}
+
+func NewNode(id string, address string, options ...NodeOption) *Node {
+	node := &Node{
+		ID:           id,
+		Address:      address,
+		Interval:     DefaultInterval,
+		Fanout:       DefaultFanout,
+		DeadAfter:    DefaultDeadAfter,
+		TombstoneTTL: DefaultTombstoneTTL,
+		DialOptions:  []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+		Store:        store.NewMemory(),
+		entries:      map[string]*entry{},
+		members:      map[string]*Member{},
+		conns:        map[string]*grpc.ClientConn{},
+	}
+
+	for _, option := range options {
+		option(node)
+	}
+
+	return node
+}
+
+// Run syncs with a random member or seed every interval until ctx is done
+func (node *Node) Run(ctx context.Context) error {
+	defer node.close()
+
+	ticker := time.NewTicker(node.Interval)
+	defer ticker.Stop()
+
+	for {
+		node.expire()
+
+		address := node.target()
+		if address != "" {
+			err := node.sync(ctx, address)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("gossip %s: %s", node.ID, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Members returns the nodes this node currently knows about ordered by id
+func (node *Node) Members() []Member {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+
+	return node.memberList()
+}
+
+func (node *Node) GetRecord(domain string) (*vinyl.Record, error) {
+	return node.Store.GetRecord(domain)
+}
+
+func (node *Node) GetRecordsByAddress(address string) ([]vinyl.Record, error) {
+	return node.Store.GetRecordsByAddress(address)
+}
+
+func (node *Node) ListRecords(filter vinyl.RecordFilter, page vinyl.Page) ([]vinyl.Record, string, error) {
+	return node.Store.ListRecords(filter, page)
+}
+
+// Watch returns the changes made to this node's copy of the records, whichever node they were made on
+func (node *Node) Watch(ctx context.Context) <-chan vinyl.RecordEvent {
+	return node.Store.Watch(ctx)
+}
+
+func (node *Node) CreateRecord(domain string, address string, ttl uint32, options ...vinyl.RecordOption) (*vinyl.Record, error) {
+	record, err := vinyl.NewRecord(domain, address, ttl, options...)
+	if err != nil {
+		return nil, fmt.Errorf("CreateRecord: %w", err)
+	}
+
+	_, err = node.change(*record, false, func(existing *vinyl.Record) error {
+		if existing != nil {
+			return &store.ExistingRecordError{Domain: domain}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("CreateRecord: %w", err)
+	}
+
+	return record, nil
+}
+
+func (node *Node) UpdateRecord(domain string, address string, ttl uint32, options ...vinyl.RecordOption) (*vinyl.Record, error) {
+	record, err := vinyl.NewRecord(domain, address, ttl, options...)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateRecord: %w", err)
+	}
+
+	_, err = node.change(*record, false, func(existing *vinyl.Record) error {
+		if existing == nil {
+			return &store.MissingRecordError{Domain: domain}
+		}
+
+		if existing.Owner != record.Owner {
+			return &store.OwnerConflictError{Domain: domain, Owner: existing.Owner}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("UpdateRecord: %w", err)
+	}
+
+	return record, nil
+}
+
+func (node *Node) RemoveRecord(domain string) (*vinyl.Record, error) {
+	record, err := node.change(vinyl.Record{Domain: domain}, true, func(existing *vinyl.Record) error {
+		if existing == nil {
+			return &store.MissingRecordError{Domain: domain}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("RemoveRecord: %w", err)
+	}
+
+	return record, nil
+}
+
+func (node *Node) RemoveOwnedRecord(domain string, owner string) (*vinyl.Record, error) {
+	record, err := node.change(vinyl.Record{Domain: domain}, true, func(existing *vinyl.Record) error {
+		if existing == nil {
+			return &store.MissingRecordError{Domain: domain}
+		}
+
+		if existing.Owner != owner {
+			return &store.OwnerConflictError{Domain: domain, Owner: existing.Owner}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("RemoveOwnedRecord: %w", err)
+	}
+
+	return record, nil
+}
+
+// change writes a local change once check accepts the current record, which is nil when there is none, and starts
+// spreading it. The record that was replaced is returned
+func (node *Node) change(record vinyl.Record, deleted bool, check func(*vinyl.Record) error) (*vinyl.Record, error) {
+	node.mutex.Lock()
+
+	var existing *vinyl.Record
+	current, exist := node.entries[record.Domain]
+	if exist && !current.deleted {
+		existing = &current.record
+	}
+
+	err := check(existing)
+	if err != nil {
+		node.mutex.Unlock()
+		return nil, fmt.Errorf("change: %w", err)
+	}
+
+	e := &entry{
+		record:  record,
+		version: version{timestamp: node.tick(), node: node.ID},
+		deleted: deleted,
+	}
+	node.apply(e)
+
+	targets := node.pick("")
+	from, members := node.self(), node.memberList()
+	node.mutex.Unlock()
+
+	go node.push(targets, from, members, []*entry{e})
+
+	return existing, nil
+}
+
+// merge applies the changes that are newer than what the node has and returns them
+func (node *Node) merge(entries []*proto.Entry) []*entry {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+
+	fresh := []*entry{}
+	for _, pe := range entries {
+		e := convertProtoToEntry(pe)
+		node.observe(e.version.timestamp)
+
+		current, exist := node.entries[e.record.Domain]
+		if exist && !e.version.newer(current.version) {
+			continue
+		}
+
+		node.apply(e)
+		fresh = append(fresh, e)
+	}
+
+	return fresh
+}
+
+// apply stores an entry and updates the records served from it, and must be called while holding the lock
+func (node *Node) apply(e *entry) {
+	node.entries[e.record.Domain] = e
+
+	if e.deleted {
+		node.Store.RemoveRecord(e.record.Domain)
+		return
+	}
+
+	_, err := node.Store.PutRecord(e.record)
+	if err != nil {
+		log.Printf("gossip %s: %s", node.ID, err)
+	}
+}
+
+// tick returns a timestamp after every one this node has written or seen, so its changes win over the changes they
+// were made on top of even when clocks drift
+func (node *Node) tick() int64 {
+	timestamp := time.Now().UnixNano()
+	if timestamp <= node.clock {
+		timestamp = node.clock + 1
+	}
+	node.clock = timestamp
+
+	return timestamp
+}
+
+func (node *Node) observe(timestamp int64) {
+	if timestamp > node.clock {
+		node.clock = timestamp
+	}
+}
+
+// spread pushes changes that were new to this node on to other members, leaving out the member they came from
+func (node *Node) spread(entries []*entry, from string) {
+	if len(entries) == 0 {
+		return
+	}
+
+	node.mutex.Lock()
+	targets := node.pick(from)
+	self, members := node.self(), node.memberList()
+	node.mutex.Unlock()
+
+	node.push(targets, self, members, entries)
+}
+
+func (node *Node) push(targets []Member, from *proto.Member, members []Member, entries []*entry) {
+	req := &proto.PushRequest{
+		From:    from,
+		Members: convertMembersToProto(members...),
+		Entries: convertEntriesToProto(entries...),
+	}
+
+	for _, target := range targets {
+		err := node.pushTo(target, req)
+		if err != nil {
+			log.Printf("gossip %s: %s", node.ID, err)
+		}
+	}
+}
+
+func (node *Node) pushTo(target Member, req *proto.PushRequest) error {
+	conn, err := node.conn(target.Address)
+	if err != nil {
+		return fmt.Errorf("pushTo: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	_, err = proto.NewGossipClient(conn).Push(ctx, req)
+	if err != nil {
+		return fmt.Errorf("pushTo: %s: %w", target.ID, err)
+	}
+
+	node.seen(target)
+
+	return nil
+}
+
+// sync is the anti-entropy exchange. The node sends a digest of its entries, takes the entries address is ahead on
+// and pushes back the ones it is ahead on
+func (node *Node) sync(ctx context.Context, address string) error {
+	conn, err := node.conn(address)
+	if err != nil {
+		return fmt.Errorf("sync: %w", err)
+	}
+	client := proto.NewGossipClient(conn)
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	node.mutex.Lock()
+	req := &proto.SyncRequest{
+		From:    node.self(),
+		Members: convertMembersToProto(node.memberList()...),
+		Digests: node.digests(),
+	}
+	node.mutex.Unlock()
+
+	resp, err := client.Sync(ctx, req)
+	if err != nil {
+		return fmt.Errorf("sync: %s: %w", address, err)
+	}
+
+	node.meet(resp.From, resp.Members)
+	fresh := node.merge(resp.Entries)
+	go node.spread(fresh, resp.From.GetId())
+
+	if len(resp.Wanted) == 0 {
+		return nil
+	}
+
+	node.mutex.Lock()
+	wanted := []*entry{}
+	for _, domain := range resp.Wanted {
+		e, exist := node.entries[domain]
+		if exist {
+			wanted = append(wanted, e)
+		}
+	}
+	push := &proto.PushRequest{
+		From:    node.self(),
+		Members: convertMembersToProto(node.memberList()...),
+		Entries: convertEntriesToProto(wanted...),
+	}
+	node.mutex.Unlock()
+
+	_, err = client.Push(ctx, push)
+	if err != nil {
+		return fmt.Errorf("sync: %s: %w", address, err)
+	}
+
+	return nil
+}
+
+// compare works out which entries a node with digests is missing or behind on and which domains it is ahead on
+func (node *Node) compare(digests []*proto.Digest) ([]*entry, []string) {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+
+	theirs := map[string]version{}
+	wanted := []string{}
+	for _, digest := range digests {
+		v := convertProtoToVersion(digest.Version)
+		theirs[digest.Domain] = v
+
+		current, exist := node.entries[digest.Domain]
+		if !exist || v.newer(current.version) {
+			wanted = append(wanted, digest.Domain)
+		}
+	}
+
+	missing := []*entry{}
+	for domain, e := range node.entries {
+		v, exist := theirs[domain]
+		if !exist || e.version.newer(v) {
+			missing = append(missing, e)
+		}
+	}
+
+	return missing, wanted
+}
+
+// digests must be called while holding the lock
+func (node *Node) digests() []*proto.Digest {
+	digests := []*proto.Digest{}
+	for domain, e := range node.entries {
+		digests = append(digests, &proto.Digest{
+			Domain:  domain,
+			Version: convertVersionToProto(e.version),
+		})
+	}
+
+	return digests
+}
+
+// meet learns about the member a message came from and the members it knows of. Only hearing from a member directly
+// counts as it being alive, so members passed along keep the time they were last heard from instead of now and a
+// member already forgotten as dead isn't brought back by a node that hasn't expired it yet
+func (node *Node) meet(from *proto.Member, members []*proto.Member) {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+
+	now := time.Now()
+	if from != nil && from.Id != "" && from.Id != node.ID {
+		node.members[from.Id] = &Member{ID: from.Id, Address: from.Address, LastSeen: now}
+	}
+
+	for _, m := range members {
+		if m.Id == "" || m.Id == node.ID {
+			continue
+		}
+
+		// clocks differ between nodes, a member can't have been seen later than now
+		lastSeen := time.Unix(0, m.LastSeen)
+		if lastSeen.After(now) {
+			lastSeen = now
+		}
+
+		current, exist := node.members[m.Id]
+		if exist {
+			if lastSeen.After(current.LastSeen) {
+				current.LastSeen = lastSeen
+			}
+			continue
+		}
+
+		if now.Sub(lastSeen) > node.DeadAfter {
+			continue
+		}
+
+		node.members[m.Id] = &Member{ID: m.Id, Address: m.Address, LastSeen: lastSeen}
+	}
+}
+
+func (node *Node) seen(target Member) {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+
+	m, exist := node.members[target.ID]
+	if exist {
+		m.LastSeen = time.Now()
+	}
+}
+
+// expire forgets members that haven't been heard from and removals old enough to no longer matter
+func (node *Node) expire() {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+
+	now := time.Now()
+	for id, m := range node.members {
+		if now.Sub(m.LastSeen) > node.DeadAfter {
+			delete(node.members, id)
+		}
+	}
+
+	horizon := now.Add(-node.TombstoneTTL).UnixNano()
+	for domain, e := range node.entries {
+		if e.deleted && e.version.timestamp < horizon {
+			delete(node.entries, domain)
+		}
+	}
+}
+
+// target picks a random member or seed to sync with
+func (node *Node) target() string {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+
+	candidates := map[string]struct{}{}
+	for _, m := range node.members {
+		candidates[m.Address] = struct{}{}
+	}
+	for _, seed := range node.Seeds {
+		candidates[seed] = struct{}{}
+	}
+	delete(candidates, node.Address)
+
+	addresses := []string{}
+	for address := range candidates {
+		addresses = append(addresses, address)
+	}
+	if len(addresses) == 0 {
+		return ""
+	}
+
+	return addresses[rand.Intn(len(addresses))]
+}
+
+// pick chooses up to Fanout random members other than exclude and must be called while holding the lock
+func (node *Node) pick(exclude string) []Member {
+	members := []Member{}
+	for _, m := range node.members {
+		if m.ID != exclude {
+			members = append(members, *m)
+		}
+	}
+
+	rand.Shuffle(len(members), func(i, j int) {
+		members[i], members[j] = members[j], members[i]
+	})
+
+	if len(members) > node.Fanout {
+		members = members[:node.Fanout]
+	}
+
+	return members
+}
+
+// memberList must be called while holding the lock
+func (node *Node) memberList() []Member {
+	members := []Member{}
+	for _, m := range node.members {
+		members = append(members, *m)
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].ID < members[j].ID
+	})
+
+	return members
+}
+
+func (node *Node) self() *proto.Member {
+	return &proto.Member{
+		Id:      node.ID,
+		Address: node.Address,
+	}
+}
+
+func (node *Node) conn(address string) (*grpc.ClientConn, error) {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+
+	conn, exist := node.conns[address]
+	if exist {
+		return conn, nil
+	}
+
+	conn, err := grpc.Dial(address, node.DialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("conn: %w", err)
+	}
+	node.conns[address] = conn
+
+	return conn, nil
+}
+
+func (node *Node) close() {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+
+	for address, conn := range node.conns {
+		conn.Close()
+		delete(node.conns, address)
+	}
+}
@@ -0,0 +1,203 @@
+package gossip_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/gossip"
+	"github.com/platform-edn/vinyl/internal/proto"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+const interval = 20 * time.Millisecond
+
+// newNode creates a node with a grpc server on loopback. The node doesn't gossip until it is started
+func newNode(t *testing.T, id string, seeds ...string) (*gossip.Node, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := gossip.NewNode(id, lis.Addr().String(), gossip.WithSeeds(seeds...), gossip.WithInterval(interval))
+
+	server := grpc.NewServer()
+	proto.RegisterGossipServer(server, gossip.NewGossipServer(node))
+	t.Cleanup(server.Stop)
+
+	start := func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		go server.Serve(lis)
+		go node.Run(ctx)
+	}
+
+	return node, start
+}
+
+func has(node *gossip.Node, domain string, address string) func() bool {
+	return func() bool {
+		record, err := node.GetRecord(domain)
+		return err == nil && record.Address == address
+	}
+}
+
+func TestNode(t *testing.T) {
+	assert := assert.New(t)
+
+	a, startA := newNode(t, "a")
+	startA()
+	b, startB := newNode(t, "b", a.Address)
+	startB()
+	c, startC := newNode(t, "c", a.Address)
+	startC()
+
+	assert.Eventually(func() bool {
+		return len(b.Members()) == 2 && len(c.Members()) == 2
+	}, 5*time.Second, interval, "nodes should find each other through the seed")
+
+	_, err := b.CreateRecord("orders.edge.internal.", "10.0.0.1", 30)
+	assert.NoError(err)
+
+	for _, node := range []*gossip.Node{a, b, c} {
+		assert.Eventually(has(node, "orders.edge.internal.", "10.0.0.1"), 5*time.Second, interval, "every node should serve the record")
+	}
+
+	_, err = c.CreateRecord("orders.edge.internal.", "10.0.0.2", 30)
+	var existing *store.ExistingRecordError
+	assert.True(errors.As(err, &existing), "records created elsewhere should exist locally")
+
+	_, err = c.UpdateRecord("orders.edge.internal.", "10.0.0.2", 30)
+	assert.NoError(err)
+
+	for _, node := range []*gossip.Node{a, b, c} {
+		assert.Eventually(has(node, "orders.edge.internal.", "10.0.0.2"), 5*time.Second, interval, "every node should serve the update")
+	}
+
+	_, err = a.RemoveRecord("orders.edge.internal.")
+	assert.NoError(err)
+
+	for _, node := range []*gossip.Node{a, b, c} {
+		assert.Eventually(func() bool {
+			_, err := node.GetRecord("orders.edge.internal.")
+			return err != nil
+		}, 5*time.Second, interval, "every node should drop the record")
+	}
+}
+
+func TestNode_AntiEntropy(t *testing.T) {
+	assert := assert.New(t)
+
+	a, startA := newNode(t, "a")
+	startA()
+	b, startB := newNode(t, "b", a.Address)
+
+	// changes made on both sides while b is cut off
+	_, err := b.CreateRecord("shared.edge.internal.", "10.0.0.1", 30)
+	assert.NoError(err)
+	_, err = b.CreateRecord("b.edge.internal.", "10.0.0.2", 30, vinyl.WithOwner("lease:/var/lib/misc/dnsmasq.leases"))
+	assert.NoError(err)
+	_, err = a.CreateRecord("shared.edge.internal.", "10.0.0.3", 30)
+	assert.NoError(err)
+	_, err = a.CreateRecord("a.edge.internal.", "10.0.0.4", 30)
+	assert.NoError(err)
+
+	startB()
+
+	for _, node := range []*gossip.Node{a, b} {
+		assert.Eventually(has(node, "a.edge.internal.", "10.0.0.4"), 5*time.Second, interval)
+		assert.Eventually(has(node, "b.edge.internal.", "10.0.0.2"), 5*time.Second, interval)
+		assert.Eventually(has(node, "shared.edge.internal.", "10.0.0.3"), 5*time.Second, interval, "the latest change should win")
+	}
+
+	record, err := a.GetRecord("b.edge.internal.")
+	assert.NoError(err)
+	assert.Equal("lease:/var/lib/misc/dnsmasq.leases", record.Owner, "owners should be kept")
+}
+
+func TestNode_Members(t *testing.T) {
+	assert := assert.New(t)
+
+	node := gossip.NewNode("a", "127.0.0.1:0", gossip.WithDeadAfter(time.Minute))
+	server := gossip.NewGossipServer(node)
+	recent := time.Now().Add(-10 * time.Second)
+
+	_, err := server.Push(context.Background(), &proto.PushRequest{
+		From: &proto.Member{Id: "b", Address: "127.0.0.1:2"},
+		Members: []*proto.Member{
+			{Id: "a", Address: "127.0.0.1:0", LastSeen: time.Now().UnixNano()},
+			{Id: "c", Address: "127.0.0.1:3", LastSeen: recent.UnixNano()},
+			{Id: "dead", Address: "127.0.0.1:4", LastSeen: time.Now().Add(-2 * time.Minute).UnixNano()},
+		},
+	})
+	assert.NoError(err)
+
+	members := node.Members()
+	assert.Len(members, 2, "members not heard from within DeadAfter shouldn't be learned second hand")
+	assert.Equal("b", members[0].ID)
+	assert.WithinDuration(time.Now(), members[0].LastSeen, time.Second, "the sender was heard from directly")
+	assert.Equal("c", members[1].ID)
+	assert.True(recent.Equal(members[1].LastSeen), "members passed along should keep when they were last heard from")
+
+	_, err = server.Push(context.Background(), &proto.PushRequest{
+		From:    &proto.Member{Id: "b", Address: "127.0.0.1:2"},
+		Members: []*proto.Member{{Id: "c", Address: "127.0.0.1:3", LastSeen: recent.Add(-time.Second).UnixNano()}},
+	})
+	assert.NoError(err)
+	assert.True(recent.Equal(node.Members()[1].LastSeen), "an older last seen time shouldn't replace a newer one")
+}
+
+func TestNode_Errors(t *testing.T) {
+	tests := map[string]struct {
+		Change func(*gossip.Node) error
+		Err    error
+	}{
+		"returns MissingRecordError updating a missing record": {
+			Change: func(node *gossip.Node) error {
+				_, err := node.UpdateRecord("missing.edge.internal.", "10.0.0.1", 30)
+				return err
+			},
+			Err: &store.MissingRecordError{Domain: "missing.edge.internal."},
+		},
+		"returns MissingRecordError removing a missing record": {
+			Change: func(node *gossip.Node) error {
+				_, err := node.RemoveRecord("missing.edge.internal.")
+				return err
+			},
+			Err: &store.MissingRecordError{Domain: "missing.edge.internal."},
+		},
+		"returns OwnerConflictError removing another owner's record": {
+			Change: func(node *gossip.Node) error {
+				_, err := node.RemoveOwnedRecord("owned.edge.internal.", "kubernetes")
+				return err
+			},
+			Err: &store.OwnerConflictError{Domain: "owned.edge.internal.", Owner: "docker"},
+		},
+		"returns InvalidRecordAddressError for bad addresses": {
+			Change: func(node *gossip.Node) error {
+				_, err := node.CreateRecord("bad.edge.internal.", "bad", 30)
+				return err
+			},
+			Err: &vinyl.InvalidRecordAddressError{Address: "bad"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			node := gossip.NewNode("a", "127.0.0.1:0")
+			_, err := node.CreateRecord("owned.edge.internal.", "10.0.0.1", 30, vinyl.WithOwner("docker"))
+			assert.NoError(err)
+
+			err = test.Change(node)
+			assert.ErrorContains(err, test.Err.Error())
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package gossip
+
+import (
+	"context"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/proto"
+)
+
+// GossipServer receives the changes and syncs of other nodes
+type GossipServer struct {
+	Node *Node
+	proto.UnimplementedGossipServer
+}
+
+func NewGossipServer(node *Node) *GossipServer {
+	return &GossipServer{
+		Node: node,
+	}
+}
+
+// Push applies changes from another node and spreads the ones that were new to this node further
+func (server *GossipServer) Push(ctx context.Context, req *proto.PushRequest) (*proto.PushResponse, error) {
+	server.Node.meet(req.From, req.Members)
+
+	fresh := server.Node.merge(req.Entries)
+	go server.Node.spread(fresh, req.From.GetId())
+
+	return &proto.PushResponse{}, nil
+}
+
+// Sync answers another node's digest with the entries it is behind on and the domains it is ahead on
+func (server *GossipServer) Sync(ctx context.Context, req *proto.SyncRequest) (*proto.SyncResponse, error) {
+	server.Node.meet(req.From, req.Members)
+
+	missing, wanted := server.Node.compare(req.Digests)
+
+	resp := &proto.SyncResponse{
+		From:    server.Node.self(),
+		Members: convertMembersToProto(server.Node.Members()...),
+		Entries: convertEntriesToProto(missing...),
+		Wanted:  wanted,
+	}
+
+	return resp, nil
+}
+
+func convertMembersToProto(members ...Member) []*proto.Member {
+	protoMembers := []*proto.Member{}
+
+	for _, m := range members {
+		protoMembers = append(protoMembers, &proto.Member{
+			Id:       m.ID,
+			Address:  m.Address,
+			LastSeen: m.LastSeen.UnixNano(),
+		})
+	}
+
+	return protoMembers
+}
+
+func convertEntriesToProto(entries ...*entry) []*proto.Entry {
+	protoEntries := []*proto.Entry{}
+
+	for _, e := range entries {
+		protoEntries = append(protoEntries, &proto.Entry{
+			Record: &proto.Record{
+				Domain:  e.record.Domain,
+				Address: e.record.Address,
+				Ttl:     e.record.TTL,
+				Labels:  e.record.Labels,
+				Owner:   e.record.Owner,
+				Expires: e.record.ExpiresUnix(),
+			},
+			Version: convertVersionToProto(e.version),
+			Deleted: e.deleted,
+		})
+	}
+
+	return protoEntries
+}
+
+func convertProtoToEntry(pe *proto.Entry) *entry {
+	return &entry{
+		record: vinyl.Record{
+			Domain:  pe.Record.GetDomain(),
+			Address: pe.Record.GetAddress(),
+			TTL:     pe.Record.GetTtl(),
+			Labels:  pe.Record.GetLabels(),
+			Owner:   pe.Record.GetOwner(),
+			Expires: vinyl.UnixExpiry(pe.Record.GetExpires()),
+		},
+		version: convertProtoToVersion(pe.Version),
+		deleted: pe.Deleted,
+	}
+}
+
+func convertVersionToProto(v version) *proto.Version {
+	return &proto.Version{
+		Timestamp: v.timestamp,
+		Node:      v.node,
+	}
+}
+
+func convertProtoToVersion(pv *proto.Version) version {
+	return version{
+		timestamp: pv.GetTimestamp(),
+		node:      pv.GetNode(),
+	}
+}
@@ -3,14 +3,87 @@ package vinyl
 import (
 	"fmt"
 	"net"
+	"strings"
+	"time"
 
 	valid "github.com/asaskevich/govalidator"
 )
 
+const (
+	TypeA    = "A"
+	TypeAAAA = "AAAA"
+)
+
 type Record struct {
-	Domain  string
-	Address string
-	TTL     uint32
+	Domain  string            `json:"domain" yaml:"domain"`
+	Address string            `json:"address" yaml:"address"`
+	TTL     uint32            `json:"ttl" yaml:"ttl"`
+	Labels  map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Owner is the source that manages the record. Records created through the api have no owner
+	Owner string `json:"owner,omitempty" yaml:"owner,omitempty"`
+	// Expires is when a leased record is removed unless its lease is renewed. Records without a lease never expire
+	Expires *time.Time `json:"expires,omitempty" yaml:"expires,omitempty"`
+}
+
+// RecordOption sets the optional fields of a record
+type RecordOption func(*Record)
+
+func WithLabels(labels map[string]string) RecordOption {
+	return func(record *Record) {
+		if len(labels) == 0 {
+			return
+		}
+
+		record.Labels = labels
+	}
+}
+
+func WithOwner(owner string) RecordOption {
+	return func(record *Record) {
+		record.Owner = owner
+	}
+}
+
+// WithExpires leases the record until expires
+func WithExpires(expires time.Time) RecordOption {
+	return func(record *Record) {
+		record.Expires = &expires
+	}
+}
+
+// Expired reports whether the record's lease ran out before now
+func (record Record) Expired(now time.Time) bool {
+	return record.Expires != nil && record.Expires.Before(now)
+}
+
+// ExpiresUnix is Expires in unix seconds, or 0 for records without a lease
+func (record Record) ExpiresUnix() int64 {
+	if record.Expires == nil {
+		return 0
+	}
+
+	return record.Expires.Unix()
+}
+
+// UnixExpiry is the expiry of unix seconds, nil for 0
+func UnixExpiry(seconds int64) *time.Time {
+	if seconds == 0 {
+		return nil
+	}
+
+	expires := time.Unix(seconds, 0)
+
+	return &expires
+}
+
+// Type returns A for ipv4 addresses and AAAA for ipv6 addresses
+func (record Record) Type() string {
+	ip := net.ParseIP(record.Address)
+	if ip != nil && ip.To4() == nil {
+		return TypeAAAA
+	}
+
+	return TypeA
 }
 
 type InvalidRecordAddressError struct {
@@ -37,13 +110,17 @@ func (e *InvalidRecordTTLError) Error() string {
 	return fmt.Sprintf("%v is not a valid tll", e.TTL)
 }
 
-func NewRecord(domain string, address string, ttl uint32) (*Record, error) {
+func NewRecord(domain string, address string, ttl uint32, options ...RecordOption) (*Record, error) {
 	record := &Record{
 		Domain:  domain,
 		Address: address,
 		TTL:     ttl,
 	}
 
+	for _, option := range options {
+		option(record)
+	}
+
 	err := ValidateRecord(record)
 	if err != nil {
 		return nil, fmt.Errorf("NewRecord: %w", err)
@@ -53,7 +130,10 @@ func NewRecord(domain string, address string, ttl uint32) (*Record, error) {
 }
 
 func ValidateRecord(record *Record) error {
-	if !valid.IsDNSName(record.Domain) {
+	// wildcard owner names are only allowed to start with a single asterisk label
+	domain := strings.TrimPrefix(record.Domain, "*.")
+
+	if !valid.IsDNSName(domain) {
 		return fmt.Errorf("ValidateRecord: %w", &InvalidRecordDomainError{
 			Domain: record.Domain,
 		})
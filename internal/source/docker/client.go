@@ -0,0 +1,189 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const DefaultSocket = "/var/run/docker.sock"
+
+// Endpoint is a container's attachment to a single network
+type Endpoint struct {
+	IPAddress         string
+	GlobalIPv6Address string
+}
+
+type Container struct {
+	ID       string
+	Name     string
+	Labels   map[string]string
+	Networks map[string]Endpoint
+}
+
+// Event is a container lifecycle event from the docker event stream
+type Event struct {
+	Action string
+	ID     string
+}
+
+// Client talks to the docker engine api over its unix socket
+type Client struct {
+	HTTP *http.Client
+}
+
+func NewClient(socket string) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+			dialer := net.Dialer{}
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}
+
+	return &Client{
+		HTTP: &http.Client{
+			Transport: transport,
+		},
+	}
+}
+
+// ListContainers returns every running container
+func (client *Client) ListContainers(ctx context.Context) ([]Container, error) {
+	listed := []struct {
+		ID              string `json:"Id"`
+		Names           []string
+		Labels          map[string]string
+		NetworkSettings struct {
+			Networks map[string]Endpoint
+		}
+	}{}
+
+	err := client.get(ctx, "/containers/json", nil, &listed)
+	if err != nil {
+		return nil, fmt.Errorf("ListContainers: %w", err)
+	}
+
+	containers := []Container{}
+	for _, l := range listed {
+		name := ""
+		if len(l.Names) > 0 {
+			name = l.Names[0]
+		}
+
+		containers = append(containers, Container{
+			ID:       l.ID,
+			Name:     strings.TrimPrefix(name, "/"),
+			Labels:   l.Labels,
+			Networks: l.NetworkSettings.Networks,
+		})
+	}
+
+	return containers, nil
+}
+
+func (client *Client) InspectContainer(ctx context.Context, id string) (*Container, error) {
+	inspected := struct {
+		ID     string `json:"Id"`
+		Name   string
+		Config struct {
+			Labels map[string]string
+		}
+		NetworkSettings struct {
+			Networks map[string]Endpoint
+		}
+	}{}
+
+	err := client.get(ctx, "/containers/"+url.PathEscape(id)+"/json", nil, &inspected)
+	if err != nil {
+		return nil, fmt.Errorf("InspectContainer: %w", err)
+	}
+
+	container := &Container{
+		ID:       inspected.ID,
+		Name:     strings.TrimPrefix(inspected.Name, "/"),
+		Labels:   inspected.Config.Labels,
+		Networks: inspected.NetworkSettings.Networks,
+	}
+
+	return container, nil
+}
+
+// Events streams container start, stop and die events to events until ctx is done or the stream breaks
+func (client *Client) Events(ctx context.Context, events chan<- Event) error {
+	query := url.Values{}
+	query.Set("filters", `{"type":["container"],"event":["start","stop","die"]}`)
+
+	resp, err := client.do(ctx, "/events", query)
+	if err != nil {
+		return fmt.Errorf("Events: %w", err)
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		message := struct {
+			Action string
+			Actor  struct {
+				ID string
+			}
+		}{}
+
+		err := decoder.Decode(&message)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("Events: %w", err)
+		}
+
+		select {
+		case events <- Event{Action: message.Action, ID: message.Actor.ID}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (client *Client) get(ctx context.Context, path string, query url.Values, v interface{}) error {
+	resp, err := client.do(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (client *Client) do(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	u := url.URL{
+		Scheme:   "http",
+		Host:     "docker",
+		Path:     path,
+		RawQuery: query.Encode(),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &APIError{
+			Path:   path,
+			Status: resp.StatusCode,
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,207 @@
+package docker_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/platform-edn/vinyl/internal/source/docker"
+	"github.com/stretchr/testify/assert"
+)
+
+// FakeDocker serves the parts of the docker engine api the watcher uses over a unix socket
+type FakeDocker struct {
+	Socket     string
+	containers map[string]docker.Container
+	events     chan docker.Event
+	mutex      sync.Mutex
+}
+
+func NewFakeDocker(t *testing.T, containers ...docker.Container) *FakeDocker {
+	// unix socket paths have to stay short so t.TempDir can't be used
+	dir, err := os.MkdirTemp("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	fake := &FakeDocker{
+		Socket:     filepath.Join(dir, "docker.sock"),
+		containers: map[string]docker.Container{},
+		events:     make(chan docker.Event, 10),
+	}
+
+	for _, c := range containers {
+		fake.containers[c.ID] = c
+	}
+
+	listener, err := net.Listen("unix", fake.Socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(fake.ServeHTTP))
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return fake
+}
+
+func (fake *FakeDocker) Start(c docker.Container) {
+	fake.mutex.Lock()
+	fake.containers[c.ID] = c
+	fake.mutex.Unlock()
+
+	fake.events <- docker.Event{Action: "start", ID: c.ID}
+}
+
+func (fake *FakeDocker) Stop(id string) {
+	fake.mutex.Lock()
+	delete(fake.containers, id)
+	fake.mutex.Unlock()
+
+	fake.events <- docker.Event{Action: "die", ID: id}
+}
+
+func (fake *FakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	switch {
+	case r.URL.Path == "/containers/json":
+		listed := []map[string]interface{}{}
+		for _, c := range fake.containers {
+			listed = append(listed, map[string]interface{}{
+				"Id":              c.ID,
+				"Names":           []string{"/" + c.Name},
+				"Labels":          c.Labels,
+				"NetworkSettings": map[string]interface{}{"Networks": c.Networks},
+			})
+		}
+
+		json.NewEncoder(w).Encode(listed)
+	case strings.HasPrefix(r.URL.Path, "/containers/"):
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/containers/"), "/json")
+
+		c, exist := fake.containers[id]
+		if !exist {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"Id":              c.ID,
+			"Name":            "/" + c.Name,
+			"Config":          map[string]interface{}{"Labels": c.Labels},
+			"NetworkSettings": map[string]interface{}{"Networks": c.Networks},
+		})
+	case r.URL.Path == "/events":
+		fake.mutex.Unlock()
+		defer fake.mutex.Lock()
+
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		for {
+			select {
+			case event := <-fake.events:
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"Type":   "container",
+					"Action": event.Action,
+					"Actor":  map[string]interface{}{"ID": event.ID},
+				})
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestClient_ListContainers(t *testing.T) {
+	assert := assert.New(t)
+	container := docker.Container{
+		ID:       "abc123",
+		Name:     "web",
+		Labels:   map[string]string{docker.NameLabel: "api"},
+		Networks: map[string]docker.Endpoint{"bridge": {IPAddress: "172.17.0.2"}},
+	}
+	fake := NewFakeDocker(t, container)
+	client := docker.NewClient(fake.Socket)
+
+	containers, err := client.ListContainers(context.Background())
+	assert.NoError(err)
+	assert.Equal([]docker.Container{container}, containers)
+}
+
+func TestClient_InspectContainer(t *testing.T) {
+	tests := map[string]struct {
+		ID  string
+		Err error
+	}{
+		"inspects a container": {
+			ID: "abc123",
+		},
+		"returns APIError for a missing container": {
+			ID: "missing",
+			Err: &docker.APIError{
+				Path:   "/containers/missing/json",
+				Status: http.StatusNotFound,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			container := docker.Container{
+				ID:       "abc123",
+				Name:     "web",
+				Networks: map[string]docker.Endpoint{"bridge": {IPAddress: "172.17.0.2"}},
+			}
+			fake := NewFakeDocker(t, container)
+			client := docker.NewClient(fake.Socket)
+
+			inspected, err := client.InspectContainer(context.Background(), test.ID)
+			if test.Err != nil {
+				assert.ErrorContains(err, test.Err.Error())
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(container, *inspected)
+		})
+	}
+}
+
+func TestClient_Events(t *testing.T) {
+	assert := assert.New(t)
+	fake := NewFakeDocker(t)
+	client := docker.NewClient(fake.Socket)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := make(chan docker.Event)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- client.Events(ctx, events)
+	}()
+
+	fake.Stop("abc123")
+	assert.Equal(docker.Event{Action: "die", ID: "abc123"}, <-events)
+
+	cancel()
+	assert.NoError(<-errs, "cancelling should end the stream cleanly")
+}
@@ -0,0 +1,12 @@
+package docker
+
+import "fmt"
+
+type APIError struct {
+	Path   string
+	Status int
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("docker api returned %v for %s", e.Status, e.Path)
+}
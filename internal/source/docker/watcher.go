@@ -0,0 +1,242 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+)
+
+const (
+	// DisableLabel set to true on a container keeps it out of dns
+	DisableLabel = "vinyl.disable"
+	// NameLabel on a container replaces the container name in its records
+	NameLabel = "vinyl.name"
+	// ContainerLabel holds the id of the container a record was created for
+	ContainerLabel = "docker.container"
+	// Owner is the owner of every record created by the watcher
+	Owner = "docker"
+
+	DefaultDomain = "docker.internal."
+	DefaultTTL    = 30
+	DefaultRetry  = 5 * time.Second
+)
+
+type RecordStorer interface {
+	CreateRecord(string, string, uint32, ...vinyl.RecordOption) (*vinyl.Record, error)
+	RemoveOwnedRecord(string, string) (*vinyl.Record, error)
+}
+
+type DockerClienter interface {
+	ListContainers(ctx context.Context) ([]Container, error)
+	InspectContainer(ctx context.Context, id string) (*Container, error)
+	Events(ctx context.Context, events chan<- Event) error
+}
+
+// Watcher registers a record for every network of every running container and removes them when it stops
+type Watcher struct {
+	Client DockerClienter
+	Store  RecordStorer
+	Domain string
+	TTL    uint32
+	Retry  time.Duration
+	// containers holds the domains registered for each container id
+	containers map[string][]string
+}
+
+// WatcherOption sets the optional fields of a Watcher
+type WatcherOption func(*Watcher)
+
+func WithDomain(domain string) WatcherOption {
+	return func(watcher *Watcher) {
+		watcher.Domain = domain
+	}
+}
+
+func WithTTL(ttl uint32) WatcherOption {
+	return func(watcher *Watcher) {
+		watcher.TTL = ttl
+	}
+}
+
+func WithRetry(retry time.Duration) WatcherOption {
+	return func(watcher *Watcher) {
+		watcher.Retry = retry
+	}
+}
+
+func NewWatcher(client DockerClienter, store RecordStorer, options ...WatcherOption) *Watcher {
+	watcher := &Watcher{
+		Client:     client,
+		Store:      store,
+		Domain:     DefaultDomain,
+		TTL:        DefaultTTL,
+		Retry:      DefaultRetry,
+		containers: map[string][]string{},
+	}
+
+	for _, option := range options {
+		option(watcher)
+	}
+
+	return watcher
+}
+
+// Run keeps the store in sync with docker until ctx is done, resyncing whenever the event stream breaks
+func (watcher *Watcher) Run(ctx context.Context) error {
+	for {
+		err := watcher.watch(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		log.Printf("docker watcher: %s, retrying in %s", err, watcher.Retry)
+
+		select {
+		case <-time.After(watcher.Retry):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (watcher *Watcher) watch(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// subscribe before listing so nothing that happens during the sync is missed
+	events := make(chan Event)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- watcher.Client.Events(ctx, events)
+	}()
+
+	err := watcher.Sync(ctx)
+	if err != nil {
+		return fmt.Errorf("watch: %w", err)
+	}
+
+	for {
+		select {
+		case event := <-events:
+			err := watcher.Handle(ctx, event)
+			if err != nil {
+				log.Printf("docker watcher: %s", err)
+			}
+		case err := <-errs:
+			if err == nil {
+				err = fmt.Errorf("event stream closed")
+			}
+
+			return fmt.Errorf("watch: %w", err)
+		}
+	}
+}
+
+// Sync registers every running container and removes the records of containers that are gone
+func (watcher *Watcher) Sync(ctx context.Context) error {
+	containers, err := watcher.Client.ListContainers(ctx)
+	if err != nil {
+		return fmt.Errorf("Sync: %w", err)
+	}
+
+	running := map[string]struct{}{}
+	for _, container := range containers {
+		running[container.ID] = struct{}{}
+		watcher.Register(container)
+	}
+
+	for id := range watcher.containers {
+		_, exist := running[id]
+		if !exist {
+			watcher.Deregister(id)
+		}
+	}
+
+	return nil
+}
+
+// Handle applies a single container event
+func (watcher *Watcher) Handle(ctx context.Context, event Event) error {
+	switch event.Action {
+	case "start":
+		container, err := watcher.Client.InspectContainer(ctx, event.ID)
+		if err != nil {
+			return fmt.Errorf("Handle: %w", err)
+		}
+
+		watcher.Register(*container)
+	case "stop", "die":
+		watcher.Deregister(event.ID)
+	}
+
+	return nil
+}
+
+// Register creates a record for each network of a container unless it has opted out
+func (watcher *Watcher) Register(container Container) {
+	if strings.EqualFold(container.Labels[DisableLabel], "true") {
+		watcher.Deregister(container.ID)
+		return
+	}
+
+	if _, exist := watcher.containers[container.ID]; exist {
+		return
+	}
+
+	name := container.Name
+	if custom := container.Labels[NameLabel]; custom != "" {
+		name = custom
+	}
+
+	domains := []string{}
+	for network, endpoint := range container.Networks {
+		// the store holds one address per domain so ipv4 wins when a network has both
+		address := endpoint.IPAddress
+		if address == "" {
+			address = endpoint.GlobalIPv6Address
+		}
+		if address == "" {
+			continue
+		}
+
+		domain := strings.ToLower(fmt.Sprintf("%s.%s.%s", name, network, watcher.domain()))
+		labels := map[string]string{
+			ContainerLabel: container.ID,
+		}
+
+		_, err := watcher.Store.CreateRecord(domain, address, watcher.TTL, vinyl.WithLabels(labels), vinyl.WithOwner(Owner))
+		if err != nil {
+			log.Printf("docker watcher: could not register %s: %s", container.Name, err)
+			continue
+		}
+
+		domains = append(domains, domain)
+	}
+
+	watcher.containers[container.ID] = domains
+}
+
+// Deregister removes every record created for a container
+func (watcher *Watcher) Deregister(id string) {
+	domains, exist := watcher.containers[id]
+	if !exist {
+		return
+	}
+
+	for _, domain := range domains {
+		_, err := watcher.Store.RemoveOwnedRecord(domain, Owner)
+		if err != nil {
+			log.Printf("docker watcher: could not remove %s: %s", domain, err)
+		}
+	}
+
+	delete(watcher.containers, id)
+}
+
+func (watcher *Watcher) domain() string {
+	return strings.Trim(watcher.Domain, ".") + "."
+}
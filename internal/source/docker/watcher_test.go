@@ -0,0 +1,111 @@
+package docker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/platform-edn/vinyl/internal/source/docker"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcher_Register(t *testing.T) {
+	tests := map[string]struct {
+		Container docker.Container
+		Domains   []string
+	}{
+		"registers a record per network": {
+			Container: docker.Container{
+				ID:   "abc123",
+				Name: "web",
+				Networks: map[string]docker.Endpoint{
+					"bridge":   {IPAddress: "172.17.0.2"},
+					"frontend": {GlobalIPv6Address: "fd00::2"},
+					"none":     {},
+				},
+			},
+			Domains: []string{"web.bridge.docker.internal.", "web.frontend.docker.internal."},
+		},
+		"uses the custom name label": {
+			Container: docker.Container{
+				ID:       "abc123",
+				Name:     "web",
+				Labels:   map[string]string{docker.NameLabel: "API"},
+				Networks: map[string]docker.Endpoint{"bridge": {IPAddress: "172.17.0.2"}},
+			},
+			Domains: []string{"api.bridge.docker.internal."},
+		},
+		"honors the opt out label": {
+			Container: docker.Container{
+				ID:       "abc123",
+				Name:     "web",
+				Labels:   map[string]string{docker.DisableLabel: "true"},
+				Networks: map[string]docker.Endpoint{"bridge": {IPAddress: "172.17.0.2"}},
+			},
+			Domains: []string{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			mem := store.NewMemory()
+			watcher := docker.NewWatcher(nil, mem)
+
+			watcher.Register(test.Container)
+
+			domains := []string{}
+			for domain, record := range mem.Records {
+				domains = append(domains, domain)
+				assert.Equal(docker.Owner, record.Owner)
+			}
+			assert.ElementsMatch(test.Domains, domains)
+
+			watcher.Deregister(test.Container.ID)
+			assert.Empty(mem.Records, "deregistering should remove every record")
+		})
+	}
+}
+
+func TestWatcher_Run(t *testing.T) {
+	assert := assert.New(t)
+	web := docker.Container{
+		ID:       "web123",
+		Name:     "web",
+		Networks: map[string]docker.Endpoint{"bridge": {IPAddress: "172.17.0.2"}},
+	}
+	db := docker.Container{
+		ID:       "db123",
+		Name:     "db",
+		Networks: map[string]docker.Endpoint{"bridge": {IPAddress: "172.17.0.3"}},
+	}
+
+	fake := NewFakeDocker(t, web)
+	mem := store.NewMemory()
+	watcher := docker.NewWatcher(docker.NewClient(fake.Socket), mem, docker.WithDomain("containers.lab"), docker.WithRetry(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watcher.Run(ctx)
+	}()
+
+	hasRecord := func(domain string) func() bool {
+		return func() bool {
+			_, err := mem.GetRecord(domain)
+			return err == nil
+		}
+	}
+
+	assert.Eventually(hasRecord("web.bridge.containers.lab."), time.Second, 10*time.Millisecond, "running containers should be registered")
+
+	fake.Start(db)
+	assert.Eventually(hasRecord("db.bridge.containers.lab."), time.Second, 10*time.Millisecond, "started containers should be registered")
+
+	fake.Stop(web.ID)
+	assert.Eventually(func() bool { return !hasRecord("web.bridge.containers.lab.")() }, time.Second, 10*time.Millisecond, "stopped containers should be removed")
+
+	cancel()
+	assert.NoError(<-done)
+}
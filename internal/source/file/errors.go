@@ -0,0 +1,16 @@
+package file
+
+import "fmt"
+
+type InvalidRecordFileError struct {
+	Path string
+	Err  error
+}
+
+func (e *InvalidRecordFileError) Error() string {
+	return fmt.Sprintf("%s is not a valid record file: %s", e.Path, e.Err)
+}
+
+func (e *InvalidRecordFileError) Unwrap() error {
+	return e.Err
+}
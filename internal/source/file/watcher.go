@@ -0,0 +1,182 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/source"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// OwnerPrefix is joined with the directory to form the owner of the records loaded from it
+	OwnerPrefix = "file:"
+
+	DefaultTTL      = 300
+	DefaultDebounce = 250 * time.Millisecond
+)
+
+// Watcher keeps the store in sync with the yaml and json record files in a directory
+type Watcher struct {
+	Dir      string
+	Store    source.RecordStorer
+	TTL      uint32
+	Debounce time.Duration
+}
+
+// WatcherOption sets the optional fields of a Watcher
+type WatcherOption func(*Watcher)
+
+// WithTTL sets the ttl of records that don't have one in their file
+func WithTTL(ttl uint32) WatcherOption {
+	return func(watcher *Watcher) {
+		watcher.TTL = ttl
+	}
+}
+
+// WithDebounce sets how long the directory has to be quiet after a change before the store is synced
+func WithDebounce(debounce time.Duration) WatcherOption {
+	return func(watcher *Watcher) {
+		watcher.Debounce = debounce
+	}
+}
+
+func NewWatcher(dir string, store source.RecordStorer, options ...WatcherOption) *Watcher {
+	watcher := &Watcher{
+		Dir:      filepath.Clean(dir),
+		Store:    store,
+		TTL:      DefaultTTL,
+		Debounce: DefaultDebounce,
+	}
+
+	for _, option := range options {
+		option(watcher)
+	}
+
+	return watcher
+}
+
+// Owner is the owner of every record created by the watcher, so watchers of different directories leave each
+// other's records alone
+func (watcher *Watcher) Owner() string {
+	return OwnerPrefix + watcher.Dir
+}
+
+// Run syncs the store and then resyncs it on every change to the directory until ctx is done
+func (watcher *Watcher) Run(ctx context.Context) error {
+	err := source.WatchFiles(ctx, watcher.Dir, isRecordFile, watcher.Debounce, func() {
+		err := watcher.Sync()
+		if err != nil {
+			log.Printf("file watcher: %s", err)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("Run: %w", err)
+	}
+
+	return nil
+}
+
+// Sync loads the directory and reconciles the store with it. Nothing is changed if any file can't be read so a
+// half written file doesn't remove records
+func (watcher *Watcher) Sync() error {
+	desired, err := watcher.Load()
+	if err != nil {
+		return fmt.Errorf("Sync: %w", err)
+	}
+
+	err = watcher.Reconcile(desired)
+	if err != nil {
+		return fmt.Errorf("Sync: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads the records of every file in the directory keyed by domain. When a domain is defined more than once
+// the definition in the file that sorts first wins
+func (watcher *Watcher) Load() (map[string]vinyl.Record, error) {
+	entries, err := os.ReadDir(watcher.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("Load: %w", err)
+	}
+
+	records := map[string]vinyl.Record{}
+	for _, entry := range entries {
+		if entry.IsDir() || !isRecordFile(entry.Name()) {
+			continue
+		}
+
+		path := filepath.Join(watcher.Dir, entry.Name())
+		loaded, err := ReadRecords(path)
+		if err != nil {
+			return nil, fmt.Errorf("Load: %w", err)
+		}
+
+		for _, record := range loaded {
+			record.Domain = fqdn(record.Domain)
+			if record.TTL == 0 {
+				record.TTL = watcher.TTL
+			}
+
+			if _, exist := records[record.Domain]; exist {
+				log.Printf("file watcher: %s is defined more than once, ignoring the one in %s", record.Domain, path)
+				continue
+			}
+
+			records[record.Domain] = record
+		}
+	}
+
+	return records, nil
+}
+
+// Reconcile creates, updates and removes the records owned by the watcher so they match desired. Records owned by
+// anyone else are never touched
+func (watcher *Watcher) Reconcile(desired map[string]vinyl.Record) error {
+	err := source.Reconcile(watcher.Store, watcher.Owner(), desired)
+	if err != nil {
+		return fmt.Errorf("Reconcile: %w", err)
+	}
+
+	return nil
+}
+
+// ReadRecords parses a file holding a list of records. yaml is a superset of json so both formats are read the
+// same way
+func ReadRecords(path string) ([]vinyl.Record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ReadRecords: %w", err)
+	}
+
+	records := []vinyl.Record{}
+	err = yaml.Unmarshal(data, &records)
+	if err != nil {
+		return nil, fmt.Errorf("ReadRecords: %w", &InvalidRecordFileError{
+			Path: path,
+			Err:  err,
+		})
+	}
+
+	return records, nil
+}
+
+func isRecordFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return !strings.HasPrefix(filepath.Base(path), ".")
+	}
+
+	return false
+}
+
+func fqdn(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(domain, ".")) + "."
+}
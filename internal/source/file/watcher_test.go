@@ -0,0 +1,157 @@
+package file_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/source/file"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, dir string, name string, content string) {
+	err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcher_Load(t *testing.T) {
+	tests := map[string]struct {
+		Files   map[string]string
+		Records map[string]vinyl.Record
+		Err     bool
+	}{
+		"reads yaml and json files": {
+			Files: map[string]string{
+				"web.yaml": "- domain: web.lab\n  address: 10.0.0.1\n  ttl: 60\n  labels:\n    rack: a1\n",
+				"db.json":  `[{"domain": "DB.lab.", "address": "fd00::1"}]`,
+				"notes.md": "not records",
+			},
+			Records: map[string]vinyl.Record{
+				"web.lab.": {Domain: "web.lab.", Address: "10.0.0.1", TTL: 60, Labels: map[string]string{"rack": "a1"}},
+				"db.lab.":  {Domain: "db.lab.", Address: "fd00::1", TTL: file.DefaultTTL},
+			},
+		},
+		"keeps the first definition of a domain": {
+			Files: map[string]string{
+				"a.yaml": "- domain: web.lab.\n  address: 10.0.0.1\n",
+				"b.yaml": "- domain: web.lab.\n  address: 10.0.0.2\n",
+			},
+			Records: map[string]vinyl.Record{
+				"web.lab.": {Domain: "web.lab.", Address: "10.0.0.1", TTL: file.DefaultTTL},
+			},
+		},
+		"reads empty files": {
+			Files:   map[string]string{"empty.yaml": ""},
+			Records: map[string]vinyl.Record{},
+		},
+		"returns InvalidRecordFileError for broken files": {
+			Files: map[string]string{"broken.yaml": "domain: [web.lab."},
+			Err:   true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			dir := t.TempDir()
+			for name, content := range test.Files {
+				writeFile(t, dir, name, content)
+			}
+
+			records, err := file.NewWatcher(dir, nil).Load()
+			if test.Err {
+				var fileErr *file.InvalidRecordFileError
+				assert.ErrorAs(err, &fileErr)
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(test.Records, records)
+		})
+	}
+}
+
+func TestWatcher_Reconcile(t *testing.T) {
+	assert := assert.New(t)
+	mem := store.NewMemory(
+		vinyl.Record{Domain: "api.lab.", Address: "10.0.0.9", TTL: 60},
+		vinyl.Record{Domain: "web.lab.", Address: "10.0.0.1", TTL: 60, Owner: "file:/etc/vinyl"},
+		vinyl.Record{Domain: "old.lab.", Address: "10.0.0.2", TTL: 60, Owner: "file:/etc/vinyl"},
+		vinyl.Record{Domain: "db.lab.", Address: "10.0.0.3", TTL: 60, Owner: "docker"},
+	)
+	watcher := file.NewWatcher("/etc/vinyl/", mem)
+
+	err := watcher.Reconcile(map[string]vinyl.Record{
+		"web.lab.": {Domain: "web.lab.", Address: "10.0.0.5", TTL: 60},
+		"new.lab.": {Domain: "new.lab.", Address: "10.0.0.6", TTL: 60},
+		"api.lab.": {Domain: "api.lab.", Address: "10.0.0.7", TTL: 60},
+		"db.lab.":  {Domain: "db.lab.", Address: "10.0.0.8", TTL: 60},
+	})
+	assert.NoError(err)
+
+	assert.Equal("10.0.0.5", mem.Records["web.lab."].Address, "changed records should be updated")
+	assert.Equal("file:/etc/vinyl", mem.Records["new.lab."].Owner, "new records should be owned by the watcher")
+	assert.NotContains(mem.Records, "old.lab.", "records no longer in the directory should be removed")
+	assert.Equal(vinyl.Record{Domain: "api.lab.", Address: "10.0.0.9", TTL: 60}, mem.Records["api.lab."], "api records should never be touched")
+	assert.Equal("docker", mem.Records["db.lab."].Owner, "records of other sources should never be touched")
+	assert.Equal("10.0.0.3", mem.Records["db.lab."].Address)
+
+	err = watcher.Reconcile(map[string]vinyl.Record{})
+	assert.NoError(err)
+	assert.Len(mem.Records, 2, "only the records of other owners should be left")
+}
+
+func TestWatcher_Run(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	writeFile(t, dir, "web.yaml", "- domain: web.lab.\n  address: 10.0.0.1\n")
+
+	mem := store.NewMemory()
+	watcher := file.NewWatcher(dir, mem, file.WithDebounce(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watcher.Run(ctx)
+	}()
+
+	address := func(domain string) func() string {
+		return func() string {
+			record, err := mem.GetRecord(domain)
+			if err != nil {
+				return ""
+			}
+
+			return record.Address
+		}
+	}
+	hasAddress := func(domain string, want string) func() bool {
+		return func() bool {
+			return address(domain)() == want
+		}
+	}
+
+	assert.Eventually(hasAddress("web.lab.", "10.0.0.1"), time.Second, 10*time.Millisecond, "existing files should be loaded")
+
+	writeFile(t, dir, "web.yaml", "- domain: web.lab.\n  address: 10.0.0.2\n")
+	assert.Eventually(hasAddress("web.lab.", "10.0.0.2"), time.Second, 10*time.Millisecond, "changed files should update records")
+
+	writeFile(t, dir, "broken.yaml", "- domain: [db.lab.")
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal("10.0.0.2", address("web.lab.")(), "a broken file should leave the store alone")
+
+	err := os.Remove(filepath.Join(dir, "broken.yaml"))
+	assert.NoError(err)
+	err = os.Remove(filepath.Join(dir, "web.yaml"))
+	assert.NoError(err)
+	assert.Eventually(hasAddress("web.lab.", ""), time.Second, 10*time.Millisecond, "removed files should remove records")
+
+	cancel()
+	assert.NoError(<-done)
+}
@@ -0,0 +1,115 @@
+package hosts
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+)
+
+type ExportStorer interface {
+	ListRecords(vinyl.RecordFilter, vinyl.Page) ([]vinyl.Record, string, error)
+	Watch(context.Context) <-chan vinyl.RecordEvent
+}
+
+// Exporter rewrites a hosts file with every record in the store whenever the store changes, for machines that
+// can't use dns
+type Exporter struct {
+	Path     string
+	Store    ExportStorer
+	Debounce time.Duration
+}
+
+func NewExporter(path string, store ExportStorer) *Exporter {
+	return &Exporter{
+		Path:     path,
+		Store:    store,
+		Debounce: DefaultDebounce,
+	}
+}
+
+// Run writes the file and rewrites it after changes until ctx is done
+func (exporter *Exporter) Run(ctx context.Context) error {
+	for {
+		// subscribe before writing so no change between the two is missed
+		events := exporter.Store.Watch(ctx)
+
+		err := exporter.Export()
+		if err != nil {
+			log.Printf("hosts exporter: %s", err)
+		}
+
+		var timer <-chan time.Time
+	watch:
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case _, ok := <-events:
+				// the store drops watchers that fall behind so start over with a full export
+				if !ok {
+					break watch
+				}
+
+				timer = time.After(exporter.Debounce)
+			case <-timer:
+				timer = nil
+
+				err := exporter.Export()
+				if err != nil {
+					log.Printf("hosts exporter: %s", err)
+				}
+			}
+		}
+	}
+}
+
+// Export replaces the file with the current records. The file is written next to the target and renamed over it so
+// readers never see a partial file
+func (exporter *Exporter) Export() error {
+	records, _, err := exporter.Store.ListRecords(vinyl.RecordFilter{}, vinyl.Page{})
+	if err != nil {
+		return fmt.Errorf("Export: %w", err)
+	}
+
+	data := &bytes.Buffer{}
+	err = WriteHosts(data, records...)
+	if err != nil {
+		return fmt.Errorf("Export: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(exporter.Path), "."+filepath.Base(exporter.Path))
+	if err != nil {
+		return fmt.Errorf("Export: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data.Bytes())
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("Export: %w", err)
+	}
+
+	err = tmp.Chmod(0644)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("Export: %w", err)
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("Export: %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), exporter.Path)
+	if err != nil {
+		return fmt.Errorf("Export: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package hosts
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+)
+
+// Entry is a name read from a hosts file along with the address it points at and the line it was defined on
+type Entry struct {
+	Name    string
+	Address string
+	Line    int
+}
+
+// ParseHosts reads a hosts file. Every name on a line becomes its own entry, comments are stripped and lines that
+// don't start with a valid address are skipped
+func ParseHosts(r io.Reader) ([]Entry, error) {
+	entries := []Entry{}
+	scanner := bufio.NewScanner(r)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// zone indexes like fe80::1%eth0 only mean something on the host that wrote the file
+		address, _, _ := strings.Cut(fields[0], "%")
+		ip := net.ParseIP(address)
+		if ip == nil {
+			log.Printf("hosts: line %v: %s is not a valid address", line, fields[0])
+			continue
+		}
+
+		for _, name := range fields[1:] {
+			entries = append(entries, Entry{
+				Name:    name,
+				Address: ip.String(),
+				Line:    line,
+			})
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("ParseHosts: %w", err)
+	}
+
+	return entries, nil
+}
+
+// WriteHosts writes records in hosts format with one line per address listing every domain pointing at it.
+// Wildcards can't be expressed in a hosts file so they are left out
+func WriteHosts(w io.Writer, records ...vinyl.Record) error {
+	addresses := []string{}
+	names := map[string][]string{}
+
+	for _, record := range records {
+		if strings.HasPrefix(record.Domain, "*.") {
+			continue
+		}
+
+		address := record.Address
+		if ip := net.ParseIP(address); ip != nil {
+			address = ip.String()
+		}
+
+		if _, exist := names[address]; !exist {
+			addresses = append(addresses, address)
+		}
+		names[address] = append(names[address], strings.TrimSuffix(record.Domain, "."))
+	}
+
+	_, err := fmt.Fprintln(w, "# generated by vinyl, changes will be overwritten")
+	if err != nil {
+		return fmt.Errorf("WriteHosts: %w", err)
+	}
+
+	for _, address := range addresses {
+		_, err := fmt.Fprintf(w, "%s\t%s\n", address, strings.Join(names[address], " "))
+		if err != nil {
+			return fmt.Errorf("WriteHosts: %w", err)
+		}
+	}
+
+	return nil
+}
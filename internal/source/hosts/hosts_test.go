@@ -0,0 +1,74 @@
+package hosts_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/source/hosts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHosts(t *testing.T) {
+	tests := map[string]struct {
+		Hosts   string
+		Entries []hosts.Entry
+	}{
+		"reads every name on a line": {
+			Hosts: "10.0.0.1 web web.lab.example www\n",
+			Entries: []hosts.Entry{
+				{Name: "web", Address: "10.0.0.1", Line: 1},
+				{Name: "web.lab.example", Address: "10.0.0.1", Line: 1},
+				{Name: "www", Address: "10.0.0.1", Line: 1},
+			},
+		},
+		"strips comments and blank lines": {
+			Hosts: "# lab hosts\n\n10.0.0.1\tweb # the web server\n  # indented comment\n",
+			Entries: []hosts.Entry{
+				{Name: "web", Address: "10.0.0.1", Line: 3},
+			},
+		},
+		"reads ipv6 addresses": {
+			Hosts: "fd00:0::1 db\nfe80::1%eth0 router\n",
+			Entries: []hosts.Entry{
+				{Name: "db", Address: "fd00::1", Line: 1},
+				{Name: "router", Address: "fe80::1", Line: 2},
+			},
+		},
+		"skips lines without a valid address": {
+			Hosts: "web 10.0.0.1\n10.0.0.2 db\n",
+			Entries: []hosts.Entry{
+				{Name: "db", Address: "10.0.0.2", Line: 2},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			entries, err := hosts.ParseHosts(strings.NewReader(test.Hosts))
+			assert.NoError(err)
+			assert.Equal(test.Entries, entries)
+		})
+	}
+}
+
+func TestWriteHosts(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &bytes.Buffer{}
+	err := hosts.WriteHosts(out,
+		vinyl.Record{Domain: "*.preview.lab.", Address: "10.0.0.9", TTL: 60},
+		vinyl.Record{Domain: "api.lab.", Address: "10.0.0.1", TTL: 60},
+		vinyl.Record{Domain: "db.lab.", Address: "fd00::1", TTL: 60},
+		vinyl.Record{Domain: "web.lab.", Address: "10.0.0.1", TTL: 60},
+	)
+	assert.NoError(err)
+	assert.Equal("# generated by vinyl, changes will be overwritten\n10.0.0.1\tapi.lab web.lab\nfd00::1\tdb.lab\n", out.String())
+
+	entries, err := hosts.ParseHosts(out)
+	assert.NoError(err)
+	assert.Len(entries, 3, "exported files should parse again")
+}
@@ -0,0 +1,160 @@
+package hosts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/source"
+)
+
+const (
+	// OwnerPrefix is joined with the file path to form the owner of the records loaded from it
+	OwnerPrefix = "hosts:"
+
+	DefaultTTL      = 300
+	DefaultDebounce = 250 * time.Millisecond
+)
+
+// Watcher keeps the store in sync with a hosts file
+type Watcher struct {
+	Path     string
+	Store    source.RecordStorer
+	Domain   string
+	TTL      uint32
+	Debounce time.Duration
+}
+
+// WatcherOption sets the optional fields of a Watcher
+type WatcherOption func(*Watcher)
+
+// WithDomain sets the domain appended to single label names like the search domain of a resolver would
+func WithDomain(domain string) WatcherOption {
+	return func(watcher *Watcher) {
+		watcher.Domain = domain
+	}
+}
+
+func WithTTL(ttl uint32) WatcherOption {
+	return func(watcher *Watcher) {
+		watcher.TTL = ttl
+	}
+}
+
+// WithDebounce sets how long the file has to be quiet after a change before the store is synced
+func WithDebounce(debounce time.Duration) WatcherOption {
+	return func(watcher *Watcher) {
+		watcher.Debounce = debounce
+	}
+}
+
+func NewWatcher(path string, store source.RecordStorer, options ...WatcherOption) *Watcher {
+	watcher := &Watcher{
+		Path:     filepath.Clean(path),
+		Store:    store,
+		TTL:      DefaultTTL,
+		Debounce: DefaultDebounce,
+	}
+
+	for _, option := range options {
+		option(watcher)
+	}
+
+	return watcher
+}
+
+// Owner is the owner of every record created by the watcher
+func (watcher *Watcher) Owner() string {
+	return OwnerPrefix + watcher.Path
+}
+
+// Run syncs the store and then resyncs it every time the file changes until ctx is done
+func (watcher *Watcher) Run(ctx context.Context) error {
+	match := func(name string) bool {
+		return filepath.Clean(name) == watcher.Path
+	}
+
+	err := source.WatchFiles(ctx, filepath.Dir(watcher.Path), match, watcher.Debounce, func() {
+		err := watcher.Sync()
+		if err != nil {
+			log.Printf("hosts watcher: %s", err)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("Run: %w", err)
+	}
+
+	return nil
+}
+
+// Sync loads the file and reconciles the store with it. A missing file removes every record it created
+func (watcher *Watcher) Sync() error {
+	desired, err := watcher.Load()
+	if err != nil {
+		return fmt.Errorf("Sync: %w", err)
+	}
+
+	err = source.Reconcile(watcher.Store, watcher.Owner(), desired)
+	if err != nil {
+		return fmt.Errorf("Sync: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads the records of the file keyed by domain. The first address of a name wins like it does for the
+// resolver, and loopback entries such as localhost are left out since they mean something different on every host
+func (watcher *Watcher) Load() (map[string]vinyl.Record, error) {
+	records := map[string]vinyl.Record{}
+
+	f, err := os.Open(watcher.Path)
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Load: %w", err)
+	}
+	defer f.Close()
+
+	entries, err := ParseHosts(f)
+	if err != nil {
+		return nil, fmt.Errorf("Load: %w", err)
+	}
+
+	for _, entry := range entries {
+		ip := net.ParseIP(entry.Address)
+		if ip.IsLoopback() || ip.IsUnspecified() {
+			continue
+		}
+
+		domain := watcher.name(entry.Name)
+		if _, exist := records[domain]; exist {
+			continue
+		}
+
+		records[domain] = vinyl.Record{
+			Domain:  domain,
+			Address: entry.Address,
+			TTL:     watcher.TTL,
+		}
+	}
+
+	return records, nil
+}
+
+func (watcher *Watcher) name(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+
+	domain := strings.Trim(watcher.Domain, ".")
+	if domain != "" && !strings.Contains(name, ".") {
+		name = name + "." + domain
+	}
+
+	return name + "."
+}
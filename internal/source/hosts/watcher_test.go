@@ -0,0 +1,111 @@
+package hosts_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/source/hosts"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcher_Load(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "hosts")
+	writeFile(t, path, "127.0.0.1 localhost\n::1 localhost ip6-localhost\n10.0.0.1 web nas.other.\nfd00::1 web db\n")
+
+	records, err := hosts.NewWatcher(path, nil, hosts.WithDomain("lab.example.")).Load()
+	assert.NoError(err)
+	assert.Equal(map[string]vinyl.Record{
+		"web.lab.example.": {Domain: "web.lab.example.", Address: "10.0.0.1", TTL: hosts.DefaultTTL},
+		"nas.other.":       {Domain: "nas.other.", Address: "10.0.0.1", TTL: hosts.DefaultTTL},
+		"db.lab.example.":  {Domain: "db.lab.example.", Address: "fd00::1", TTL: hosts.DefaultTTL},
+	}, records)
+
+	records, err = hosts.NewWatcher(filepath.Join(t.TempDir(), "missing"), nil).Load()
+	assert.NoError(err)
+	assert.Empty(records, "a missing file should have no records")
+}
+
+func TestWatcher_Run(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "hosts")
+	writeFile(t, path, "10.0.0.1 web.lab\n")
+
+	mem := store.NewMemory(vinyl.Record{Domain: "api.lab.", Address: "10.0.0.9", TTL: 60})
+	watcher := hosts.NewWatcher(path, mem, hosts.WithDebounce(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watcher.Run(ctx)
+	}()
+
+	hasAddress := func(domain string, want string) func() bool {
+		return func() bool {
+			record, err := mem.GetRecord(domain)
+			if err != nil {
+				return want == ""
+			}
+
+			return record.Address == want
+		}
+	}
+
+	assert.Eventually(hasAddress("web.lab.", "10.0.0.1"), time.Second, 10*time.Millisecond, "the file should be loaded")
+
+	// replace the file the way editors do
+	writeFile(t, path+".tmp", "10.0.0.2 db.lab\n")
+	err := os.Rename(path+".tmp", path)
+	assert.NoError(err)
+
+	assert.Eventually(hasAddress("db.lab.", "10.0.0.2"), time.Second, 10*time.Millisecond, "new names should be registered")
+	assert.Eventually(hasAddress("web.lab.", ""), time.Second, 10*time.Millisecond, "removed names should be removed")
+	assert.True(hasAddress("api.lab.", "10.0.0.9")(), "api records should never be touched")
+
+	cancel()
+	assert.NoError(<-done)
+}
+
+func TestExporter_Run(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "hosts")
+
+	mem := store.NewMemory(vinyl.Record{Domain: "web.lab.", Address: "10.0.0.1", TTL: 60})
+	exporter := hosts.NewExporter(path, mem)
+	exporter.Debounce = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- exporter.Run(ctx)
+	}()
+
+	contains := func(line string) func() bool {
+		return func() bool {
+			data, _ := os.ReadFile(path)
+			return strings.Contains(string(data), line)
+		}
+	}
+
+	assert.Eventually(contains("10.0.0.1\tweb.lab\n"), time.Second, 10*time.Millisecond, "the store should be exported")
+
+	_, err := mem.CreateRecord("db.lab.", "10.0.0.2", 60)
+	assert.NoError(err)
+	assert.Eventually(contains("10.0.0.2\tdb.lab\n"), time.Second, 10*time.Millisecond, "changes should be exported")
+
+	cancel()
+	assert.NoError(<-done)
+}
@@ -0,0 +1,266 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// ObjectLabel holds the kind, namespace and name of the object a record was created for
+	ObjectLabel = "kubernetes.object"
+	// Owner is the owner of every record created by the watcher
+	Owner = "kubernetes"
+
+	DefaultDomain = "cluster.local."
+	DefaultTTL    = 30
+	DefaultResync = 10 * time.Minute
+)
+
+type RecordStorer interface {
+	CreateRecord(string, string, uint32, ...vinyl.RecordOption) (*vinyl.Record, error)
+	RemoveOwnedRecord(string, string) (*vinyl.Record, error)
+}
+
+// Watcher mirrors services, headless service endpoints and optionally pods into the store
+type Watcher struct {
+	Client kubernetes.Interface
+	Store  RecordStorer
+	Domain string
+	TTL    uint32
+	Resync time.Duration
+	Pods   bool
+	// objects holds the domain and address of every record created for an object key
+	objects map[string]map[string]string
+	mutex   sync.Mutex
+}
+
+// WatcherOption sets the optional fields of a Watcher
+type WatcherOption func(*Watcher)
+
+func WithDomain(domain string) WatcherOption {
+	return func(watcher *Watcher) {
+		watcher.Domain = domain
+	}
+}
+
+func WithTTL(ttl uint32) WatcherOption {
+	return func(watcher *Watcher) {
+		watcher.TTL = ttl
+	}
+}
+
+func WithResync(resync time.Duration) WatcherOption {
+	return func(watcher *Watcher) {
+		watcher.Resync = resync
+	}
+}
+
+func WithPods(pods bool) WatcherOption {
+	return func(watcher *Watcher) {
+		watcher.Pods = pods
+	}
+}
+
+// NewClient connects using a kubeconfig file, or the in cluster service account when kubeconfig is empty
+func NewClient(kubeconfig string) (kubernetes.Interface, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("NewClient: %w", err)
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("NewClient: %w", err)
+	}
+
+	return client, nil
+}
+
+func NewWatcher(client kubernetes.Interface, store RecordStorer, options ...WatcherOption) *Watcher {
+	watcher := &Watcher{
+		Client:  client,
+		Store:   store,
+		Domain:  DefaultDomain,
+		TTL:     DefaultTTL,
+		Resync:  DefaultResync,
+		objects: map[string]map[string]string{},
+	}
+
+	for _, option := range options {
+		option(watcher)
+	}
+
+	return watcher
+}
+
+// Run lists and watches the cluster until ctx is done
+func (watcher *Watcher) Run(ctx context.Context) error {
+	factory := informers.NewSharedInformerFactory(watcher.Client, watcher.Resync)
+
+	watcher.handle(factory.Core().V1().Services().Informer(), "service", func(obj interface{}) map[string]string {
+		return watcher.ServiceRecords(obj.(*corev1.Service))
+	})
+	watcher.handle(factory.Core().V1().Endpoints().Informer(), "endpoints", func(obj interface{}) map[string]string {
+		return watcher.EndpointsRecords(obj.(*corev1.Endpoints))
+	})
+	if watcher.Pods {
+		watcher.handle(factory.Core().V1().Pods().Informer(), "pod", func(obj interface{}) map[string]string {
+			return watcher.PodRecords(obj.(*corev1.Pod))
+		})
+	}
+
+	factory.Start(ctx.Done())
+
+	for informer, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced && ctx.Err() == nil {
+			return fmt.Errorf("Run: %v cache never synced", informer)
+		}
+	}
+
+	<-ctx.Done()
+
+	return nil
+}
+
+func (watcher *Watcher) handle(informer cache.SharedIndexInformer, kind string, records func(interface{}) map[string]string) {
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			watcher.Reconcile(objectKey(kind, obj), records(obj))
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			watcher.Reconcile(objectKey(kind, obj), records(obj))
+		},
+		DeleteFunc: func(obj interface{}) {
+			watcher.Reconcile(objectKey(kind, obj), map[string]string{})
+		},
+	})
+}
+
+// ServiceRecords maps a service with a cluster ip to <service>.<namespace>.svc.<domain>
+func (watcher *Watcher) ServiceRecords(service *corev1.Service) map[string]string {
+	records := map[string]string{}
+
+	ip := service.Spec.ClusterIP
+	if ip == "" || ip == corev1.ClusterIPNone {
+		return records
+	}
+
+	records[watcher.name(service.Name, service.Namespace, "svc")] = ip
+
+	return records
+}
+
+// EndpointsRecords maps the ready addresses of headless services to <hostname>.<service>.<namespace>.svc.<domain>,
+// using the dashed ip when an address has no hostname
+func (watcher *Watcher) EndpointsRecords(endpoints *corev1.Endpoints) map[string]string {
+	records := map[string]string{}
+
+	if _, headless := endpoints.Labels[corev1.IsHeadlessService]; !headless {
+		return records
+	}
+
+	for _, subset := range endpoints.Subsets {
+		for _, address := range subset.Addresses {
+			host := address.Hostname
+			if host == "" {
+				host = dashed(address.IP)
+			}
+
+			records[watcher.name(host+"."+endpoints.Name, endpoints.Namespace, "svc")] = address.IP
+		}
+	}
+
+	return records
+}
+
+// PodRecords maps a running pod to <dashed-ip>.<namespace>.pod.<domain>
+func (watcher *Watcher) PodRecords(pod *corev1.Pod) map[string]string {
+	records := map[string]string{}
+
+	if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+		return records
+	}
+
+	records[watcher.name(dashed(pod.Status.PodIP), pod.Namespace, "pod")] = pod.Status.PodIP
+
+	return records
+}
+
+// Reconcile makes the records of an object match desired, a map of domain to address
+func (watcher *Watcher) Reconcile(key string, desired map[string]string) {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+
+	current := watcher.objects[key]
+
+	for domain, address := range current {
+		if desired[domain] == address {
+			continue
+		}
+
+		_, err := watcher.Store.RemoveOwnedRecord(domain, Owner)
+		if err != nil {
+			log.Printf("kubernetes watcher: could not remove %s: %s", domain, err)
+		}
+
+		delete(current, domain)
+	}
+
+	if current == nil {
+		current = map[string]string{}
+	}
+
+	labels := map[string]string{
+		ObjectLabel: key,
+	}
+
+	for domain, address := range desired {
+		if _, exist := current[domain]; exist {
+			continue
+		}
+
+		_, err := watcher.Store.CreateRecord(domain, address, watcher.TTL, vinyl.WithLabels(labels), vinyl.WithOwner(Owner))
+		if err != nil {
+			log.Printf("kubernetes watcher: could not register %s: %s", domain, err)
+			continue
+		}
+
+		current[domain] = address
+	}
+
+	if len(current) == 0 {
+		delete(watcher.objects, key)
+		return
+	}
+
+	watcher.objects[key] = current
+}
+
+func (watcher *Watcher) name(name string, namespace string, kind string) string {
+	return strings.ToLower(fmt.Sprintf("%s.%s.%s.%s.", name, namespace, kind, strings.Trim(watcher.Domain, ".")))
+}
+
+func objectKey(kind string, obj interface{}) string {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		log.Printf("kubernetes watcher: %s", err)
+	}
+
+	return kind + "/" + key
+}
+
+// dashed turns an ip into a single dns label the way kubernetes dns does
+func dashed(ip string) string {
+	return strings.NewReplacer(".", "-", ":", "-").Replace(ip)
+}
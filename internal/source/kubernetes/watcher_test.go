@@ -0,0 +1,156 @@
+package kubernetes_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/platform-edn/vinyl/internal/source/kubernetes"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWatcher_Records(t *testing.T) {
+	watcher := kubernetes.NewWatcher(nil, nil, kubernetes.WithDomain("cluster.lab"))
+
+	tests := map[string]struct {
+		Records func() map[string]string
+		Want    map[string]string
+	}{
+		"maps cluster ip services": {
+			Records: func() map[string]string {
+				return watcher.ServiceRecords(&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "shop"},
+					Spec:       corev1.ServiceSpec{ClusterIP: "10.96.0.10"},
+				})
+			},
+			Want: map[string]string{"orders.shop.svc.cluster.lab.": "10.96.0.10"},
+		},
+		"skips headless services": {
+			Records: func() map[string]string {
+				return watcher.ServiceRecords(&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "shop"},
+					Spec:       corev1.ServiceSpec{ClusterIP: corev1.ClusterIPNone},
+				})
+			},
+			Want: map[string]string{},
+		},
+		"maps headless endpoints": {
+			Records: func() map[string]string {
+				return watcher.EndpointsRecords(&corev1.Endpoints{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "db",
+						Namespace: "shop",
+						Labels:    map[string]string{corev1.IsHeadlessService: ""},
+					},
+					Subsets: []corev1.EndpointSubset{{
+						Addresses: []corev1.EndpointAddress{
+							{IP: "10.244.0.5", Hostname: "db-0"},
+							{IP: "10.244.0.6"},
+						},
+					}},
+				})
+			},
+			Want: map[string]string{
+				"db-0.db.shop.svc.cluster.lab.":       "10.244.0.5",
+				"10-244-0-6.db.shop.svc.cluster.lab.": "10.244.0.6",
+			},
+		},
+		"skips endpoints of cluster ip services": {
+			Records: func() map[string]string {
+				return watcher.EndpointsRecords(&corev1.Endpoints{
+					ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "shop"},
+					Subsets: []corev1.EndpointSubset{{
+						Addresses: []corev1.EndpointAddress{{IP: "10.244.0.7"}},
+					}},
+				})
+			},
+			Want: map[string]string{},
+		},
+		"maps running pods": {
+			Records: func() map[string]string {
+				return watcher.PodRecords(&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+					Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.244.0.8"},
+				})
+			},
+			Want: map[string]string{"10-244-0-8.shop.pod.cluster.lab.": "10.244.0.8"},
+		},
+		"skips pending pods": {
+			Records: func() map[string]string {
+				return watcher.PodRecords(&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+					Status:     corev1.PodStatus{Phase: corev1.PodPending},
+				})
+			},
+			Want: map[string]string{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			assert.Equal(test.Want, test.Records())
+		})
+	}
+}
+
+func TestWatcher_Reconcile(t *testing.T) {
+	assert := assert.New(t)
+	mem := store.NewMemory()
+	watcher := kubernetes.NewWatcher(nil, mem)
+
+	watcher.Reconcile("service/shop/orders", map[string]string{"orders.shop.svc.cluster.local.": "10.96.0.10"})
+	watcher.Reconcile("service/shop/orders", map[string]string{"orders.shop.svc.cluster.local.": "10.96.0.11"})
+
+	record, err := mem.GetRecord("orders.shop.svc.cluster.local.")
+	assert.NoError(err)
+	assert.Equal("10.96.0.11", record.Address, "changed addresses should replace the record")
+	assert.Equal("service/shop/orders", record.Labels[kubernetes.ObjectLabel])
+
+	watcher.Reconcile("service/shop/orders", map[string]string{})
+	assert.Empty(mem.Records, "records should be removed with their object")
+}
+
+func TestWatcher_Run(t *testing.T) {
+	assert := assert.New(t)
+	client := fake.NewSimpleClientset(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "shop"},
+		Spec:       corev1.ServiceSpec{ClusterIP: "10.96.0.10"},
+	})
+	mem := store.NewMemory()
+	watcher := kubernetes.NewWatcher(client, mem, kubernetes.WithPods(true), kubernetes.WithResync(time.Second))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watcher.Run(ctx)
+	}()
+
+	hasRecord := func(domain string) func() bool {
+		return func() bool {
+			_, err := mem.GetRecord(domain)
+			return err == nil
+		}
+	}
+
+	assert.Eventually(hasRecord("orders.shop.svc.cluster.local."), 5*time.Second, 10*time.Millisecond, "listed services should be registered")
+
+	_, err := client.CoreV1().Pods("shop").Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.244.0.8"},
+	}, metav1.CreateOptions{})
+	assert.NoError(err)
+	assert.Eventually(hasRecord("10-244-0-8.shop.pod.cluster.local."), 5*time.Second, 10*time.Millisecond, "watched pods should be registered")
+
+	err = client.CoreV1().Services("shop").Delete(ctx, "orders", metav1.DeleteOptions{})
+	assert.NoError(err)
+	assert.Eventually(func() bool { return !hasRecord("orders.shop.svc.cluster.local.")() }, 5*time.Second, 10*time.Millisecond, "deleted services should be removed")
+
+	cancel()
+	assert.NoError(<-done)
+}
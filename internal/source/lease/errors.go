@@ -0,0 +1,11 @@
+package lease
+
+import "fmt"
+
+type UnsupportedFormatError struct {
+	Format string
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("lease file format %s is not supported", e.Format)
+}
@@ -0,0 +1,208 @@
+package lease
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatDnsmasq = "dnsmasq"
+	FormatISC     = "isc"
+)
+
+// Lease is an address handed out to a host. A zero Expires means the lease never ends and a zero Starts means the
+// file doesn't say when it was handed out
+type Lease struct {
+	Hostname string
+	Address  string
+	Starts   time.Time
+	Expires  time.Time
+}
+
+// Expired reports whether the lease has ended by now
+func (lease Lease) Expired(now time.Time) bool {
+	return !lease.Expires.IsZero() && !lease.Expires.After(now)
+}
+
+// ParseLeases reads a lease file in format, either FormatDnsmasq or FormatISC
+func ParseLeases(r io.Reader, format string) ([]Lease, error) {
+	switch format {
+	case FormatDnsmasq:
+		return ParseDnsmasq(r)
+	case FormatISC:
+		return ParseISC(r)
+	}
+
+	return nil, fmt.Errorf("ParseLeases: %w", &UnsupportedFormatError{
+		Format: format,
+	})
+}
+
+// ParseDnsmasq reads a dnsmasq lease file, where every line is `<expiry> <mac or iaid> <address> <hostname> <client id>`
+// and ipv6 leases follow a `duid` line. Leases without a hostname are skipped
+func ParseDnsmasq(r io.Reader) ([]Lease, error) {
+	leases := []Lease{}
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[0] == "duid" {
+			continue
+		}
+
+		expiry, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		ip := net.ParseIP(fields[2])
+		if ip == nil || fields[3] == "*" {
+			continue
+		}
+
+		lease := Lease{
+			Hostname: fields[3],
+			Address:  ip.String(),
+		}
+		if expiry != 0 {
+			lease.Expires = time.Unix(expiry, 0)
+		}
+
+		leases = append(leases, lease)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("ParseDnsmasq: %w", err)
+	}
+
+	return leases, nil
+}
+
+// ParseISC reads an ISC dhcpd lease file. The file is a journal so only the last block for an address counts, and
+// only active bindings with a client hostname are returned
+func ParseISC(r io.Reader) ([]Lease, error) {
+	addresses := []string{}
+	blocks := map[string]iscBlock{}
+	scanner := bufio.NewScanner(r)
+
+	var current *iscBlock
+	line := 0
+	for scanner.Scan() {
+		line++
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		if current == nil {
+			fields := strings.Fields(text)
+			if len(fields) == 3 && fields[0] == "lease" && fields[2] == "{" {
+				current = &iscBlock{address: fields[1]}
+			}
+
+			continue
+		}
+
+		if text == "}" {
+			if _, exist := blocks[current.address]; !exist {
+				addresses = append(addresses, current.address)
+			}
+			blocks[current.address] = *current
+			current = nil
+
+			continue
+		}
+
+		statement := strings.Fields(strings.TrimSuffix(text, ";"))
+		if len(statement) < 2 {
+			continue
+		}
+
+		switch statement[0] {
+		case "binding":
+			if statement[1] == "state" && len(statement) == 3 {
+				current.state = statement[2]
+			}
+		case "client-hostname":
+			current.hostname = strings.Trim(statement[1], `"`)
+		case "starts":
+			starts, err := parseISCTime(statement[1:])
+			if err != nil {
+				return nil, fmt.Errorf("ParseISC: line %v: %w", line, err)
+			}
+
+			current.starts = starts
+		case "ends":
+			expires, err := parseISCTime(statement[1:])
+			if err != nil {
+				return nil, fmt.Errorf("ParseISC: line %v: %w", line, err)
+			}
+
+			current.expires = expires
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("ParseISC: %w", err)
+	}
+
+	leases := []Lease{}
+	for _, address := range addresses {
+		block := blocks[address]
+
+		ip := net.ParseIP(block.address)
+		if ip == nil || block.state != "active" || block.hostname == "" {
+			continue
+		}
+
+		leases = append(leases, Lease{
+			Hostname: block.hostname,
+			Address:  ip.String(),
+			Starts:   block.starts,
+			Expires:  block.expires,
+		})
+	}
+
+	return leases, nil
+}
+
+type iscBlock struct {
+	address  string
+	hostname string
+	state    string
+	starts   time.Time
+	expires  time.Time
+}
+
+// parseISCTime reads the value of a starts or ends statement: `never`, `epoch <seconds>` or `<weekday> <yyyy/mm/dd> <hh:mm:ss>`
+// in utc
+func parseISCTime(fields []string) (time.Time, error) {
+	switch {
+	case fields[0] == "never":
+		return time.Time{}, nil
+	case fields[0] == "epoch" && len(fields) >= 2:
+		seconds, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parseISCTime: %w", err)
+		}
+
+		return time.Unix(seconds, 0), nil
+	case len(fields) >= 3:
+		expires, err := time.Parse("2006/01/02 15:04:05", fields[1]+" "+fields[2])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parseISCTime: %w", err)
+		}
+
+		return expires, nil
+	}
+
+	return time.Time{}, fmt.Errorf("parseISCTime: %s is not a lease time", strings.Join(fields, " "))
+}
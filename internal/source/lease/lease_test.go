@@ -0,0 +1,83 @@
+package lease_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/platform-edn/vinyl/internal/source/lease"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLeases(t *testing.T) {
+	tests := map[string]struct {
+		Format string
+		Leases string
+		Want   []lease.Lease
+		Err    error
+	}{
+		"reads dnsmasq leases": {
+			Format: lease.FormatDnsmasq,
+			Leases: "1704146400 aa:bb:cc:dd:ee:01 10.0.0.5 nas 01:aa:bb:cc:dd:ee:01\n" +
+				"0 aa:bb:cc:dd:ee:02 10.0.0.6 printer *\n" +
+				"1704146400 aa:bb:cc:dd:ee:03 10.0.0.7 * *\n" +
+				"duid 00:01:00:01:2b:00:00:00:aa:bb:cc:dd:ee:ff\n" +
+				"1704146400 1234 fd00::5 nas 00:01:00:01\n",
+			Want: []lease.Lease{
+				{Hostname: "nas", Address: "10.0.0.5", Expires: time.Unix(1704146400, 0)},
+				{Hostname: "printer", Address: "10.0.0.6"},
+				{Hostname: "nas", Address: "fd00::5", Expires: time.Unix(1704146400, 0)},
+			},
+		},
+		"reads the last isc block for an address": {
+			Format: lease.FormatISC,
+			Leases: `# The format of this file is documented in the dhcpd.leases(5) manual page.
+lease 10.0.0.5 {
+  starts 1 2024/01/01 10:00:00;
+  ends 1 2024/01/01 12:00:00;
+  binding state active;
+  client-hostname "nas";
+}
+lease 10.0.0.6 {
+  ends never;
+  binding state active;
+  client-hostname "printer";
+}
+lease 10.0.0.7 {
+  ends epoch 1704146400; # Mon Jan 01 22:00:00 2024
+  binding state free;
+  client-hostname "old";
+}
+lease 10.0.0.5 {
+  starts 1 2024/01/01 12:00:00;
+  ends 1 2024/01/01 22:00:00;
+  binding state active;
+  client-hostname "nas";
+}
+`,
+			Want: []lease.Lease{
+				{Hostname: "nas", Address: "10.0.0.5", Starts: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), Expires: time.Date(2024, 1, 1, 22, 0, 0, 0, time.UTC)},
+				{Hostname: "printer", Address: "10.0.0.6"},
+			},
+		},
+		"returns UnsupportedFormatError for unknown formats": {
+			Format: "kea",
+			Err:    &lease.UnsupportedFormatError{Format: "kea"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			leases, err := lease.ParseLeases(strings.NewReader(test.Leases), test.Format)
+			if test.Err != nil {
+				assert.ErrorContains(err, test.Err.Error())
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(test.Want, leases)
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package lease
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/source"
+)
+
+const (
+	// OwnerPrefix is joined with the file path to form the owner of the records loaded from it
+	OwnerPrefix = "lease:"
+
+	DefaultDomain   = "lan."
+	DefaultMaxTTL   = 300
+	DefaultDebounce = 250 * time.Millisecond
+)
+
+// Watcher registers a record for every active lease in a dhcp lease file and removes it when the lease ends
+type Watcher struct {
+	Path     string
+	Format   string
+	Store    source.RecordStorer
+	Domain   string
+	MaxTTL   uint32
+	Debounce time.Duration
+	// Now returns the time leases are compared against
+	Now func() time.Time
+	// expiry resyncs the store when the next lease ends
+	expiry  *time.Timer
+	stopped bool
+	mutex   sync.Mutex
+}
+
+// WatcherOption sets the optional fields of a Watcher
+type WatcherOption func(*Watcher)
+
+func WithDomain(domain string) WatcherOption {
+	return func(watcher *Watcher) {
+		watcher.Domain = domain
+	}
+}
+
+// WithMaxTTL sets the ttl of records, lowered to the time left on leases ending sooner
+func WithMaxTTL(ttl uint32) WatcherOption {
+	return func(watcher *Watcher) {
+		watcher.MaxTTL = ttl
+	}
+}
+
+// WithDebounce sets how long the file has to be quiet after a change before the store is synced
+func WithDebounce(debounce time.Duration) WatcherOption {
+	return func(watcher *Watcher) {
+		watcher.Debounce = debounce
+	}
+}
+
+func NewWatcher(path string, format string, store source.RecordStorer, options ...WatcherOption) *Watcher {
+	watcher := &Watcher{
+		Path:     filepath.Clean(path),
+		Format:   format,
+		Store:    store,
+		Domain:   DefaultDomain,
+		MaxTTL:   DefaultMaxTTL,
+		Debounce: DefaultDebounce,
+		Now:      time.Now,
+	}
+
+	for _, option := range options {
+		option(watcher)
+	}
+
+	return watcher
+}
+
+// Owner is the owner of every record created by the watcher
+func (watcher *Watcher) Owner() string {
+	return OwnerPrefix + watcher.Path
+}
+
+// Run syncs the store whenever the lease file changes or a lease ends until ctx is done
+func (watcher *Watcher) Run(ctx context.Context) error {
+	defer watcher.stop()
+
+	match := func(name string) bool {
+		return filepath.Clean(name) == watcher.Path
+	}
+
+	err := source.WatchFiles(ctx, filepath.Dir(watcher.Path), match, watcher.Debounce, watcher.sync)
+	if err != nil {
+		return fmt.Errorf("Run: %w", err)
+	}
+
+	return nil
+}
+
+// Sync reconciles the store with the active leases and schedules the next sync for when the earliest of them ends
+func (watcher *Watcher) Sync() error {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+
+	leases, err := watcher.Load()
+	if err != nil {
+		return fmt.Errorf("Sync: %w", err)
+	}
+
+	now := watcher.Now()
+	desired := map[string]vinyl.Record{}
+	expires := map[string]time.Time{}
+	next := time.Time{}
+
+	for _, lease := range leases {
+		if lease.Expired(now) {
+			continue
+		}
+
+		domain := watcher.name(lease.Hostname)
+
+		// a host can hold several leases, the one lasting longest is the most recent
+		if current, exist := expires[domain]; exist && !outlasts(lease.Expires, current) {
+			continue
+		}
+
+		desired[domain] = vinyl.Record{
+			Domain:  domain,
+			Address: lease.Address,
+			TTL:     watcher.ttl(lease, now),
+		}
+		expires[domain] = lease.Expires
+	}
+
+	for _, expiry := range expires {
+		if !expiry.IsZero() && (next.IsZero() || expiry.Before(next)) {
+			next = expiry
+		}
+	}
+
+	err = source.Reconcile(watcher.Store, watcher.Owner(), desired)
+	if err != nil {
+		return fmt.Errorf("Sync: %w", err)
+	}
+
+	if watcher.expiry != nil {
+		watcher.expiry.Stop()
+		watcher.expiry = nil
+	}
+
+	if !next.IsZero() && !watcher.stopped {
+		watcher.expiry = time.AfterFunc(next.Sub(now), watcher.sync)
+	}
+
+	return nil
+}
+
+// Load reads every lease in the file. A missing file has no leases
+func (watcher *Watcher) Load() ([]Lease, error) {
+	f, err := os.Open(watcher.Path)
+	if os.IsNotExist(err) {
+		return []Lease{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Load: %w", err)
+	}
+	defer f.Close()
+
+	leases, err := ParseLeases(f, watcher.Format)
+	if err != nil {
+		return nil, fmt.Errorf("Load: %w", err)
+	}
+
+	return leases, nil
+}
+
+func (watcher *Watcher) sync() {
+	err := watcher.Sync()
+	if err != nil {
+		log.Printf("lease watcher: %s", err)
+	}
+}
+
+func (watcher *Watcher) stop() {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+
+	watcher.stopped = true
+	if watcher.expiry != nil {
+		watcher.expiry.Stop()
+		watcher.expiry = nil
+	}
+}
+
+// ttl is the time left on the lease when the record is written, capped at MaxTTL, so caches don't keep the record
+// past the end of the lease. Leases that never end get MaxTTL
+func (watcher *Watcher) ttl(lease Lease, now time.Time) uint32 {
+	left := lease.Expires.Sub(now)
+	if lease.Expires.IsZero() || left >= time.Duration(watcher.MaxTTL)*time.Second {
+		return watcher.MaxTTL
+	}
+
+	return uint32(math.Ceil(left.Seconds()))
+}
+
+// outlasts reports whether a lease ending at a ends after one ending at b, where a zero time never ends
+func outlasts(a time.Time, b time.Time) bool {
+	if a.IsZero() {
+		return !b.IsZero()
+	}
+
+	return !b.IsZero() && a.After(b)
+}
+
+func (watcher *Watcher) name(hostname string) string {
+	return strings.ToLower(fmt.Sprintf("%s.%s.", hostname, strings.Trim(watcher.Domain, ".")))
+}
@@ -0,0 +1,152 @@
+package lease_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/source/lease"
+	"github.com/platform-edn/vinyl/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcher_Sync(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Unix(1704146400, 0)
+	path := filepath.Join(t.TempDir(), "dnsmasq.leases")
+	writeFile(t, path, fmt.Sprintf("%v aa:01 10.0.0.5 nas *\n%v aa:02 10.0.0.6 tv *\n%v aa:03 10.0.0.7 old *\n%v aa:01 10.0.0.8 nas *\n0 aa:04 10.0.0.9 printer *\n",
+		now.Add(time.Minute).Unix(), now.Add(time.Hour).Unix(), now.Add(-time.Minute).Unix(), now.Add(30*time.Second).Unix()))
+
+	mem := store.NewMemory(vinyl.Record{Domain: "api.lab.", Address: "10.0.0.1", TTL: 60})
+	watcher := lease.NewWatcher(path, lease.FormatDnsmasq, mem, lease.WithDomain("lab"))
+	watcher.Now = func() time.Time { return now }
+
+	err := watcher.Sync()
+	assert.NoError(err)
+
+	records, _, err := mem.ListRecords(vinyl.RecordFilter{Owner: watcher.Owner()}, vinyl.Page{})
+	assert.NoError(err)
+	assert.Equal([]vinyl.Record{
+		{Domain: "nas.lab.", Address: "10.0.0.5", TTL: 60, Owner: watcher.Owner()},
+		{Domain: "printer.lab.", Address: "10.0.0.9", TTL: lease.DefaultMaxTTL, Owner: watcher.Owner()},
+		{Domain: "tv.lab.", Address: "10.0.0.6", TTL: lease.DefaultMaxTTL, Owner: watcher.Owner()},
+	}, records, "active leases should be registered")
+
+	watcher.Now = func() time.Time { return now.Add(2 * time.Minute) }
+	err = watcher.Sync()
+	assert.NoError(err)
+
+	_, err = mem.GetRecord("nas.lab.")
+	assert.Error(err, "ended leases should be removed")
+	assert.Len(mem.Records, 3)
+}
+
+func TestWatcher_SyncTTL(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC)
+
+	tests := map[string]struct {
+		Format string
+		Leases string
+	}{
+		"dnsmasq": {
+			Format: lease.FormatDnsmasq,
+			Leases: fmt.Sprintf("%v aa:01 10.0.0.5 nas *\n%v aa:02 10.0.0.6 tv *\n0 aa:03 10.0.0.7 printer *\n",
+				now.Add(90*time.Second).Unix(), now.Add(10*time.Hour).Unix()),
+		},
+		"isc": {
+			Format: lease.FormatISC,
+			Leases: `lease 10.0.0.5 {
+  starts 1 2024/01/01 12:00:00;
+  ends 1 2024/01/01 12:02:00;
+  binding state active;
+  client-hostname "nas";
+}
+lease 10.0.0.6 {
+  starts 1 2024/01/01 12:00:00;
+  ends 1 2024/01/01 22:00:00;
+  binding state active;
+  client-hostname "tv";
+}
+lease 10.0.0.7 {
+  starts 1 2024/01/01 12:00:00;
+  ends never;
+  binding state active;
+  client-hostname "printer";
+}
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			path := filepath.Join(t.TempDir(), "leases")
+			writeFile(t, path, test.Leases)
+
+			mem := store.NewMemory()
+			watcher := lease.NewWatcher(path, test.Format, mem)
+			watcher.Now = func() time.Time { return now }
+
+			err := watcher.Sync()
+			assert.NoError(err)
+
+			records, _, err := mem.ListRecords(vinyl.RecordFilter{Owner: watcher.Owner()}, vinyl.Page{})
+			assert.NoError(err)
+			assert.Equal([]vinyl.Record{
+				{Domain: "nas.lan.", Address: "10.0.0.5", TTL: 90, Owner: watcher.Owner()},
+				{Domain: "printer.lan.", Address: "10.0.0.7", TTL: lease.DefaultMaxTTL, Owner: watcher.Owner()},
+				{Domain: "tv.lan.", Address: "10.0.0.6", TTL: lease.DefaultMaxTTL, Owner: watcher.Owner()},
+			}, records, "the ttl should be lowered to the time left on leases ending sooner than the max ttl")
+
+			watcher.Now = func() time.Time { return now.Add(time.Minute) }
+			err = watcher.Sync()
+			assert.NoError(err)
+
+			record, err := mem.GetRecord("nas.lan.")
+			assert.NoError(err)
+			assert.Equal(uint32(30), record.TTL, "the ttl should count down with the lease when the record is written again")
+		})
+	}
+}
+
+func TestWatcher_Run(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "dnsmasq.leases")
+
+	mem := store.NewMemory()
+	watcher := lease.NewWatcher(path, lease.FormatDnsmasq, mem, lease.WithDebounce(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watcher.Run(ctx)
+	}()
+
+	hasRecord := func(domain string) func() bool {
+		return func() bool {
+			_, err := mem.GetRecord(domain)
+			return err == nil
+		}
+	}
+
+	// give the watcher a moment to start watching the directory before the file shows up
+	time.Sleep(50 * time.Millisecond)
+	writeFile(t, path, fmt.Sprintf("%v aa:01 10.0.0.5 nas *\n", time.Now().Add(time.Second).Unix()))
+
+	assert.Eventually(hasRecord("nas.lan."), time.Second, 10*time.Millisecond, "new leases should be registered")
+	assert.Eventually(func() bool { return !hasRecord("nas.lan.")() }, 3*time.Second, 10*time.Millisecond, "records should be removed when the lease ends")
+
+	cancel()
+	assert.NoError(<-done)
+}
@@ -0,0 +1,129 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	vinyl "github.com/platform-edn/vinyl/internal"
+)
+
+type RecordStorer interface {
+	CreateRecord(string, string, uint32, ...vinyl.RecordOption) (*vinyl.Record, error)
+	UpdateRecord(string, string, uint32, ...vinyl.RecordOption) (*vinyl.Record, error)
+	RemoveOwnedRecord(string, string) (*vinyl.Record, error)
+	ListRecords(vinyl.RecordFilter, vinyl.Page) ([]vinyl.Record, string, error)
+}
+
+// Reconcile creates, updates and removes the records of owner so they match desired, a map of domain to record.
+// Records of any other owner are never touched and failures on single records are logged so the rest still apply
+func Reconcile(store RecordStorer, owner string, desired map[string]vinyl.Record) error {
+	current, _, err := store.ListRecords(vinyl.RecordFilter{Owner: owner}, vinyl.Page{})
+	if err != nil {
+		return fmt.Errorf("Reconcile: %w", err)
+	}
+
+	existing := map[string]struct{}{}
+	for _, record := range current {
+		existing[record.Domain] = struct{}{}
+
+		want, exist := desired[record.Domain]
+		if !exist {
+			_, err := store.RemoveOwnedRecord(record.Domain, owner)
+			if err != nil {
+				log.Printf("%s: could not remove %s: %s", owner, record.Domain, err)
+			}
+
+			continue
+		}
+
+		if !changed(record, want) {
+			continue
+		}
+
+		_, err := store.UpdateRecord(want.Domain, want.Address, want.TTL, vinyl.WithLabels(want.Labels), vinyl.WithOwner(owner))
+		if err != nil {
+			log.Printf("%s: could not update %s: %s", owner, want.Domain, err)
+		}
+	}
+
+	domains := []string{}
+	for domain := range desired {
+		if _, exist := existing[domain]; !exist {
+			domains = append(domains, domain)
+		}
+	}
+	sort.Strings(domains)
+
+	for _, domain := range domains {
+		want := desired[domain]
+
+		_, err := store.CreateRecord(want.Domain, want.Address, want.TTL, vinyl.WithLabels(want.Labels), vinyl.WithOwner(owner))
+		if err != nil {
+			log.Printf("%s: could not register %s: %s", owner, want.Domain, err)
+		}
+	}
+
+	return nil
+}
+
+// WatchFiles calls sync once and then again whenever a file in dir that match accepts changes, waiting for debounce
+// of quiet first so a burst of writes only syncs once. It returns when ctx is done
+func WatchFiles(ctx context.Context, dir string, match func(string) bool, debounce time.Duration, sync func()) error {
+	notify, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("WatchFiles: %w", err)
+	}
+	defer notify.Close()
+
+	// watch before the first sync so nothing written during it is missed. The directory is watched rather than the
+	// files because editors and config management replace files instead of writing to them
+	err = notify.Add(dir)
+	if err != nil {
+		return fmt.Errorf("WatchFiles: %w", err)
+	}
+
+	sync()
+
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-notify.Events:
+			if !ok {
+				return fmt.Errorf("WatchFiles: event stream closed")
+			}
+
+			if match(event.Name) {
+				timer = time.After(debounce)
+			}
+		case err, ok := <-notify.Errors:
+			if !ok {
+				return fmt.Errorf("WatchFiles: event stream closed")
+			}
+
+			log.Printf("watching %s: %s", dir, err)
+		case <-timer:
+			timer = nil
+			sync()
+		}
+	}
+}
+
+func changed(current vinyl.Record, desired vinyl.Record) bool {
+	if current.Address != desired.Address || current.TTL != desired.TTL || len(current.Labels) != len(desired.Labels) {
+		return true
+	}
+
+	for key, value := range desired.Labels {
+		if current.Labels[key] != value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -10,6 +10,14 @@ func (e *MissingRecordError) Error() string {
 	return fmt.Sprintf("domain %s is not implemented", e.Domain)
 }
 
+type MissingAddressError struct {
+	Address string
+}
+
+func (e *MissingAddressError) Error() string {
+	return fmt.Sprintf("no records point at address %s", e.Address)
+}
+
 type ExistingRecordError struct {
 	Domain string
 }
@@ -17,3 +25,50 @@ type ExistingRecordError struct {
 func (e *ExistingRecordError) Error() string {
 	return fmt.Sprintf("a record with the domain %s already exists", e.Domain)
 }
+
+type InvalidPageTokenError struct {
+	Token string
+}
+
+func (e *InvalidPageTokenError) Error() string {
+	return fmt.Sprintf("page token %s is not valid", e.Token)
+}
+
+type OwnerConflictError struct {
+	Domain string
+	Owner  string
+}
+
+func (e *OwnerConflictError) Error() string {
+	owner := e.Owner
+	if owner == "" {
+		owner = "the api"
+	}
+
+	return fmt.Sprintf("domain %s is owned by %s", e.Domain, owner)
+}
+
+type MissingViewError struct {
+	Name string
+}
+
+func (e *MissingViewError) Error() string {
+	return fmt.Sprintf("view %s does not exist", e.Name)
+}
+
+type AddressConflictError struct {
+	Domain  string
+	Address string
+}
+
+func (e *AddressConflictError) Error() string {
+	return fmt.Sprintf("domain %s points at %s", e.Domain, e.Address)
+}
+
+type InvalidLeaseError struct {
+	Lease uint32
+}
+
+func (e *InvalidLeaseError) Error() string {
+	return fmt.Sprintf("%v is not a valid lease", e.Lease)
+}
@@ -1,7 +1,13 @@
 package store
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
 
 	vinyl "github.com/platform-edn/vinyl/internal"
@@ -9,36 +15,90 @@ import (
 
 type RecordMap map[string]vinyl.Record
 
+// WatchBuffer is how many events a watcher can fall behind before it is dropped
+const WatchBuffer = 100
+
 type Memory struct {
 	Records RecordMap
-	mutex   sync.RWMutex
+	// domains is kept sorted so records can be listed in a stable order
+	domains []string
+	// addresses indexes the sorted domains pointing at each address for reverse lookups
+	addresses map[string][]string
+	// nodes counts the records at or below every name so empty non-terminals count as existing for wildcards
+	nodes    map[string]int
+	watchers map[chan vinyl.RecordEvent]struct{}
+	mutex    sync.RWMutex
 }
 
 func NewMemory(records ...vinyl.Record) *Memory {
 	rmap := RecordMap{}
+	domains := []string{}
 
 	for _, r := range records {
+		_, exist := rmap[r.Domain]
+		if !exist {
+			domains = append(domains, r.Domain)
+		}
+
 		rmap[r.Domain] = r
 	}
 
+	sort.Strings(domains)
+
+	addresses := map[string][]string{}
+	nodes := map[string]int{}
+	for _, domain := range domains {
+		address := normalizeAddress(rmap[domain].Address)
+		addresses[address] = append(addresses[address], domain)
+		countNodes(nodes, domain, 1)
+	}
+
 	return &Memory{
-		Records: rmap,
-		mutex:   sync.RWMutex{},
+		Records:   rmap,
+		domains:   domains,
+		addresses: addresses,
+		nodes:     nodes,
+		watchers:  map[chan vinyl.RecordEvent]struct{}{},
+		mutex:     sync.RWMutex{},
 	}
 }
 
+// GetRecord returns the record for domain. Without an exact match a wildcard at the closest existing ancestor
+// is used as described in RFC 4592, and the returned record is renamed to domain
 func (store *Memory) GetRecord(domain string) (*vinyl.Record, error) {
 	store.mutex.RLock()
 	defer store.mutex.RUnlock()
 
 	record, exist := store.Records[domain]
-	if !exist {
+	if exist {
+		return &record, nil
+	}
+
+	// an empty non-terminal exists, so wildcards above it don't answer for it
+	if store.nodes[domain] > 0 {
 		return nil, fmt.Errorf("GetRecord: %w", &MissingRecordError{
 			Domain: domain,
 		})
 	}
 
-	return &record, nil
+	for ancestor := parentName(domain); ancestor != ""; ancestor = parentName(ancestor) {
+		if store.nodes[ancestor] == 0 {
+			continue
+		}
+
+		// ancestor is the closest encloser so only its wildcard may answer
+		wildcard, exist := store.Records["*."+ancestor]
+		if !exist {
+			break
+		}
+
+		wildcard.Domain = domain
+		return &wildcard, nil
+	}
+
+	return nil, fmt.Errorf("GetRecord: %w", &MissingRecordError{
+		Domain: domain,
+	})
 }
 
 func (store *Memory) RemoveRecord(domain string) (*vinyl.Record, error) {
@@ -52,24 +112,127 @@ func (store *Memory) RemoveRecord(domain string) (*vinyl.Record, error) {
 		})
 	}
 
-	delete(store.Records, domain)
+	store.remove(record)
+
+	return &record, nil
+}
+
+// RemoveOwnedRecord removes a record only if it is managed by owner
+func (store *Memory) RemoveOwnedRecord(domain string, owner string) (*vinyl.Record, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	record, exist := store.Records[domain]
+	if !exist {
+		return nil, fmt.Errorf("RemoveOwnedRecord: %w", &MissingRecordError{
+			Domain: domain,
+		})
+	}
+
+	if record.Owner != owner {
+		return nil, fmt.Errorf("RemoveOwnedRecord: %w", &OwnerConflictError{
+			Domain: domain,
+			Owner:  record.Owner,
+		})
+	}
+
+	store.remove(record)
 
 	return &record, nil
 }
 
-func (store *Memory) ListRecords() ([]vinyl.Record, error) {
+// UpdateRecord replaces an existing record. The new record must have the same owner as the one it replaces
+func (store *Memory) UpdateRecord(domain string, address string, ttl uint32, options ...vinyl.RecordOption) (*vinyl.Record, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	existing, exist := store.Records[domain]
+	if !exist {
+		return nil, fmt.Errorf("UpdateRecord: %w", &MissingRecordError{
+			Domain: domain,
+		})
+	}
+
+	record, err := vinyl.NewRecord(domain, address, ttl, options...)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateRecord: %w", err)
+	}
+
+	if record.Owner != existing.Owner {
+		return nil, fmt.Errorf("UpdateRecord: %w", &OwnerConflictError{
+			Domain: domain,
+			Owner:  existing.Owner,
+		})
+	}
+
+	store.unindexAddress(existing)
+	store.Records[domain] = *record
+	store.indexAddress(*record)
+	store.publish(vinyl.RecordUpdated, *record)
+
+	return record, nil
+}
+
+// GetRecordsByAddress returns every record pointing at address ordered by domain
+func (store *Memory) GetRecordsByAddress(address string) ([]vinyl.Record, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	domains, exist := store.addresses[normalizeAddress(address)]
+	if !exist {
+		return nil, fmt.Errorf("GetRecordsByAddress: %w", &MissingAddressError{
+			Address: address,
+		})
+	}
+
+	records := []vinyl.Record{}
+	for _, domain := range domains {
+		records = append(records, store.Records[domain])
+	}
+
+	return records, nil
+}
+
+// ListRecords returns the records matching filter ordered by domain. When more records match than fit in the
+// page a token for the next page is returned as well
+func (store *Memory) ListRecords(filter vinyl.RecordFilter, page vinyl.Page) ([]vinyl.Record, string, error) {
 	store.mutex.RLock()
 	defer store.mutex.RUnlock()
 
+	start := 0
+	if page.Token != "" {
+		after, err := base64.RawURLEncoding.DecodeString(page.Token)
+		if err != nil {
+			return nil, "", fmt.Errorf("ListRecords: %w", &InvalidPageTokenError{
+				Token: page.Token,
+			})
+		}
+
+		start = sort.SearchStrings(store.domains, string(after))
+		if start < len(store.domains) && store.domains[start] == string(after) {
+			start++
+		}
+	}
+
 	records := []vinyl.Record{}
-	for _, record := range store.Records {
+	for _, domain := range store.domains[start:] {
+		record := store.Records[domain]
+		if !filter.Match(record) {
+			continue
+		}
+
+		if page.Size > 0 && len(records) == page.Size {
+			last := records[len(records)-1].Domain
+			return records, base64.RawURLEncoding.EncodeToString([]byte(last)), nil
+		}
+
 		records = append(records, record)
 	}
 
-	return records, nil
+	return records, "", nil
 }
 
-func (store *Memory) CreateRecord(domain string, address string, ttl uint32) (*vinyl.Record, error) {
+func (store *Memory) CreateRecord(domain string, address string, ttl uint32, options ...vinyl.RecordOption) (*vinyl.Record, error) {
 	store.mutex.Lock()
 	defer store.mutex.Unlock()
 
@@ -80,11 +243,199 @@ func (store *Memory) CreateRecord(domain string, address string, ttl uint32) (*v
 		})
 	}
 
-	record, err := vinyl.NewRecord(domain, address, ttl)
+	record, err := vinyl.NewRecord(domain, address, ttl, options...)
 	if err != nil {
 		return nil, fmt.Errorf("CreateRecord: %w", err)
 	}
 
 	store.Records[domain] = *record
+	store.domains = insertSorted(store.domains, domain)
+	store.indexAddress(*record)
+	countNodes(store.nodes, domain, 1)
+	store.publish(vinyl.RecordCreated, *record)
+
 	return record, nil
 }
+
+// PutRecord creates record or replaces the existing one whatever its owner, for changes that were already
+// accepted elsewhere such as by another node
+func (store *Memory) PutRecord(record vinyl.Record) (*vinyl.Record, error) {
+	err := vinyl.ValidateRecord(&record)
+	if err != nil {
+		return nil, fmt.Errorf("PutRecord: %w", err)
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	existing, exist := store.Records[record.Domain]
+	if !exist {
+		store.Records[record.Domain] = record
+		store.domains = insertSorted(store.domains, record.Domain)
+		store.indexAddress(record)
+		countNodes(store.nodes, record.Domain, 1)
+		store.publish(vinyl.RecordCreated, record)
+
+		return &record, nil
+	}
+
+	store.unindexAddress(existing)
+	store.Records[record.Domain] = record
+	store.indexAddress(record)
+	store.publish(vinyl.RecordUpdated, record)
+
+	return &record, nil
+}
+
+// Replace swaps every record in the store for records, such as when restoring a snapshot, and publishes the
+// differences so watchers stay in sync without relisting
+func (store *Memory) Replace(records ...vinyl.Record) {
+	replacement := NewMemory(records...)
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, domain := range store.domains {
+		_, exist := replacement.Records[domain]
+		if !exist {
+			store.publish(vinyl.RecordRemoved, store.Records[domain])
+		}
+	}
+
+	for _, domain := range replacement.domains {
+		record := replacement.Records[domain]
+
+		existing, exist := store.Records[domain]
+		switch {
+		case !exist:
+			store.publish(vinyl.RecordCreated, record)
+		case !reflect.DeepEqual(existing, record):
+			store.publish(vinyl.RecordUpdated, record)
+		}
+	}
+
+	store.Records = replacement.Records
+	store.domains = replacement.domains
+	store.addresses = replacement.addresses
+	store.nodes = replacement.nodes
+}
+
+// remove drops a record and its index entries and must be called while holding the write lock
+func (store *Memory) remove(record vinyl.Record) {
+	domain := record.Domain
+
+	delete(store.Records, domain)
+	store.domains = removeSorted(store.domains, domain)
+	store.unindexAddress(record)
+	countNodes(store.nodes, domain, -1)
+	store.publish(vinyl.RecordRemoved, record)
+}
+
+func (store *Memory) indexAddress(record vinyl.Record) {
+	address := normalizeAddress(record.Address)
+	store.addresses[address] = insertSorted(store.addresses[address], record.Domain)
+}
+
+func (store *Memory) unindexAddress(record vinyl.Record) {
+	address := normalizeAddress(record.Address)
+
+	store.addresses[address] = removeSorted(store.addresses[address], record.Domain)
+	if len(store.addresses[address]) == 0 {
+		delete(store.addresses, address)
+	}
+}
+
+// Watch returns a channel of record changes until ctx is done. The channel is closed early if the watcher falls
+// more than WatchBuffer events behind so it can relist instead of silently missing changes
+func (store *Memory) Watch(ctx context.Context) <-chan vinyl.RecordEvent {
+	events := make(chan vinyl.RecordEvent, WatchBuffer)
+
+	store.mutex.Lock()
+	store.watchers[events] = struct{}{}
+	store.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		store.mutex.Lock()
+		defer store.mutex.Unlock()
+
+		store.unwatch(events)
+	}()
+
+	return events
+}
+
+// publish sends an event to every watcher and must be called while holding the write lock
+func (store *Memory) publish(eventType vinyl.EventType, record vinyl.Record) {
+	event := vinyl.RecordEvent{
+		Type:   eventType,
+		Record: record,
+	}
+
+	for events := range store.watchers {
+		select {
+		case events <- event:
+		default:
+			store.unwatch(events)
+		}
+	}
+}
+
+func (store *Memory) unwatch(events chan vinyl.RecordEvent) {
+	_, exist := store.watchers[events]
+	if !exist {
+		return
+	}
+
+	delete(store.watchers, events)
+	close(events)
+}
+
+// countNodes adds delta to domain and every ancestor of it
+func countNodes(nodes map[string]int, domain string, delta int) {
+	for name := domain; name != ""; name = parentName(name) {
+		nodes[name] += delta
+		if nodes[name] <= 0 {
+			delete(nodes, name)
+		}
+	}
+}
+
+// parentName strips the first label off a domain, returning an empty string past the top level
+func parentName(domain string) string {
+	i := strings.Index(domain, ".")
+	if i < 0 {
+		return ""
+	}
+
+	return domain[i+1:]
+}
+
+// normalizeAddress makes equivalent ipv6 spellings share a reverse index entry
+func normalizeAddress(address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return address
+	}
+
+	return ip.String()
+}
+
+func insertSorted(values []string, value string) []string {
+	i := sort.SearchStrings(values, value)
+	values = append(values, "")
+	copy(values[i+1:], values[i:])
+	values[i] = value
+
+	return values
+}
+
+func removeSorted(values []string, value string) []string {
+	i := sort.SearchStrings(values, value)
+	if i == len(values) || values[i] != value {
+		return values
+	}
+
+	return append(values[:i], values[i+1:]...)
+}
@@ -1,6 +1,9 @@
 package store_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"testing"
 
 	vinyl "github.com/platform-edn/vinyl/internal"
@@ -153,7 +156,7 @@ func TestMemory_ListRecords(t *testing.T) {
 
 			mem := store.NewMemory(test.Records...)
 
-			records, err := mem.ListRecords()
+			records, _, err := mem.ListRecords(vinyl.RecordFilter{}, vinyl.Page{})
 			if test.Err != nil {
 				assert.ErrorContains(err, test.Err.Error())
 				return
@@ -242,3 +245,397 @@ func TestMemory_CreateRecord(t *testing.T) {
 		})
 	}
 }
+
+func TestMemory_Watch(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	mem := store.NewMemory()
+	events := mem.Watch(ctx)
+
+	created, err := mem.CreateRecord("test.com", "127.0.0.1", 60)
+	assert.NoError(err)
+
+	removed, err := mem.RemoveRecord("test.com")
+	assert.NoError(err)
+
+	assert.Equal(vinyl.RecordEvent{Type: vinyl.RecordCreated, Record: *created}, <-events)
+	assert.Equal(vinyl.RecordEvent{Type: vinyl.RecordRemoved, Record: *removed}, <-events)
+
+	cancel()
+
+	_, open := <-events
+	assert.False(open, "events should be closed when ctx is done")
+}
+
+func TestMemory_WatchSlowWatcher(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mem := store.NewMemory()
+	events := mem.Watch(ctx)
+
+	for i := 0; i <= store.WatchBuffer; i++ {
+		_, err := mem.CreateRecord(fmt.Sprintf("test%v.com", i), "127.0.0.1", 60)
+		assert.NoError(err)
+	}
+
+	count := 0
+	for range events {
+		count++
+	}
+
+	assert.Equal(store.WatchBuffer, count, "watcher should be closed once the buffer is full")
+}
+
+func TestMemory_ListRecordsPages(t *testing.T) {
+	assert := assert.New(t)
+	records := []vinyl.Record{}
+	for i := 9; i >= 0; i-- {
+		records = append(records, vinyl.Record{
+			Domain:  fmt.Sprintf("test%v.com", i),
+			Address: "127.0.0.1",
+			TTL:     60,
+		})
+	}
+
+	mem := store.NewMemory(records...)
+	domains := []string{}
+	page := vinyl.Page{Size: 3}
+	pages := 0
+
+	for {
+		list, next, err := mem.ListRecords(vinyl.RecordFilter{}, page)
+		assert.NoError(err)
+		assert.LessOrEqual(len(list), page.Size)
+
+		for _, r := range list {
+			domains = append(domains, r.Domain)
+		}
+
+		pages++
+		if next == "" {
+			break
+		}
+
+		page.Token = next
+	}
+
+	assert.Equal(4, pages, "10 records in pages of 3 should take 4 pages")
+	assert.IsIncreasing(domains, "records should be listed in domain order")
+	assert.Len(domains, 10)
+
+	_, _, err := mem.ListRecords(vinyl.RecordFilter{}, vinyl.Page{Token: "!!!"})
+	assert.ErrorContains(err, (&store.InvalidPageTokenError{Token: "!!!"}).Error())
+}
+
+func TestMemory_ListRecordsFilter(t *testing.T) {
+	records := []vinyl.Record{
+		{Domain: "api.svc.internal.", Address: "10.0.0.1", TTL: 60, Labels: map[string]string{"team": "edge"}},
+		{Domain: "db.svc.internal.", Address: "10.0.1.1", TTL: 60, Labels: map[string]string{"team": "data"}},
+		{Domain: "api.lab.internal.", Address: "fd00::1", TTL: 60},
+	}
+
+	tests := map[string]struct {
+		Suffix  string
+		Type    string
+		CIDR    string
+		Labels  map[string]string
+		Domains []string
+	}{
+		"filters by domain suffix": {
+			Suffix:  "svc.internal",
+			Domains: []string{"api.svc.internal.", "db.svc.internal."},
+		},
+		"filters by record type": {
+			Type:    "aaaa",
+			Domains: []string{"api.lab.internal."},
+		},
+		"filters by cidr": {
+			CIDR:    "10.0.1.0/24",
+			Domains: []string{"db.svc.internal."},
+		},
+		"filters by labels": {
+			Labels:  map[string]string{"team": "edge"},
+			Domains: []string{"api.svc.internal."},
+		},
+		"combines filters": {
+			Suffix:  "internal.",
+			Type:    "A",
+			Labels:  map[string]string{"team": "data"},
+			Domains: []string{"db.svc.internal."},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			mem := store.NewMemory(records...)
+
+			filter, err := vinyl.NewRecordFilter(test.Suffix, test.Type, test.CIDR, test.Labels)
+			assert.NoError(err)
+
+			list, _, err := mem.ListRecords(*filter, vinyl.Page{})
+			assert.NoError(err)
+
+			domains := []string{}
+			for _, r := range list {
+				domains = append(domains, r.Domain)
+			}
+
+			assert.ElementsMatch(test.Domains, domains)
+		})
+	}
+}
+
+func TestMemory_GetRecordsByAddress(t *testing.T) {
+	assert := assert.New(t)
+	mem := store.NewMemory(vinyl.Record{
+		Domain:  "b.test.com",
+		Address: "fd00::1",
+		TTL:     60,
+	})
+
+	_, err := mem.CreateRecord("a.test.com", "fd00:0:0::1", 60)
+	assert.NoError(err)
+
+	records, err := mem.GetRecordsByAddress("fd00:0000::0001")
+	assert.NoError(err)
+	assert.Len(records, 2, "equivalent ipv6 addresses should share an index entry")
+	assert.Equal("a.test.com", records[0].Domain, "records should be ordered by domain")
+
+	_, err = mem.RemoveRecord("a.test.com")
+	assert.NoError(err)
+	_, err = mem.RemoveRecord("b.test.com")
+	assert.NoError(err)
+
+	_, err = mem.GetRecordsByAddress("fd00::1")
+	assert.ErrorContains(err, (&store.MissingAddressError{Address: "fd00::1"}).Error())
+}
+
+func TestMemory_GetRecordWildcard(t *testing.T) {
+	records := []vinyl.Record{
+		{Domain: "*.preview.svc.internal.", Address: "10.0.0.1", TTL: 60},
+		{Domain: "main.preview.svc.internal.", Address: "10.0.0.2", TTL: 60},
+		{Domain: "db.team.preview.svc.internal.", Address: "10.0.0.3", TTL: 60},
+	}
+
+	tests := map[string]struct {
+		Domain  string
+		Address string
+		Err     bool
+	}{
+		"matches a wildcard": {
+			Domain:  "feature-123.preview.svc.internal.",
+			Address: "10.0.0.1",
+		},
+		"matches a wildcard several labels down": {
+			Domain:  "api.feature-123.preview.svc.internal.",
+			Address: "10.0.0.1",
+		},
+		"prefers explicit records over wildcards": {
+			Domain:  "main.preview.svc.internal.",
+			Address: "10.0.0.2",
+		},
+		"does not match below an existing name": {
+			Domain: "api.main.preview.svc.internal.",
+			Err:    true,
+		},
+		"does not match below an empty non-terminal": {
+			Domain: "web.team.preview.svc.internal.",
+			Err:    true,
+		},
+		"does not match an empty non-terminal": {
+			Domain: "team.preview.svc.internal.",
+			Err:    true,
+		},
+		"does not match the wildcard parent": {
+			Domain: "preview.svc.internal.",
+			Err:    true,
+		},
+		"does not match outside the wildcard": {
+			Domain: "api.svc.internal.",
+			Err:    true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			mem := store.NewMemory(records...)
+
+			record, err := mem.GetRecord(test.Domain)
+			if test.Err {
+				assert.ErrorContains(err, (&store.MissingRecordError{Domain: test.Domain}).Error())
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(test.Domain, record.Domain, "wildcard answers should be renamed to the query")
+			assert.Equal(test.Address, record.Address)
+		})
+	}
+}
+
+func TestMemory_GetRecordWildcardAfterRemove(t *testing.T) {
+	assert := assert.New(t)
+	mem := store.NewMemory()
+
+	_, err := mem.CreateRecord("*.preview.svc.internal.", "10.0.0.1", 60)
+	assert.NoError(err)
+	_, err = mem.CreateRecord("db.team.preview.svc.internal.", "10.0.0.3", 60)
+	assert.NoError(err)
+
+	_, err = mem.GetRecord("web.team.preview.svc.internal.")
+	assert.Error(err, "empty non-terminal should block the wildcard")
+
+	_, err = mem.RemoveRecord("db.team.preview.svc.internal.")
+	assert.NoError(err)
+
+	record, err := mem.GetRecord("web.team.preview.svc.internal.")
+	assert.NoError(err, "wildcard should match once the empty non-terminal is gone")
+	assert.Equal("10.0.0.1", record.Address)
+}
+
+func TestMemory_UpdateRecord(t *testing.T) {
+	existing := vinyl.Record{
+		Domain:  "web.lab.",
+		Address: "10.0.0.1",
+		TTL:     60,
+		Owner:   "file:/etc/vinyl",
+	}
+
+	tests := map[string]struct {
+		Address string
+		Owner   string
+		Err     error
+	}{
+		"replaces a record with the same owner": {
+			Address: "10.0.0.2",
+			Owner:   "file:/etc/vinyl",
+		},
+		"returns OwnerConflictError for another owner": {
+			Address: "10.0.0.2",
+			Owner:   "docker",
+			Err: &store.OwnerConflictError{
+				Domain: existing.Domain,
+				Owner:  existing.Owner,
+			},
+		},
+		"returns OwnerConflictError for api updates": {
+			Address: "10.0.0.2",
+			Err: &store.OwnerConflictError{
+				Domain: existing.Domain,
+				Owner:  existing.Owner,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			mem := store.NewMemory(existing)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events := mem.Watch(ctx)
+
+			_, err := mem.UpdateRecord(existing.Domain, test.Address, 30, vinyl.WithOwner(test.Owner))
+			if test.Err != nil {
+				assert.ErrorContains(err, test.Err.Error())
+				assert.Equal(existing, mem.Records[existing.Domain])
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(test.Address, mem.Records[existing.Domain].Address)
+			assert.Equal(vinyl.RecordUpdated, (<-events).Type)
+
+			_, err = mem.GetRecordsByAddress(existing.Address)
+			assert.Error(err, "the old address should be unindexed")
+			records, err := mem.GetRecordsByAddress(test.Address)
+			assert.NoError(err)
+			assert.Len(records, 1)
+		})
+	}
+}
+
+func TestMemory_RemoveOwnedRecord(t *testing.T) {
+	assert := assert.New(t)
+	mem := store.NewMemory(
+		vinyl.Record{Domain: "api.lab.", Address: "10.0.0.1", TTL: 60},
+		vinyl.Record{Domain: "web.lab.", Address: "10.0.0.2", TTL: 60, Owner: "docker"},
+	)
+
+	_, err := mem.RemoveOwnedRecord("api.lab.", "docker")
+	assert.ErrorContains(err, (&store.OwnerConflictError{Domain: "api.lab."}).Error(), "api records should never be removed by a source")
+
+	_, err = mem.RemoveOwnedRecord("web.lab.", "kubernetes")
+	assert.ErrorContains(err, (&store.OwnerConflictError{Domain: "web.lab.", Owner: "docker"}).Error())
+
+	_, err = mem.RemoveOwnedRecord("web.lab.", "docker")
+	assert.NoError(err)
+
+	records, _, err := mem.ListRecords(vinyl.RecordFilter{Owner: "docker"}, vinyl.Page{})
+	assert.NoError(err)
+	assert.Empty(records)
+	assert.Len(mem.Records, 1)
+}
+
+func TestMemory_Replace(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mem := store.NewMemory(
+		vinyl.Record{Domain: "kept.com", Address: "127.0.0.1", TTL: 60},
+		vinyl.Record{Domain: "changed.com", Address: "127.0.0.2", TTL: 60},
+		vinyl.Record{Domain: "removed.com", Address: "127.0.0.3", TTL: 60},
+	)
+	events := mem.Watch(ctx)
+
+	changed := vinyl.Record{Domain: "changed.com", Address: "127.0.0.4", TTL: 60}
+	created := vinyl.Record{Domain: "created.com", Address: "127.0.0.5", TTL: 60}
+	mem.Replace(vinyl.Record{Domain: "kept.com", Address: "127.0.0.1", TTL: 60}, changed, created)
+
+	assert.Equal(vinyl.RecordEvent{Type: vinyl.RecordRemoved, Record: vinyl.Record{Domain: "removed.com", Address: "127.0.0.3", TTL: 60}}, <-events)
+	assert.Equal(vinyl.RecordEvent{Type: vinyl.RecordUpdated, Record: changed}, <-events)
+	assert.Equal(vinyl.RecordEvent{Type: vinyl.RecordCreated, Record: created}, <-events)
+	assert.Len(events, 0, "unchanged records should not publish events")
+
+	records, err := mem.GetRecordsByAddress("127.0.0.4")
+	assert.NoError(err)
+	assert.Equal([]vinyl.Record{changed}, records)
+
+	_, err = mem.GetRecordsByAddress("127.0.0.2")
+	assert.Error(err, "the replaced address should no longer be indexed")
+}
+
+func TestMemory_PutRecord(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mem := store.NewMemory(vinyl.Record{Domain: "test.com", Address: "127.0.0.1", TTL: 60, Owner: "docker"})
+	events := mem.Watch(ctx)
+
+	replaced := vinyl.Record{Domain: "test.com", Address: "127.0.0.2", TTL: 60}
+	record, err := mem.PutRecord(replaced)
+	assert.NoError(err)
+	assert.Equal(&replaced, record, "records should be replaced whatever their owner")
+
+	created := vinyl.Record{Domain: "new.com", Address: "127.0.0.3", TTL: 60}
+	_, err = mem.PutRecord(created)
+	assert.NoError(err)
+
+	assert.Equal(vinyl.RecordEvent{Type: vinyl.RecordUpdated, Record: replaced}, <-events)
+	assert.Equal(vinyl.RecordEvent{Type: vinyl.RecordCreated, Record: created}, <-events)
+
+	_, err = mem.GetRecordsByAddress("127.0.0.1")
+	assert.Error(err, "the replaced address should no longer be indexed")
+
+	_, err = mem.PutRecord(vinyl.Record{Domain: "bad.com", Address: "bad", TTL: 60})
+	var invalid *vinyl.InvalidRecordAddressError
+	assert.True(errors.As(err, &invalid))
+}
@@ -0,0 +1,31 @@
+package zone
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnrepresentableRecordError is a zone file entry that can't be stored as a vinyl record
+type UnrepresentableRecordError struct {
+	Line   int
+	Name   string
+	Type   string
+	Reason string
+}
+
+func (e *UnrepresentableRecordError) Error() string {
+	return fmt.Sprintf("line %v: %s can't be imported: %s", e.Line, strings.TrimSpace(e.Name+" "+e.Type), e.Reason)
+}
+
+type InvalidZoneError struct {
+	Origin string
+	Err    error
+}
+
+func (e *InvalidZoneError) Error() string {
+	return fmt.Sprintf("zone %s is not a valid master file: %s", e.Origin, e.Err)
+}
+
+func (e *InvalidZoneError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,222 @@
+// Package zone reads and writes RFC 1035 master files as vinyl records
+package zone
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+	vinyl "github.com/platform-edn/vinyl/internal"
+)
+
+// Record is a record read from a zone file along with the line it starts on
+type Record struct {
+	vinyl.Record
+	Line int
+}
+
+// Parse reads an RFC 1035 master file relative to origin. Only the first A or AAAA record of each name inside the
+// zone can be stored, every other entry is returned as an UnrepresentableRecordError holding its line
+func Parse(r io.Reader, origin string) ([]Record, []*UnrepresentableRecordError, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Parse: %w", err)
+	}
+
+	origin = dns.Fqdn(origin)
+	skipped := []*UnrepresentableRecordError{}
+
+	// $GENERATE expands to records that can't be traced back to a line so it is blanked out instead of parsed
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && strings.EqualFold(fields[0], "$GENERATE") {
+			skipped = append(skipped, &UnrepresentableRecordError{
+				Line:   i + 1,
+				Name:   fields[0],
+				Reason: "$GENERATE is not supported",
+			})
+			lines[i] = ""
+		}
+	}
+	data = []byte(strings.Join(lines, "\n"))
+
+	starts := recordLines(data)
+	parser := dns.NewZoneParser(bytes.NewReader(data), origin, "")
+
+	records := []Record{}
+	seen := map[string]int{}
+	i := 0
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		line := 0
+		if i < len(starts) {
+			line = starts[i]
+		}
+		i++
+
+		header := rr.Header()
+		name := strings.ToLower(header.Name)
+
+		if !dns.IsSubDomain(origin, name) {
+			skipped = append(skipped, &UnrepresentableRecordError{
+				Line:   line,
+				Name:   name,
+				Type:   dns.TypeToString[header.Rrtype],
+				Reason: fmt.Sprintf("outside of %s", origin),
+			})
+			continue
+		}
+
+		var address string
+		switch record := rr.(type) {
+		case *dns.A:
+			address = record.A.String()
+		case *dns.AAAA:
+			address = record.AAAA.String()
+		default:
+			skipped = append(skipped, &UnrepresentableRecordError{
+				Line:   line,
+				Name:   name,
+				Type:   dns.TypeToString[header.Rrtype],
+				Reason: "only A and AAAA records can be stored",
+			})
+			continue
+		}
+
+		// the store holds a single address per name so only the first one defined is kept
+		if first, exist := seen[name]; exist {
+			skipped = append(skipped, &UnrepresentableRecordError{
+				Line:   line,
+				Name:   name,
+				Type:   dns.TypeToString[header.Rrtype],
+				Reason: fmt.Sprintf("already defined on line %v", first),
+			})
+			continue
+		}
+		seen[name] = line
+
+		records = append(records, Record{
+			Record: vinyl.Record{
+				Domain:  name,
+				Address: address,
+				TTL:     header.Ttl,
+			},
+			Line: line,
+		})
+	}
+
+	err = parser.Err()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Parse: %w", &InvalidZoneError{
+			Origin: origin,
+			Err:    err,
+		})
+	}
+
+	sort.SliceStable(skipped, func(i, j int) bool {
+		return skipped[i].Line < skipped[j].Line
+	})
+
+	return records, skipped, nil
+}
+
+// Write writes records as an RFC 1035 master file with names relative to origin
+func Write(w io.Writer, origin string, records ...vinyl.Record) error {
+	origin = dns.Fqdn(strings.ToLower(origin))
+
+	_, err := fmt.Fprintf(w, "$ORIGIN %s\n", origin)
+	if err != nil {
+		return fmt.Errorf("Write: %w", err)
+	}
+
+	for _, record := range records {
+		_, err := fmt.Fprintf(w, "%s\t%v\tIN\t%s\t%s\n", relativeName(record.Domain, origin), record.TTL, record.Type(), record.Address)
+		if err != nil {
+			return fmt.Errorf("Write: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func relativeName(domain string, origin string) string {
+	domain = dns.Fqdn(strings.ToLower(domain))
+
+	switch {
+	case domain == origin:
+		return "@"
+	case origin != "." && strings.HasSuffix(domain, "."+origin):
+		return strings.TrimSuffix(domain, "."+origin)
+	}
+
+	return domain
+}
+
+// recordLines returns the line every resource record of a master file starts on, in the order the zone parser
+// returns them. Directives, comments and the continuation lines of parenthesized records are skipped
+func recordLines(data []byte) []int {
+	starts := []int{}
+	line := 1
+	depth := 0
+	lineStart := true
+	comment, quoted, escaped := false, false, false
+
+	for _, c := range data {
+		if c == '\n' {
+			line++
+			comment = false
+			if depth == 0 {
+				lineStart = true
+			}
+
+			continue
+		}
+
+		switch {
+		case comment:
+			continue
+		case escaped:
+			escaped = false
+			continue
+		case quoted:
+			switch c {
+			case '\\':
+				escaped = true
+			case '"':
+				quoted = false
+			}
+
+			continue
+		}
+
+		switch c {
+		case ' ', '\t', '\r':
+			continue
+		case ';':
+			comment = true
+			continue
+		case '(':
+			depth++
+			continue
+		case ')':
+			depth--
+			continue
+		case '"':
+			quoted = true
+		case '\\':
+			escaped = true
+		}
+
+		if lineStart {
+			lineStart = false
+			if c != '$' {
+				starts = append(starts, line)
+			}
+		}
+	}
+
+	return starts
+}
@@ -0,0 +1,98 @@
+package zone_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	vinyl "github.com/platform-edn/vinyl/internal"
+	"github.com/platform-edn/vinyl/internal/zone"
+	"github.com/stretchr/testify/assert"
+)
+
+const testZone = `$ORIGIN lab.example.
+$TTL 300
+@	IN SOA ns1 hostmaster (
+		2024010101 ; serial
+		3600 900 604800 300 )
+	IN NS ns1
+ns1	IN A 10.0.0.1
+; a comment on its own line
+web	60 IN A 10.0.0.2 ; trailing comment
+	IN AAAA fd00::2
+mail	IN MX 10 web
+*.preview	IN A 10.0.0.3
+other.example.	IN A 10.0.0.4
+$GENERATE 1-3 host$ A 10.0.1.$
+txt	IN TXT "a ; quoted (string"
+db	IN A 10.0.0.5
+`
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	records, skipped, err := zone.Parse(strings.NewReader(testZone), "lab.example")
+	assert.NoError(err)
+
+	assert.Equal([]zone.Record{
+		{Record: vinyl.Record{Domain: "ns1.lab.example.", Address: "10.0.0.1", TTL: 300}, Line: 7},
+		{Record: vinyl.Record{Domain: "web.lab.example.", Address: "10.0.0.2", TTL: 60}, Line: 9},
+		{Record: vinyl.Record{Domain: "*.preview.lab.example.", Address: "10.0.0.3", TTL: 300}, Line: 12},
+		{Record: vinyl.Record{Domain: "db.lab.example.", Address: "10.0.0.5", TTL: 300}, Line: 16},
+	}, records)
+
+	lines := map[int]string{}
+	for _, s := range skipped {
+		lines[s.Line] = s.Type
+	}
+	assert.Equal(map[int]string{3: "SOA", 6: "NS", 10: "AAAA", 11: "MX", 13: "A", 14: "", 15: "TXT"}, lines)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]struct {
+		Zone string
+	}{
+		"returns InvalidZoneError for bad syntax": {
+			Zone: "web IN A not-an-ip\n",
+		},
+		"returns InvalidZoneError for includes": {
+			Zone: "$INCLUDE /etc/passwd\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			_, _, err := zone.Parse(strings.NewReader(test.Zone), "lab.example.")
+
+			var zoneErr *zone.InvalidZoneError
+			assert.ErrorAs(err, &zoneErr)
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	assert := assert.New(t)
+	records := []vinyl.Record{
+		{Domain: "lab.example.", Address: "10.0.0.1", TTL: 60},
+		{Domain: "*.preview.lab.example.", Address: "10.0.0.3", TTL: 60},
+		{Domain: "web.lab.example.", Address: "fd00::2", TTL: 300},
+	}
+
+	out := &bytes.Buffer{}
+	err := zone.Write(out, "lab.example", records...)
+	assert.NoError(err)
+
+	assert.Equal("$ORIGIN lab.example.\n"+
+		"@\t60\tIN\tA\t10.0.0.1\n"+
+		"*.preview\t60\tIN\tA\t10.0.0.3\n"+
+		"web\t300\tIN\tAAAA\tfd00::2\n", out.String())
+
+	parsed, skipped, err := zone.Parse(out, "lab.example.")
+	assert.NoError(err)
+	assert.Empty(skipped)
+	for i, record := range parsed {
+		assert.Equal(records[i], record.Record, "exported zones should import again")
+	}
+}
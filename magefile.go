@@ -68,12 +68,42 @@ func Proto() error {
 			if err != nil {
 				return fmt.Errorf("could not create go proto files: %s", err)
 			}
+
+			err = gateway(protopath, file.Name())
+			if err != nil {
+				return fmt.Errorf("could not create gateway files: %s", err)
+			}
 		}
 	}
 
 	return nil
 }
 
+// generates the http gateway and openapi description for a proto file if it has an http rule config next to it
+func gateway(protopath string, protofile string) error {
+	config := filepath.Join(protopath, strings.TrimSuffix(protofile, ".proto")+".yaml")
+
+	_, err := os.Stat(config)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = sh.Run("protoc", "--proto_path="+protopath, "--grpc-gateway_out=.", "--grpc-gateway_opt=grpc_api_configuration="+config, protofile)
+	if err != nil {
+		return err
+	}
+
+	err = sh.Run("protoc", "--proto_path="+protopath, "--openapiv2_out="+protopath, "--openapiv2_opt=grpc_api_configuration="+config, protofile)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 //runs race tests
 func Race() error {
 	coverage := "coverage.out"